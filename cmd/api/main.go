@@ -8,6 +8,7 @@ import (
 	"cyber-range/internal/infra/docker"
 	"cyber-range/internal/infra/logstore"
 	"cyber-range/internal/infra/redis"
+	"cyber-range/internal/infra/storage"
 	"cyber-range/internal/model"
 	"cyber-range/internal/service"
 	"cyber-range/pkg/config"
@@ -58,12 +59,30 @@ func main() {
 
 	// 5. Initialize Docker Host Manager
 	dockerManager := docker.NewDockerHostManager()
+	dockerManager.SetEgressHelperImage(cfg.Docker.EgressHelperImage)
 	logger.Info(ctx, "Docker Host Manager initialized")
 
 	// 6. Initialize Repository
 	repository := db.NewRepository(gormDB)
 	logger.Info(ctx, "Repository initialized")
 
+	// 6.1 端口租约（Redis 共享），按各主机运行中的容器重建租约状态
+	dockerManager.SetPortLeaser(redis.PortLeaser{})
+	if hosts, err := repository.ListDockerHosts(ctx, true); err != nil {
+		logger.Warn(ctx, "Failed to list Docker hosts for port lease sync", "error", err)
+	} else {
+		for _, host := range hosts {
+			syncCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			leased, err := dockerManager.SyncPortLeases(syncCtx, host)
+			cancel()
+			if err != nil {
+				logger.Warn(ctx, "Failed to sync port leases", "docker_host", host.Name, "error", err)
+				continue
+			}
+			logger.Info(ctx, "Port leases synced", "docker_host", host.Name, "leased_ports", leased)
+		}
+	}
+
 	// 7. Auto-migrate APILog table
 	if err := gormDB.AutoMigrate(&model.APILog{}); err != nil {
 		logger.Warn(ctx, "Failed to auto-migrate APILog table", "error", err)
@@ -79,8 +98,21 @@ func main() {
 	// 9. Initialize Services
 	challengeSvc := service.NewChallengeService(dockerManager, repository, gormDB, cfg)
 	adminSvc := service.NewAdminService(gormDB)
+	userSvc := service.NewUserService(gormDB)
+	teamSvc := service.NewTeamService(gormDB, cfg)
+	eventSvc := service.NewEventService(gormDB, cfg)
+	scoreboardSvc := service.NewScoreboardService(gormDB, cfg)
+	submitLimiter := service.NewSubmitLimiter(gormDB, cfg)
+	hintSvc := service.NewHintService(gormDB, cfg)
 	imageSvc := service.NewImageService(repository, dockerManager)
 
+	attachmentStore, err := storage.New(cfg.Storage)
+	if err != nil {
+		logger.Error(ctx, "Failed to initialize attachment storage", "error", err)
+		panic(err)
+	}
+	attachmentSvc := service.NewAttachmentService(gormDB, cfg, attachmentStore)
+
 	// 11. 启动时自动同步 Registry 并预加载镜像
 	go func() {
 		time.Sleep(3 * time.Second) // 等待服务启动
@@ -109,13 +141,48 @@ func main() {
 	reaper.Start(ctx)
 	defer reaper.Stop()
 
+	// 12.0 实例主机调度器：定期探测各主机健康状态与资源
+	// 按数据库中运行中的实例重建主机容量预留（进程重启期间释放的预留不会残留）
+	scheduler := challengeSvc.Scheduler()
+	if err := scheduler.SyncReservations(ctx); err != nil {
+		logger.Warn(ctx, "Failed to sync host capacity reservations", "error", err)
+	}
+	scheduler.Start(ctx)
+
+	// 12.1 HTTP 实例网关（Web 题目按子域名或路径访问，宿主机端口无需对外开放）
+	var httpGateway *service.HTTPGateway
+	if cfg.Gateway.HTTP.Enabled {
+		httpGateway = service.NewHTTPGateway(cfg.Gateway.HTTP, repository)
+		httpGateway.Start(ctx)
+	}
+
+	// 12.2 TCP/TLS 实例网关（nc 类题目共用一个公网端口）
+	var tcpGateway *service.TCPGateway
+	if cfg.Gateway.TCP.Enabled {
+		tcpGateway, err = service.NewTCPGateway(cfg.Gateway.TCP, repository)
+		if err == nil {
+			err = tcpGateway.Start(ctx)
+		}
+		if err != nil {
+			logger.Error(ctx, "Failed to start TCP gateway", "error", err)
+			tcpGateway = nil
+		}
+	}
+
 	// 10. Initialize Handlers
-	challengeHandler := handlers.NewChallengeHandler(challengeSvc)
-	adminHandler := handlers.NewAdminHandler(adminSvc, challengeSvc, gormDB)
-	dockerHostHandler := handlers.NewDockerHostHandler(repository, dockerManager)
+	challengeHandler := handlers.NewChallengeHandler(challengeSvc, submitLimiter)
+	userHandler := handlers.NewUserHandler(userSvc, scoreboardSvc)
+	teamHandler := handlers.NewTeamHandler(teamSvc, scoreboardSvc)
+	eventHandler := handlers.NewEventHandler(eventSvc)
+	scoreboardHandler := handlers.NewScoreboardHandler(scoreboardSvc)
+	submitLimitHandler := handlers.NewSubmitLimitHandler(submitLimiter)
+	adminHandler := handlers.NewAdminHandler(adminSvc, challengeSvc, attachmentSvc, gormDB)
+	dockerHostHandler := handlers.NewDockerHostHandler(repository, dockerManager, scheduler)
 	imageHandler := handlers.NewImageHandler(imageSvc)
 	instanceHandler := handlers.NewInstanceHandler(repository, dockerManager)
 	logHandler := handlers.NewLogHandler(logStore)
+	attachmentHandler := handlers.NewAttachmentHandler(attachmentSvc)
+	hintHandler := handlers.NewHintHandler(hintSvc)
 
 	// 10. Setup Router
 	gin.SetMode(gin.ReleaseMode)
@@ -137,8 +204,8 @@ func main() {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "X-Trace-ID", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length", "X-Trace-ID"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "X-Trace-ID", "Authorization", "Idempotency-Key"},
+		ExposeHeaders:    []string{"Content-Length", "X-Trace-ID", "Retry-After", "X-Checksum-SHA256"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -149,10 +216,47 @@ func main() {
 	// API Routes (User)
 	api := r.Group("/api")
 	{
-		api.GET("/challenges", challengeHandler.List)
-		api.POST("/challenges/:id/start", challengeHandler.Start)
-		api.POST("/challenges/:id/stop", challengeHandler.Stop)
-		api.POST("/submit", challengeHandler.Verify)
+		// Public routes
+		api.POST("/register", userHandler.Register)
+		api.POST("/login", userHandler.Login)
+		api.GET("/scoreboard", scoreboardHandler.Scoreboard)
+		api.GET("/scoreboard/progression", scoreboardHandler.Progression)
+		api.GET("/attachments/:id/download", attachmentHandler.Download)
+
+		// Protected routes (require user auth)
+		player := api.Group("")
+		player.Use(middleware.UserAuth())
+		{
+			player.GET("/me", userHandler.Me)
+			player.GET("/challenges", challengeHandler.List)
+			player.POST("/challenges/:id/start", challengeHandler.Start)
+			player.GET("/instance-jobs/:id", challengeHandler.Job)
+			player.GET("/instance-jobs/:id/events", challengeHandler.JobEvents)
+			player.POST("/challenges/:id/stop", challengeHandler.Stop)
+			player.POST("/challenges/:id/extend", challengeHandler.Extend)
+			player.POST("/challenges/:id/reset", challengeHandler.Reset)
+			player.GET("/instances", challengeHandler.Instances)
+			player.POST("/instances/:id/gateway", challengeHandler.GatewayAccess)
+			player.GET("/challenges/:id/solves", challengeHandler.Solves)
+			player.GET("/challenges/:id/attachments", attachmentHandler.List)
+			player.GET("/challenges/:id/hints", hintHandler.List)
+			player.POST("/hints/:id/unlock", hintHandler.Unlock)
+			player.POST("/submit", challengeHandler.Verify)
+
+			// 队伍（团队模式）
+			player.POST("/teams", teamHandler.Create)
+			player.POST("/teams/join", teamHandler.Join)
+			player.POST("/teams/leave", teamHandler.Leave)
+			player.POST("/teams/transfer", teamHandler.TransferCaptain)
+			player.GET("/teams/me", teamHandler.Mine)
+			player.GET("/teams/scoreboard", teamHandler.Scoreboard)
+
+			// 赛事
+			player.GET("/events", eventHandler.List)
+			player.POST("/events/:id/register", eventHandler.Register)
+			player.GET("/events/:id/scoreboard", scoreboardHandler.EventScoreboard)
+			player.GET("/events/:id/scoreboard/progression", scoreboardHandler.EventProgression)
+		}
 	}
 
 	// Admin API Routes
@@ -172,6 +276,28 @@ func main() {
 			protected.PUT("/challenges/:id", adminHandler.UpdateChallenge)
 			protected.DELETE("/challenges/:id", adminHandler.DeleteChallenge)
 			protected.PUT("/challenges/:id/status", adminHandler.UpdateChallengeStatus)
+			protected.GET("/challenges/:id/attachments", attachmentHandler.AdminList)
+			protected.POST("/challenges/:id/attachments", attachmentHandler.AdminUpload)
+			protected.DELETE("/attachments/:id", attachmentHandler.AdminDelete)
+
+			// 提示管理
+			protected.GET("/challenges/:id/hints", hintHandler.AdminList)
+			protected.POST("/challenges/:id/hints", hintHandler.AdminCreate)
+			protected.PUT("/hints/:id", hintHandler.AdminUpdate)
+			protected.DELETE("/hints/:id", hintHandler.AdminDelete)
+			protected.GET("/hint-unlocks", hintHandler.AdminUnlocks)
+
+			// 赛事管理
+			protected.GET("/events", eventHandler.AdminList)
+			protected.GET("/events/:id", eventHandler.AdminGet)
+			protected.POST("/events", eventHandler.AdminCreate)
+			protected.PUT("/events/:id", eventHandler.AdminUpdate)
+			protected.DELETE("/events/:id", eventHandler.AdminDelete)
+			protected.PUT("/events/:id/challenges", eventHandler.AdminSetChallenges)
+
+			// 积分榜（不受封榜限制）
+			protected.GET("/scoreboard", scoreboardHandler.AdminScoreboard)
+			protected.GET("/scoreboard/progression", scoreboardHandler.AdminProgression)
 
 			// 实例管理
 			protected.GET("/instances", adminHandler.ListInstances)
@@ -181,6 +307,10 @@ func main() {
 			// 提交记录
 			protected.GET("/submissions", adminHandler.ListSubmissions)
 
+			// 提交限流
+			protected.GET("/throttled", submitLimitHandler.ListThrottled)
+			protected.DELETE("/throttled", submitLimitHandler.ClearThrottle)
+
 			// 总览统计
 			protected.GET("/overview/stats", adminHandler.GetOverviewStats)
 
@@ -191,6 +321,7 @@ func main() {
 			protected.DELETE("/docker-hosts/:id", dockerHostHandler.DeleteDockerHost)
 			protected.POST("/docker-hosts/:id/test", dockerHostHandler.TestDockerHost)
 			protected.POST("/docker-hosts/:id/toggle", dockerHostHandler.ToggleDockerHost)
+			protected.GET("/docker-hosts/ports", dockerHostHandler.PortUsage)
 
 			// Docker 镜像管理
 			protected.GET("/images", imageHandler.List)
@@ -224,6 +355,13 @@ func main() {
 
 	logger.Info(ctx, "Shutting down server gracefully...")
 	reaper.Stop()
+	scheduler.Stop()
+	if httpGateway != nil {
+		httpGateway.Stop()
+	}
+	if tcpGateway != nil {
+		tcpGateway.Stop()
+	}
 	logCleaner.Stop()
 	logStore.Shutdown()
 	logger.Info(ctx, "Server exited")
@@ -36,6 +36,15 @@ func main() {
 	db.Exec("DROP TABLE IF EXISTS docker_hosts")
 	db.Exec("DROP TABLE IF EXISTS users")
 	db.Exec("DROP TABLE IF EXISTS admins")
+	db.Exec("DROP TABLE IF EXISTS teams")
+	db.Exec("DROP TABLE IF EXISTS event_participants")
+	db.Exec("DROP TABLE IF EXISTS event_challenges")
+	db.Exec("DROP TABLE IF EXISTS events")
+	db.Exec("DROP TABLE IF EXISTS attachments")
+	db.Exec("DROP TABLE IF EXISTS hint_unlocks")
+	db.Exec("DROP TABLE IF EXISTS hints")
+	db.Exec("DROP TABLE IF EXISTS challenge_prerequisites")
+	db.Exec("DROP TABLE IF EXISTS challenge_services")
 	fmt.Println("✓ 旧表已删除")
 
 	// 重新创建表（带中文注释）
@@ -48,6 +57,15 @@ func main() {
 		&model.User{},
 		&model.Submission{},
 		&model.Admin{},
+		&model.Team{},
+		&model.Event{},
+		&model.EventChallenge{},
+		&model.EventParticipant{},
+		&model.Attachment{},
+		&model.Hint{},
+		&model.HintUnlock{},
+		&model.ChallengePrerequisite{},
+		&model.ServiceDefinition{},
 	); err != nil {
 		log.Fatalf("表创建失败: %v", err)
 	}
@@ -58,7 +76,7 @@ func main() {
 	fmt.Println("📊 验证表结构")
 	fmt.Println(repeat("=", 70))
 
-	tables := []string{"docker_hosts", "challenges", "instances", "users", "submissions", "admins"}
+	tables := []string{"docker_hosts", "challenges", "instances", "users", "submissions", "admins", "teams", "events", "event_challenges", "event_participants", "attachments", "hints", "hint_unlocks", "challenge_prerequisites", "challenge_services"}
 	for _, table := range tables {
 		var createSQL string
 		db.Raw(fmt.Sprintf("SHOW CREATE TABLE %s", table)).Scan(&createSQL)
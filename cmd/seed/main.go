@@ -36,6 +36,15 @@ func main() {
 	db.Exec("DELETE FROM docker_hosts")
 	db.Exec("DELETE FROM users")
 	db.Exec("DELETE FROM admins")
+	db.Exec("DELETE FROM teams")
+	db.Exec("DELETE FROM event_participants")
+	db.Exec("DELETE FROM event_challenges")
+	db.Exec("DELETE FROM events")
+	db.Exec("DELETE FROM attachments")
+	db.Exec("DELETE FROM hint_unlocks")
+	db.Exec("DELETE FROM hints")
+	db.Exec("DELETE FROM challenge_prerequisites")
+	db.Exec("DELETE FROM challenge_services")
 	fmt.Println("✓ 旧数据已清除")
 
 	// 4. 插入管理员
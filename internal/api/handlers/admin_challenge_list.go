@@ -31,7 +31,9 @@ type ListChallengesResponse struct {
 // AdminChallengeView 包含 Flag 的完整题目视图
 type AdminChallengeView struct {
 	model.Challenge
-	Flag string `json:"flag"`
+	Flag          string                    `json:"flag"`
+	Prerequisites []string                  `json:"prerequisites,omitempty"` // 前置题目ID（仅详情返回）
+	Services      []model.ServiceDefinition `json:"services,omitempty"`      // 多容器题目的服务定义（仅详情返回）
 }
 
 // ListChallenges 获取题目列表（管理员）
@@ -171,13 +173,27 @@ func (h *AdminHandler) GetChallenge(c *gin.Context) {
 		return
 	}
 
+	prerequisites, err := h.challengeSvc.GetPrerequisites(c.Request.Context(), challengeID)
+	if err != nil {
+		logger.Error(c.Request.Context(), "Failed to get challenge prerequisites", "error", err)
+	}
+
+	var services []model.ServiceDefinition
+	if challenge.IsCompose() {
+		if services, err = h.challengeSvc.GetServices(c.Request.Context(), challengeID); err != nil {
+			logger.Error(c.Request.Context(), "Failed to get challenge services", "error", err)
+		}
+	}
+
 	// 返回完整视图
 	c.PureJSON(http.StatusOK, APIResponse{
 		Code: 200,
 		Msg:  "success",
 		Data: AdminChallengeView{
-			Challenge: challenge,
-			Flag:      challenge.Flag,
+			Challenge:     challenge,
+			Flag:          challenge.Flag,
+			Prerequisites: prerequisites,
+			Services:      services,
 		},
 	})
 }
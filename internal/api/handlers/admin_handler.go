@@ -16,17 +16,19 @@ import (
 
 // AdminHandler 管理员处理器
 type AdminHandler struct {
-	adminSvc     *service.AdminService
-	challengeSvc *service.ChallengeService
-	db           interface{} // 用于直接查询实例和提交记录
+	adminSvc      *service.AdminService
+	challengeSvc  *service.ChallengeService
+	attachmentSvc *service.AttachmentService
+	db            interface{} // 用于直接查询实例和提交记录
 }
 
 // NewAdminHandler 创建管理员处理器
-func NewAdminHandler(adminSvc *service.AdminService, challengeSvc *service.ChallengeService, db interface{}) *AdminHandler {
+func NewAdminHandler(adminSvc *service.AdminService, challengeSvc *service.ChallengeService, attachmentSvc *service.AttachmentService, db interface{}) *AdminHandler {
 	return &AdminHandler{
-		adminSvc:     adminSvc,
-		challengeSvc: challengeSvc,
-		db:           db,
+		adminSvc:      adminSvc,
+		challengeSvc:  challengeSvc,
+		attachmentSvc: attachmentSvc,
+		db:            db,
 	}
 }
 
@@ -74,21 +76,131 @@ func (h *AdminHandler) Login(c *gin.Context) {
 
 // CreateChallengeRequest 创建题目请求
 type CreateChallengeRequest struct {
-	Title           string  `json:"title" binding:"required"`
-	DescriptionHtml string  `json:"descriptionHtml"` // 富文本 HTML (允许为空)
-	HintHtml        string  `json:"hintHtml"`        // 提示 HTML
-	Category        string  `json:"category" binding:"required,oneof=Web Pwn Crypto Reverse Misc web pwn crypto reverse misc"`
-	Difficulty      string  `json:"difficulty" binding:"required,oneof=Easy Medium Hard easy medium hard"`
-	Image           string  `json:"image"`          // 兼容旧字段，逻辑校验
-	ImageID         string  `json:"image_id"`       // 关联镜像ID
-	DockerHostID    string  `json:"docker_host_id"` // Docker主机ID
-	Port            int     `json:"port" binding:"required"`
-	MemoryLimit     int64   `json:"memory_limit"` // 内存限制
-	CPULimit        float64 `json:"cpu_limit"`    // CPU限制
-	Privileged      bool    `json:"privileged"`   // 特权模式
-	Flag            string  `json:"flag"`         // 逻辑校验 (Create 必填, Update 选填)
-	Points          int     `json:"points" binding:"required"`
-	Status          string  `json:"status"` // published/unpublished
+	Title           string                `json:"title" binding:"required"`
+	DescriptionHtml string                `json:"descriptionHtml"` // 富文本 HTML (允许为空)
+	HintHtml        string                `json:"hintHtml"`        // 提示 HTML
+	Category        string                `json:"category" binding:"required,oneof=Web Pwn Crypto Reverse Misc web pwn crypto reverse misc"`
+	Difficulty      string                `json:"difficulty" binding:"required,oneof=Easy Medium Hard easy medium hard"`
+	Kind            string                `json:"kind"`           // container/compose/static，默认 container
+	Image           string                `json:"image"`          // 兼容旧字段，逻辑校验
+	ImageID         string                `json:"image_id"`       // 关联镜像ID
+	DockerHostID    string                `json:"docker_host_id"` // Docker主机ID（指定后实例固定在该主机，为空由调度器选择）
+	HostTags        string                `json:"host_tags"`      // 调度约束：实例主机须具备的标签（逗号分隔）
+	Port            int                   `json:"port"`
+	Ports           []model.ChallengePort `json:"ports"`            // 暴露给玩家的端口列表（标签/容器端口/协议），为空时暴露 port
+	MemoryLimit     int64                 `json:"memory_limit"`     // 内存限制
+	CPULimit        float64               `json:"cpu_limit"`        // CPU限制
+	Privileged      bool                  `json:"privileged"`       // 特权模式
+	Egress          string                `json:"egress"`           // 出站网络策略 none/internet/allowlist，默认 internet
+	EgressAllowlist string                `json:"egress_allowlist"` // allowlist 策略的白名单，每行一个 IP/CIDR/域名
+	MaxLifetime     int                   `json:"max_lifetime"`     // 实例最长存活时间（分钟），0 表示使用全局配置
+	MaxExtensions   int                   `json:"max_extensions"`   // 实例最多延长次数，0 表示使用全局配置，-1 表示不允许延长
+	Flag            string                `json:"flag"`             // 逻辑校验 (Create 必填, Update 选填)
+	FlagType        string                `json:"flag_type"`        // dynamic/static/static_ci/regex/multiple，默认 dynamic
+	Points          int                   `json:"points" binding:"required"`
+	Status          string                `json:"status"` // published/unpublished
+
+	// 多容器题目（kind=compose）的服务定义，按顺序启动（更新时不传表示保持不变）
+	Services []model.ServiceDefinition `json:"services"`
+
+	// 前置题目：解出全部前置题目后才解锁（更新时不传表示保持不变，传空数组表示清空）
+	Prerequisites []string `json:"prerequisites"`
+	HideLocked    bool     `json:"hide_locked"` // 未解锁时对玩家隐藏（否则显示为锁定）
+
+	// 动态计分参数（scoring_mode=dynamic 时未传 initial_points 则以 points 为初始分值，更新时沿用原初始分值）
+	ScoringMode   string `json:"scoring_mode"`   // static/dynamic，默认 static
+	InitialPoints int    `json:"initial_points"` // 初始分值
+	MinimumPoints int    `json:"minimum_points"` // 最低分值
+	Decay         int    `json:"decay"`          // 衰减参数
+	DecayFunction string `json:"decay_function"` // linear/logarithmic，默认 linear
+}
+
+// validateScoring 校验并补全计分参数，返回错误提示（为空表示通过）
+func (req *CreateChallengeRequest) validateScoring() string {
+	if req.ScoringMode == "" {
+		req.ScoringMode = "static"
+	}
+	if req.DecayFunction == "" {
+		req.DecayFunction = "linear"
+	}
+
+	switch req.ScoringMode {
+	case "static":
+		return ""
+	case "dynamic":
+	default:
+		return "计分模式必须是 static 或 dynamic"
+	}
+
+	if req.DecayFunction != "linear" && req.DecayFunction != "logarithmic" {
+		return "衰减函数必须是 linear 或 logarithmic"
+	}
+	if req.InitialPoints < 0 || req.InitialPoints > 10000 {
+		return "初始分值必须在 1-10000 之间"
+	}
+	if req.MinimumPoints < 0 || req.MinimumPoints > req.initialPoints() {
+		return "最低分值必须在 0 与初始分值之间"
+	}
+	if req.Decay < 1 {
+		return "衰减参数必须大于 0"
+	}
+	return ""
+}
+
+// initialPoints 动态计分初始分值，未传 initial_points 时为 points
+func (req *CreateChallengeRequest) initialPoints() int {
+	if req.InitialPoints > 0 {
+		return req.InitialPoints
+	}
+	return req.Points
+}
+
+// validateKind 校验并补全题目类型，返回错误提示（为空表示通过）
+// 容器题需要有效端口，多容器题校验服务定义；无需实例的题目默认使用静态 Flag，且不能使用动态 Flag
+func (req *CreateChallengeRequest) validateKind() string {
+	if req.Kind == "" {
+		req.Kind = "container"
+	}
+
+	switch req.Kind {
+	case "container", "compose":
+		if req.Kind == "container" {
+			if len(req.Ports) > 0 {
+				if err := service.ValidatePorts(req.Ports, map[string]bool{}); err != nil {
+					return err.Error()
+				}
+				req.Port = req.Ports[0].Port
+			}
+			if req.Port < 1 || req.Port > 65535 {
+				return "端口必须在 1-65535 之间"
+			}
+		}
+		if req.Kind == "compose" && req.Services != nil {
+			if err := service.ValidateServices(req.Services); err != nil {
+				return err.Error()
+			}
+		}
+		if req.MaxLifetime < 0 || req.MaxExtensions < -1 {
+			return "实例最长存活时间不能为负数，最多延长次数不能小于 -1"
+		}
+		if req.Egress == "" {
+			req.Egress = model.EgressInternet
+		}
+		privileged := req.Privileged || (req.Kind == "compose" && service.HasPrivilegedService(req.Services))
+		if err := service.ValidateEgress(req.Egress, req.EgressAllowlist, privileged); err != nil {
+			return err.Error()
+		}
+	case "static":
+		if req.FlagType == "" {
+			req.FlagType = service.FlagTypeStatic
+		}
+		if req.FlagType == service.FlagTypeDynamic {
+			return "无需实例的题目不能使用动态 Flag"
+		}
+	default:
+		return "题目类型必须是 container、compose 或 static"
+	}
+	return ""
 }
 
 // CreateChallenge 创建题目
@@ -112,14 +224,29 @@ func (h *AdminHandler) CreateChallenge(c *gin.Context) {
 		return
 	}
 
-	// 校验镜像：Image 和 ImageID 必须有一个
-	if req.Image == "" && req.ImageID == "" {
+	if msg := req.validateKind(); msg != "" {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  msg,
+		})
+		return
+	}
+
+	// 校验镜像：容器题的 Image 和 ImageID 必须有一个，多容器题必须定义服务
+	if req.Kind == "container" && req.Image == "" && req.ImageID == "" {
 		c.PureJSON(http.StatusBadRequest, APIResponse{
 			Code: 400,
 			Msg:  "必须指定镜像或镜像ID",
 		})
 		return
 	}
+	if req.Kind == "compose" && len(req.Services) == 0 {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  "多容器题目至少需要一个服务",
+		})
+		return
+	}
 
 	// 输入验证
 	if req.Points < 1 || req.Points > 10000 {
@@ -130,10 +257,21 @@ func (h *AdminHandler) CreateChallenge(c *gin.Context) {
 		return
 	}
 
-	if req.Port < 1 || req.Port > 65535 {
+	if msg := req.validateScoring(); msg != "" {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  msg,
+		})
+		return
+	}
+
+	if req.FlagType == "" {
+		req.FlagType = service.FlagTypeDynamic
+	}
+	if err := service.ValidateFlagConfig(req.FlagType, req.Flag); err != nil {
 		c.PureJSON(http.StatusBadRequest, APIResponse{
 			Code: 400,
-			Msg:  "端口必须在 1-65535 之间",
+			Msg:  err.Error(),
 		})
 		return
 	}
@@ -154,24 +292,38 @@ func (h *AdminHandler) CreateChallenge(c *gin.Context) {
 
 	// 创建题目
 	challenge := &model.Challenge{
-		ID:           uuid.New().String(),
-		Title:        req.Title,
-		Description:  req.DescriptionHtml,
-		Hint:         req.HintHtml,
-		Category:     req.Category,
-		Difficulty:   req.Difficulty,
-		Image:        req.Image,
-		ImageID:      req.ImageID,
-		DockerHostID: req.DockerHostID,
-		Port:         req.Port,
-		MemoryLimit:  req.MemoryLimit,
-		CPULimit:     req.CPULimit,
-		Privileged:   req.Privileged,
-		Flag:         req.Flag,
-		Points:       req.Points,
-		Status:       req.Status,
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
+		ID:              uuid.New().String(),
+		Title:           req.Title,
+		Kind:            req.Kind,
+		Description:     req.DescriptionHtml,
+		Hint:            req.HintHtml,
+		Category:        req.Category,
+		Difficulty:      req.Difficulty,
+		Image:           req.Image,
+		ImageID:         req.ImageID,
+		DockerHostID:    req.DockerHostID,
+		HostTags:        normalizeTags(req.HostTags),
+		Port:            req.Port,
+		Ports:           req.Ports,
+		MemoryLimit:     req.MemoryLimit,
+		CPULimit:        req.CPULimit,
+		Privileged:      req.Privileged,
+		Egress:          req.Egress,
+		EgressAllowlist: req.EgressAllowlist,
+		MaxLifetime:     req.MaxLifetime,
+		MaxExtensions:   req.MaxExtensions,
+		Flag:            req.Flag,
+		FlagType:        req.FlagType,
+		Points:          req.Points,
+		ScoringMode:     req.ScoringMode,
+		InitialPoints:   req.initialPoints(),
+		MinimumPoints:   req.MinimumPoints,
+		Decay:           req.Decay,
+		DecayFunction:   req.DecayFunction,
+		Status:          req.Status,
+		HideLocked:      req.HideLocked,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
 	}
 
 	// 保存到数据库
@@ -185,7 +337,27 @@ func (h *AdminHandler) CreateChallenge(c *gin.Context) {
 		return
 	}
 
-	if err := db.WithContext(c.Request.Context()).Create(challenge).Error; err != nil {
+	var prereqErr error
+	err := db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(challenge).Error; err != nil {
+			return err
+		}
+		if req.Kind == "compose" {
+			if err := h.challengeSvc.SetServices(tx, challenge.ID, req.Services); err != nil {
+				return err
+			}
+		}
+		prereqErr = h.challengeSvc.SetPrerequisites(tx, challenge.ID, req.Prerequisites)
+		return prereqErr
+	})
+	if prereqErr != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  prereqErr.Error(),
+		})
+		return
+	}
+	if err != nil {
 		logger.Error(c.Request.Context(), "Failed to create challenge", "error", err)
 		c.PureJSON(http.StatusInternalServerError, APIResponse{
 			Code: 500,
@@ -226,10 +398,10 @@ func (h *AdminHandler) UpdateChallenge(c *gin.Context) {
 		return
 	}
 
-	if req.Port < 1 || req.Port > 65535 {
+	if msg := req.validateScoring(); msg != "" {
 		c.PureJSON(http.StatusBadRequest, APIResponse{
 			Code: 400,
-			Msg:  "端口必须在 1-65535 之间",
+			Msg:  msg,
 		})
 		return
 	}
@@ -262,6 +434,53 @@ func (h *AdminHandler) UpdateChallenge(c *gin.Context) {
 		return
 	}
 
+	// Flag 选填，为空时沿用原值
+	if req.Flag == "" {
+		req.Flag = existing.Flag
+	}
+	if req.FlagType == "" {
+		req.FlagType = existing.FlagType
+	}
+	if req.Kind == "" {
+		req.Kind = existing.Kind
+	}
+	if req.Ports == nil && req.Kind == "container" && len(existing.Ports) > 0 {
+		// 未传端口列表时保持原有端口配置不变，主端口仍为首个端口
+		req.Port = existing.Ports[0].Port
+	}
+	if msg := req.validateKind(); msg != "" {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  msg,
+		})
+		return
+	}
+	if err := service.ValidateFlagConfig(req.FlagType, req.Flag); err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+	if req.Kind == "compose" && req.Services == nil {
+		// 未传服务定义时沿用原有服务，改为多容器题目时必须提供
+		services, err := h.challengeSvc.GetServices(c.Request.Context(), challengeID)
+		if err != nil || len(services) == 0 {
+			c.PureJSON(http.StatusBadRequest, APIResponse{
+				Code: 400,
+				Msg:  "多容器题目至少需要一个服务",
+			})
+			return
+		}
+		if err := service.ValidateEgress(req.Egress, req.EgressAllowlist, service.HasPrivilegedService(services)); err != nil {
+			c.PureJSON(http.StatusBadRequest, APIResponse{
+				Code: 400,
+				Msg:  err.Error(),
+			})
+			return
+		}
+	}
+
 	// 自动填充镜像名称
 	if req.ImageID != "" {
 		var dockerImage model.DockerImage
@@ -277,7 +496,7 @@ func (h *AdminHandler) UpdateChallenge(c *gin.Context) {
 	// 但鉴于 updates map 的构造方式，如果 Image 为空字符串，它会被更新为空。
 	// 所以我们得确保 req.Image 有值，或者如果为空则沿用旧值（但这在 PUT 全量更新语义下通常不推荐，不过这里是 partial update 吗？结构体绑定是全量的）
 
-	if req.Image == "" {
+	if req.Image == "" && req.Kind == "container" {
 		// 如果尝试更新为空，且原本有值，这可能是个错误，或者是前端只传了部分字段？
 		// 暂且认为 Image 是必填的
 		if existing.Image != "" && req.ImageID == "" {
@@ -291,30 +510,77 @@ func (h *AdminHandler) UpdateChallenge(c *gin.Context) {
 		}
 	}
 
+	// 动态计分题目的 points 为衰减后的当前分值，未传 initial_points 时沿用原初始分值，避免编辑题目时初始分值被覆盖为当前分值
+	if req.InitialPoints == 0 && existing.IsDynamic() && existing.InitialPoints > 0 {
+		req.InitialPoints = existing.InitialPoints
+	}
+
 	// 更新字段
 	updates := map[string]interface{}{
-		"title":          req.Title,
-		"description":    req.DescriptionHtml,
-		"hint":           req.HintHtml,
-		"category":       req.Category,
-		"difficulty":     req.Difficulty,
-		"image":          req.Image,
-		"image_id":       req.ImageID,
-		"docker_host_id": req.DockerHostID,
-		"port":           req.Port,
-		"memory_limit":   req.MemoryLimit,
-		"cpu_limit":      req.CPULimit,
-		"privileged":     req.Privileged,
-		"flag":           req.Flag,
-		"points":         req.Points,
-		"updated_at":     time.Now(),
+		"title":            req.Title,
+		"kind":             req.Kind,
+		"description":      req.DescriptionHtml,
+		"hint":             req.HintHtml,
+		"category":         req.Category,
+		"difficulty":       req.Difficulty,
+		"image":            req.Image,
+		"image_id":         req.ImageID,
+		"docker_host_id":   req.DockerHostID,
+		"host_tags":        normalizeTags(req.HostTags),
+		"port":             req.Port,
+		"memory_limit":     req.MemoryLimit,
+		"cpu_limit":        req.CPULimit,
+		"privileged":       req.Privileged,
+		"egress":           req.Egress,
+		"egress_allowlist": req.EgressAllowlist,
+		"max_lifetime":     req.MaxLifetime,
+		"max_extensions":   req.MaxExtensions,
+		"flag":             req.Flag,
+		"flag_type":        req.FlagType,
+		"points":           req.Points,
+		"scoring_mode":     req.ScoringMode,
+		"initial_points":   req.initialPoints(),
+		"minimum_points":   req.MinimumPoints,
+		"decay":            req.Decay,
+		"decay_function":   req.DecayFunction,
+		"hide_locked":      req.HideLocked,
+		"updated_at":       time.Now(),
 	}
 
 	if req.Status != "" {
 		updates["status"] = req.Status
 	}
 
-	if err := db.WithContext(c.Request.Context()).Model(&existing).Updates(updates).Error; err != nil {
+	var prereqErr error
+	err := db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&existing).Updates(updates).Error; err != nil {
+			return err
+		}
+		// 端口列表为 JSON 字段，需通过结构体更新；未传时表示保持不变
+		if req.Ports != nil {
+			existing.Ports = req.Ports
+			if err := tx.Model(&existing).Select("ports").Updates(&existing).Error; err != nil {
+				return err
+			}
+		}
+		if req.Services != nil {
+			if err := h.challengeSvc.SetServices(tx, challengeID, req.Services); err != nil {
+				return err
+			}
+		}
+		if req.Prerequisites != nil {
+			prereqErr = h.challengeSvc.SetPrerequisites(tx, challengeID, req.Prerequisites)
+		}
+		return prereqErr
+	})
+	if prereqErr != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  prereqErr.Error(),
+		})
+		return
+	}
+	if err != nil {
 		logger.Error(c.Request.Context(), "Failed to update challenge", "error", err)
 		c.PureJSON(http.StatusInternalServerError, APIResponse{
 			Code: 500,
@@ -323,6 +589,11 @@ func (h *AdminHandler) UpdateChallenge(c *gin.Context) {
 		return
 	}
 
+	// 动态计分题目按新参数重算当前分值，并追溯更新已解出玩家的积分
+	if err := h.challengeSvc.RecalculateChallengeScore(c.Request.Context(), challengeID); err != nil {
+		logger.Error(c.Request.Context(), "Failed to recalculate challenge score", "id", challengeID, "error", err)
+	}
+
 	logger.Info(c.Request.Context(), "Updated challenge", "id", challengeID)
 
 	c.PureJSON(http.StatusOK, APIResponse{
@@ -368,8 +639,35 @@ func (h *AdminHandler) DeleteChallenge(c *gin.Context) {
 		return
 	}
 
-	// 删除题目
-	if err := db.WithContext(c.Request.Context()).Where("id = ?", challengeID).Delete(&model.Challenge{}).Error; err != nil {
+	// 先删除附件（存储对象与记录），避免题目删除后附件无法再通过管理端访问而永久占用存储
+	if err := h.attachmentSvc.DeleteChallengeAttachments(c.Request.Context(), challengeID); err != nil {
+		logger.Error(c.Request.Context(), "Failed to delete challenge attachments", "id", challengeID, "error", err)
+		c.PureJSON(http.StatusInternalServerError, APIResponse{
+			Code: 500,
+			Msg:  "删除附件失败",
+		})
+		return
+	}
+
+	// 删除题目及其关联数据（服务定义、前置关系、赛事题目列表、提示）
+	// 提示解锁记录保留，积分历史与保留的提交记录保持一致
+	err := db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", challengeID).Delete(&model.Challenge{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("challenge_id = ?", challengeID).Delete(&model.ServiceDefinition{}).Error; err != nil {
+			return err
+		}
+		// 以该题目为前置或依赖其他题目的前置关系
+		if err := tx.Where("challenge_id = ? OR prerequisite_id = ?", challengeID, challengeID).Delete(&model.ChallengePrerequisite{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("challenge_id = ?", challengeID).Delete(&model.EventChallenge{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("challenge_id = ?", challengeID).Delete(&model.Hint{}).Error
+	})
+	if err != nil {
 		logger.Error(c.Request.Context(), "Failed to delete challenge", "error", err)
 		c.PureJSON(http.StatusInternalServerError, APIResponse{
 			Code: 500,
@@ -539,9 +837,11 @@ func (h *AdminHandler) ListInstances(c *gin.Context) {
 // GET /api/admin/submissions
 func (h *AdminHandler) ListSubmissions(c *gin.Context) {
 	// 查询参数
-	user := c.Query("user")
-	challenge := c.Query("challenge")
-	result := c.Query("result") // correct/wrong
+	user := c.Query("user")           // 用户ID
+	challenge := c.Query("challenge") // 题目ID
+	event := c.Query("event")         // 赛事ID
+	result := c.Query("result")       // correct/wrong
+	bloodOnly := c.Query("blood") == "true"
 	page := c.DefaultQuery("page", "1")
 	pageSize := c.DefaultQuery("pageSize", "20")
 
@@ -550,13 +850,80 @@ func (h *AdminHandler) ListSubmissions(c *gin.Context) {
 
 	logger.Info(c.Request.Context(), "Listing submissions", "user", user, "challenge", challenge, "result", result)
 
-	// TODO: 实现提交记录查询逻辑
+	db, ok := h.db.(*gorm.DB)
+	if !ok {
+		c.PureJSON(http.StatusInternalServerError, APIResponse{
+			Code: 500,
+			Msg:  "系统错误",
+		})
+		return
+	}
+
+	// 构建查询
+	query := db.WithContext(c.Request.Context()).Model(&model.Submission{})
+
+	if user != "" {
+		query = query.Where("user_id = ?", user)
+	}
+	if challenge != "" {
+		query = query.Where("challenge_id = ?", challenge)
+	}
+	if event != "" {
+		query = query.Where("event_id = ?", event)
+	}
+	switch result {
+	case "correct":
+		query = query.Where("is_correct = ?", true)
+	case "wrong":
+		query = query.Where("is_correct = ?", false)
+	}
+	if bloodOnly {
+		query = query.Where("blood_rank > ?", 0)
+	}
+
+	// 计算总数
+	var total int64
+	query.Count(&total)
+
+	// 分页查询
+	var submissions []model.Submission
+	offset := (pageNum - 1) * pageSizeNum
+	if err := query.Order("submitted_at DESC").Offset(offset).Limit(pageSizeNum).Find(&submissions).Error; err != nil {
+		logger.Error(c.Request.Context(), "Failed to list submissions", "error", err)
+		c.PureJSON(http.StatusInternalServerError, APIResponse{
+			Code: 500,
+			Msg:  "查询失败",
+		})
+		return
+	}
+
+	// 关联用户名和题目标题
+	type SubmissionWithDetail struct {
+		model.Submission
+		Username       string `json:"username"`
+		ChallengeTitle string `json:"challenge_title"`
+	}
+
+	list := make([]SubmissionWithDetail, 0, len(submissions))
+	for _, sub := range submissions {
+		item := SubmissionWithDetail{Submission: sub}
+		var u model.User
+		if db.Select("id", "username").First(&u, "id = ?", sub.UserID).Error == nil {
+			item.Username = u.Username
+		}
+		var chal model.Challenge
+		if db.Select("id", "title").First(&chal, "id = ?", sub.ChallengeID).Error == nil {
+			item.ChallengeTitle = chal.Title
+		}
+		list = append(list, item)
+	}
+
 	c.PureJSON(http.StatusOK, APIResponse{
 		Code: 200,
 		Msg:  "success",
 		Data: gin.H{
-			"list":     []interface{}{},
-			"total":    0,
+			"list":     list,
+			"total":    total,
 			"page":     pageNum,
 			"pageSize": pageSizeNum,
 		},
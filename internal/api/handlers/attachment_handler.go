@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"cyber-range/internal/api/middleware"
+	"cyber-range/internal/service"
+	"cyber-range/pkg/logger"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AttachmentHandler 题目附件处理器（包含管理端与玩家端接口）
+type AttachmentHandler struct {
+	svc *service.AttachmentService
+}
+
+// NewAttachmentHandler 创建题目附件处理器
+func NewAttachmentHandler(svc *service.AttachmentService) *AttachmentHandler {
+	return &AttachmentHandler{svc: svc}
+}
+
+// AdminList 获取题目附件列表
+// GET /api/admin/challenges/:id/attachments
+func (h *AttachmentHandler) AdminList(c *gin.Context) {
+	attachments, err := h.svc.ListAttachments(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.PureJSON(http.StatusInternalServerError, APIResponse{
+			Code: 500,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "success",
+		Data: attachments,
+	})
+}
+
+// AdminUpload 上传题目附件（multipart 字段 file）
+// POST /api/admin/challenges/:id/attachments
+func (h *AttachmentHandler) AdminUpload(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  "请上传附件文件",
+		})
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		c.PureJSON(http.StatusInternalServerError, APIResponse{
+			Code: 500,
+			Msg:  "读取文件失败: " + err.Error(),
+		})
+		return
+	}
+	defer src.Close()
+
+	attachment, err := h.svc.Upload(c.Request.Context(), c.Param("id"), file.Filename, file.Header.Get("Content-Type"), src, file.Size)
+	if err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	adminID, _ := middleware.GetAdminID(c)
+	logger.Info(c.Request.Context(), "Attachment uploaded",
+		"attachment_id", attachment.ID, "challenge_id", attachment.ChallengeID, "size", attachment.Size, "admin_id", adminID)
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "附件上传成功",
+		Data: attachment,
+	})
+}
+
+// AdminDelete 删除附件
+// DELETE /api/admin/attachments/:id
+func (h *AttachmentHandler) AdminDelete(c *gin.Context) {
+	if err := h.svc.DeleteAttachment(c.Request.Context(), c.Param("id")); err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "附件删除成功",
+	})
+}
+
+// List 获取题目附件及下载链接
+// GET /api/challenges/:id/attachments?event_id=xxx
+func (h *AttachmentHandler) List(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	attachments, err := h.svc.ListForPlayer(c.Request.Context(), userID, c.Param("id"), c.Query("event_id"))
+	if err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "success",
+		Data: attachments,
+	})
+}
+
+// Download 通过签名链接下载附件（无需登录，链接短期有效）
+// GET /api/attachments/:id/download?expires=xxx&sig=xxx
+func (h *AttachmentHandler) Download(c *gin.Context) {
+	attachment, body, err := h.svc.Open(c.Request.Context(), c.Param("id"), c.Query("expires"), c.Query("sig"))
+	if err != nil {
+		c.PureJSON(http.StatusForbidden, APIResponse{
+			Code: 403,
+			Msg:  err.Error(),
+		})
+		return
+	}
+	defer body.Close()
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	c.Header("Content-Type", attachment.ContentType)
+	c.Header("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	c.Header("X-Checksum-SHA256", attachment.SHA256)
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, body); err != nil {
+		logger.Warn(c.Request.Context(), "Attachment download interrupted", "attachment_id", attachment.ID, "error", err)
+	}
+}
@@ -1,19 +1,29 @@
 package handlers
 
 import (
+	"cyber-range/internal/api/middleware"
 	"cyber-range/internal/service"
 	"cyber-range/pkg/logger"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	maxIdempotencyKeyLen = 128              // Idempotency-Key 请求头的最大长度
+	jobHeartbeatInterval = 15 * time.Second // 启动任务事件流的心跳间隔（防止代理断开空闲连接）
+)
+
 type ChallengeHandler struct {
-	svc *service.ChallengeService
+	svc     *service.ChallengeService
+	limiter *service.SubmitLimiter
 }
 
-func NewChallengeHandler(svc *service.ChallengeService) *ChallengeHandler {
-	return &ChallengeHandler{svc: svc}
+func NewChallengeHandler(svc *service.ChallengeService, limiter *service.SubmitLimiter) *ChallengeHandler {
+	return &ChallengeHandler{svc: svc, limiter: limiter}
 }
 
 // Standard API response format
@@ -24,9 +34,20 @@ type APIResponse struct {
 }
 
 // List returns all available challenges
+// 可选 query 参数 event_id：返回指定赛事的题目集合
 func (h *ChallengeHandler) List(c *gin.Context) {
-	challenges, err := h.svc.ListChallenges(c.Request.Context())
+	userID, _ := middleware.GetUserID(c)
+	eventID := c.Query("event_id")
+
+	challenges, err := h.svc.ListChallenges(c.Request.Context(), userID, eventID)
 	if err != nil {
+		if eventID != "" {
+			c.PureJSON(http.StatusForbidden, APIResponse{
+				Code: 403,
+				Msg:  err.Error(),
+			})
+			return
+		}
 		logger.Error(c.Request.Context(), "Failed to list challenges", "error", err)
 		c.PureJSON(http.StatusInternalServerError, APIResponse{
 			Code: 500,
@@ -42,18 +63,39 @@ func (h *ChallengeHandler) List(c *gin.Context) {
 	})
 }
 
-// Start launches a challenge instance for the user
+// Start creates an instance job for the user and returns immediately
+// 容器在后台创建，通过 /instance-jobs/:id 轮询或 /instance-jobs/:id/events 订阅进度
+// 可选 query 参数 event_id：在指定赛事中启动题目
+// 可选请求头 Idempotency-Key：使用相同 key 重试时返回同一个任务
 func (h *ChallengeHandler) Start(c *gin.Context) {
 	challengeID := c.Param("id")
+	eventID := c.Query("event_id")
+	idempotencyKey := c.GetHeader("Idempotency-Key")
+	if len(idempotencyKey) > maxIdempotencyKeyLen {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  "Idempotency-Key 过长",
+		})
+		return
+	}
 
-	// TODO: Extract userID from JWT token (for now use mock user)
-	userID := "user_mock_001"
+	userID, _ := middleware.GetUserID(c)
 
-	instance, err := h.svc.StartInstance(c.Request.Context(), userID, challengeID)
+	job, err := h.svc.StartInstance(c.Request.Context(), userID, challengeID, eventID, idempotencyKey)
 	if err != nil {
 		logger.Error(c.Request.Context(), "Failed to start instance",
 			"user_id", userID, "challenge_id", challengeID, "error", err)
 
+		// 配额不足时返回需要先停止的实例
+		if quotaErr, ok := err.(*service.QuotaError); ok {
+			c.PureJSON(http.StatusBadRequest, APIResponse{
+				Code: 400,
+				Msg:  quotaErr.Error(),
+				Data: gin.H{"instances": quotaErr.Instances},
+			})
+			return
+		}
+
 		c.PureJSON(http.StatusBadRequest, APIResponse{
 			Code: 400,
 			Msg:  err.Error(),
@@ -61,17 +103,79 @@ func (h *ChallengeHandler) Start(c *gin.Context) {
 		return
 	}
 
+	c.PureJSON(http.StatusAccepted, APIResponse{
+		Code: 202,
+		Msg:  "Instance job accepted",
+		Data: job,
+	})
+}
+
+// Job returns the status of an instance job
+// 任务 running 时附带实例信息，failed 时 reason 为失败原因
+func (h *ChallengeHandler) Job(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	job, err := h.svc.GetJob(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		c.PureJSON(http.StatusNotFound, APIResponse{
+			Code: 404,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
 	c.PureJSON(http.StatusOK, APIResponse{
 		Code: 200,
-		Msg:  "Instance started successfully",
-		Data: instance,
+		Msg:  "success",
+		Data: job,
+	})
+}
+
+// JobEvents streams instance job status changes as Server-Sent Events
+// 每次状态变更发送一条 status 事件，任务结束（running/failed）后关闭连接
+func (h *ChallengeHandler) JobEvents(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	ctx := c.Request.Context()
+
+	events, err := h.svc.WatchJob(ctx, userID, c.Param("id"))
+	if err != nil {
+		c.PureJSON(http.StatusNotFound, APIResponse{
+			Code: 404,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	heartbeat := time.NewTicker(jobHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case job, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent("status", job)
+			return !job.Done()
+		case <-heartbeat.C:
+			// SSE 注释行，客户端会忽略
+			io.WriteString(w, ": ping\n\n")
+			return true
+		case <-ctx.Done():
+			return false
+		}
 	})
 }
 
 // Stop terminates a challenge instance
 func (h *ChallengeHandler) Stop(c *gin.Context) {
 	challengeID := c.Param("id")
-	userID := "user_mock_001" // TODO: Extract from JWT
+	userID, _ := middleware.GetUserID(c)
 
 	if err := h.svc.StopInstance(c.Request.Context(), userID, challengeID); err != nil {
 		logger.Error(c.Request.Context(), "Failed to stop instance", "error", err)
@@ -89,10 +193,105 @@ func (h *ChallengeHandler) Stop(c *gin.Context) {
 	})
 }
 
+// Extend extends the lifetime of the user's running instance
+// 受题目/全局配置的最多延长次数与最长存活时间限制
+func (h *ChallengeHandler) Extend(c *gin.Context) {
+	challengeID := c.Param("id")
+	userID, _ := middleware.GetUserID(c)
+
+	instance, err := h.svc.ExtendInstance(c.Request.Context(), userID, challengeID)
+	if err != nil {
+		logger.Warn(c.Request.Context(), "Failed to extend instance",
+			"user_id", userID, "challenge_id", challengeID, "error", err)
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "Instance extended successfully",
+		Data: instance,
+	})
+}
+
+// Reset recreates the user's running instance with the same flag
+// 容器在原主机上重建，端口可能变化，过期时间不变
+func (h *ChallengeHandler) Reset(c *gin.Context) {
+	challengeID := c.Param("id")
+	userID, _ := middleware.GetUserID(c)
+
+	instance, err := h.svc.ResetInstance(c.Request.Context(), userID, challengeID)
+	if err != nil {
+		logger.Error(c.Request.Context(), "Failed to reset instance",
+			"user_id", userID, "challenge_id", challengeID, "error", err)
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "Instance reset successfully",
+		Data: instance,
+	})
+}
+
+// Instances lists the user's running instances with connection info
+// 团队模式下返回队伍的全部实例
+func (h *ChallengeHandler) Instances(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	instances, err := h.svc.ListInstances(c.Request.Context(), userID)
+	if err != nil {
+		logger.Warn(c.Request.Context(), "Failed to list instances", "user_id", userID, "error", err)
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "success",
+		Data: instances,
+	})
+}
+
+// GatewayAccess returns the HTTP gateway URL of the user's instance
+// 网关要求访问会话时返回附带一次性票据的地址（1 分钟内有效），前端应直接在新窗口打开
+func (h *ChallengeHandler) GatewayAccess(c *gin.Context) {
+	instanceID := c.Param("id")
+	userID, _ := middleware.GetUserID(c)
+
+	accessURL, err := h.svc.GatewayAccess(c.Request.Context(), userID, instanceID)
+	if err != nil {
+		logger.Warn(c.Request.Context(), "Failed to create gateway access",
+			"user_id", userID, "instance_id", instanceID, "error", err)
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "success",
+		Data: map[string]string{"url": accessURL},
+	})
+}
+
 // Verify validates a flag submission
 func (h *ChallengeHandler) Verify(c *gin.Context) {
 	var req struct {
 		ChallengeID string `json:"challenge_id" binding:"required"`
+		EventID     string `json:"event_id"` // 可选，赛事内提交
 		Flag        string `json:"flag" binding:"required"`
 	}
 
@@ -104,9 +303,22 @@ func (h *ChallengeHandler) Verify(c *gin.Context) {
 		return
 	}
 
-	userID := "user_mock_001" // TODO: Extract from JWT
+	userID, _ := middleware.GetUserID(c)
+
+	// 提交限流（按用户和IP分题目计数）
+	if err := h.limiter.Allow(c.Request.Context(), userID, c.ClientIP(), req.ChallengeID); err != nil {
+		if rlErr, ok := err.(*service.RateLimitError); ok {
+			c.Header("Retry-After", strconv.Itoa(rlErr.RetryAfterSeconds()))
+			c.PureJSON(http.StatusTooManyRequests, APIResponse{
+				Code: 429,
+				Msg:  rlErr.Error(),
+				Data: gin.H{"retry_after": rlErr.RetryAfterSeconds()},
+			})
+			return
+		}
+	}
 
-	correct, message, err := h.svc.VerifyFlag(c.Request.Context(), userID, req.ChallengeID, req.Flag)
+	result, err := h.svc.VerifyFlag(c.Request.Context(), userID, req.ChallengeID, req.EventID, req.Flag)
 	if err != nil {
 		logger.Error(c.Request.Context(), "Failed to verify flag", "error", err)
 		c.PureJSON(http.StatusInternalServerError, APIResponse{
@@ -119,9 +331,27 @@ func (h *ChallengeHandler) Verify(c *gin.Context) {
 	c.PureJSON(http.StatusOK, APIResponse{
 		Code: 200,
 		Msg:  "success",
-		Data: map[string]interface{}{
-			"correct": correct,
-			"message": message,
-		},
+		Data: result,
+	})
+}
+
+// Solves returns the solvers of a challenge (with first/second/third blood)
+// 可选 query 参数 event_id：查看指定赛事内的解出记录
+func (h *ChallengeHandler) Solves(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	solves, err := h.svc.ListSolves(c.Request.Context(), userID, c.Param("id"), c.Query("event_id"))
+	if err != nil {
+		logger.Warn(c.Request.Context(), "Failed to list solves", "error", err)
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "success",
+		Data: solves,
 	})
 }
@@ -4,8 +4,11 @@ import (
 	"cyber-range/internal/infra/db"
 	"cyber-range/internal/infra/docker"
 	"cyber-range/internal/model"
+	"cyber-range/internal/service"
 	"cyber-range/pkg/logger"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,15 +18,23 @@ import (
 type DockerHostHandler struct {
 	repo          *db.Repository
 	dockerManager *docker.DockerHostManager
+	scheduler     *service.HostScheduler
 }
 
-func NewDockerHostHandler(repo *db.Repository, dockerManager *docker.DockerHostManager) *DockerHostHandler {
+func NewDockerHostHandler(repo *db.Repository, dockerManager *docker.DockerHostManager, scheduler *service.HostScheduler) *DockerHostHandler {
 	return &DockerHostHandler{
 		repo:          repo,
 		dockerManager: dockerManager,
+		scheduler:     scheduler,
 	}
 }
 
+// dockerHostView 主机列表项，附带容量使用情况与健康状态
+type dockerHostView struct {
+	*model.DockerHost
+	Utilization *service.HostUtilization `json:"utilization,omitempty"`
+}
+
 // ListDockerHosts 获取 Docker 主机列表
 // GET /api/admin/docker-hosts
 func (h *DockerHostHandler) ListDockerHosts(c *gin.Context) {
@@ -39,10 +50,21 @@ func (h *DockerHostHandler) ListDockerHosts(c *gin.Context) {
 		return
 	}
 
+	views := make([]dockerHostView, 0, len(hosts))
+	for _, host := range hosts {
+		view := dockerHostView{DockerHost: host}
+		if utilization, err := h.scheduler.Utilization(ctx, host); err != nil {
+			logger.Warn(ctx, "Failed to get host utilization", "host_id", host.ID, "error", err)
+		} else {
+			view.Utilization = utilization
+		}
+		views = append(views, view)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
 		"msg":  "success",
-		"data": hosts,
+		"data": views,
 	})
 }
 
@@ -56,10 +78,17 @@ func (h *DockerHostHandler) CreateDockerHost(c *gin.Context) {
 		Host         string  `json:"host" binding:"required"`
 		TLSVerify    bool    `json:"tls_verify"`
 		CertPath     string  `json:"cert_path"`
+		PublicHost   string  `json:"public_host"`
+		ConnectTpl   string  `json:"connect_template"`
+		Tags         string  `json:"tags"`         // 主机标签（逗号分隔），题目可按标签约束调度
+		NetworkPool  string  `json:"network_pool"` // 实例网络地址池（IPv4 CIDR），为空使用 Docker 默认地址池
 		PortRangeMin int     `json:"port_range_min" binding:"required,min=1024,max=65535"`
 		PortRangeMax int     `json:"port_range_max" binding:"required,min=1024,max=65535"`
 		MemoryLimit  int64   `json:"memory_limit" binding:"required,min=67108864"` // 最小 64MB
 		CPULimit     float64 `json:"cpu_limit" binding:"required,min=0.1,max=128"` // 最小 0.1 核
+		MaxInstances int     `json:"max_instances" binding:"min=0"`                // 容量上限：实例数（0 表示不限制）
+		MaxMemory    int64   `json:"max_memory" binding:"min=0"`                   // 容量上限：预留内存总量（字节）
+		MaxCPU       float64 `json:"max_cpu" binding:"min=0"`                      // 容量上限：预留 CPU 总量（核心数）
 		Enabled      bool    `json:"enabled"`
 		IsDefault    bool    `json:"is_default"`
 		Description  string  `json:"description"`
@@ -82,21 +111,44 @@ func (h *DockerHostHandler) CreateDockerHost(c *gin.Context) {
 		return
 	}
 
+	if msg := validatePublicAccess(req.PublicHost, req.ConnectTpl); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  msg,
+		})
+		return
+	}
+
+	if err := docker.ValidateNetworkPool(req.NetworkPool, req.MaxInstances); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
 	host := &model.DockerHost{
-		ID:           uuid.New().String(),
-		Name:         req.Name,
-		Host:         req.Host,
-		TLSVerify:    req.TLSVerify,
-		CertPath:     req.CertPath,
-		PortRangeMin: req.PortRangeMin,
-		PortRangeMax: req.PortRangeMax,
-		MemoryLimit:  req.MemoryLimit,
-		CPULimit:     req.CPULimit,
-		Enabled:      req.Enabled,
-		IsDefault:    req.IsDefault,
-		Description:  req.Description,
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
+		ID:              uuid.New().String(),
+		Name:            req.Name,
+		Host:            req.Host,
+		TLSVerify:       req.TLSVerify,
+		CertPath:        req.CertPath,
+		PublicHost:      req.PublicHost,
+		ConnectTemplate: req.ConnectTpl,
+		Tags:            normalizeTags(req.Tags),
+		NetworkPool:     req.NetworkPool,
+		PortRangeMin:    req.PortRangeMin,
+		PortRangeMax:    req.PortRangeMax,
+		MemoryLimit:     req.MemoryLimit,
+		CPULimit:        req.CPULimit,
+		MaxInstances:    req.MaxInstances,
+		MaxMemory:       req.MaxMemory,
+		MaxCPU:          req.MaxCPU,
+		Enabled:         req.Enabled,
+		IsDefault:       req.IsDefault,
+		Description:     req.Description,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
 	}
 
 	if err := h.repo.CreateDockerHost(ctx, host); err != nil {
@@ -117,6 +169,25 @@ func (h *DockerHostHandler) CreateDockerHost(c *gin.Context) {
 	})
 }
 
+// validatePublicAccess 校验玩家访问地址与连接信息模板，返回错误信息（合法时为空）
+func validatePublicAccess(publicHost, template string) string {
+	if strings.ContainsAny(publicHost, "/ \t") {
+		return "公网地址应为主机名或IP，不含协议与路径"
+	}
+	if _, _, err := net.SplitHostPort(publicHost); err == nil {
+		return "公网地址不含端口，端口由实例映射决定"
+	}
+	if err := service.ValidateConnectTemplate(template); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// normalizeTags 规范化逗号分隔的标签（去除空白与空项，统一小写）
+func normalizeTags(tags string) string {
+	return strings.Join(model.ParseTags(tags), ",")
+}
+
 // UpdateDockerHost 更新 Docker 主机配置
 // PUT /api/admin/docker-hosts/:id
 func (h *DockerHostHandler) UpdateDockerHost(c *gin.Context) {
@@ -128,10 +199,17 @@ func (h *DockerHostHandler) UpdateDockerHost(c *gin.Context) {
 		Host         string  `json:"host" binding:"required"`
 		TLSVerify    bool    `json:"tls_verify"`
 		CertPath     string  `json:"cert_path"`
+		PublicHost   string  `json:"public_host"`
+		ConnectTpl   string  `json:"connect_template"`
+		Tags         string  `json:"tags"`         // 主机标签（逗号分隔），题目可按标签约束调度
+		NetworkPool  string  `json:"network_pool"` // 实例网络地址池（IPv4 CIDR），为空使用 Docker 默认地址池
 		PortRangeMin int     `json:"port_range_min" binding:"required,min=1024,max=65535"`
 		PortRangeMax int     `json:"port_range_max" binding:"required,min=1024,max=65535"`
 		MemoryLimit  int64   `json:"memory_limit" binding:"required,min=67108864"`
 		CPULimit     float64 `json:"cpu_limit" binding:"required,min=0.1,max=128"`
+		MaxInstances int     `json:"max_instances" binding:"min=0"` // 容量上限：实例数（0 表示不限制）
+		MaxMemory    int64   `json:"max_memory" binding:"min=0"`    // 容量上限：预留内存总量（字节）
+		MaxCPU       float64 `json:"max_cpu" binding:"min=0"`       // 容量上限：预留 CPU 总量（核心数）
 		Enabled      bool    `json:"enabled"`
 		IsDefault    bool    `json:"is_default"`
 		Description  string  `json:"description"`
@@ -154,6 +232,22 @@ func (h *DockerHostHandler) UpdateDockerHost(c *gin.Context) {
 		return
 	}
 
+	if msg := validatePublicAccess(req.PublicHost, req.ConnectTpl); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  msg,
+		})
+		return
+	}
+
+	if err := docker.ValidateNetworkPool(req.NetworkPool, req.MaxInstances); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
 	// 获取现有主机信息
 	existingHost, err := h.repo.GetDockerHostByID(ctx, hostID)
 	if err != nil {
@@ -169,10 +263,17 @@ func (h *DockerHostHandler) UpdateDockerHost(c *gin.Context) {
 	existingHost.Host = req.Host
 	existingHost.TLSVerify = req.TLSVerify
 	existingHost.CertPath = req.CertPath
+	existingHost.PublicHost = req.PublicHost
+	existingHost.ConnectTemplate = req.ConnectTpl
+	existingHost.Tags = normalizeTags(req.Tags)
+	existingHost.NetworkPool = req.NetworkPool
 	existingHost.PortRangeMin = req.PortRangeMin
 	existingHost.PortRangeMax = req.PortRangeMax
 	existingHost.MemoryLimit = req.MemoryLimit
 	existingHost.CPULimit = req.CPULimit
+	existingHost.MaxInstances = req.MaxInstances
+	existingHost.MaxMemory = req.MaxMemory
+	existingHost.MaxCPU = req.MaxCPU
 	existingHost.Enabled = req.Enabled
 	existingHost.IsDefault = req.IsDefault
 	existingHost.Description = req.Description
@@ -291,3 +392,39 @@ func (h *DockerHostHandler) ToggleDockerHost(c *gin.Context) {
 		"data": host,
 	})
 }
+
+// PortUsage 获取各 Docker 主机端口范围的使用情况
+// GET /api/admin/docker-hosts/ports
+func (h *DockerHostHandler) PortUsage(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	hosts, err := h.repo.ListDockerHosts(ctx, false)
+	if err != nil {
+		logger.Error(ctx, "Failed to list Docker hosts", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取 Docker 主机列表失败",
+		})
+		return
+	}
+
+	usages := make([]*docker.PortUsage, 0, len(hosts))
+	for _, host := range hosts {
+		usage, err := h.dockerManager.PortUsage(ctx, host)
+		if err != nil {
+			logger.Error(ctx, "Failed to get port usage", "host_id", host.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code": 500,
+				"msg":  "获取端口使用情况失败",
+			})
+			return
+		}
+		usages = append(usages, usage)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": usages,
+	})
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"cyber-range/internal/api/middleware"
+	"cyber-range/internal/model"
+	"cyber-range/internal/service"
+	"cyber-range/pkg/logger"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EventHandler 赛事处理器（包含管理端与玩家端接口）
+type EventHandler struct {
+	eventSvc *service.EventService
+}
+
+// NewEventHandler 创建赛事处理器
+func NewEventHandler(eventSvc *service.EventService) *EventHandler {
+	return &EventHandler{eventSvc: eventSvc}
+}
+
+// EventRequest 创建/更新赛事请求
+type EventRequest struct {
+	Name                string     `json:"name" binding:"required,max=200"`
+	Description         string     `json:"description"`
+	Visibility          string     `json:"visibility"` // public / invite_only，默认 public
+	RegistrationStartAt *time.Time `json:"registration_start_at"`
+	RegistrationEndAt   *time.Time `json:"registration_end_at"`
+	StartAt             time.Time  `json:"start_at" binding:"required"`
+	EndAt               time.Time  `json:"end_at" binding:"required"`
+	FreezeAt            *time.Time `json:"freeze_at"`     // 封榜时间，可选
+	ChallengeIDs        []string   `json:"challenge_ids"` // 仅创建时生效，更新题目集合请使用 PUT /events/:id/challenges
+}
+
+func (r *EventRequest) toModel() *model.Event {
+	return &model.Event{
+		Name:                r.Name,
+		Description:         r.Description,
+		Visibility:          r.Visibility,
+		RegistrationStartAt: r.RegistrationStartAt,
+		RegistrationEndAt:   r.RegistrationEndAt,
+		StartAt:             r.StartAt,
+		EndAt:               r.EndAt,
+		FreezeAt:            r.FreezeAt,
+	}
+}
+
+// AdminList 获取赛事列表
+// GET /api/admin/events
+func (h *EventHandler) AdminList(c *gin.Context) {
+	events, err := h.eventSvc.ListEvents(c.Request.Context())
+	if err != nil {
+		c.PureJSON(http.StatusInternalServerError, APIResponse{
+			Code: 500,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "success",
+		Data: events,
+	})
+}
+
+// AdminGet 获取赛事详情
+// GET /api/admin/events/:id
+func (h *EventHandler) AdminGet(c *gin.Context) {
+	event, err := h.eventSvc.GetEvent(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.PureJSON(http.StatusNotFound, APIResponse{
+			Code: 404,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "success",
+		Data: event,
+	})
+}
+
+// AdminCreate 创建赛事
+// POST /api/admin/events
+func (h *EventHandler) AdminCreate(c *gin.Context) {
+	var req EventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	event, err := h.eventSvc.CreateEvent(c.Request.Context(), req.toModel(), req.ChallengeIDs)
+	if err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	adminID, _ := middleware.GetAdminID(c)
+	logger.Info(c.Request.Context(), "Event created", "event_id", event.ID, "admin_id", adminID)
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "赛事创建成功",
+		Data: event,
+	})
+}
+
+// AdminUpdate 更新赛事信息
+// PUT /api/admin/events/:id
+func (h *EventHandler) AdminUpdate(c *gin.Context) {
+	var req EventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	event, err := h.eventSvc.UpdateEvent(c.Request.Context(), c.Param("id"), req.toModel())
+	if err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "赛事更新成功",
+		Data: event,
+	})
+}
+
+// AdminDelete 删除赛事
+// DELETE /api/admin/events/:id
+func (h *EventHandler) AdminDelete(c *gin.Context) {
+	if err := h.eventSvc.DeleteEvent(c.Request.Context(), c.Param("id")); err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "赛事删除成功",
+	})
+}
+
+// AdminSetChallenges 覆盖设置赛事题目集合
+// PUT /api/admin/events/:id/challenges
+func (h *EventHandler) AdminSetChallenges(c *gin.Context) {
+	var req struct {
+		ChallengeIDs []string `json:"challenge_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  "Invalid request format",
+		})
+		return
+	}
+
+	event, err := h.eventSvc.SetEventChallenges(c.Request.Context(), c.Param("id"), req.ChallengeIDs)
+	if err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "赛事题目已更新",
+		Data: event,
+	})
+}
+
+// List 获取玩家可见的赛事列表
+// GET /api/events
+func (h *EventHandler) List(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	events, err := h.eventSvc.ListVisibleEvents(c.Request.Context(), userID)
+	if err != nil {
+		c.PureJSON(http.StatusInternalServerError, APIResponse{
+			Code: 500,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "success",
+		Data: events,
+	})
+}
+
+// Register 报名赛事
+// POST /api/events/:id/register
+func (h *EventHandler) Register(c *gin.Context) {
+	var req struct {
+		InviteCode string `json:"invite_code"` // 邀请制赛事必填
+	}
+	// 公开赛事允许空请求体
+	_ = c.ShouldBindJSON(&req)
+
+	userID, _ := middleware.GetUserID(c)
+	eventID := c.Param("id")
+
+	if err := h.eventSvc.RegisterEvent(c.Request.Context(), userID, eventID, req.InviteCode); err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	logger.Info(c.Request.Context(), "User registered for event", "user_id", userID, "event_id", eventID)
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "报名成功",
+	})
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"cyber-range/internal/api/middleware"
+	"cyber-range/internal/model"
+	"cyber-range/internal/service"
+	"cyber-range/pkg/logger"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HintHandler 题目提示处理器（包含管理端与玩家端接口）
+type HintHandler struct {
+	svc *service.HintService
+}
+
+// NewHintHandler 创建题目提示处理器
+func NewHintHandler(svc *service.HintService) *HintHandler {
+	return &HintHandler{svc: svc}
+}
+
+// HintRequest 创建/更新提示请求
+type HintRequest struct {
+	Content  string `json:"content" binding:"required"`
+	Cost     int    `json:"cost"`     // 解锁扣分，0 表示免费
+	Position int    `json:"position"` // 排序序号，升序
+}
+
+// AdminList 获取题目提示列表
+// GET /api/admin/challenges/:id/hints
+func (h *HintHandler) AdminList(c *gin.Context) {
+	hints, err := h.svc.ListHints(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.PureJSON(http.StatusInternalServerError, APIResponse{
+			Code: 500,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "success",
+		Data: hints,
+	})
+}
+
+// AdminCreate 创建提示
+// POST /api/admin/challenges/:id/hints
+func (h *HintHandler) AdminCreate(c *gin.Context) {
+	var req HintRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	hint, err := h.svc.CreateHint(c.Request.Context(), &model.Hint{
+		ChallengeID: c.Param("id"),
+		Content:     req.Content,
+		Cost:        req.Cost,
+		Position:    req.Position,
+	})
+	if err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "提示创建成功",
+		Data: hint,
+	})
+}
+
+// AdminUpdate 更新提示
+// PUT /api/admin/hints/:id
+func (h *HintHandler) AdminUpdate(c *gin.Context) {
+	var req HintRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	hint, err := h.svc.UpdateHint(c.Request.Context(), c.Param("id"), &model.Hint{
+		Content:  req.Content,
+		Cost:     req.Cost,
+		Position: req.Position,
+	})
+	if err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "提示更新成功",
+		Data: hint,
+	})
+}
+
+// AdminDelete 删除提示（退还已解锁者的扣分）
+// DELETE /api/admin/hints/:id
+func (h *HintHandler) AdminDelete(c *gin.Context) {
+	if err := h.svc.DeleteHint(c.Request.Context(), c.Param("id")); err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "提示删除成功",
+	})
+}
+
+// AdminUnlocks 查看提示解锁记录
+// GET /api/admin/hint-unlocks?challenge=xxx&hint=xxx
+func (h *HintHandler) AdminUnlocks(c *gin.Context) {
+	records, err := h.svc.ListUnlocks(c.Request.Context(), c.Query("challenge"), c.Query("hint"))
+	if err != nil {
+		c.PureJSON(http.StatusInternalServerError, APIResponse{
+			Code: 500,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "success",
+		Data: records,
+	})
+}
+
+// List 获取题目提示（未解锁的付费提示不含内容）
+// GET /api/challenges/:id/hints?event_id=xxx
+func (h *HintHandler) List(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	hints, err := h.svc.ListForPlayer(c.Request.Context(), userID, c.Param("id"), c.Query("event_id"))
+	if err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "success",
+		Data: hints,
+	})
+}
+
+// Unlock 解锁提示（扣除积分）
+// POST /api/hints/:id/unlock
+func (h *HintHandler) Unlock(c *gin.Context) {
+	var req struct {
+		EventID string `json:"event_id"` // 赛事内解锁时必填
+	}
+	// 非赛事场景允许空请求体
+	_ = c.ShouldBindJSON(&req)
+
+	userID, _ := middleware.GetUserID(c)
+	hintID := c.Param("id")
+
+	hint, err := h.svc.UnlockHint(c.Request.Context(), userID, hintID, req.EventID)
+	if err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	logger.Info(c.Request.Context(), "Hint unlocked", "user_id", userID, "hint_id", hintID, "event_id", req.EventID, "cost", hint.Cost)
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "提示已解锁",
+		Data: hint,
+	})
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"cyber-range/internal/api/middleware"
+	"cyber-range/internal/service"
+	"cyber-range/pkg/logger"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScoreboardHandler 积分榜处理器
+type ScoreboardHandler struct {
+	scoreboardSvc *service.ScoreboardService
+}
+
+// NewScoreboardHandler 创建积分榜处理器
+func NewScoreboardHandler(scoreboardSvc *service.ScoreboardService) *ScoreboardHandler {
+	return &ScoreboardHandler{scoreboardSvc: scoreboardSvc}
+}
+
+// Scoreboard 全站积分榜（封榜后隐藏封榜时间之后的解题）
+// GET /api/scoreboard
+func (h *ScoreboardHandler) Scoreboard(c *gin.Context) {
+	h.respondScoreboard(c, "", false)
+}
+
+// Progression 全站前 N 名得分曲线
+// GET /api/scoreboard/progression?top=10
+func (h *ScoreboardHandler) Progression(c *gin.Context) {
+	h.respondProgression(c, "", false)
+}
+
+// EventScoreboard 赛事积分榜（邀请制赛事仅报名玩家可见）
+// GET /api/events/:id/scoreboard
+func (h *ScoreboardHandler) EventScoreboard(c *gin.Context) {
+	if h.checkEventAccess(c) {
+		h.respondScoreboard(c, c.Param("id"), false)
+	}
+}
+
+// EventProgression 赛事前 N 名得分曲线
+// GET /api/events/:id/scoreboard/progression?top=10
+func (h *ScoreboardHandler) EventProgression(c *gin.Context) {
+	if h.checkEventAccess(c) {
+		h.respondProgression(c, c.Param("id"), false)
+	}
+}
+
+// AdminScoreboard 管理端积分榜（不受封榜限制）
+// GET /api/admin/scoreboard?event_id=xxx
+func (h *ScoreboardHandler) AdminScoreboard(c *gin.Context) {
+	h.respondScoreboard(c, c.Query("event_id"), true)
+}
+
+// AdminProgression 管理端得分曲线（不受封榜限制）
+// GET /api/admin/scoreboard/progression?event_id=xxx&top=10
+func (h *ScoreboardHandler) AdminProgression(c *gin.Context) {
+	h.respondProgression(c, c.Query("event_id"), true)
+}
+
+// checkEventAccess 校验玩家查看赛事积分榜的权限，无权限时写入 403 响应并返回 false
+func (h *ScoreboardHandler) checkEventAccess(c *gin.Context) bool {
+	userID, _ := middleware.GetUserID(c)
+	if err := h.scoreboardSvc.CheckEventAccess(c.Request.Context(), userID, c.Param("id")); err != nil {
+		c.PureJSON(http.StatusForbidden, APIResponse{
+			Code: 403,
+			Msg:  err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+func (h *ScoreboardHandler) respondScoreboard(c *gin.Context, eventID string, unfrozen bool) {
+	board, err := h.scoreboardSvc.GetScoreboard(c.Request.Context(), eventID, unfrozen)
+	if err != nil {
+		logger.Error(c.Request.Context(), "Failed to get scoreboard", "event_id", eventID, "error", err)
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "success",
+		Data: board,
+	})
+}
+
+func (h *ScoreboardHandler) respondProgression(c *gin.Context, eventID string, unfrozen bool) {
+	top, _ := strconv.Atoi(c.DefaultQuery("top", "10"))
+	if top < 1 || top > 50 {
+		top = 10
+	}
+
+	series, err := h.scoreboardSvc.GetProgression(c.Request.Context(), eventID, top, unfrozen)
+	if err != nil {
+		logger.Error(c.Request.Context(), "Failed to get score progression", "event_id", eventID, "error", err)
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "success",
+		Data: series,
+	})
+}
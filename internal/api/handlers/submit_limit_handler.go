@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"cyber-range/internal/api/middleware"
+	"cyber-range/internal/service"
+	"cyber-range/pkg/logger"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SubmitLimitHandler 提交限流管理处理器
+type SubmitLimitHandler struct {
+	limiter *service.SubmitLimiter
+}
+
+// NewSubmitLimitHandler 创建提交限流管理处理器
+func NewSubmitLimitHandler(limiter *service.SubmitLimiter) *SubmitLimitHandler {
+	return &SubmitLimitHandler{limiter: limiter}
+}
+
+// ListThrottled 查看当前被限流的用户与IP
+// GET /api/admin/throttled
+func (h *SubmitLimitHandler) ListThrottled(c *gin.Context) {
+	list, err := h.limiter.ListThrottled(c.Request.Context())
+	if err != nil {
+		logger.Error(c.Request.Context(), "Failed to list throttled subjects", "error", err)
+		c.PureJSON(http.StatusInternalServerError, APIResponse{
+			Code: 500,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "success",
+		Data: list,
+	})
+}
+
+// ClearThrottle 解除限流
+// DELETE /api/admin/throttled
+func (h *SubmitLimitHandler) ClearThrottle(c *gin.Context) {
+	var req struct {
+		Kind        string `json:"kind" binding:"required"` // user / ip
+		ID          string `json:"id" binding:"required"`
+		ChallengeID string `json:"challenge_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.limiter.ClearThrottle(c.Request.Context(), req.Kind, req.ID, req.ChallengeID); err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	adminID, _ := middleware.GetAdminID(c)
+	logger.Info(c.Request.Context(), "Throttle cleared", "kind", req.Kind, "id", req.ID, "challenge_id", req.ChallengeID, "admin_id", adminID)
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "已解除限流",
+	})
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"cyber-range/internal/api/middleware"
+	"cyber-range/internal/service"
+	"cyber-range/pkg/logger"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TeamHandler 队伍处理器
+type TeamHandler struct {
+	teamSvc       *service.TeamService
+	scoreboardSvc *service.ScoreboardService
+}
+
+// NewTeamHandler 创建队伍处理器
+func NewTeamHandler(teamSvc *service.TeamService, scoreboardSvc *service.ScoreboardService) *TeamHandler {
+	return &TeamHandler{teamSvc: teamSvc, scoreboardSvc: scoreboardSvc}
+}
+
+// Create 创建队伍
+// POST /api/teams
+func (h *TeamHandler) Create(c *gin.Context) {
+	var req struct {
+		Name string `json:"name" binding:"required,min=2,max=50"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  "Invalid request format",
+		})
+		return
+	}
+
+	userID, _ := middleware.GetUserID(c)
+	team, err := h.teamSvc.CreateTeam(c.Request.Context(), userID, req.Name)
+	if err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	logger.Info(c.Request.Context(), "Team created", "team_id", team.ID, "captain_id", userID)
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "队伍创建成功",
+		Data: gin.H{
+			"team":        team,
+			"invite_code": team.InviteCode,
+		},
+	})
+}
+
+// Join 通过邀请码加入队伍
+// POST /api/teams/join
+func (h *TeamHandler) Join(c *gin.Context) {
+	var req struct {
+		InviteCode string `json:"invite_code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  "Invalid request format",
+		})
+		return
+	}
+
+	userID, _ := middleware.GetUserID(c)
+	team, err := h.teamSvc.JoinTeam(c.Request.Context(), userID, req.InviteCode)
+	if err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	logger.Info(c.Request.Context(), "User joined team", "team_id", team.ID, "user_id", userID)
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "加入队伍成功",
+		Data: team,
+	})
+}
+
+// Leave 退出队伍
+// POST /api/teams/leave
+func (h *TeamHandler) Leave(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	if err := h.teamSvc.LeaveTeam(c.Request.Context(), userID); err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "已退出队伍",
+	})
+}
+
+// TransferCaptain 转让队长
+// POST /api/teams/transfer
+func (h *TeamHandler) TransferCaptain(c *gin.Context) {
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  "Invalid request format",
+		})
+		return
+	}
+
+	userID, _ := middleware.GetUserID(c)
+	if err := h.teamSvc.TransferCaptain(c.Request.Context(), userID, req.UserID); err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	logger.Info(c.Request.Context(), "Team captain transferred", "from", userID, "to", req.UserID)
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "队长已转让",
+	})
+}
+
+// Mine 获取我的队伍详情
+// GET /api/teams/me
+func (h *TeamHandler) Mine(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	team, err := h.teamSvc.GetMyTeam(c.Request.Context(), userID)
+	if err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "success",
+		Data: team,
+	})
+}
+
+// Scoreboard 队伍积分榜（封榜后按封榜时间之前的得分排名）
+// GET /api/teams/scoreboard
+func (h *TeamHandler) Scoreboard(c *gin.Context) {
+	teams, err := h.scoreboardSvc.ListTeamScoreboard(c.Request.Context())
+	if err != nil {
+		logger.Error(c.Request.Context(), "Failed to list team scoreboard", "error", err)
+		c.PureJSON(http.StatusInternalServerError, APIResponse{
+			Code: 500,
+			Msg:  "获取队伍积分榜失败",
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "success",
+		Data: teams,
+	})
+}
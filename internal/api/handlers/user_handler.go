@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"cyber-range/internal/api/middleware"
+	"cyber-range/internal/service"
+	"cyber-range/pkg/logger"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserHandler 玩家账号处理器
+type UserHandler struct {
+	userSvc       *service.UserService
+	scoreboardSvc *service.ScoreboardService
+}
+
+// NewUserHandler 创建玩家账号处理器
+func NewUserHandler(userSvc *service.UserService, scoreboardSvc *service.ScoreboardService) *UserHandler {
+	return &UserHandler{userSvc: userSvc, scoreboardSvc: scoreboardSvc}
+}
+
+// RegisterRequest 注册请求
+type RegisterRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=50"`
+	Email    string `json:"email" binding:"required,email,max=100"`
+	Password string `json:"password" binding:"required,min=6,max=72"` // bcrypt 最多处理 72 字节
+}
+
+// Register 玩家注册
+// POST /api/register
+func (h *UserHandler) Register(c *gin.Context) {
+	var req RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	user, err := h.userSvc.Register(c.Request.Context(), req.Username, req.Email, req.Password)
+	if err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	logger.Info(c.Request.Context(), "User registered", "user_id", user.ID, "username", user.Username)
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "注册成功",
+		Data: user,
+	})
+}
+
+// Login 玩家登录
+// POST /api/login
+func (h *UserHandler) Login(c *gin.Context) {
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.PureJSON(http.StatusBadRequest, APIResponse{
+			Code: 400,
+			Msg:  "Invalid request format",
+		})
+		return
+	}
+
+	token, user, err := h.userSvc.Login(c.Request.Context(), req.Username, req.Password)
+	if err != nil {
+		c.PureJSON(http.StatusUnauthorized, APIResponse{
+			Code: 401,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "success",
+		Data: gin.H{
+			"token": token,
+			"user":  user,
+		},
+	})
+}
+
+// Me 获取当前登录玩家信息
+// GET /api/me（封榜后 total_points 为封榜时间之前的得分）
+func (h *UserHandler) Me(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	user, err := h.userSvc.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		c.PureJSON(http.StatusNotFound, APIResponse{
+			Code: 404,
+			Msg:  "用户不存在",
+		})
+		return
+	}
+	// 封榜后总分只计封榜时间之前的得分
+	if err := h.scoreboardSvc.ApplyFreeze(c.Request.Context(), user); err != nil {
+		logger.Error(c.Request.Context(), "Failed to apply scoreboard freeze", "user_id", userID, "error", err)
+		c.PureJSON(http.StatusInternalServerError, APIResponse{
+			Code: 500,
+			Msg:  "获取用户信息失败",
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, APIResponse{
+		Code: 200,
+		Msg:  "success",
+		Data: user,
+	})
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"cyber-range/pkg/jwt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserAuth 玩家认证中间件
+func UserAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 从 Header 获取 Token
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code": 401,
+				"msg":  "请先登录",
+			})
+			return
+		}
+
+		// Bearer Token 格式
+		parts := strings.SplitN(authHeader, " ", 2)
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code": 401,
+				"msg":  "token格式错误",
+			})
+			return
+		}
+
+		// 解析 Token
+		claims, err := jwt.ParseUserToken(parts[1])
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code": 401,
+				"msg":  "token无效或已过期",
+			})
+			return
+		}
+
+		// 将玩家信息存入上下文（LoggerMiddleware 会读取 user_id 写入 API 日志）
+		c.Set("user_id", claims.UserID)
+		c.Set("username", claims.Username)
+
+		c.Next()
+	}
+}
+
+// GetUserID 从Context获取玩家ID
+func GetUserID(c *gin.Context) (string, bool) {
+	userIDVal, exists := c.Get("user_id")
+	if !exists {
+		return "", false
+	}
+	userID, ok := userIDVal.(string)
+	if !ok || userID == "" {
+		return "", false
+	}
+	return userID, true
+}
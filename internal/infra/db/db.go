@@ -52,6 +52,15 @@ func InitDB(ctx context.Context, cfg *config.MySQLConfig) (*gorm.DB, error) {
 		&model.Submission{},
 		&model.Admin{},
 		&model.DockerImage{}, // 添加 DockerImage 表
+		&model.Team{},
+		&model.Event{},
+		&model.EventChallenge{},
+		&model.EventParticipant{},
+		&model.Attachment{},
+		&model.Hint{},
+		&model.HintUnlock{},
+		&model.ChallengePrerequisite{},
+		&model.ServiceDefinition{},
 	); err != nil {
 		return nil, fmt.Errorf("failed to auto-migrate: %w", err)
 	}
@@ -132,6 +132,35 @@ func (r *Repository) ToggleDockerHostEnabled(ctx context.Context, id string) err
 	return nil
 }
 
+// CountRunningInstancesByHost 统计各 Docker 主机上运行中的实例数（hostID -> 数量）
+func (r *Repository) CountRunningInstancesByHost(ctx context.Context) (map[string]int, error) {
+	var rows []struct {
+		DockerHostID string
+		Count        int
+	}
+	if err := r.db.WithContext(ctx).Model(&model.Instance{}).
+		Select("docker_host_id, COUNT(*) AS count").
+		Where("status = ?", "running").
+		Group("docker_host_id").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("统计主机实例数失败: %w", err)
+	}
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.DockerHostID] = row.Count
+	}
+	return counts, nil
+}
+
+// GetRunningInstances 获取所有运行中的实例
+func (r *Repository) GetRunningInstances(ctx context.Context) ([]*model.Instance, error) {
+	var instances []*model.Instance
+	if err := r.db.WithContext(ctx).Where("status = ?", "running").Find(&instances).Error; err != nil {
+		return nil, fmt.Errorf("获取运行中实例失败: %w", err)
+	}
+	return instances, nil
+}
+
 // ===== 题目管理 =====
 
 // GetChallengeByID 根据 ID 获取题目
@@ -2,14 +2,19 @@ package docker
 
 import (
 	"context"
+	"cyber-range/internal/model"
 	"cyber-range/pkg/config"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
+	"net"
+	"sync"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
 )
@@ -21,6 +26,10 @@ type DockerClient struct {
 	portRangeMax int     // 端口范围最大值
 	memoryLimit  int64   // 内存限制（字节）
 	cpuLimit     float64 // CPU 限制（核心数）
+	ports        PortLeaser
+
+	networkPool       *net.IPNet // 实例网络地址池，为空时使用 Docker 默认地址池
+	egressHelperImage string     // 出站规则辅助镜像
 }
 
 // NewDockerClient 构造Docker客户端，支持本地/远程模式配置
@@ -66,7 +75,7 @@ func NewDockerClient(cfg *config.DockerConfig) (*DockerClient, error) {
 	}, nil
 }
 
-// AllocatePort 从配置的端口范围中随机分配一个端口
+// AllocatePort 从配置的端口范围中随机分配一个端口（未配置端口租约时使用，不检查端口是否占用）
 func (d *DockerClient) AllocatePort() int {
 	portRange := d.portRangeMax - d.portRangeMin + 1
 	return d.portRangeMin + rand.Intn(portRange)
@@ -77,63 +86,202 @@ func (d *DockerClient) Ping(ctx context.Context) (interface{}, error) {
 	return d.cli.Ping(ctx)
 }
 
-// StartContainer 启动容器
-func (d *DockerClient) StartContainer(ctx context.Context, imageName string, envVars []string, containerPort int, privileged bool, memoryLimit int64, cpuLimit float64) (string, int, error) {
+// StartContainer 启动容器，返回容器ID与端口映射
+// 每个实例使用独立的 bridge 网络，随容器创建与删除；出站访问按 spec.Egress 控制
+func (d *DockerClient) StartContainer(ctx context.Context, spec ContainerSpec) (string, []model.InstancePort, error) {
+	started, err := d.StartServices(ctx, ServiceGroupSpec{
+		Services: []ServiceSpec{{
+			Image:       spec.Image,
+			Env:         spec.Env,
+			Ports:       spec.Ports,
+			Privileged:  spec.Privileged,
+			MemoryLimit: spec.MemoryLimit,
+			CPULimit:    spec.CPULimit,
+		}},
+		Egress:          spec.Egress,
+		EgressAllowlist: spec.EgressAllowlist,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return started[0].ContainerID, started[0].Ports, nil
+}
+
+// StartServices 在新建的实例网络中按顺序启动一组服务容器
+// 任一步骤失败时删除已创建的容器与网络并释放端口租约
+func (d *DockerClient) StartServices(ctx context.Context, spec ServiceGroupSpec) (started []StartedService, err error) {
 	// 1. 确保镜像存在（优化：使用 EnsureImage）
-	if err := d.EnsureImage(ctx, imageName); err != nil {
-		return "", 0, fmt.Errorf("镜像准备失败: %w", err)
+	for _, svc := range spec.Services {
+		if err := d.EnsureImage(ctx, svc.Image); err != nil {
+			return nil, fmt.Errorf("镜像准备失败: %w", err)
+		}
+	}
+	if spec.Egress == "" {
+		spec.Egress = model.EgressInternet
 	}
 
-	// 2. 分配端口
-	allocatedPort := d.AllocatePort()
+	// 特权容器可修改宿主机网络规则，不能限制出站
+	if spec.Egress != model.EgressInternet {
+		for _, svc := range spec.Services {
+			if svc.Privileged {
+				return nil, errors.New("特权容器不支持限制出站网络")
+			}
+		}
+	}
 
-	// 3. 资源限制优先级: 参数传入 > Docker Host 配置 > 默认值
-	effectiveMemory := d.memoryLimit
-	effectiveCPU := d.cpuLimit
-	if memoryLimit > 0 {
-		effectiveMemory = memoryLimit
+	// 2. 创建实例专属网络（限制出站时同时写入宿主机出站规则）
+	networkName, err := d.createInstanceNetwork(ctx, spec.Egress, spec.EgressAllowlist)
+	if err != nil {
+		return nil, err
 	}
-	if cpuLimit > 0 {
-		effectiveCPU = cpuLimit
+	defer func() {
+		if err != nil {
+			cleanupCtx := context.WithoutCancel(ctx)
+			for _, svc := range started {
+				d.removeContainer(cleanupCtx, svc.ContainerID)
+			}
+			d.removeNetwork(cleanupCtx, networkName)
+		}
+	}()
+
+	// 3. 按顺序创建并启动服务容器
+	for _, svc := range spec.Services {
+		result, err := d.startService(ctx, networkName, svc)
+		if err != nil {
+			if svc.Name != "" {
+				err = fmt.Errorf("服务 %s: %w", svc.Name, err)
+			}
+			return started, err
+		}
+		started = append(started, result)
+	}
+
+	return started, nil
+}
+
+// startService 为每个暴露端口分配宿主机端口并创建服务容器
+// 端口被外部进程占用时标记冲突并重新分配端口重试
+func (d *DockerClient) startService(ctx context.Context, networkName string, svc ServiceSpec) (StartedService, error) {
+	result := StartedService{Name: svc.Name}
+
+	// 资源限制优先级: 参数传入 > Docker Host 配置 > 默认值
+	if svc.MemoryLimit <= 0 {
+		svc.MemoryLimit = d.memoryLimit
+	}
+	if svc.CPULimit <= 0 {
+		svc.CPULimit = d.cpuLimit
+	}
+
+	for attempt := 1; ; attempt++ {
+		hostPorts, err := d.leasePorts(ctx, len(svc.Ports))
+		if err != nil {
+			return result, err
+		}
+
+		containerID, err := d.createAndStart(ctx, networkName, svc, hostPorts)
+		if err == nil {
+			if d.ports != nil {
+				for _, hostPort := range hostPorts {
+					if err := d.ports.BindPort(ctx, d.hostID, hostPort, containerID); err != nil {
+						d.removeContainer(ctx, containerID)
+						d.releasePorts(ctx, hostPorts)
+						return result, fmt.Errorf("登记端口租约失败: %w", err)
+					}
+				}
+			}
+			result.ContainerID = containerID
+			for i, p := range svc.Ports {
+				result.Ports = append(result.Ports, model.InstancePort{
+					Label:    p.Label,
+					Service:  svc.Name,
+					Protocol: p.Protocol,
+					Port:     p.Port,
+					HostPort: hostPorts[i],
+					Template: p.Template,
+				})
+			}
+			return result, nil
+		}
+
+		conflict := isPortConflict(err)
+		if d.ports != nil {
+			for _, hostPort := range hostPorts {
+				if conflict && isConflictingPort(err, hostPort, hostPorts) {
+					d.ports.MarkPortConflict(ctx, d.hostID, hostPort)
+				} else {
+					d.ports.ReleasePort(ctx, d.hostID, hostPort)
+				}
+			}
+		}
+		if !conflict || attempt >= maxPortAttempts {
+			return result, err
+		}
 	}
+}
 
-	// 4. 构建端口配置
-	portStr := fmt.Sprintf("%d/tcp", containerPort)
-	exposedPorts := nat.PortSet{nat.Port(portStr): struct{}{}}
-	portBindings := nat.PortMap{
-		nat.Port(portStr): []nat.PortBinding{{
+// createAndStart 在实例网络中创建容器并启动，svc.Ports[i] 映射到 hostPorts[i]；启动失败时删除容器
+func (d *DockerClient) createAndStart(ctx context.Context, networkName string, svc ServiceSpec, hostPorts []int) (string, error) {
+	// 1. 构建端口配置
+	exposedPorts := nat.PortSet{}
+	portBindings := nat.PortMap{}
+	for i, p := range svc.Ports {
+		protocol := p.Protocol
+		if protocol == "" {
+			protocol = model.ProtocolTCP
+		}
+		port := nat.Port(fmt.Sprintf("%d/%s", p.Port, protocol))
+		exposedPorts[port] = struct{}{}
+		portBindings[port] = []nat.PortBinding{{
 			HostIP:   "0.0.0.0",
-			HostPort: fmt.Sprintf("%d", allocatedPort),
-		}},
+			HostPort: fmt.Sprintf("%d", hostPorts[i]),
+		}}
+	}
+
+	// 2. 服务名与别名作为实例网络内的主机名
+	endpoint := &network.EndpointSettings{}
+	if svc.Name != "" {
+		endpoint.Aliases = append([]string{svc.Name}, svc.Aliases...)
 	}
 
-	// 5. 创建容器并设置严格的资源限制
+	// 3. 创建容器并设置严格的资源限制（仅接入实例网络，不接入默认 bridge）
 	resp, err := d.cli.ContainerCreate(ctx,
 		&container.Config{
-			Image:        imageName,
-			Env:          envVars,
+			Image:        svc.Image,
+			Env:          svc.Env,
 			ExposedPorts: exposedPorts,
 		},
 		&container.HostConfig{
 			// 关键：资源约束，防止DoS攻击
 			Resources: container.Resources{
-				Memory:   effectiveMemory,           // 内存限制
-				NanoCPUs: int64(effectiveCPU * 1e9), // CPU 限制
+				Memory:   svc.MemoryLimit,           // 内存限制
+				NanoCPUs: int64(svc.CPULimit * 1e9), // CPU 限制
 			},
 			PortBindings: portBindings,
-			Privileged:   privileged, // 特权模式
-		}, nil, nil, "")
+			Privileged:   svc.Privileged, // 特权模式
+			NetworkMode:  container.NetworkMode(networkName),
+		},
+		&network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{networkName: endpoint},
+		}, nil, "")
 
 	if err != nil {
-		return "", 0, fmt.Errorf("容器创建失败: %w", err)
+		return "", fmt.Errorf("容器创建失败: %w", err)
 	}
 
-	// 5. 启动容器
+	// 4. 启动容器
+	ReportStage(ctx, StageStarting)
 	if err := d.cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
-		return "", 0, fmt.Errorf("启动容器失败: %w", err)
+		d.cli.ContainerRemove(context.WithoutCancel(ctx), resp.ID, container.RemoveOptions{Force: true})
+		return "", fmt.Errorf("启动容器失败: %w", err)
 	}
 
-	return resp.ID, allocatedPort, nil
+	return resp.ID, nil
+}
+
+// removeContainer 强制删除容器并释放其端口租约（用于启动失败时的清理）
+func (d *DockerClient) removeContainer(ctx context.Context, containerID string) {
+	d.cli.ContainerRemove(ctx, containerID, container.RemoveOptions{Force: true})
+	d.releaseContainerPorts(ctx, containerID)
 }
 
 // EnsureImage 确保镜像存在（不存在则拉取）
@@ -167,16 +315,55 @@ func (d *DockerClient) HasLocalImage(ctx context.Context, imageName string) bool
 	return err == nil
 }
 
-// StopContainer 强制停止并删除容器
+// StopContainer 强制停止并删除容器，同时删除实例网络并释放端口租约
 func (d *DockerClient) StopContainer(ctx context.Context, containerID string) error {
-	// 强制停止（跳过优雅关闭，提高安全性）
-	if err := d.cli.ContainerStop(ctx, containerID, container.StopOptions{}); err != nil {
-		return fmt.Errorf("容器停止失败: %w", err)
+	return d.StopContainers(ctx, []string{containerID})
+}
+
+// StopContainers 强制停止并删除一组容器（多容器实例），释放端口租约后删除它们所在的实例网络
+// 单个容器失败时继续处理其余容器，返回遇到的全部错误
+func (d *DockerClient) StopContainers(ctx context.Context, containerIDs []string) error {
+	// 删除容器前记录其实例网络（容器已不存在时由 Reaper 清理残留网络）
+	var networks []string
+	seen := make(map[string]bool)
+	for _, containerID := range containerIDs {
+		names, _ := d.instanceNetworksOf(ctx, containerID)
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				networks = append(networks, name)
+			}
+		}
 	}
 
-	// 删除容器以释放资源
-	if err := d.cli.ContainerRemove(ctx, containerID, container.RemoveOptions{Force: true}); err != nil {
-		return fmt.Errorf("容器删除失败: %w", err)
+	var errs []error
+	for _, containerID := range containerIDs {
+		// 强制停止（跳过优雅关闭，提高安全性）；容器已不存在时仅释放端口租约
+		if err := d.cli.ContainerStop(ctx, containerID, container.StopOptions{}); err != nil && !client.IsErrNotFound(err) {
+			errs = append(errs, fmt.Errorf("容器停止失败: %w", err))
+			continue
+		}
+
+		// 删除容器以释放资源
+		if err := d.cli.ContainerRemove(ctx, containerID, container.RemoveOptions{Force: true}); err != nil && !client.IsErrNotFound(err) {
+			errs = append(errs, fmt.Errorf("容器删除失败: %w", err))
+			continue
+		}
+
+		// 释放端口租约
+		if err := d.releaseContainerPorts(ctx, containerID); err != nil {
+			errs = append(errs, fmt.Errorf("释放端口租约失败: %w", err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	// 删除实例网络（失败时由 Reaper 清理）
+	for _, name := range networks {
+		if err := d.removeNetwork(ctx, name); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -240,6 +427,56 @@ func (d *DockerClient) GetContainerStats(ctx context.Context, containerID string
 	}, nil
 }
 
+// hostStatsConcurrency 汇总主机容器内存用量时的并发请求数
+const hostStatsConcurrency = 8
+
+// HostResources 获取主机总内存、CPU 核心数与运行中容器的内存用量合计
+func (d *DockerClient) HostResources(ctx context.Context) (*HostResources, error) {
+	info, err := d.cli.Info(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取主机信息失败: %w", err)
+	}
+	containers, err := d.cli.ContainerList(ctx, container.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("获取容器列表失败: %w", err)
+	}
+
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		usage int64
+		sem   = make(chan struct{}, hostStatsConcurrency)
+	)
+	for _, c := range containers {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(containerID string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			resp, err := d.cli.ContainerStatsOneShot(ctx, containerID)
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close()
+			var stats container.StatsResponse
+			if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+				return
+			}
+			mu.Lock()
+			usage += int64(stats.MemoryStats.Usage)
+			mu.Unlock()
+		}(c.ID)
+	}
+	wg.Wait()
+
+	return &HostResources{
+		MemoryTotal: info.MemTotal,
+		MemoryUsage: usage,
+		CPUs:        info.NCPU,
+		Containers:  len(containers),
+	}, nil
+}
+
 // GetContainerLogs 获取容器日志
 func (d *DockerClient) GetContainerLogs(ctx context.Context, containerID string, tail int) (string, error) {
 	// 默认获取最近 200 行
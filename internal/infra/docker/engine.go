@@ -0,0 +1,112 @@
+package docker
+
+import (
+	"context"
+	"cyber-range/internal/model"
+)
+
+// ContainerEngine 题目容器的启动与销毁（由 DockerClient 实现，单元测试中可替换为 Mock）
+type ContainerEngine interface {
+	EnsureImage(ctx context.Context, imageName string) error
+	StartContainer(ctx context.Context, spec ContainerSpec) (string, []model.InstancePort, error)
+	// StartServices 在同一实例网络中按顺序启动一组服务容器，任一服务失败时删除已创建的容器与网络
+	StartServices(ctx context.Context, spec ServiceGroupSpec) ([]StartedService, error)
+	StopContainer(ctx context.Context, containerID string) error
+	// StopContainers 删除一组容器，全部删除后再删除它们所在的实例网络
+	StopContainers(ctx context.Context, containerIDs []string) error
+}
+
+// ContainerSpec 题目容器配置
+type ContainerSpec struct {
+	Image           string
+	Env             []string
+	Ports           []model.ChallengePort // 暴露的容器端口（每个端口映射到一个宿主机租约端口）
+	Privileged      bool                  // 特权模式
+	MemoryLimit     int64                 // 内存限制（字节），0 使用主机默认值
+	CPULimit        float64               // CPU 限制（核心数），0 使用主机默认值
+	Egress          string                // 出站网络策略（model.EgressNone/EgressInternet/EgressAllowlist）
+	EgressAllowlist []string              // allowlist 策略下允许访问的 IP、CIDR 或域名
+}
+
+// ServiceSpec 多容器题目中的单个服务容器配置
+type ServiceSpec struct {
+	Name        string   // 服务名，作为实例网络内的主机名
+	Aliases     []string // 额外的网络别名
+	Image       string
+	Env         []string
+	Ports       []model.ChallengePort // 暴露给玩家的容器端口（每个端口映射到一个宿主机租约端口），为空表示仅在实例网络内访问
+	Privileged  bool
+	MemoryLimit int64   // 内存限制（字节），0 使用主机默认值
+	CPULimit    float64 // CPU 限制（核心数），0 使用主机默认值
+}
+
+// ServiceGroupSpec 成组启动的服务容器配置，出站策略对组内全部容器生效
+type ServiceGroupSpec struct {
+	Services        []ServiceSpec
+	Egress          string
+	EgressAllowlist []string
+}
+
+// StartedService 已启动的服务容器
+type StartedService struct {
+	Name        string
+	ContainerID string
+	Ports       []model.InstancePort // 映射到宿主机的端口，未暴露端口的服务为空
+}
+
+// EngineProvider 按 Docker 主机获取容器引擎
+type EngineProvider interface {
+	GetEngine(ctx context.Context, host *model.DockerHost) (ContainerEngine, error)
+}
+
+// HostProber 调度器探测主机健康状态与资源（由 DockerClient 实现，未实现的引擎视为健康且资源未知）
+type HostProber interface {
+	Ping(ctx context.Context) (interface{}, error)
+	HostResources(ctx context.Context) (*HostResources, error)
+	HasLocalImage(ctx context.Context, imageName string) bool
+}
+
+// HostResources 主机资源概况
+type HostResources struct {
+	MemoryTotal int64 `json:"memory_total"` // 主机总内存（字节）
+	MemoryUsage int64 `json:"memory_usage"` // 运行中容器的内存用量合计（字节）
+	CPUs        int   `json:"cpus"`         // CPU 核心数
+	Containers  int   `json:"containers"`   // 运行中的容器数
+}
+
+// MemoryFree 主机可用内存估算（总内存减去容器内存用量）
+func (r *HostResources) MemoryFree() int64 {
+	return max(r.MemoryTotal-r.MemoryUsage, 0)
+}
+
+var (
+	_ ContainerEngine = (*DockerClient)(nil)
+	_ HostProber      = (*DockerClient)(nil)
+	_ EngineProvider  = (*DockerHostManager)(nil)
+)
+
+// StageStarting 容器已创建、开始启动
+const StageStarting = "starting"
+
+type stageReporterKey struct{}
+
+// WithStageReporter 在 ctx 中注入容器启动阶段回调，StartContainer 进入新阶段时调用
+func WithStageReporter(ctx context.Context, report func(stage string)) context.Context {
+	return context.WithValue(ctx, stageReporterKey{}, report)
+}
+
+// ReportStage 通知 ctx 中的阶段回调（未注入时忽略）
+func ReportStage(ctx context.Context, stage string) {
+	if report, ok := ctx.Value(stageReporterKey{}).(func(string)); ok && report != nil {
+		report(stage)
+	}
+}
+
+// GetEngine 获取指定主机的容器引擎
+func (m *DockerHostManager) GetEngine(ctx context.Context, host *model.DockerHost) (ContainerEngine, error) {
+	cli, err := m.GetOrCreateClient(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	return cli, nil
+}
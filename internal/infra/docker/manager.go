@@ -4,6 +4,7 @@ import (
 	"context"
 	"cyber-range/internal/model"
 	"fmt"
+	"net"
 	"sync"
 
 	"github.com/docker/docker/client"
@@ -12,7 +13,10 @@ import (
 // DockerHostManager 管理多个 Docker 主机客户端
 type DockerHostManager struct {
 	clients map[string]*DockerClient // hostID -> DockerClient
+	ports   PortLeaser               // 端口租约（未设置时随机分配端口）
 	mu      sync.RWMutex
+
+	egressHelperImage string // 出站规则辅助镜像
 }
 
 // NewDockerHostManager 创建 Docker 主机管理器
@@ -72,6 +76,14 @@ func (m *DockerHostManager) GetOrCreateClient(ctx context.Context, host *model.D
 		portRangeMax: host.PortRangeMax,
 		memoryLimit:  host.MemoryLimit,
 		cpuLimit:     host.CPULimit,
+		ports:        m.ports,
+
+		egressHelperImage: m.egressHelperImage,
+	}
+	if host.NetworkPool != "" {
+		if _, pool, err := net.ParseCIDR(host.NetworkPool); err == nil {
+			dc.networkPool = pool
+		}
 	}
 
 	m.clients[host.ID] = dc
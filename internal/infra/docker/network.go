@@ -0,0 +1,395 @@
+package docker
+
+import (
+	"context"
+	"crypto/rand"
+	"cyber-range/internal/model"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mathrand "math/rand/v2"
+	"net"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+)
+
+const (
+	// LabelInstanceNetwork 实例专属网络标签（值为出站策略），Reaper 按此标签清理残留网络
+	LabelInstanceNetwork = "cyber-range.instance-network"
+
+	instanceNetworkPrefix    = "cr-inst-"
+	defaultEgressHelperImage = "nicolaka/netshoot:v0.13"
+	networkPruneAge          = "5m" // 仅清理创建超过该时间且未挂载容器的网络，避免误删正在创建实例的网络
+
+	instanceSubnetBits     = 28 // 每个实例网络从主机地址池中分配的子网大小（/28，可容纳 13 个容器）
+	maxNetworkPoolAttempts = 5  // 子网与其他网络重叠（其他 API 实例并发分配）时最多尝试的子网数
+)
+
+// ErrNetworkPoolExhausted 主机实例网络地址池已无空闲子网
+var ErrNetworkPoolExhausted = errors.New("实例网络地址池已耗尽")
+
+// ValidateNetworkPool 校验主机的实例网络地址池（IPv4 CIDR，至少包含一个 /28 子网），
+// 设置了实例数上限时地址池须能容纳全部实例的网络；为空表示使用 Docker 守护进程的默认地址池
+func ValidateNetworkPool(pool string, maxInstances int) error {
+	if pool == "" {
+		return nil
+	}
+	ip, ipnet, err := net.ParseCIDR(pool)
+	if err != nil || ip.To4() == nil {
+		return errors.New("实例网络地址池必须是 IPv4 CIDR（如 10.213.0.0/16）")
+	}
+	ones, _ := ipnet.Mask.Size()
+	if ones > instanceSubnetBits {
+		return fmt.Errorf("实例网络地址池至少需要 /%d", instanceSubnetBits)
+	}
+	if subnets := 1 << (instanceSubnetBits - ones); maxInstances > subnets {
+		return fmt.Errorf("实例网络地址池只能容纳 %d 个实例网络，小于实例数上限 %d", subnets, maxInstances)
+	}
+	return nil
+}
+
+// createInstanceNetwork 为实例创建专属 bridge 网络，不同实例的网络之间由 Docker 隔离
+// egress 不为 internet 时在创建容器前于宿主机写入出站规则，规则失败时删除网络
+func (d *DockerClient) createInstanceNetwork(ctx context.Context, egress string, allowlist []string) (string, error) {
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	name := instanceNetworkPrefix + hex.EncodeToString(suffix)
+
+	options := network.CreateOptions{
+		Driver: "bridge",
+		Labels: map[string]string{LabelInstanceNetwork: egress},
+	}
+	if egress == model.EgressNone {
+		options.Options = map[string]string{"com.docker.network.bridge.enable_ip_masquerade": "false"}
+	}
+	resp, err := d.createNetwork(ctx, name, options)
+	if err != nil {
+		return "", err
+	}
+
+	if egress == model.EgressNone || egress == model.EgressAllowlist {
+		if err := d.installEgressRules(ctx, name, resp.ID, allowlist); err != nil {
+			d.removeNetwork(context.WithoutCancel(ctx), name)
+			return "", err
+		}
+	}
+	return name, nil
+}
+
+// createNetwork 创建网络；主机配置了地址池时从中分配未使用的 /28 子网，子网随网络删除释放
+// 其他 API 实例并发创建网络导致子网重叠时换一个子网重试
+func (d *DockerClient) createNetwork(ctx context.Context, name string, options network.CreateOptions) (network.CreateResponse, error) {
+	if d.networkPool == nil {
+		resp, err := d.cli.NetworkCreate(ctx, name, options)
+		if err != nil {
+			return resp, fmt.Errorf("创建实例网络失败（未配置实例网络地址池时需调大 Docker 的 default-address-pools）: %w", err)
+		}
+		return resp, nil
+	}
+
+	used, err := d.usedSubnets(ctx)
+	if err != nil {
+		return network.CreateResponse{}, err
+	}
+	for attempt := 0; attempt < maxNetworkPoolAttempts; attempt++ {
+		subnet := freeSubnet(d.networkPool, used)
+		if subnet == "" {
+			return network.CreateResponse{}, fmt.Errorf("%w（%s）", ErrNetworkPoolExhausted, d.networkPool)
+		}
+		options.IPAM = &network.IPAM{Config: []network.IPAMConfig{{Subnet: subnet}}}
+		resp, err := d.cli.NetworkCreate(ctx, name, options)
+		if err == nil {
+			return resp, nil
+		}
+		if !strings.Contains(err.Error(), "overlap") {
+			return resp, fmt.Errorf("创建实例网络失败: %w", err)
+		}
+		used[subnet] = true
+	}
+	return network.CreateResponse{}, fmt.Errorf("%w（%s）", ErrNetworkPoolExhausted, d.networkPool)
+}
+
+// usedSubnets 主机上全部网络已使用的 IPv4 子网
+func (d *DockerClient) usedSubnets(ctx context.Context) (map[string]bool, error) {
+	networks, err := d.cli.NetworkList(ctx, network.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("获取主机网络失败: %w", err)
+	}
+	used := make(map[string]bool)
+	for _, n := range networks {
+		for _, cfg := range n.IPAM.Config {
+			used[cfg.Subnet] = true
+		}
+	}
+	return used, nil
+}
+
+// freeSubnet 从地址池中随机位置开始查找未使用且不与已用网段重叠的 /28 子网，没有时返回空
+func freeSubnet(pool *net.IPNet, used map[string]bool) string {
+	var taken []*net.IPNet
+	for cidr := range used {
+		if _, n, err := net.ParseCIDR(cidr); err == nil {
+			taken = append(taken, n)
+		}
+	}
+
+	ones, _ := pool.Mask.Size()
+	total := uint32(1) << (instanceSubnetBits - ones)
+	base := binary.BigEndian.Uint32(pool.IP.To4())
+	start := mathrand.Uint32N(total)
+	for i := uint32(0); i < total; i++ {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, base+((start+i)%total)<<(32-instanceSubnetBits))
+		candidate := &net.IPNet{IP: ip, Mask: net.CIDRMask(instanceSubnetBits, 32)}
+		overlaps := false
+		for _, n := range taken {
+			if n.Contains(candidate.IP) || candidate.Contains(n.IP) {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			return candidate.String()
+		}
+	}
+	return ""
+}
+
+// removeNetwork 删除网络并清理其出站规则，网络已不存在时忽略
+// 先删除网络再清理规则，网络删除失败（仍有容器挂载）时规则继续生效
+func (d *DockerClient) removeNetwork(ctx context.Context, name string) error {
+	info, err := d.cli.NetworkInspect(ctx, name, network.InspectOptions{})
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("删除实例网络失败: %w", err)
+	}
+	if err := d.cli.NetworkRemove(ctx, name); err != nil && !client.IsErrNotFound(err) {
+		return fmt.Errorf("删除实例网络失败: %w", err)
+	}
+	if hasEgressRules(info.Labels[LabelInstanceNetwork]) {
+		return d.removeEgressRules(ctx, name)
+	}
+	return nil
+}
+
+// instanceNetworksOf 获取容器所在的实例专属网络
+func (d *DockerClient) instanceNetworksOf(ctx context.Context, containerID string) ([]string, error) {
+	info, err := d.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	if info.NetworkSettings != nil {
+		for name := range info.NetworkSettings.Networks {
+			if strings.HasPrefix(name, instanceNetworkPrefix) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// PruneInstanceNetworks 清理未挂载容器的实例网络（容器异常退出或删除失败时残留），返回清理数量
+func (d *DockerClient) PruneInstanceNetworks(ctx context.Context) (int, error) {
+	// 清理前记录带出站规则的网络，删除后一并清理宿主机上的规则
+	networks, err := d.cli.NetworkList(ctx, network.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", LabelInstanceNetwork)),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("清理实例网络失败: %w", err)
+	}
+	restricted := make(map[string]bool)
+	for _, n := range networks {
+		if hasEgressRules(n.Labels[LabelInstanceNetwork]) {
+			restricted[n.Name] = true
+		}
+	}
+
+	report, err := d.cli.NetworksPrune(ctx, filters.NewArgs(
+		filters.Arg("label", LabelInstanceNetwork),
+		filters.Arg("until", networkPruneAge),
+	))
+	if err != nil {
+		return 0, fmt.Errorf("清理实例网络失败: %w", err)
+	}
+	var errs []error
+	for _, name := range report.NetworksDeleted {
+		if restricted[name] {
+			if err := d.removeEgressRules(ctx, name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return len(report.NetworksDeleted), errors.Join(errs...)
+}
+
+// hasEgressRules 出站策略是否需要在宿主机写入规则
+func hasEgressRules(egress string) bool {
+	return egress == model.EgressNone || egress == model.EgressAllowlist
+}
+
+// egressChains 实例网络在宿主机上的出站规则链名称：转发流量（DOCKER-USER）与访问宿主机本身的流量（INPUT）
+func egressChains(networkName string) (forward, input string) {
+	suffix := strings.TrimPrefix(networkName, instanceNetworkPrefix)
+	return "CR-" + suffix, "CRI-" + suffix
+}
+
+// installEgressRules 在宿主机为实例网络写入出站规则：
+// DOCKER-USER 链放行网络内部流量、已建立连接的回包和白名单地址，丢弃该网段发起的其他转发流量；
+// INPUT 链只放行已建立连接的回包和白名单地址，禁止实例访问宿主机上的服务（Docker API、数据库等）
+// 规则在创建容器前写入，且位于宿主机网络命名空间，容器内无法修改；域名在平台侧解析为 IPv4 地址
+func (d *DockerClient) installEgressRules(ctx context.Context, networkName, networkID string, allowlist []string) error {
+	targets, err := resolveAllowlist(ctx, allowlist)
+	if err != nil {
+		return err
+	}
+
+	info, err := d.cli.NetworkInspect(ctx, networkID, network.InspectOptions{})
+	if err != nil {
+		return fmt.Errorf("获取实例网络信息失败: %w", err)
+	}
+	var subnet string
+	for _, cfg := range info.IPAM.Config {
+		if ip, _, err := net.ParseCIDR(cfg.Subnet); err == nil && ip.To4() != nil {
+			subnet = cfg.Subnet
+			break
+		}
+	}
+	if subnet == "" {
+		return fmt.Errorf("实例网络 %s 没有 IPv4 网段", networkName)
+	}
+
+	forward, input := egressChains(networkName)
+	rules := []string{
+		"set -e",
+		"iptables -N " + forward,
+		"iptables -A " + forward + " -s " + subnet + " -d " + subnet + " -j RETURN",
+		"iptables -A " + forward + " -m conntrack --ctstate ESTABLISHED,RELATED -j RETURN",
+		"iptables -N " + input,
+		"iptables -A " + input + " -s " + subnet + " -m conntrack --ctstate ESTABLISHED,RELATED -j RETURN",
+	}
+	for _, target := range targets {
+		rules = append(rules,
+			"iptables -A "+forward+" -s "+subnet+" -d "+target+" -j RETURN",
+			"iptables -A "+input+" -s "+subnet+" -d "+target+" -j RETURN",
+		)
+	}
+	rules = append(rules,
+		"iptables -A "+forward+" -s "+subnet+" -j DROP",
+		"iptables -A "+input+" -s "+subnet+" -j DROP",
+		"iptables -I DOCKER-USER -j "+forward,
+		"iptables -I INPUT -j "+input,
+	)
+	if err := d.runEgressHelper(ctx, strings.Join(rules, "\n")); err != nil {
+		d.removeEgressRules(context.WithoutCancel(ctx), networkName)
+		return err
+	}
+	return nil
+}
+
+// removeEgressRules 删除实例网络在宿主机上的出站规则，规则不存在时忽略
+func (d *DockerClient) removeEgressRules(ctx context.Context, networkName string) error {
+	forward, input := egressChains(networkName)
+	script := strings.Join([]string{
+		"while iptables -D DOCKER-USER -j " + forward + " 2>/dev/null; do :; done",
+		"while iptables -D INPUT -j " + input + " 2>/dev/null; do :; done",
+		"iptables -F " + forward + " 2>/dev/null || true",
+		"iptables -X " + forward + " 2>/dev/null || true",
+		"iptables -F " + input + " 2>/dev/null || true",
+		"iptables -X " + input + " 2>/dev/null || true",
+	}, "\n")
+	return d.runEgressHelper(ctx, script)
+}
+
+// runEgressHelper 以宿主机网络模式运行辅助容器执行 iptables 脚本
+func (d *DockerClient) runEgressHelper(ctx context.Context, script string) error {
+	helperImage := d.egressHelperImage
+	if helperImage == "" {
+		helperImage = defaultEgressHelperImage
+	}
+	if err := d.EnsureImage(ctx, helperImage); err != nil {
+		return fmt.Errorf("出站规则辅助镜像准备失败: %w", err)
+	}
+
+	resp, err := d.cli.ContainerCreate(ctx,
+		&container.Config{
+			Image:      helperImage,
+			Entrypoint: []string{"sh", "-c"},
+			Cmd:        []string{script},
+		},
+		&container.HostConfig{
+			NetworkMode: network.NetworkHost,
+			CapAdd:      []string{"NET_ADMIN", "NET_RAW"},
+		}, nil, nil, "")
+	if err != nil {
+		return fmt.Errorf("创建出站规则辅助容器失败: %w", err)
+	}
+	defer d.cli.ContainerRemove(context.WithoutCancel(ctx), resp.ID, container.RemoveOptions{Force: true})
+
+	if err := d.cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("启动出站规则辅助容器失败: %w", err)
+	}
+	waitCh, errCh := d.cli.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
+	select {
+	case result := <-waitCh:
+		if result.StatusCode != 0 {
+			return fmt.Errorf("设置出站规则失败（退出码 %d）", result.StatusCode)
+		}
+		return nil
+	case err := <-errCh:
+		return fmt.Errorf("等待出站规则辅助容器失败: %w", err)
+	}
+}
+
+// resolveAllowlist 将白名单条目（IP、CIDR 或域名）解析为 iptables 目标地址
+func resolveAllowlist(ctx context.Context, allowlist []string) ([]string, error) {
+	var targets []string
+	for _, entry := range allowlist {
+		if _, _, err := net.ParseCIDR(entry); err == nil {
+			targets = append(targets, entry)
+			continue
+		}
+		if ip := net.ParseIP(entry); ip != nil {
+			targets = append(targets, ip.String())
+			continue
+		}
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, entry)
+		if err != nil {
+			return nil, fmt.Errorf("解析出站白名单域名 %s 失败: %w", entry, err)
+		}
+		for _, addr := range addrs {
+			if ip4 := addr.IP.To4(); ip4 != nil {
+				targets = append(targets, ip4.String())
+			}
+		}
+	}
+	return targets, nil
+}
+
+// SetEgressHelperImage 设置出站规则使用的辅助镜像（需包含 sh 与 iptables），为空时使用默认镜像
+func (m *DockerHostManager) SetEgressHelperImage(image string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.egressHelperImage = image
+	for _, cli := range m.clients {
+		cli.egressHelperImage = image
+	}
+}
+
+// PruneInstanceNetworks 清理指定主机上未挂载容器的实例网络
+func (m *DockerHostManager) PruneInstanceNetworks(ctx context.Context, host *model.DockerHost) (int, error) {
+	cli, err := m.GetOrCreateClient(ctx, host)
+	if err != nil {
+		return 0, err
+	}
+	return cli.PruneInstanceNetworks(ctx)
+}
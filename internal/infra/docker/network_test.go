@@ -0,0 +1,44 @@
+package docker
+
+import (
+	"net"
+	"testing"
+)
+
+func TestValidateNetworkPool(t *testing.T) {
+	tests := []struct {
+		pool         string
+		maxInstances int
+		wantErr      bool
+	}{
+		{"", 100, false},
+		{"10.213.0.0/16", 0, false},
+		{"10.213.0.0/24", 16, false},
+		{"10.213.0.0/24", 17, true},
+		{"10.213.0.0/29", 0, true},
+		{"fd00::/64", 0, true},
+		{"10.213.0.0", 0, true},
+	}
+	for _, tt := range tests {
+		if err := ValidateNetworkPool(tt.pool, tt.maxInstances); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateNetworkPool(%q, %d) error = %v, wantErr %v", tt.pool, tt.maxInstances, err, tt.wantErr)
+		}
+	}
+}
+
+func TestFreeSubnet(t *testing.T) {
+	_, pool, _ := net.ParseCIDR("10.213.0.0/26")
+	used := map[string]bool{"10.213.0.0/28": true, "10.213.0.32/27": true, "172.17.0.0/16": true}
+
+	// 地址池中只剩 10.213.0.16/28 未被占用（含与更大网段重叠的子网）
+	for i := 0; i < 10; i++ {
+		if got := freeSubnet(pool, used); got != "10.213.0.16/28" {
+			t.Fatalf("freeSubnet() = %q, want 10.213.0.16/28", got)
+		}
+	}
+
+	used["10.213.0.16/28"] = true
+	if got := freeSubnet(pool, used); got != "" {
+		t.Errorf("地址池耗尽时应返回空, got %q", got)
+	}
+}
@@ -0,0 +1,202 @@
+package docker
+
+import (
+	"context"
+	"cyber-range/internal/model"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// maxPortAttempts 端口被占用（bind 冲突）时最多尝试的端口数
+const maxPortAttempts = 5
+
+// ErrPortsExhausted 主机端口范围内已无空闲端口
+var ErrPortsExhausted = errors.New("主机端口已耗尽")
+
+// PortLeaser 主机端口租约登记（由 Redis 实现，多个 API 实例共享）
+type PortLeaser interface {
+	// LeasePort 分配空闲端口并登记为 pending，端口耗尽时返回 0
+	LeasePort(ctx context.Context, hostID string, min, max int) (int, error)
+	// BindPort 容器创建成功后将租约绑定到容器
+	BindPort(ctx context.Context, hostID string, port int, containerID string) error
+	// MarkPortConflict 标记端口被外部进程占用，暂停分配
+	MarkPortConflict(ctx context.Context, hostID string, port int) error
+	ReleasePort(ctx context.Context, hostID string, port int) error
+	ReleaseContainerPorts(ctx context.Context, hostID, containerID string) error
+	// ResetPortLeases 按运行中容器占用的端口重建租约（端口 -> 容器ID）
+	ResetPortLeases(ctx context.Context, hostID string, leases map[int]string) error
+	// GetPortLeases 获取有效租约（端口 -> 容器ID / "pending" / "conflict"）
+	GetPortLeases(ctx context.Context, hostID string) (map[int]string, error)
+}
+
+// PortUsage 主机端口范围使用情况
+type PortUsage struct {
+	HostID       string  `json:"host_id"`
+	HostName     string  `json:"host_name"`
+	PortRangeMin int     `json:"port_range_min"`
+	PortRangeMax int     `json:"port_range_max"`
+	Total        int     `json:"total"`     // 端口范围内的端口总数
+	Leased       int     `json:"leased"`    // 已绑定容器的端口
+	Pending      int     `json:"pending"`   // 已分配、容器创建中的端口
+	Conflict     int     `json:"conflict"`  // 被外部进程占用、暂停分配的端口
+	Available    int     `json:"available"` // 可分配的端口
+	UsagePercent float64 `json:"usage_percent"`
+}
+
+// leasePort 为新容器分配端口，未配置租约时退化为随机分配
+func (d *DockerClient) leasePort(ctx context.Context) (int, error) {
+	if d.ports == nil {
+		return d.AllocatePort(), nil
+	}
+	port, err := d.ports.LeasePort(ctx, d.hostID, d.portRangeMin, d.portRangeMax)
+	if err != nil {
+		return 0, fmt.Errorf("分配端口失败: %w", err)
+	}
+	if port == 0 {
+		return 0, fmt.Errorf("%w（%d-%d）", ErrPortsExhausted, d.portRangeMin, d.portRangeMax)
+	}
+	return port, nil
+}
+
+// leasePorts 为容器的多个暴露端口分配宿主机端口，任一端口分配失败时释放已分配的端口
+func (d *DockerClient) leasePorts(ctx context.Context, n int) ([]int, error) {
+	hostPorts := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		hostPort, err := d.leasePort(ctx)
+		if err != nil {
+			d.releasePorts(ctx, hostPorts)
+			return nil, err
+		}
+		hostPorts = append(hostPorts, hostPort)
+	}
+	return hostPorts, nil
+}
+
+// releasePorts 释放未绑定容器的端口租约
+func (d *DockerClient) releasePorts(ctx context.Context, hostPorts []int) {
+	if d.ports == nil {
+		return
+	}
+	for _, hostPort := range hostPorts {
+		d.ports.ReleasePort(ctx, d.hostID, hostPort)
+	}
+}
+
+// isPortConflict 判断容器启动失败是否由宿主机端口被占用导致
+func isPortConflict(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "port is already allocated") || strings.Contains(msg, "address already in use")
+}
+
+// bindAddressPattern 端口冲突错误信息中的监听地址端口（如 "0.0.0.0:20001"）
+var bindAddressPattern = regexp.MustCompile(`:(\d+)\b`)
+
+// isConflictingPort 判断端口冲突错误是否由 hostPort 导致，错误信息中找不到已分配的端口时视为全部冲突
+func isConflictingPort(err error, hostPort int, hostPorts []int) bool {
+	leased := make(map[int]bool, len(hostPorts))
+	for _, p := range hostPorts {
+		leased[p] = true
+	}
+	conflicts := make(map[int]bool)
+	for _, m := range bindAddressPattern.FindAllStringSubmatch(err.Error(), -1) {
+		if p, _ := strconv.Atoi(m[1]); leased[p] {
+			conflicts[p] = true
+		}
+	}
+	return len(conflicts) == 0 || conflicts[hostPort]
+}
+
+// releaseContainerPorts 容器删除后释放其端口租约
+func (d *DockerClient) releaseContainerPorts(ctx context.Context, containerID string) error {
+	if d.ports == nil {
+		return nil
+	}
+	return d.ports.ReleaseContainerPorts(ctx, d.hostID, containerID)
+}
+
+// SyncPortLeases 按主机上运行中容器实际占用的端口重建端口租约（服务启动时调用）
+func (d *DockerClient) SyncPortLeases(ctx context.Context) (int, error) {
+	if d.ports == nil {
+		return 0, nil
+	}
+	containers, err := d.cli.ContainerList(ctx, container.ListOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("获取容器列表失败: %w", err)
+	}
+	leases := make(map[int]string)
+	for _, c := range containers {
+		for _, p := range c.Ports {
+			port := int(p.PublicPort)
+			if port >= d.portRangeMin && port <= d.portRangeMax {
+				leases[port] = c.ID
+			}
+		}
+	}
+	if err := d.ports.ResetPortLeases(ctx, d.hostID, leases); err != nil {
+		return 0, fmt.Errorf("重建端口租约失败: %w", err)
+	}
+	return len(leases), nil
+}
+
+// SetPortLeaser 设置端口租约登记，之后创建的客户端通过租约分配端口
+func (m *DockerHostManager) SetPortLeaser(ports PortLeaser) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ports = ports
+	for _, cli := range m.clients {
+		cli.ports = ports
+	}
+}
+
+// SyncPortLeases 按主机上运行中的容器重建端口租约，返回占用的端口数
+func (m *DockerHostManager) SyncPortLeases(ctx context.Context, host *model.DockerHost) (int, error) {
+	cli, err := m.GetOrCreateClient(ctx, host)
+	if err != nil {
+		return 0, err
+	}
+	return cli.SyncPortLeases(ctx)
+}
+
+// PortUsage 统计主机端口范围的使用情况（租约在端口范围外的不计入）
+func (m *DockerHostManager) PortUsage(ctx context.Context, host *model.DockerHost) (*PortUsage, error) {
+	usage := &PortUsage{
+		HostID:       host.ID,
+		HostName:     host.Name,
+		PortRangeMin: host.PortRangeMin,
+		PortRangeMax: host.PortRangeMax,
+		Total:        host.PortRangeMax - host.PortRangeMin + 1,
+	}
+	m.mu.RLock()
+	ports := m.ports
+	m.mu.RUnlock()
+	if ports != nil {
+		leases, err := ports.GetPortLeases(ctx, host.ID)
+		if err != nil {
+			return nil, err
+		}
+		for port, owner := range leases {
+			if port < host.PortRangeMin || port > host.PortRangeMax {
+				continue
+			}
+			switch owner {
+			case "pending":
+				usage.Pending++
+			case "conflict":
+				usage.Conflict++
+			default:
+				usage.Leased++
+			}
+		}
+	}
+	usage.Available = usage.Total - usage.Leased - usage.Pending - usage.Conflict
+	if usage.Total > 0 {
+		usage.UsagePercent = math.Round(float64(usage.Total-usage.Available)/float64(usage.Total)*10000) / 100
+	}
+	return usage, nil
+}
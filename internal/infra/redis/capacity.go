@@ -0,0 +1,175 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Host capacity keys
+const (
+	KeyHostReservationsPrefix = "host_reservations:" // host_reservations:{docker_host_id} (HASH field=实例ID value=内存字节:CPU毫核[:预留时间ms])
+	reservationPendingTTL     = 15 * time.Minute     // 未确认的预留最长保留时间（覆盖拉取镜像与创建容器），超时后释放
+)
+
+// Host capacity violation kinds
+const (
+	CapacityInstances = "instances" // 实例数已达上限
+	CapacityMemory    = "memory"    // 预留内存已达上限
+	CapacityCPU       = "cpu"       // 预留 CPU 已达上限
+)
+
+// HostReservation 实例在主机上预留的资源
+type HostReservation struct {
+	Memory   int64 // 内存（字节）
+	MilliCPU int64 // CPU（千分之一核）
+}
+
+// HostCapacity 主机容量上限（0 表示不限制）
+type HostCapacity struct {
+	MaxInstances int
+	MaxMemory    int64
+	MaxMilliCPU  int64
+}
+
+// HostUsage 主机已预留的资源合计（含创建中的实例）
+type HostUsage struct {
+	Instances int
+	Memory    int64
+	MilliCPU  int64
+}
+
+// reservationUsage Lua 函数：汇总主机预留，删除超时未确认的预留
+const reservationUsage = `
+local function usage(key, now, pendingTTL)
+  local n, mem, cpu = 0, 0, 0
+  local entries = redis.call('HGETALL', key)
+  for i = 1, #entries, 2 do
+    local m, c, ts = string.match(entries[i + 1], '^(%d+):(%d+):?(%d*)$')
+    if not m or (ts ~= '' and tonumber(ts) + pendingTTL < now) then
+      redis.call('HDEL', key, entries[i])
+    else
+      n = n + 1
+      mem = mem + tonumber(m)
+      cpu = cpu + tonumber(c)
+    end
+  end
+  return n, mem, cpu
+end
+`
+
+// reserveHostScript 原子地检查主机容量并登记未确认的预留
+// ARGV: now, pendingTTL, 实例ID, 内存, CPU 毫核, 实例数上限, 内存上限, CPU 上限
+// 返回 0 通过，1 实例数上限，2 内存上限，3 CPU 上限
+var reserveHostScript = redis.NewScript(reservationUsage + `
+local now, pendingTTL = tonumber(ARGV[1]), tonumber(ARGV[2])
+local mem, cpu = tonumber(ARGV[4]), tonumber(ARGV[5])
+local maxN, maxMem, maxCPU = tonumber(ARGV[6]), tonumber(ARGV[7]), tonumber(ARGV[8])
+redis.call('HDEL', KEYS[1], ARGV[3])
+local n, usedMem, usedCPU = usage(KEYS[1], now, pendingTTL)
+local code = 0
+if maxN > 0 and n + 1 > maxN then
+  code = 1
+elseif maxMem > 0 and usedMem + mem > maxMem then
+  code = 2
+elseif maxCPU > 0 and usedCPU + cpu > maxCPU then
+  code = 3
+else
+  redis.call('HSET', KEYS[1], ARGV[3], mem .. ':' .. cpu .. ':' .. now)
+end
+return code
+`)
+
+// resetHostReservationsScript 用运行中的实例重建预留，保留仍在有效期内的未确认预留（其他 API 实例正在创建的实例）
+// ARGV: now, pendingTTL, 实例ID1, 预留1, 实例ID2, 预留2, ...
+var resetHostReservationsScript = redis.NewScript(reservationUsage + `
+local now, pendingTTL = tonumber(ARGV[1]), tonumber(ARGV[2])
+usage(KEYS[1], now, pendingTTL)
+local entries = redis.call('HGETALL', KEYS[1])
+for i = 1, #entries, 2 do
+  if not string.find(entries[i + 1], '^%d+:%d+:%d+$') then
+    redis.call('HDEL', KEYS[1], entries[i])
+  end
+end
+for i = 3, #ARGV, 2 do
+  redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+return redis.call('HLEN', KEYS[1])
+`)
+
+// ReserveHostCapacity 检查主机容量并为实例登记未确认的预留，超出上限时返回超限的资源类型（通过时为空）
+// 通过后须在实例创建成功时调用 ConfirmHostReservation，失败时调用 ReleaseHostReservation
+func ReserveHostCapacity(ctx context.Context, hostID, instanceID string, r HostReservation, limit HostCapacity) (string, error) {
+	res, err := reserveHostScript.Run(ctx, Client, []string{KeyHostReservationsPrefix + hostID},
+		time.Now().UnixMilli(), reservationPendingTTL.Milliseconds(), instanceID,
+		r.Memory, r.MilliCPU, limit.MaxInstances, limit.MaxMemory, limit.MaxMilliCPU,
+	).Int()
+	if err != nil {
+		return "", err
+	}
+	switch res {
+	case 0:
+		return "", nil
+	case 1:
+		return CapacityInstances, nil
+	case 2:
+		return CapacityMemory, nil
+	case 3:
+		return CapacityCPU, nil
+	}
+	return "", fmt.Errorf("unexpected capacity script result: %v", res)
+}
+
+// ConfirmHostReservation 实例创建成功后确认预留（不再超时释放，实例停止或回收时释放）
+func ConfirmHostReservation(ctx context.Context, hostID, instanceID string, r HostReservation) error {
+	return Client.HSet(ctx, KeyHostReservationsPrefix+hostID, instanceID, encodeReservation(r)).Err()
+}
+
+// ReleaseHostReservation 释放实例在主机上的预留
+func ReleaseHostReservation(ctx context.Context, hostID, instanceID string) error {
+	return Client.HDel(ctx, KeyHostReservationsPrefix+hostID, instanceID).Err()
+}
+
+// ResetHostReservations 按运行中的实例重建主机预留（服务启动时调用）
+func ResetHostReservations(ctx context.Context, hostID string, reservations map[string]HostReservation) error {
+	args := []interface{}{time.Now().UnixMilli(), reservationPendingTTL.Milliseconds()}
+	for instanceID, r := range reservations {
+		args = append(args, instanceID, encodeReservation(r))
+	}
+	return resetHostReservationsScript.Run(ctx, Client, []string{KeyHostReservationsPrefix + hostID}, args...).Err()
+}
+
+// GetHostUsage 汇总主机已预留的资源（超时未确认的预留不计入）
+func GetHostUsage(ctx context.Context, hostID string) (HostUsage, error) {
+	entries, err := Client.HGetAll(ctx, KeyHostReservationsPrefix+hostID).Result()
+	if err != nil {
+		return HostUsage{}, err
+	}
+	var usage HostUsage
+	stale := time.Now().Add(-reservationPendingTTL).UnixMilli()
+	for _, value := range entries {
+		parts := strings.Split(value, ":")
+		if len(parts) < 2 {
+			continue
+		}
+		if len(parts) == 3 {
+			if ts, _ := strconv.ParseInt(parts[2], 10, 64); ts < stale {
+				continue
+			}
+		}
+		mem, _ := strconv.ParseInt(parts[0], 10, 64)
+		cpu, _ := strconv.ParseInt(parts[1], 10, 64)
+		usage.Instances++
+		usage.Memory += mem
+		usage.MilliCPU += cpu
+	}
+	return usage, nil
+}
+
+func encodeReservation(r HostReservation) string {
+	return strconv.FormatInt(r.Memory, 10) + ":" + strconv.FormatInt(r.MilliCPU, 10)
+}
@@ -0,0 +1,43 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Gateway keys
+const (
+	KeyGatewayTicketPrefix  = "gateway_ticket:"  // gateway_ticket:{ticket} (值为实例ID，一次性)
+	KeyGatewaySessionPrefix = "gateway_session:" // gateway_session:{session_id} (值为实例ID)
+)
+
+// CreateGatewayTicket 创建一次性网关访问票据，玩家凭票据换取实例的访问会话
+func CreateGatewayTicket(ctx context.Context, ticket, instanceID string, ttl time.Duration) error {
+	return Client.Set(ctx, KeyGatewayTicketPrefix+ticket, instanceID, ttl).Err()
+}
+
+// ConsumeGatewayTicket 取出并删除网关访问票据，票据不存在或已使用时返回空字符串
+func ConsumeGatewayTicket(ctx context.Context, ticket string) (string, error) {
+	instanceID, err := Client.GetDel(ctx, KeyGatewayTicketPrefix+ticket).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return instanceID, err
+}
+
+// StoreGatewaySession 保存网关访问会话
+func StoreGatewaySession(ctx context.Context, sessionID, instanceID string, ttl time.Duration) error {
+	return Client.Set(ctx, KeyGatewaySessionPrefix+sessionID, instanceID, ttl).Err()
+}
+
+// GetGatewaySession 获取网关访问会话对应的实例ID，不存在时返回空字符串
+func GetGatewaySession(ctx context.Context, sessionID string) (string, error) {
+	instanceID, err := Client.Get(ctx, KeyGatewaySessionPrefix+sessionID).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return instanceID, err
+}
@@ -0,0 +1,37 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Instance job keys
+const (
+	KeyInstanceJobPrefix     = "instance_job:"        // instance_job:{job_id} (HASH 实例启动任务状态)
+	ChannelInstanceJobPrefix = "instance_job_events:" // instance_job_events:{job_id} (PUB/SUB 状态变更通知)
+)
+
+// SaveInstanceJob 写入实例启动任务字段并刷新过期时间，同时发布状态变更通知
+func SaveInstanceJob(ctx context.Context, jobID string, fields map[string]interface{}, ttl time.Duration, event []byte) error {
+	key := KeyInstanceJobPrefix + jobID
+	pipe := Client.TxPipeline()
+	pipe.HSet(ctx, key, fields)
+	pipe.Expire(ctx, key, ttl)
+	if event != nil {
+		pipe.Publish(ctx, ChannelInstanceJobPrefix+jobID, event)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetInstanceJob 获取实例启动任务，不存在（或已过期）时返回空 map
+func GetInstanceJob(ctx context.Context, jobID string) (map[string]string, error) {
+	return Client.HGetAll(ctx, KeyInstanceJobPrefix+jobID).Result()
+}
+
+// SubscribeInstanceJob 订阅实例启动任务的状态变更，调用方负责 Close
+func SubscribeInstanceJob(ctx context.Context, jobID string) *redis.PubSub {
+	return Client.Subscribe(ctx, ChannelInstanceJobPrefix+jobID)
+}
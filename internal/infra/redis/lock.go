@@ -0,0 +1,52 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Start lock & idempotency keys
+const (
+	KeyStartLockPrefix   = "lock:start:"        // lock:start:{owner_id}:{challenge_id} (值为持有者 token)
+	KeyIdempotencyPrefix = "idempotency:start:" // idempotency:start:{user_id}:{key} (值为 challenge_id|instance_id)
+)
+
+// releaseLockScript 仅当锁仍由自己持有时删除（避免误删超时后被他人获取的锁）
+var releaseLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// AcquireStartLock 获取实例启动锁（按用户或队伍 + 题目），锁在 ttl 后自动释放
+func AcquireStartLock(ctx context.Context, ownerID, challengeID, token string, ttl time.Duration) (bool, error) {
+	return Client.SetNX(ctx, KeyStartLockPrefix+ownerID+":"+challengeID, token, ttl).Result()
+}
+
+// ReleaseStartLock 释放实例启动锁
+func ReleaseStartLock(ctx context.Context, ownerID, challengeID, token string) error {
+	return releaseLockScript.Run(ctx, Client, []string{KeyStartLockPrefix + ownerID + ":" + challengeID}, token).Err()
+}
+
+// GetIdempotentStart 获取幂等键对应的启动任务，不存在时返回空字符串
+func GetIdempotentStart(ctx context.Context, userID, key string) (challengeID, jobID string, err error) {
+	val, err := Client.Get(ctx, KeyIdempotencyPrefix+userID+":"+key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	challengeID, jobID, _ = strings.Cut(val, "|")
+	return challengeID, jobID, nil
+}
+
+// SetIdempotentStart 记录幂等键对应的启动任务
+func SetIdempotentStart(ctx context.Context, userID, key, challengeID, jobID string, ttl time.Duration) error {
+	return Client.Set(ctx, KeyIdempotencyPrefix+userID+":"+key, challengeID+"|"+jobID, ttl).Err()
+}
@@ -0,0 +1,181 @@
+package redis
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Port lease keys
+const (
+	KeyPortLeasesPrefix = "port_leases:"   // port_leases:{docker_host_id} (HASH field=端口 value=容器ID / pending:{ms} / conflict:{ms})
+	portPendingTTL      = 15 * time.Minute // 未绑定容器的租约最长保留时间（覆盖拉取镜像与创建容器），超时后视为空闲
+	portConflictTTL     = 10 * time.Minute // 被外部进程占用的端口暂停分配的时间
+)
+
+// Port lease states（GetPortLeases 返回值中未绑定容器的租约）
+const (
+	PortPending  = "pending"  // 已分配，容器创建中
+	PortConflict = "conflict" // 端口被 Docker 之外的进程占用
+)
+
+// portLeaseExpired Lua 函数：pending/conflict 租约超过各自有效期后视为空闲
+const portLeaseExpired = `
+local function expired(owner, now, pendingTTL, conflictTTL)
+  local kind, ts = string.match(owner, '^(%a+):(%d+)$')
+  if not kind then
+    return false
+  end
+  local ttl = pendingTTL
+  if kind == 'conflict' then
+    ttl = conflictTTL
+  end
+  return tonumber(ts) + ttl < now
+end
+`
+
+// leasePortScript 从随机起点开始顺序查找空闲端口并登记为 pending，端口耗尽时返回 0
+var leasePortScript = redis.NewScript(portLeaseExpired + `
+local min, max, start = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+local now, pendingTTL, conflictTTL = tonumber(ARGV[4]), tonumber(ARGV[5]), tonumber(ARGV[6])
+local n = max - min + 1
+for i = 0, n - 1 do
+  local port = min + (start - min + i) % n
+  local owner = redis.call('HGET', KEYS[1], port)
+  if not owner or expired(owner, now, pendingTTL, conflictTTL) then
+    redis.call('HSET', KEYS[1], port, 'pending:' .. now)
+    return port
+  end
+end
+return 0
+`)
+
+// releaseContainerPortsScript 释放指定容器持有的全部端口
+var releaseContainerPortsScript = redis.NewScript(`
+local leases = redis.call('HGETALL', KEYS[1])
+local released = 0
+for i = 1, #leases, 2 do
+  if leases[i + 1] == ARGV[1] then
+    redis.call('HDEL', KEYS[1], leases[i])
+    released = released + 1
+  end
+end
+return released
+`)
+
+// resetPortLeasesScript 用运行中容器重建租约，保留仍在有效期内的 pending/conflict 租约（其他 API 实例正在创建的容器）
+// ARGV: now, pendingTTL, conflictTTL, port1, owner1, port2, owner2, ...
+var resetPortLeasesScript = redis.NewScript(portLeaseExpired + `
+local now, pendingTTL, conflictTTL = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+local leases = redis.call('HGETALL', KEYS[1])
+for i = 1, #leases, 2 do
+  local owner = leases[i + 1]
+  if not string.find(owner, ':') or expired(owner, now, pendingTTL, conflictTTL) then
+    redis.call('HDEL', KEYS[1], leases[i])
+  end
+end
+for i = 4, #ARGV, 2 do
+  redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+return redis.call('HLEN', KEYS[1])
+`)
+
+// LeasePort 在 [min, max] 范围内为 Docker 主机分配一个空闲端口，端口耗尽时返回 0
+func LeasePort(ctx context.Context, hostID string, min, max int) (int, error) {
+	start := min + rand.Intn(max-min+1)
+	return leasePortScript.Run(ctx, Client, []string{KeyPortLeasesPrefix + hostID},
+		min, max, start, time.Now().UnixMilli(), portPendingTTL.Milliseconds(), portConflictTTL.Milliseconds(),
+	).Int()
+}
+
+// BindPort 容器创建成功后将端口租约绑定到容器ID（绑定后不会过期，直到容器删除）
+func BindPort(ctx context.Context, hostID string, port int, containerID string) error {
+	return Client.HSet(ctx, KeyPortLeasesPrefix+hostID, port, containerID).Err()
+}
+
+// MarkPortConflict 标记端口被外部进程占用，在 portConflictTTL 内不再分配
+func MarkPortConflict(ctx context.Context, hostID string, port int) error {
+	return Client.HSet(ctx, KeyPortLeasesPrefix+hostID, port, PortConflict+":"+strconv.FormatInt(time.Now().UnixMilli(), 10)).Err()
+}
+
+// ReleasePort 释放端口租约
+func ReleasePort(ctx context.Context, hostID string, port int) error {
+	return Client.HDel(ctx, KeyPortLeasesPrefix+hostID, strconv.Itoa(port)).Err()
+}
+
+// ReleaseContainerPorts 释放容器持有的全部端口租约
+func ReleaseContainerPorts(ctx context.Context, hostID, containerID string) error {
+	return releaseContainerPortsScript.Run(ctx, Client, []string{KeyPortLeasesPrefix + hostID}, containerID).Err()
+}
+
+// ResetPortLeases 按运行中容器占用的端口重建主机的端口租约（leases: 端口 -> 容器ID）
+func ResetPortLeases(ctx context.Context, hostID string, leases map[int]string) error {
+	args := []interface{}{time.Now().UnixMilli(), portPendingTTL.Milliseconds(), portConflictTTL.Milliseconds()}
+	for port, containerID := range leases {
+		args = append(args, port, containerID)
+	}
+	return resetPortLeasesScript.Run(ctx, Client, []string{KeyPortLeasesPrefix + hostID}, args...).Err()
+}
+
+// GetPortLeases 获取主机当前有效的端口租约（端口 -> 容器ID / PortPending / PortConflict），已过期的租约不返回
+func GetPortLeases(ctx context.Context, hostID string) (map[int]string, error) {
+	data, err := Client.HGetAll(ctx, KeyPortLeasesPrefix+hostID).Result()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixMilli()
+	leases := make(map[int]string, len(data))
+	for field, owner := range data {
+		port, err := strconv.Atoi(field)
+		if err != nil {
+			continue
+		}
+		if kind, ts, ok := strings.Cut(owner, ":"); ok {
+			ttl := portPendingTTL
+			if kind == PortConflict {
+				ttl = portConflictTTL
+			}
+			if at, _ := strconv.ParseInt(ts, 10, 64); at+ttl.Milliseconds() < now {
+				continue
+			}
+			owner = kind
+		}
+		leases[port] = owner
+	}
+	return leases, nil
+}
+
+// PortLeaser 基于 Redis 的端口租约（实现 docker.PortLeaser，多个 API 实例共享）
+type PortLeaser struct{}
+
+func (PortLeaser) LeasePort(ctx context.Context, hostID string, min, max int) (int, error) {
+	return LeasePort(ctx, hostID, min, max)
+}
+
+func (PortLeaser) BindPort(ctx context.Context, hostID string, port int, containerID string) error {
+	return BindPort(ctx, hostID, port, containerID)
+}
+
+func (PortLeaser) MarkPortConflict(ctx context.Context, hostID string, port int) error {
+	return MarkPortConflict(ctx, hostID, port)
+}
+
+func (PortLeaser) ReleasePort(ctx context.Context, hostID string, port int) error {
+	return ReleasePort(ctx, hostID, port)
+}
+
+func (PortLeaser) ReleaseContainerPorts(ctx context.Context, hostID, containerID string) error {
+	return ReleaseContainerPorts(ctx, hostID, containerID)
+}
+
+func (PortLeaser) ResetPortLeases(ctx context.Context, hostID string, leases map[int]string) error {
+	return ResetPortLeases(ctx, hostID, leases)
+}
+
+func (PortLeaser) GetPortLeases(ctx context.Context, hostID string) (map[int]string, error) {
+	return GetPortLeases(ctx, hostID)
+}
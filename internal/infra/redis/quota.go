@@ -0,0 +1,187 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Instance quota keys
+const (
+	KeyUserPendingPrefix   = "instance_pending:user:" // instance_pending:user:{user_id} (ZSET member=challenge_id score=预留过期时间ms)
+	KeyTeamPendingPrefix   = "instance_pending:team:" // instance_pending:team:{team_id}
+	KeyDailyStartsPrefix   = "instance_starts:"       // instance_starts:{user_id}:{yyyymmdd} (当日启动次数)
+	defaultInstanceSlotTTL = 15 * time.Minute         // 未指定 SlotTTL 时启动中预留的最长时间
+	instanceDailyStartTTL  = 48 * time.Hour
+)
+
+// Quota violation kinds
+const (
+	QuotaDuplicate = "duplicate" // 该题目已有运行中或启动中的实例
+	QuotaUser      = "user"      // 用户同时运行实例数已达上限
+	QuotaTeam      = "team"      // 队伍同时运行实例数已达上限
+	QuotaDaily     = "daily"     // 用户当日启动次数已达上限
+)
+
+// InstanceQuota 实例启动配额（上限为 0 表示不限制）
+type InstanceQuota struct {
+	UserID      string
+	TeamID      string // 团队模式下的队伍ID，个人模式为空
+	ChallengeID string
+	UserLimit   int    // 每个用户同时运行的实例数
+	TeamLimit   int    // 每支队伍同时运行的实例数
+	DailyLimit  int    // 每个用户每日启动实例次数
+	Day         string // 日期（yyyymmdd），用于每日计数
+
+	// SlotTTL 启动中预留的有效期，需覆盖后台任务的最长耗时（含排队等待容量），0 使用默认值
+	SlotTTL time.Duration
+}
+
+func (q InstanceQuota) keys() []string {
+	teamKey, teamPending := "", ""
+	if q.TeamID != "" {
+		teamKey = KeyTeamInstancesPrefix + q.TeamID
+		teamPending = KeyTeamPendingPrefix + q.TeamID
+	}
+	return []string{
+		KeyUserInstancesPrefix + q.UserID,
+		KeyUserPendingPrefix + q.UserID,
+		teamKey,
+		teamPending,
+		KeyDailyStartsPrefix + q.UserID + ":" + q.Day,
+	}
+}
+
+// QuotaViolation 配额检查未通过的原因
+type QuotaViolation struct {
+	Kind        string
+	InstanceIDs []string // 占用配额的运行中实例（duplicate 时为该题目的实例，启动中时为空）
+}
+
+// reserveInstanceScript 原子地检查实例配额并预留启动名额
+// 运行中实例从用户/队伍实例集合读取（仅统计实例 HASH 仍存在的），启动中的请求记录在 pending ZSET 中
+// KEYS: 用户实例 SET, 用户 pending ZSET, 队伍实例 SET, 队伍 pending ZSET, 当日启动计数
+// ARGV: now_ms, pending_ttl_ms, challenge_id, user_limit, team_limit, daily_limit, daily_ttl_s, instance key 前缀
+// 返回 {状态, 实例ID...}：0 通过，1 重复启动，2 用户上限，3 队伍上限，4 当日次数上限
+var reserveInstanceScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local challenge = ARGV[3]
+local hasTeam = KEYS[3] ~= ''
+
+local function live(setKey)
+  local ids, challenges = {}, {}
+  for _, id in ipairs(redis.call('SMEMBERS', setKey)) do
+    local cid = redis.call('HGET', ARGV[8] .. id, 'challenge_id')
+    if cid then
+      ids[#ids + 1] = id
+      challenges[#challenges + 1] = cid
+    end
+  end
+  return ids, challenges
+end
+
+redis.call('ZREMRANGEBYSCORE', KEYS[2], '-inf', now)
+if hasTeam then
+  redis.call('ZREMRANGEBYSCORE', KEYS[4], '-inf', now)
+end
+
+local userIDs, userChallenges = live(KEYS[1])
+local ownerIDs, ownerChallenges, ownerPending = userIDs, userChallenges, KEYS[2]
+local teamIDs
+if hasTeam then
+  teamIDs, ownerChallenges = live(KEYS[3])
+  ownerIDs, ownerPending = teamIDs, KEYS[4]
+end
+
+for i, cid in ipairs(ownerChallenges) do
+  if cid == challenge then
+    return {1, ownerIDs[i]}
+  end
+end
+if redis.call('ZSCORE', ownerPending, challenge) then
+  return {1}
+end
+
+local userLimit = tonumber(ARGV[4])
+if userLimit > 0 and #userIDs + redis.call('ZCARD', KEYS[2]) >= userLimit then
+  return {2, unpack(userIDs)}
+end
+local teamLimit = tonumber(ARGV[5])
+if hasTeam and teamLimit > 0 and #teamIDs + redis.call('ZCARD', KEYS[4]) >= teamLimit then
+  return {3, unpack(teamIDs)}
+end
+
+local dailyLimit = tonumber(ARGV[6])
+if dailyLimit > 0 then
+  local used = tonumber(redis.call('GET', KEYS[5]) or '0')
+  if used >= dailyLimit then
+    return {4}
+  end
+  redis.call('INCR', KEYS[5])
+  redis.call('EXPIRE', KEYS[5], ARGV[7])
+end
+
+local expires = now + tonumber(ARGV[2])
+redis.call('ZADD', KEYS[2], expires, challenge)
+if hasTeam then
+  redis.call('ZADD', KEYS[4], expires, challenge)
+end
+return {0}
+`)
+
+// ReserveInstanceSlot 检查并预留实例启动名额，未通过时返回 *QuotaViolation
+// 通过后必须调用 ReleaseInstanceSlot（实例写入 Redis 后或启动失败时）
+func ReserveInstanceSlot(ctx context.Context, q InstanceQuota) (*QuotaViolation, error) {
+	ttl := q.SlotTTL
+	if ttl <= 0 {
+		ttl = defaultInstanceSlotTTL
+	}
+	res, err := reserveInstanceScript.Run(ctx, Client, q.keys(),
+		time.Now().UnixMilli(), ttl.Milliseconds(), q.ChallengeID,
+		q.UserLimit, q.TeamLimit, q.DailyLimit, int(instanceDailyStartTTL.Seconds()), KeyInstancePrefix,
+	).Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("unexpected quota script result")
+	}
+
+	code, _ := res[0].(int64)
+	ids := make([]string, 0, len(res)-1)
+	for _, v := range res[1:] {
+		if id, ok := v.(string); ok {
+			ids = append(ids, id)
+		}
+	}
+	switch code {
+	case 0:
+		return nil, nil
+	case 1:
+		return &QuotaViolation{Kind: QuotaDuplicate, InstanceIDs: ids}, nil
+	case 2:
+		return &QuotaViolation{Kind: QuotaUser, InstanceIDs: ids}, nil
+	case 3:
+		return &QuotaViolation{Kind: QuotaTeam, InstanceIDs: ids}, nil
+	case 4:
+		return &QuotaViolation{Kind: QuotaDaily}, nil
+	}
+	return nil, fmt.Errorf("unexpected quota script result: %v", res)
+}
+
+// ReleaseInstanceSlot 释放启动名额；refund 为 true 时（启动失败）退还当日启动次数
+func ReleaseInstanceSlot(ctx context.Context, q InstanceQuota, refund bool) error {
+	keys := q.keys()
+	pipe := Client.Pipeline()
+	pipe.ZRem(ctx, keys[1], q.ChallengeID)
+	if q.TeamID != "" {
+		pipe.ZRem(ctx, keys[3], q.ChallengeID)
+	}
+	if refund && q.DailyLimit > 0 {
+		pipe.Decr(ctx, keys[4])
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
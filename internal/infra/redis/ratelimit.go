@@ -0,0 +1,167 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Submit rate limit keys
+const (
+	KeySubmitWindowPrefix   = "ratelimit:submit:"   // ratelimit:submit:{kind}:{id}:{challenge_id} (ZSET 滑动窗口)
+	KeySubmitCooldownPrefix = "ratelimit:cooldown:" // ratelimit:cooldown:{kind}:{id}:{challenge_id} (冷却期，TTL 即剩余时间)
+	KeySubmitOffensePrefix  = "ratelimit:offenses:" // ratelimit:offenses:{kind}:{id}:{challenge_id} (违规次数)
+	KeyThrottledSet         = "ratelimit:throttled" // ZSET member={kind}|{id}|{challenge_id} score=解除限制时间
+)
+
+// RateLimitSubject 限流主体（按用户或IP，分题目计数）
+type RateLimitSubject struct {
+	Kind        string // user / ip
+	ID          string
+	ChallengeID string
+	Limit       int // 窗口内最大提交次数，0 表示不限制
+}
+
+func (s RateLimitSubject) suffix() string {
+	return s.Kind + ":" + s.ID + ":" + s.ChallengeID
+}
+
+func (s RateLimitSubject) member() string {
+	return s.Kind + "|" + s.ID + "|" + s.ChallengeID
+}
+
+// submitLimitScript 原子地完成冷却检查、滑动窗口计数与违规升级
+// KEYS 每 3 个一组：窗口 ZSET, 冷却 KEY, 违规计数 KEY
+// ARGV: now_ms, window_ms, member, offense_ttl_s, limit_1..limit_n, cooldown_ms_1..cooldown_ms_m
+// 返回 {被限流主体序号(0 表示放行), 需等待毫秒数}
+var submitLimitScript = redis.NewScript(`
+local n = #KEYS / 3
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local member = ARGV[3]
+local offenseTTL = tonumber(ARGV[4])
+local cooldowns = {}
+for i = 5 + n, #ARGV do
+  cooldowns[#cooldowns + 1] = tonumber(ARGV[i])
+end
+
+for i = 0, n - 1 do
+  local ttl = redis.call('PTTL', KEYS[i * 3 + 2])
+  if ttl > 0 then
+    return {i + 1, ttl}
+  end
+end
+
+for i = 0, n - 1 do
+  local key = KEYS[i * 3 + 1]
+  local limit = tonumber(ARGV[5 + i])
+  redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+  if limit > 0 and redis.call('ZCARD', key) >= limit then
+    local retry = window
+    local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+    if oldest[2] then
+      retry = tonumber(oldest[2]) + window - now
+    end
+    local offenses = redis.call('INCR', KEYS[i * 3 + 3])
+    redis.call('EXPIRE', KEYS[i * 3 + 3], offenseTTL)
+    if #cooldowns > 0 then
+      local cd = cooldowns[math.min(offenses, #cooldowns)]
+      redis.call('SET', KEYS[i * 3 + 2], offenses, 'PX', cd)
+      if cd > retry then
+        retry = cd
+      end
+    end
+    return {i + 1, retry}
+  end
+end
+
+for i = 0, n - 1 do
+  redis.call('ZADD', KEYS[i * 3 + 1], now, member)
+  redis.call('PEXPIRE', KEYS[i * 3 + 1], window)
+end
+return {0, 0}
+`)
+
+// CheckSubmitRateLimit 检查并记录一次提交
+// 返回：被限流的主体（放行时为 nil）, 需等待时间, 错误
+func CheckSubmitRateLimit(ctx context.Context, subjects []RateLimitSubject, window time.Duration, cooldowns []time.Duration, offenseTTL time.Duration) (*RateLimitSubject, time.Duration, error) {
+	now := time.Now()
+	keys := make([]string, 0, len(subjects)*3)
+	args := []interface{}{now.UnixMilli(), window.Milliseconds(), fmt.Sprintf("%d", now.UnixNano()), int64(offenseTTL.Seconds())}
+	for _, s := range subjects {
+		keys = append(keys, KeySubmitWindowPrefix+s.suffix(), KeySubmitCooldownPrefix+s.suffix(), KeySubmitOffensePrefix+s.suffix())
+		args = append(args, s.Limit)
+	}
+	for _, cd := range cooldowns {
+		args = append(args, cd.Milliseconds())
+	}
+
+	res, err := submitLimitScript.Run(ctx, Client, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, 0, err
+	}
+	if res[0] == 0 {
+		return nil, 0, nil
+	}
+
+	blocked := subjects[res[0]-1]
+	retryAfter := time.Duration(res[1]) * time.Millisecond
+	// 记录到限流名单，供管理员查看
+	Client.ZAdd(ctx, KeyThrottledSet, redis.Z{
+		Score:  float64(now.Add(retryAfter).Unix()),
+		Member: blocked.member(),
+	})
+	return &blocked, retryAfter, nil
+}
+
+// ThrottledSubject 当前处于限流状态的主体
+type ThrottledSubject struct {
+	Kind        string
+	ID          string
+	ChallengeID string
+	Until       time.Time
+	Offenses    int
+}
+
+// ListThrottled 返回仍处于限流状态的主体（同时清理已过期记录）
+func ListThrottled(ctx context.Context) ([]ThrottledSubject, error) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	Client.ZRemRangeByScore(ctx, KeyThrottledSet, "-inf", "("+now)
+
+	items, err := Client.ZRangeByScoreWithScores(ctx, KeyThrottledSet, &redis.ZRangeBy{Min: now, Max: "+inf"}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]ThrottledSubject, 0, len(items))
+	for _, item := range items {
+		parts := strings.SplitN(item.Member.(string), "|", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		subject := ThrottledSubject{
+			Kind:        parts[0],
+			ID:          parts[1],
+			ChallengeID: parts[2],
+			Until:       time.Unix(int64(item.Score), 0),
+		}
+		suffix := RateLimitSubject{Kind: parts[0], ID: parts[1], ChallengeID: parts[2]}.suffix()
+		subject.Offenses, _ = Client.Get(ctx, KeySubmitOffensePrefix+suffix).Int()
+		result = append(result, subject)
+	}
+	return result, nil
+}
+
+// ClearThrottle 解除主体的限流状态并清空违规计数
+func ClearThrottle(ctx context.Context, kind, id, challengeID string) error {
+	s := RateLimitSubject{Kind: kind, ID: id, ChallengeID: challengeID}
+	pipe := Client.Pipeline()
+	pipe.Del(ctx, KeySubmitWindowPrefix+s.suffix(), KeySubmitCooldownPrefix+s.suffix(), KeySubmitOffensePrefix+s.suffix())
+	pipe.ZRem(ctx, KeyThrottledSet, s.member())
+	_, err := pipe.Exec(ctx)
+	return err
+}
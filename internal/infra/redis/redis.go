@@ -2,8 +2,10 @@ package redis
 
 import (
 	"context"
+	"cyber-range/internal/model"
 	"cyber-range/pkg/config"
 	"cyber-range/pkg/logger"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -42,23 +44,32 @@ func Close() error {
 const (
 	KeyInstancePrefix      = "instance:"         // instance:{id}
 	KeyUserInstancesPrefix = "user_instances:"   // user_instances:{user_id} (SET)
+	KeyTeamInstancesPrefix = "team_instances:"   // team_instances:{team_id} (SET，团队模式)
 	KeyExpiredInstancesSet = "expired_instances" // ZSET sorted by expiry time
+	KeyInstanceLockPrefix  = "instance_lock:"    // instance_lock:{id} (实例重置等操作的互斥锁)
 )
 
 // StoreInstance stores instance metadata in Redis with TTL
-func StoreInstance(ctx context.Context, instanceID, userID, challengeID, containerID, flag string, port int, expiresAt time.Time) error {
-	key := KeyInstancePrefix + instanceID
+func StoreInstance(ctx context.Context, inst *model.Instance) error {
+	key := KeyInstancePrefix + inst.ID
 	data := map[string]interface{}{
-		"user_id":      userID,
-		"challenge_id": challengeID,
-		"container_id": containerID,
-		"flag":         flag,
-		"port":         port,
-		"expires_at":   expiresAt.Unix(),
+		"user_id":        inst.UserID,
+		"team_id":        inst.TeamID,
+		"event_id":       inst.EventID,
+		"challenge_id":   inst.ChallengeID,
+		"container_id":   inst.ContainerID,
+		"docker_host_id": inst.DockerHostID,
+		"gateway_token":  inst.GatewayToken,
+		"flag":           inst.Flag,
+		"port":           inst.Port,
+		"expires_at":     inst.ExpiresAt.Unix(),
+		"extensions":     inst.Extensions,
+		"ports":          encodeJSON(inst.Ports),
+		"services":       encodeJSON(inst.Services),
 	}
 
 	// 检查过期时间有效性
-	if time.Until(expiresAt) <= 0 {
+	if time.Until(inst.ExpiresAt) <= 0 {
 		return fmt.Errorf("expiry time is in the past")
 	}
 
@@ -67,19 +78,84 @@ func StoreInstance(ctx context.Context, instanceID, userID, challengeID, contain
 	// 注意：不设置 TTL，由 Reaper 通过 ZSET 追踪过期并显式删除
 
 	// Add to user's instances set
-	userKey := KeyUserInstancesPrefix + userID
-	pipe.SAdd(ctx, userKey, instanceID)
+	userKey := KeyUserInstancesPrefix + inst.UserID
+	pipe.SAdd(ctx, userKey, inst.ID)
+
+	// 团队模式下同时加入队伍实例集合
+	if inst.TeamID != "" {
+		pipe.SAdd(ctx, KeyTeamInstancesPrefix+inst.TeamID, inst.ID)
+	}
 
 	// Add to expiry tracking (sorted set)
 	pipe.ZAdd(ctx, KeyExpiredInstancesSet, redis.Z{
-		Score:  float64(expiresAt.Unix()),
-		Member: instanceID,
+		Score:  float64(inst.ExpiresAt.Unix()),
+		Member: inst.ID,
 	})
 
 	_, err := pipe.Exec(ctx)
 	return err
 }
 
+// extendInstanceScript 原子地延长实例过期时间（按已延长次数做比较交换，防止并发重复延长）
+// KEYS: 实例 HASH, 过期 ZSET；ARGV: instance_id, 期望的已延长次数, 新过期时间(unix)
+// 返回 1 成功，0 已被并发延长，-1 实例不存在
+var extendInstanceScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+  return -1
+end
+local current = tonumber(redis.call('HGET', KEYS[1], 'extensions') or '0')
+if current ~= tonumber(ARGV[2]) then
+  return 0
+end
+redis.call('HSET', KEYS[1], 'expires_at', ARGV[3], 'extensions', current + 1)
+redis.call('ZADD', KEYS[2], 'XX', ARGV[3], ARGV[1])
+return 1
+`)
+
+// ExtendInstance 延长实例过期时间并更新过期追踪 ZSET
+// 返回 false 表示实例已被并发延长（extensions 不匹配）
+func ExtendInstance(ctx context.Context, instanceID string, extensions int, expiresAt time.Time) (bool, error) {
+	res, err := extendInstanceScript.Run(ctx, Client,
+		[]string{KeyInstancePrefix + instanceID, KeyExpiredInstancesSet},
+		instanceID, extensions, expiresAt.Unix()).Int()
+	if err != nil {
+		return false, err
+	}
+	if res < 0 {
+		return false, fmt.Errorf("instance %s not found", instanceID)
+	}
+	return res == 1, nil
+}
+
+// UpdateInstanceContainer 实例重置后更新容器ID、端口映射与服务容器列表
+func UpdateInstanceContainer(ctx context.Context, inst *model.Instance) error {
+	return Client.HSet(ctx, KeyInstancePrefix+inst.ID,
+		"container_id", inst.ContainerID,
+		"port", inst.Port,
+		"ports", encodeJSON(inst.Ports),
+		"services", encodeJSON(inst.Services),
+	).Err()
+}
+
+// encodeJSON 端口映射、服务容器等列表编码为 JSON（空列表为空字符串）
+func encodeJSON[T any](items []T) string {
+	if len(items) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(items)
+	return string(data)
+}
+
+// AcquireInstanceLock 获取实例操作锁（如重置），避免同一实例被并发操作
+func AcquireInstanceLock(ctx context.Context, instanceID string, ttl time.Duration) (bool, error) {
+	return Client.SetNX(ctx, KeyInstanceLockPrefix+instanceID, 1, ttl).Result()
+}
+
+// ReleaseInstanceLock 释放实例操作锁
+func ReleaseInstanceLock(ctx context.Context, instanceID string) error {
+	return Client.Del(ctx, KeyInstanceLockPrefix+instanceID).Err()
+}
+
 // GetInstance retrieves instance metadata
 func GetInstance(ctx context.Context, instanceID string) (map[string]string, error) {
 	key := KeyInstancePrefix + instanceID
@@ -92,11 +168,20 @@ func GetUserActiveInstances(ctx context.Context, userID string) ([]string, error
 	return Client.SMembers(ctx, key).Result()
 }
 
-// DeleteInstance removes instance from Redis
-func DeleteInstance(ctx context.Context, instanceID, userID string) error {
+// GetTeamActiveInstances returns all active instance IDs for a team
+func GetTeamActiveInstances(ctx context.Context, teamID string) ([]string, error) {
+	key := KeyTeamInstancesPrefix + teamID
+	return Client.SMembers(ctx, key).Result()
+}
+
+// DeleteInstance removes instance from Redis（teamID 为空表示个人实例）
+func DeleteInstance(ctx context.Context, instanceID, userID, teamID string) error {
 	pipe := Client.Pipeline()
 	pipe.Del(ctx, KeyInstancePrefix+instanceID)
 	pipe.SRem(ctx, KeyUserInstancesPrefix+userID, instanceID)
+	if teamID != "" {
+		pipe.SRem(ctx, KeyTeamInstancesPrefix+teamID, instanceID)
+	}
 	pipe.ZRem(ctx, KeyExpiredInstancesSet, instanceID)
 	_, err := pipe.Exec(ctx)
 	return err
@@ -151,3 +236,25 @@ func GetInstanceByUserAndChallenge(ctx context.Context, userID, challengeID stri
 
 	return nil, nil // 没有找到该题目的实例
 }
+
+// GetInstanceByTeamAndChallenge 检查队伍是否已有该题目的运行实例（团队模式）
+// 返回：实例数据, 错误（如果不存在返回nil, nil）
+func GetInstanceByTeamAndChallenge(ctx context.Context, teamID, challengeID string) (map[string]string, error) {
+	instanceIDs, err := GetTeamActiveInstances(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, instanceID := range instanceIDs {
+		data, err := GetInstance(ctx, instanceID)
+		if err != nil {
+			continue
+		}
+
+		if data["challenge_id"] == challengeID {
+			return data, nil
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,47 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Scoreboard cache keys
+const (
+	KeyScoreboardVersion     = "scoreboard:version" // 缓存版本号，解题后递增使旧缓存失效
+	KeyScoreboardCachePrefix = "scoreboard:cache:"  // scoreboard:cache:{version}:{name}
+)
+
+// ScoreboardCacheKey 返回带当前版本号的积分榜缓存键
+func ScoreboardCacheKey(ctx context.Context, name string) (string, error) {
+	version, err := Client.Get(ctx, KeyScoreboardVersion).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return "", err
+	}
+	return fmt.Sprintf("%s%d:%s", KeyScoreboardCachePrefix, version, name), nil
+}
+
+// GetScoreboardCache 读取积分榜缓存（不存在时返回 false）
+func GetScoreboardCache(ctx context.Context, key string) ([]byte, bool, error) {
+	data, err := Client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// SetScoreboardCache 写入积分榜缓存
+func SetScoreboardCache(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	return Client.Set(ctx, key, data, ttl).Err()
+}
+
+// InvalidateScoreboardCache 递增版本号使所有积分榜缓存失效（旧缓存随 TTL 自然过期）
+func InvalidateScoreboardCache(ctx context.Context) error {
+	return Client.Incr(ctx, KeyScoreboardVersion).Err()
+}
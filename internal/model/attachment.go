@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// Attachment 题目附件表 - 存储题目附带的可下载文件（二进制、流量包、源码等）
+type Attachment struct {
+	ID          string    `gorm:"primaryKey;size:36;comment:附件唯一标识" json:"id"`
+	ChallengeID string    `gorm:"size:36;not null;index;comment:所属题目ID" json:"challenge_id"`
+	Filename    string    `gorm:"size:255;not null;comment:原始文件名" json:"filename"`
+	StorageKey  string    `gorm:"size:500;not null;comment:存储后端中的对象Key(不返回给前端)" json:"-"`
+	ContentType string    `gorm:"size:100;comment:文件MIME类型" json:"content_type"`
+	Size        int64     `gorm:"not null;default:0;comment:文件大小(字节)" json:"size"`
+	SHA256      string    `gorm:"size:64;not null;comment:文件SHA-256校验和" json:"sha256"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;comment:上传时间" json:"created_at"`
+}
+
+// TableName 指定表名
+func (Attachment) TableName() string { return "attachments" }
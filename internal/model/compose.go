@@ -0,0 +1,73 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// ServiceDefinition 题目服务表 - 多容器题目（kind=compose）的服务定义，如 Web 应用 + 数据库 + Bot
+// 实例启动时全部服务在同一 Docker 主机的实例专属网络中成组创建，服务之间通过服务名与别名互相访问
+type ServiceDefinition struct {
+	ID          string          `gorm:"primaryKey;size:36;comment:服务唯一标识" json:"id"`
+	ChallengeID string          `gorm:"size:36;not null;index;comment:所属题目ID" json:"challenge_id"`
+	Name        string          `gorm:"size:50;not null;comment:服务名(实例网络内的主机名)" json:"name"`
+	Image       string          `gorm:"size:500;not null;comment:Docker镜像名称" json:"image"`
+	Env         string          `gorm:"type:text;comment:环境变量(每行一个KEY=VALUE)" json:"env,omitempty"`
+	Aliases     string          `gorm:"size:500;comment:额外的网络别名(逗号分隔)" json:"aliases,omitempty"`
+	Port        int             `gorm:"not null;default:0;comment:暴露给玩家的容器端口(未设置ports时使用),0表示仅实例网络内访问" json:"port"`
+	Ports       []ChallengePort `gorm:"serializer:json;type:text;comment:暴露给玩家的端口列表(JSON,含标签/容器端口/协议)" json:"ports,omitempty"`
+	MemoryLimit int64           `gorm:"default:0;comment:内存限制(字节),0表示使用主机默认值" json:"memory_limit"`
+	CPULimit    float64         `gorm:"default:0;comment:CPU限制(核心数),0表示使用主机默认值" json:"cpu_limit"`
+	Privileged  bool            `gorm:"default:false;comment:是否以特权模式运行容器" json:"privileged"`
+	Flag        bool            `gorm:"default:false;comment:是否注入FLAG环境变量(均未标记时注入首个暴露端口的服务)" json:"flag"`
+	Position    int             `gorm:"not null;default:0;comment:启动顺序(升序)" json:"position"`
+	CreatedAt   time.Time       `gorm:"autoCreateTime;comment:创建时间" json:"created_at"`
+	UpdatedAt   time.Time       `gorm:"autoUpdateTime;comment:更新时间" json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ServiceDefinition) TableName() string { return "challenge_services" }
+
+// EnvList 环境变量列表（忽略空行）
+func (s *ServiceDefinition) EnvList() []string {
+	var env []string
+	for _, line := range strings.Split(s.Env, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			env = append(env, line)
+		}
+	}
+	return env
+}
+
+// FlagServices 需要注入 FLAG 的服务下标：标记了 flag 的服务，均未标记时为首个暴露端口的服务
+func FlagServices(services []ServiceDefinition) map[int]bool {
+	marked := make(map[int]bool)
+	for i, svc := range services {
+		if svc.Flag {
+			marked[i] = true
+		}
+	}
+	if len(marked) == 0 {
+		for i, svc := range services {
+			if len(svc.ExposedPorts()) > 0 {
+				marked[i] = true
+				break
+			}
+		}
+	}
+	return marked
+}
+
+// AliasList 额外的网络别名（按逗号或空白分隔）
+func (s *ServiceDefinition) AliasList() []string {
+	return strings.FieldsFunc(s.Aliases, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+}
+
+// InstanceService 多容器实例中的单个服务容器
+type InstanceService struct {
+	Name        string `json:"name"`
+	ContainerID string `json:"container_id"`
+	Port        int    `json:"port,omitempty"` // 映射到宿主机的首个端口号，仅暴露端口的服务有值
+}
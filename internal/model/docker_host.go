@@ -1,6 +1,10 @@
 package model
 
-import "time"
+import (
+	"slices"
+	"strings"
+	"time"
+)
 
 // DockerHost Docker主机配置表 - 存储所有Docker主机连接信息
 type DockerHost struct {
@@ -10,14 +14,29 @@ type DockerHost struct {
 	TLSVerify bool   `gorm:"default:false;comment:是否启用TLS加密" json:"tls_verify"`
 	CertPath  string `gorm:"size:500;comment:TLS证书路径" json:"cert_path"`
 
+	// 玩家访问地址
+	PublicHost      string `gorm:"size:255;comment:玩家访问实例的公网主机名或IP(为空时取Docker连接地址的主机名)" json:"public_host"`
+	ConnectTemplate string `gorm:"size:255;comment:连接信息模板(如 nc {host} {port}),为空时为 host:port" json:"connect_template"`
+
 	// 端口分配范围
 	PortRangeMin int `gorm:"not null;default:20000;comment:端口范围最小值" json:"port_range_min"`
 	PortRangeMax int `gorm:"not null;default:40000;comment:端口范围最大值" json:"port_range_max"`
 
+	// 实例网络地址池：每个实例从中分配一个 /28 子网，为空时使用 Docker 守护进程的默认地址池（default-address-pools）
+	NetworkPool string `gorm:"size:50;comment:实例网络地址池(IPv4 CIDR,每个实例分配一个/28子网),为空时使用Docker默认地址池" json:"network_pool"`
+
 	// 资源限制（可选，允许每个主机有不同的限制）
 	MemoryLimit int64   `gorm:"default:134217728;comment:默认内存限制(字节)" json:"memory_limit"`
 	CPULimit    float64 `gorm:"type:decimal(3,2);default:0.50;comment:默认CPU限制(核心数)" json:"cpu_limit"`
 
+	// 容量上限（0 表示不限制）：按实例预留的资源（各容器内存/CPU 限制之和）计算，超出时不再向该主机调度
+	MaxInstances int     `gorm:"default:0;comment:最多同时运行的实例数,0表示不限制" json:"max_instances"`
+	MaxMemory    int64   `gorm:"default:0;comment:实例预留内存合计上限(字节),0表示不限制" json:"max_memory"`
+	MaxCPU       float64 `gorm:"type:decimal(8,2);default:0;comment:实例预留CPU合计上限(核心数),0表示不限制" json:"max_cpu"`
+
+	// 调度
+	Tags string `gorm:"size:255;comment:主机标签(逗号分隔,如 pwn,gpu;题目可按标签约束调度)" json:"tags"`
+
 	// 状态控制
 	Enabled   bool `gorm:"default:true;comment:是否启用(管理员可手动禁用)" json:"enabled"`
 	IsDefault bool `gorm:"default:false;index;comment:是否为默认主机" json:"is_default"`
@@ -32,3 +51,25 @@ type DockerHost struct {
 func (DockerHost) TableName() string {
 	return "docker_hosts"
 }
+
+// ParseTags 解析逗号分隔的标签列表（去除空白与空项，统一小写）
+func ParseTags(tags string) []string {
+	var list []string
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			list = append(list, tag)
+		}
+	}
+	return list
+}
+
+// HasTags 主机是否具备全部指定标签
+func (h *DockerHost) HasTags(required []string) bool {
+	own := ParseTags(h.Tags)
+	for _, tag := range required {
+		if !slices.Contains(own, tag) {
+			return false
+		}
+	}
+	return true
+}
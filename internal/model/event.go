@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// Event 赛事表 - 一场 CTF 比赛或训练，拥有独立的时间窗口、题目集合与积分榜
+type Event struct {
+	ID                  string     `gorm:"primaryKey;size:36;comment:赛事唯一标识" json:"id"`
+	Name                string     `gorm:"size:200;not null;comment:赛事名称" json:"name"`
+	Description         string     `gorm:"type:text;comment:赛事描述(富文本HTML)" json:"description"`
+	Visibility          string     `gorm:"size:20;default:'public';comment:可见性(public/invite_only)" json:"visibility"`
+	InviteCode          string     `gorm:"size:32;index;comment:邀请码(invite_only时报名使用,不返回给前端)" json:"-"`
+	RegistrationStartAt *time.Time `gorm:"comment:报名开始时间(为空表示不限制)" json:"registration_start_at,omitempty"`
+	RegistrationEndAt   *time.Time `gorm:"comment:报名截止时间(为空表示比赛结束前均可报名)" json:"registration_end_at,omitempty"`
+	StartAt             time.Time  `gorm:"not null;index;comment:比赛开始时间" json:"start_at"`
+	EndAt               time.Time  `gorm:"not null;index;comment:比赛结束时间" json:"end_at"`
+	FreezeAt            *time.Time `gorm:"comment:封榜时间(为空表示不封榜)" json:"freeze_at,omitempty"`
+	CreatedAt           time.Time  `gorm:"autoCreateTime;comment:创建时间" json:"created_at"`
+	UpdatedAt           time.Time  `gorm:"autoUpdateTime;comment:更新时间" json:"updated_at"`
+}
+
+// EventChallenge 赛事题目关联表 - 每个赛事拥有独立的题目集合
+type EventChallenge struct {
+	EventID     string    `gorm:"primaryKey;size:36;comment:赛事ID" json:"event_id"`
+	ChallengeID string    `gorm:"primaryKey;size:36;index;comment:题目ID" json:"challenge_id"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;comment:加入时间" json:"created_at"`
+}
+
+// EventParticipant 赛事报名表
+type EventParticipant struct {
+	EventID  string    `gorm:"primaryKey;size:36;comment:赛事ID" json:"event_id"`
+	UserID   string    `gorm:"primaryKey;size:36;index;comment:报名用户ID" json:"user_id"`
+	JoinedAt time.Time `gorm:"autoCreateTime;comment:报名时间" json:"joined_at"`
+}
+
+// TableName 指定表名
+func (Event) TableName() string            { return "events" }
+func (EventChallenge) TableName() string   { return "event_challenges" }
+func (EventParticipant) TableName() string { return "event_participants" }
+
+// IsRunning 判断赛事在指定时间是否处于比赛窗口内
+func (e *Event) IsRunning(now time.Time) bool {
+	return !now.Before(e.StartAt) && now.Before(e.EndAt)
+}
+
+// IsRegistrationOpen 判断赛事在指定时间是否开放报名
+func (e *Event) IsRegistrationOpen(now time.Time) bool {
+	if e.RegistrationStartAt != nil && now.Before(*e.RegistrationStartAt) {
+		return false
+	}
+	if e.RegistrationEndAt != nil {
+		return now.Before(*e.RegistrationEndAt)
+	}
+	return now.Before(e.EndAt)
+}
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// Hint 题目提示表 - 每个题目可有多条按顺序排列的提示，可设置解锁扣分
+type Hint struct {
+	ID          string    `gorm:"primaryKey;size:36;comment:提示唯一标识" json:"id"`
+	ChallengeID string    `gorm:"size:36;not null;index;comment:所属题目ID" json:"challenge_id"`
+	Content     string    `gorm:"type:text;comment:提示内容(富文本HTML,解锁后可见)" json:"content"`
+	Cost        int       `gorm:"not null;default:0;comment:解锁扣分(0表示免费)" json:"cost"`
+	Position    int       `gorm:"not null;default:0;comment:排序序号(升序)" json:"position"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;comment:创建时间" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime;comment:更新时间" json:"updated_at"`
+}
+
+// HintUnlock 提示解锁记录表 - 同一赛事内每个用户（团队模式下为队伍）对同一提示只解锁、扣分一次
+type HintUnlock struct {
+	ID          string    `gorm:"primaryKey;size:36;comment:解锁记录唯一标识" json:"id"`
+	HintID      string    `gorm:"size:36;not null;index;comment:提示ID" json:"hint_id"`
+	ChallengeID string    `gorm:"size:36;not null;index;comment:题目ID" json:"challenge_id"`
+	UserID      string    `gorm:"size:36;not null;index;comment:解锁用户ID" json:"user_id"`
+	TeamID      string    `gorm:"size:36;index;comment:解锁时所属队伍ID(团队模式)" json:"team_id,omitempty"`
+	EventID     string    `gorm:"size:36;index;comment:所属赛事ID(非赛事场景为空)" json:"event_id,omitempty"`
+	UnlockKey   string    `gorm:"size:120;uniqueIndex;not null;comment:解锁唯一键(赛事ID:提示ID:用户或队伍ID)" json:"-"`
+	Cost        int       `gorm:"not null;default:0;comment:解锁时扣除的积分" json:"cost"`
+	UnlockedAt  time.Time `gorm:"autoCreateTime;index;comment:解锁时间" json:"unlocked_at"`
+}
+
+// TableName 指定表名
+func (Hint) TableName() string       { return "hints" }
+func (HintUnlock) TableName() string { return "hint_unlocks" }
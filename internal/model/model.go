@@ -1,43 +1,74 @@
 package model
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // Challenge 挑战题目表 - 存储CTF挑战的基本信息
 type Challenge struct {
-	ID            string     `gorm:"primaryKey;size:36;comment:题目唯一标识" json:"id"`
-	Title         string     `gorm:"size:200;not null;comment:题目标题" json:"title"`
-	Description   string     `gorm:"type:text;comment:题目描述(富文本HTML)" json:"description"`
-	Hint          string     `gorm:"type:text;comment:题目提示(富文本HTML)" json:"hint,omitempty"`
-	Category      string     `gorm:"size:50;comment:题目分类(Web/Pwn/Crypto/Reverse)" json:"category"`
-	Difficulty    string     `gorm:"size:20;comment:难度级别(Easy/Medium/Hard)" json:"difficulty"`
-	Image         string     `gorm:"size:500;not null;comment:Docker镜像名称(兼容字段)" json:"image"`
-	ImageID       string     `gorm:"size:36;index;comment:镜像ID(外键关联docker_images.id)" json:"image_id,omitempty"`
-	Port          int        `gorm:"not null;default:80;comment:容器内服务端口" json:"port"`
-	MemoryLimit   int64      `gorm:"default:0;comment:内存限制(字节),0表示使用镜像推荐或默认" json:"memory_limit"`
-	CPULimit      float64    `gorm:"default:0;comment:CPU限制(核心数),0表示使用镜像推荐或默认" json:"cpu_limit"`
-	Privileged    bool       `gorm:"default:false;comment:是否以特权模式运行容器" json:"privileged"`
-	Flag          string     `gorm:"size:500;not null;comment:Flag答案(静态模板,不返回给前端)" json:"-"`
-	Points        int        `gorm:"not null;default:100;comment:题目分值" json:"points"`
-	DockerHostID  string     `gorm:"size:36;index;comment:Docker主机ID(外键关联docker_hosts.id)" json:"docker_host_id,omitempty"`
-	Status        string     `gorm:"size:20;default:'unpublished';comment:发布状态(published/unpublished)" json:"status"`
-	PublishedAt   *time.Time `gorm:"comment:上架时间" json:"published_at,omitempty"`
-	UnpublishedAt *time.Time `gorm:"comment:下架时间" json:"unpublished_at,omitempty"`
-	CreatedAt     time.Time  `gorm:"autoCreateTime;comment:创建时间" json:"created_at"`
-	UpdatedAt     time.Time  `gorm:"autoUpdateTime;comment:更新时间" json:"updated_at"`
+	ID              string          `gorm:"primaryKey;size:36;comment:题目唯一标识" json:"id"`
+	Title           string          `gorm:"size:200;not null;comment:题目标题" json:"title"`
+	Description     string          `gorm:"type:text;comment:题目描述(富文本HTML)" json:"description"`
+	Hint            string          `gorm:"type:text;comment:题目提示(富文本HTML)" json:"hint,omitempty"`
+	Category        string          `gorm:"size:50;comment:题目分类(Web/Pwn/Crypto/Reverse)" json:"category"`
+	Difficulty      string          `gorm:"size:20;comment:难度级别(Easy/Medium/Hard)" json:"difficulty"`
+	Kind            string          `gorm:"size:20;default:'container';comment:题目类型(container:需启动容器实例,compose:多容器服务组,static:仅附件/静态Flag,无需实例)" json:"kind"`
+	Image           string          `gorm:"size:500;not null;comment:Docker镜像名称(兼容字段)" json:"image"`
+	ImageID         string          `gorm:"size:36;index;comment:镜像ID(外键关联docker_images.id)" json:"image_id,omitempty"`
+	Port            int             `gorm:"not null;default:80;comment:容器内服务端口(未设置ports时暴露该端口)" json:"port"`
+	Ports           []ChallengePort `gorm:"serializer:json;type:text;comment:暴露给玩家的端口列表(JSON,含标签/容器端口/协议)" json:"ports,omitempty"`
+	MemoryLimit     int64           `gorm:"default:0;comment:内存限制(字节),0表示使用镜像推荐或默认" json:"memory_limit"`
+	CPULimit        float64         `gorm:"default:0;comment:CPU限制(核心数),0表示使用镜像推荐或默认" json:"cpu_limit"`
+	Privileged      bool            `gorm:"default:false;comment:是否以特权模式运行容器" json:"privileged"`
+	Egress          string          `gorm:"size:20;default:'internet';comment:实例出站网络策略(none:禁止出站,internet:允许访问外网,allowlist:仅允许白名单地址)" json:"egress"`
+	EgressAllowlist string          `gorm:"type:text;comment:出站白名单(egress=allowlist时生效,每行一个IP/CIDR/域名)" json:"egress_allowlist,omitempty"`
+	MaxLifetime     int             `gorm:"default:0;comment:实例最长存活时间(分钟,含延长),0表示使用全局配置" json:"max_lifetime,omitempty"`
+	MaxExtensions   int             `gorm:"default:0;comment:实例最多延长次数,0表示使用全局配置,-1表示不允许延长" json:"max_extensions,omitempty"`
+	Flag            string          `gorm:"type:text;not null;comment:Flag答案(dynamic:静态模板,static/static_ci:Flag,regex:正则表达式,multiple:每行一个Flag;不返回给前端)" json:"-"`
+	FlagType        string          `gorm:"size:20;default:'dynamic';comment:Flag校验方式(dynamic/static/static_ci/regex/multiple)" json:"flag_type"`
+	Points          int             `gorm:"not null;default:100;comment:题目分值(动态计分时为当前分值)" json:"points"`
+	ScoringMode     string          `gorm:"size:20;default:'static';comment:计分模式(static/dynamic)" json:"scoring_mode"`
+	InitialPoints   int             `gorm:"default:0;comment:动态计分初始分值" json:"initial_points,omitempty"`
+	MinimumPoints   int             `gorm:"default:0;comment:动态计分最低分值" json:"minimum_points,omitempty"`
+	Decay           int             `gorm:"default:0;comment:衰减参数(linear:每次解出扣减的分值,logarithmic:降到最低分所需解出次数)" json:"decay,omitempty"`
+	DecayFunction   string          `gorm:"size:20;default:'linear';comment:衰减函数(linear/logarithmic)" json:"decay_function,omitempty"`
+	SolveCount      int             `gorm:"default:0;comment:解出次数(计分提交数)" json:"solve_count"`
+	DockerHostID    string          `gorm:"size:36;index;comment:Docker主机ID(外键关联docker_hosts.id,设置后实例固定在该主机,为空由调度器选择)" json:"docker_host_id,omitempty"`
+	HostTags        string          `gorm:"size:255;comment:调度约束:实例主机须具备的标签(逗号分隔,为空不限制)" json:"host_tags,omitempty"`
+	Status          string          `gorm:"size:20;default:'unpublished';comment:发布状态(published/unpublished)" json:"status"`
+	HideLocked      bool            `gorm:"default:false;comment:未满足前置条件时是否对玩家隐藏(否则显示为锁定)" json:"hide_locked"`
+	PublishedAt     *time.Time      `gorm:"comment:上架时间" json:"published_at,omitempty"`
+	UnpublishedAt   *time.Time      `gorm:"comment:下架时间" json:"unpublished_at,omitempty"`
+	CreatedAt       time.Time       `gorm:"autoCreateTime;comment:创建时间" json:"created_at"`
+	UpdatedAt       time.Time       `gorm:"autoUpdateTime;comment:更新时间" json:"updated_at"`
 }
 
 // Instance 容器实例表 - 存储用户运行中的靶机实例
 type Instance struct {
-	ID           string    `gorm:"primaryKey;size:36;comment:实例唯一标识" json:"id"`
-	UserID       string    `gorm:"size:36;not null;index:idx_user_challenge;comment:所属用户ID" json:"user_id"`
-	ChallengeID  string    `gorm:"size:36;not null;index:idx_user_challenge;comment:关联题目ID" json:"challenge_id"`
-	ContainerID  string    `gorm:"size:100;not null;comment:Docker容器ID" json:"container_id"`
-	DockerHostID string    `gorm:"size:36;not null;index;comment:Docker主机ID" json:"docker_host_id"`
-	Flag         string    `gorm:"size:500;not null;comment:用户专属动态Flag(不返回给前端)" json:"-"`
-	Port         int       `gorm:"not null;comment:映射到宿主机的端口号(20000-40000)" json:"port"`
-	Status       string    `gorm:"size:20;default:'running';comment:实例状态(running/stopped/expired)" json:"status"`
-	ExpiresAt    time.Time `gorm:"not null;index;comment:过期时间(默认1小时后)" json:"expires_at"`
-	CreatedAt    time.Time `gorm:"autoCreateTime;comment:创建时间" json:"created_at"`
+	ID             string            `gorm:"primaryKey;size:36;comment:实例唯一标识" json:"id"`
+	UserID         string            `gorm:"size:36;not null;index:idx_user_challenge;comment:所属用户ID" json:"user_id"`
+	TeamID         string            `gorm:"size:36;index;comment:所属队伍ID(团队模式,个人模式为空)" json:"team_id,omitempty"`
+	EventID        string            `gorm:"size:36;index;comment:所属赛事ID(非赛事场景为空)" json:"event_id,omitempty"`
+	ChallengeID    string            `gorm:"size:36;not null;index:idx_user_challenge;comment:关联题目ID" json:"challenge_id"`
+	ContainerID    string            `gorm:"size:100;not null;comment:Docker容器ID(多容器实例为首个暴露端口的服务容器)" json:"container_id"`
+	DockerHostID   string            `gorm:"size:36;not null;index;comment:Docker主机ID" json:"docker_host_id"`
+	Flag           string            `gorm:"size:500;not null;comment:用户专属动态Flag(不返回给前端)" json:"-"`
+	Port           int               `gorm:"not null;comment:映射到宿主机的端口号(20000-40000),多端口实例为首个端口" json:"port"`
+	Ports          []InstancePort    `gorm:"serializer:json;type:text;comment:映射到宿主机的端口列表(JSON,含标签/协议/容器端口/宿主机端口)" json:"ports,omitempty"`
+	Status         string            `gorm:"size:20;default:'running';comment:实例状态(running/stopped/expired)" json:"status"`
+	Services       []InstanceService `gorm:"serializer:json;type:text;comment:多容器实例的服务容器列表(JSON,含服务名/容器ID/映射端口),单容器实例为空" json:"services,omitempty"`
+	ExpiresAt      time.Time         `gorm:"not null;index;comment:过期时间(默认1小时后)" json:"expires_at"`
+	Extensions     int               `gorm:"default:0;comment:已延长次数" json:"extensions"`
+	ReservedMemory int64             `gorm:"default:0;comment:实例在主机上预留的内存(字节,各容器内存限制之和)" json:"reserved_memory,omitempty"`
+	ReservedCPU    float64           `gorm:"default:0;comment:实例在主机上预留的CPU(核心数,各容器CPU限制之和)" json:"reserved_cpu,omitempty"`
+	CreatedAt      time.Time         `gorm:"autoCreateTime;comment:创建时间" json:"created_at"`
+
+	Endpoints    map[string]string `gorm:"-" json:"endpoints,omitempty"`   // 连接地址（端口标签 -> host:port），返回前由服务层填充
+	Connections  map[string]string `gorm:"-" json:"connections,omitempty"` // 连接信息（端口标签 -> 按模板生成的连接串，如 nc host port）
+	GatewayURL   string            `gorm:"-" json:"gateway_url,omitempty"` // HTTP 网关访问地址（网关启用且实例有 TCP 端口时）
+	GatewayToken string            `gorm:"-" json:"-"`                     // TCP 网关访问令牌（<instance-id>:<secret>，仅存于 Redis）
+	TCPGateway   *TCPGatewayAccess `gorm:"-" json:"tcp_gateway,omitempty"` // TCP 网关连接信息（网关启用且实例有 TCP 端口时）
 }
 
 // User 用户表 - 存储平台用户信息
@@ -47,6 +78,7 @@ type User struct {
 	Email        string    `gorm:"uniqueIndex;size:100;comment:邮箱地址(唯一)" json:"email"`
 	PasswordHash string    `gorm:"size:100;not null;comment:密码哈希值(bcrypt加密)" json:"-"`
 	Role         string    `gorm:"size:20;default:'user';comment:用户角色(user/admin)" json:"role"`
+	TeamID       string    `gorm:"size:36;index;comment:所属队伍ID(团队模式)" json:"team_id,omitempty"`
 	TotalPoints  int       `gorm:"default:0;comment:累计积分" json:"total_points"`
 	CreatedAt    time.Time `gorm:"autoCreateTime;comment:注册时间" json:"created_at"`
 	UpdatedAt    time.Time `gorm:"autoUpdateTime;comment:更新时间" json:"-"`
@@ -56,10 +88,16 @@ type User struct {
 type Submission struct {
 	ID          string    `gorm:"primaryKey;size:36;comment:提交记录唯一标识" json:"id"`
 	UserID      string    `gorm:"size:36;not null;index;comment:提交用户ID" json:"user_id"`
+	TeamID      string    `gorm:"size:36;index;comment:提交时所属队伍ID(团队模式)" json:"team_id,omitempty"`
+	EventID     string    `gorm:"size:36;index;comment:所属赛事ID(非赛事场景为空)" json:"event_id,omitempty"`
 	ChallengeID string    `gorm:"size:36;not null;index;comment:题目ID" json:"challenge_id"`
 	Flag        string    `gorm:"size:500;not null;comment:用户提交的Flag内容" json:"flag"`
 	IsCorrect   bool      `gorm:"not null;comment:是否正确(true/false)" json:"is_correct"`
-	Points      int       `gorm:"default:0;comment:获得的积分(错误为0)" json:"points"`
+	Scored      bool      `gorm:"default:false;index;comment:是否计分(同一用户/队伍对同一题目仅首次正确提交计分)" json:"scored"`
+	SolveKey    *string   `gorm:"size:120;uniqueIndex;comment:解题唯一键(赛事ID:题目ID:用户或队伍ID),仅计分提交有值" json:"-"`
+	Points      int       `gorm:"default:0;comment:获得的积分(错误或重复解题为0)" json:"points"`
+	BloodRank   int       `gorm:"default:0;comment:血次序(1/2/3为一二三血,0表示无)" json:"blood_rank,omitempty"`
+	BonusPoints int       `gorm:"default:0;comment:血奖励积分" json:"bonus_points,omitempty"`
 	SubmittedAt time.Time `gorm:"autoCreateTime;index;comment:提交时间" json:"submitted_at"`
 }
 
@@ -82,3 +120,55 @@ func (Instance) TableName() string   { return "instances" }
 func (User) TableName() string       { return "users" }
 func (Submission) TableName() string { return "submissions" }
 func (Admin) TableName() string      { return "admins" }
+
+// RequiresInstance 是否需要启动容器实例（static 类型题目仅提供附件）
+func (c *Challenge) RequiresInstance() bool {
+	return c.Kind != "static"
+}
+
+// IsCompose 是否为多容器题目（由 challenge_services 中的服务成组启动）
+func (c *Challenge) IsCompose() bool {
+	return c.Kind == "compose"
+}
+
+// ContainerIDs 实例的全部容器ID（多容器实例包含所有服务容器）
+func (i *Instance) ContainerIDs() []string {
+	if len(i.Services) == 0 {
+		if i.ContainerID == "" {
+			return nil
+		}
+		return []string{i.ContainerID}
+	}
+	ids := make([]string, 0, len(i.Services))
+	for _, svc := range i.Services {
+		ids = append(ids, svc.ContainerID)
+	}
+	return ids
+}
+
+// 实例出站网络策略
+const (
+	EgressNone      = "none"      // 禁止访问外网
+	EgressInternet  = "internet"  // 允许访问外网（默认）
+	EgressAllowlist = "allowlist" // 仅允许访问白名单地址
+)
+
+// EgressPolicy 实例出站网络策略，未设置时允许访问外网
+func (c *Challenge) EgressPolicy() string {
+	if c.Egress == "" {
+		return EgressInternet
+	}
+	return c.Egress
+}
+
+// EgressAllowlistEntries 出站白名单条目（按换行、逗号或空白分隔）
+func (c *Challenge) EgressAllowlistEntries() []string {
+	return strings.FieldsFunc(c.EgressAllowlist, func(r rune) bool {
+		return r == '\n' || r == '\r' || r == ',' || r == ' ' || r == '\t'
+	})
+}
+
+// IsDynamic 是否为动态计分题目
+func (c *Challenge) IsDynamic() bool {
+	return c.ScoringMode == "dynamic"
+}
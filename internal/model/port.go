@@ -0,0 +1,161 @@
+package model
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// 端口协议
+const (
+	ProtocolTCP = "tcp"
+	ProtocolUDP = "udp"
+)
+
+// DefaultPortLabel 未声明端口列表的题目，其 port 字段对应的端口标签
+const DefaultPortLabel = "main"
+
+// 连接信息模板占位符
+const (
+	TemplateHost = "{host}"
+	TemplatePort = "{port}"
+)
+
+// ChallengePort 题目暴露给玩家的容器端口
+type ChallengePort struct {
+	Label    string `json:"label"`              // 端口标签（如 http、ssh），实例连接信息按标签返回
+	Port     int    `json:"port"`               // 容器内端口
+	Protocol string `json:"protocol,omitempty"` // tcp/udp，默认 tcp
+	Template string `json:"template,omitempty"` // 连接信息模板（如 http://{host}:{port}），为空时使用 Docker 主机的模板
+}
+
+// InstancePort 实例映射到宿主机的端口
+type InstancePort struct {
+	Label    string `json:"label"`
+	Service  string `json:"service,omitempty"` // 所属服务（多容器实例）
+	Protocol string `json:"protocol"`
+	Port     int    `json:"port"`               // 容器内端口
+	HostPort int    `json:"host_port"`          // 宿主机端口
+	Template string `json:"template,omitempty"` // 启动时题目声明的连接信息模板
+}
+
+// normalizePorts 补全端口协议，未声明端口列表时使用单个 TCP 端口
+func normalizePorts(ports []ChallengePort, fallbackLabel string, fallbackPort int) []ChallengePort {
+	if len(ports) == 0 {
+		if fallbackPort <= 0 {
+			return nil
+		}
+		return []ChallengePort{{Label: fallbackLabel, Port: fallbackPort, Protocol: ProtocolTCP}}
+	}
+	normalized := make([]ChallengePort, len(ports))
+	for i, p := range ports {
+		if p.Protocol == "" {
+			p.Protocol = ProtocolTCP
+		}
+		normalized[i] = p
+	}
+	return normalized
+}
+
+// ExposedPorts 题目暴露给玩家的端口（未声明 ports 时为 port 字段对应的 TCP 端口）
+func (c *Challenge) ExposedPorts() []ChallengePort {
+	return normalizePorts(c.Ports, DefaultPortLabel, c.Port)
+}
+
+// ExposedPorts 服务暴露给玩家的端口（未声明 ports 时为 port 字段对应的 TCP 端口，标签为服务名）
+func (s *ServiceDefinition) ExposedPorts() []ChallengePort {
+	return normalizePorts(s.Ports, s.Name, s.Port)
+}
+
+// SetEndpoints 按实例所在主机生成各端口的连接地址（标签 -> host:port）与连接信息
+// 连接信息优先使用端口声明的模板，其次为主机模板，均未配置时与连接地址相同
+func (i *Instance) SetEndpoints(host *DockerHost) {
+	ports := i.HostPorts()
+	if len(ports) == 0 {
+		return
+	}
+	address := host.PublicAddress()
+	i.Endpoints = make(map[string]string, len(ports))
+	i.Connections = make(map[string]string, len(ports))
+	for _, p := range ports {
+		i.Endpoints[p.Label] = net.JoinHostPort(address, strconv.Itoa(p.HostPort))
+		template := p.Template
+		if template == "" {
+			template = host.ConnectTemplate
+		}
+		i.Connections[p.Label] = RenderConnection(template, address, p.HostPort)
+	}
+}
+
+// HostPorts 实例映射到宿主机的端口（兼容未记录端口列表的实例）
+func (i *Instance) HostPorts() []InstancePort {
+	if len(i.Ports) == 0 && i.Port > 0 {
+		return []InstancePort{{Label: DefaultPortLabel, Protocol: ProtocolTCP, HostPort: i.Port}}
+	}
+	return i.Ports
+}
+
+// RenderConnection 按模板生成连接信息，模板为空时返回 host:port
+func RenderConnection(template, host string, port int) string {
+	if template == "" {
+		return net.JoinHostPort(host, strconv.Itoa(port))
+	}
+	return strings.NewReplacer(TemplateHost, host, TemplatePort, strconv.Itoa(port)).Replace(template)
+}
+
+// WebPort 通过 HTTP 网关访问的端口：优先使用标签为 http 的端口，否则为首个 TCP 端口
+func WebPort(ports []InstancePort) (InstancePort, bool) {
+	for _, p := range ports {
+		if p.Label == "http" && p.Protocol != ProtocolUDP {
+			return p, true
+		}
+	}
+	for _, p := range ports {
+		if p.Protocol != ProtocolUDP {
+			return p, true
+		}
+	}
+	return InstancePort{}, false
+}
+
+// StreamPort 通过 TCP 网关访问的端口：优先使用首个标签不为 http 的 TCP 端口，否则为首个 TCP 端口
+func StreamPort(ports []InstancePort) (InstancePort, bool) {
+	for _, p := range ports {
+		if p.Label != "http" && p.Protocol != ProtocolUDP {
+			return p, true
+		}
+	}
+	return WebPort(ports)
+}
+
+// PublicAddress 玩家访问实例使用的主机地址
+// 优先使用配置的公网地址，未配置时取自 Docker 连接地址（本地 Docker 为 localhost）
+func (h *DockerHost) PublicAddress() string {
+	if h.PublicHost != "" {
+		return h.PublicHost
+	}
+	return h.dockerAddress("localhost")
+}
+
+// GatewayAddress 网关转发到实例端口时使用的主机地址，取自 Docker 连接地址（本地 Docker 为 127.0.0.1）
+func (h *DockerHost) GatewayAddress() string {
+	return h.dockerAddress("127.0.0.1")
+}
+
+// dockerAddress Docker 连接地址中的主机名，本地 socket 返回 local
+func (h *DockerHost) dockerAddress(local string) string {
+	if u, err := url.Parse(h.Host); err == nil && (u.Scheme == "tcp" || u.Scheme == "http" || u.Scheme == "https") {
+		if hostname := u.Hostname(); hostname != "" {
+			return hostname
+		}
+	}
+	return local
+}
+
+// TCPGatewayAccess 经 TCP 网关访问实例的连接信息
+type TCPGatewayAccess struct {
+	Address    string `json:"address"`               // 网关地址（host:port）
+	Token      string `json:"token"`                 // 明文连接时首行发送的令牌
+	ServerName string `json:"server_name,omitempty"` // TLS 连接使用的 SNI（网关配置证书时）
+}
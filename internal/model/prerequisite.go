@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// ChallengePrerequisite 题目前置关系表 - 解出全部前置题目后才能解锁该题目
+type ChallengePrerequisite struct {
+	ChallengeID    string    `gorm:"primaryKey;size:36;comment:题目ID" json:"challenge_id"`
+	PrerequisiteID string    `gorm:"primaryKey;size:36;index;comment:前置题目ID" json:"prerequisite_id"`
+	CreatedAt      time.Time `gorm:"autoCreateTime;comment:创建时间" json:"created_at"`
+}
+
+// TableName 指定表名
+func (ChallengePrerequisite) TableName() string { return "challenge_prerequisites" }
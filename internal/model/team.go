@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// Team 队伍表 - 团队模式下玩家以队伍为单位共享实例、解题与积分
+type Team struct {
+	ID          string    `gorm:"primaryKey;size:36;comment:队伍唯一标识" json:"id"`
+	Name        string    `gorm:"uniqueIndex;size:50;not null;comment:队伍名称(唯一)" json:"name"`
+	InviteCode  string    `gorm:"uniqueIndex;size:32;not null;comment:邀请码(仅队伍成员可见)" json:"-"`
+	CaptainID   string    `gorm:"size:36;not null;index;comment:队长用户ID" json:"captain_id"`
+	TotalPoints int       `gorm:"default:0;comment:队伍累计积分" json:"total_points"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;comment:创建时间" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime;comment:更新时间" json:"-"`
+}
+
+// TableName 指定表名
+func (Team) TableName() string { return "teams" }
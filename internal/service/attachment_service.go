@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"cyber-range/internal/infra/storage"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/config"
+	"cyber-range/pkg/logger"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const defaultAttachmentURLTTL = 5 * time.Minute
+
+// AttachmentService 题目附件服务
+// 文件保存在存储后端（本地目录或 S3），玩家通过带 HMAC 签名的短期链接下载
+type AttachmentService struct {
+	db     *gorm.DB
+	cfg    *config.Config
+	store  storage.Storage
+	secret []byte
+}
+
+// NewAttachmentService 创建附件服务
+func NewAttachmentService(db *gorm.DB, cfg *config.Config, store storage.Storage) *AttachmentService {
+	secret := []byte(cfg.Storage.SignSecret)
+	if len(secret) == 0 {
+		// 未配置时随机生成，重启后已签发的链接失效
+		secret = make([]byte, 32)
+		rand.Read(secret)
+		logger.Warn(context.Background(), "storage.sign_secret is not set, using a random secret for attachment links")
+	}
+	return &AttachmentService{db: db, cfg: cfg, store: store, secret: secret}
+}
+
+// AttachmentView 玩家端附件信息（含下载链接）
+type AttachmentView struct {
+	ID        string    `json:"id"`
+	Filename  string    `json:"filename"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Upload 上传题目附件，边写入存储边计算 SHA-256
+// size 为 -1 表示未知长度
+func (s *AttachmentService) Upload(ctx context.Context, challengeID, filename, contentType string, r io.Reader, size int64) (*model.Attachment, error) {
+	var challenge model.Challenge
+	if err := s.db.WithContext(ctx).Select("id").First(&challenge, "id = ?", challengeID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("题目不存在")
+		}
+		return nil, err
+	}
+
+	filename = filepath.Base(filepath.Clean("/" + filename))
+	if filename == "/" || filename == "." {
+		return nil, errors.New("文件名无效")
+	}
+	if maxSize := int64(s.cfg.Storage.MaxSizeMB) << 20; maxSize > 0 && size > maxSize {
+		return nil, fmt.Errorf("附件大小超过 %dMB 限制", s.cfg.Storage.MaxSizeMB)
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	attachment := &model.Attachment{
+		ID:          generateID(),
+		ChallengeID: challengeID,
+		Filename:    filename,
+		ContentType: contentType,
+	}
+	attachment.StorageKey = fmt.Sprintf("challenges/%s/%s", challengeID, attachment.ID)
+
+	hasher := sha256.New()
+	counter := &countingReader{r: io.TeeReader(r, hasher)}
+	if err := s.store.Put(ctx, attachment.StorageKey, counter, size, contentType); err != nil {
+		return nil, fmt.Errorf("保存附件失败: %w", err)
+	}
+	attachment.Size = counter.n
+	attachment.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+
+	if err := s.db.WithContext(ctx).Create(attachment).Error; err != nil {
+		s.store.Delete(ctx, attachment.StorageKey)
+		return nil, fmt.Errorf("保存附件记录失败: %w", err)
+	}
+	return attachment, nil
+}
+
+// ListAttachments 获取题目的附件列表（管理端）
+func (s *AttachmentService) ListAttachments(ctx context.Context, challengeID string) ([]model.Attachment, error) {
+	attachments := make([]model.Attachment, 0)
+	if err := s.db.WithContext(ctx).Where("challenge_id = ?", challengeID).Order("created_at ASC").Find(&attachments).Error; err != nil {
+		return nil, fmt.Errorf("获取附件列表失败: %w", err)
+	}
+	return attachments, nil
+}
+
+// DeleteAttachment 删除附件及其存储对象
+func (s *AttachmentService) DeleteAttachment(ctx context.Context, id string) error {
+	var attachment model.Attachment
+	if err := s.db.WithContext(ctx).First(&attachment, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("附件不存在")
+		}
+		return err
+	}
+
+	if err := s.store.Delete(ctx, attachment.StorageKey); err != nil {
+		return fmt.Errorf("删除附件文件失败: %w", err)
+	}
+	return s.db.WithContext(ctx).Delete(&attachment).Error
+}
+
+// DeleteChallengeAttachments 删除题目的全部附件（题目删除前调用），逐个先删除存储对象再删除记录
+func (s *AttachmentService) DeleteChallengeAttachments(ctx context.Context, challengeID string) error {
+	attachments, err := s.ListAttachments(ctx, challengeID)
+	if err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		if err := s.store.Delete(ctx, attachment.StorageKey); err != nil {
+			return fmt.Errorf("删除附件文件失败: %w", err)
+		}
+		if err := s.db.WithContext(ctx).Delete(&attachment).Error; err != nil {
+			return fmt.Errorf("删除附件记录失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListForPlayer 获取玩家可下载的附件（含签名链接）
+// eventID 非空时校验赛事访问权限，否则题目需已发布
+func (s *AttachmentService) ListForPlayer(ctx context.Context, userID, challengeID, eventID string) ([]AttachmentView, error) {
+	if eventID != "" {
+		if _, err := checkEventAccess(ctx, s.db, userID, eventID, challengeID, false); err != nil {
+			return nil, err
+		}
+	} else {
+		var challenge model.Challenge
+		if err := s.db.WithContext(ctx).Select("id", "status").First(&challenge, "id = ?", challengeID).Error; err != nil || challenge.Status != "published" {
+			return nil, errors.New("题目不存在或未发布")
+		}
+	}
+	if err := checkPrerequisites(ctx, s.db, s.cfg, userID, challengeID); err != nil {
+		return nil, err
+	}
+
+	attachments, err := s.ListAttachments(ctx, challengeID)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := time.Duration(s.cfg.Storage.URLTTL) * time.Second
+	if ttl <= 0 {
+		ttl = defaultAttachmentURLTTL
+	}
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+
+	views := make([]AttachmentView, 0, len(attachments))
+	for _, a := range attachments {
+		views = append(views, AttachmentView{
+			ID:        a.ID,
+			Filename:  a.Filename,
+			Size:      a.Size,
+			SHA256:    a.SHA256,
+			URL:       s.signedURL(a.ID, expiresAt),
+			ExpiresAt: expiresAt,
+		})
+	}
+	return views, nil
+}
+
+// Open 校验下载签名并打开附件
+func (s *AttachmentService) Open(ctx context.Context, id, expires, sig string) (*model.Attachment, io.ReadCloser, error) {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !hmac.Equal([]byte(sig), []byte(s.sign(id, exp))) {
+		return nil, nil, errors.New("下载链接无效")
+	}
+	if time.Now().Unix() > exp {
+		return nil, nil, errors.New("下载链接已过期")
+	}
+
+	var attachment model.Attachment
+	if err := s.db.WithContext(ctx).First(&attachment, "id = ?", id).Error; err != nil {
+		return nil, nil, errors.New("附件不存在")
+	}
+	body, err := s.store.Open(ctx, attachment.StorageKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取附件失败: %w", err)
+	}
+	return &attachment, body, nil
+}
+
+func (s *AttachmentService) signedURL(id string, expiresAt time.Time) string {
+	exp := expiresAt.Unix()
+	return fmt.Sprintf("/api/attachments/%s/download?expires=%d&sig=%s", id, exp, s.sign(id, exp))
+}
+
+// sign 计算下载链接签名 HMAC-SHA256(id:expires)
+func (s *AttachmentService) sign(id string, expires int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "%s:%d", id, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// countingReader 统计实际读取的字节数
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"cyber-range/internal/infra/storage"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/config"
+	"encoding/hex"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestAttachmentService(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("创建测试数据库失败: %v", err)
+	}
+	db.AutoMigrate(&model.Challenge{}, &model.Attachment{}, &model.ChallengePrerequisite{})
+	db.Create(&model.Challenge{ID: "c1", Title: "crypto1", Kind: "static", Flag: "flag{x}", Points: 100})
+
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage() error = %v", err)
+	}
+	svc := NewAttachmentService(db, &config.Config{Storage: config.StorageConfig{MaxSizeMB: 1, URLTTL: 60, SignSecret: "test"}}, store)
+	ctx := context.Background()
+
+	content := "RSA parameters: n=..."
+	attachment, err := svc.Upload(ctx, "c1", "../../task.txt", "text/plain", strings.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	sum := sha256.Sum256([]byte(content))
+	if attachment.Filename != "task.txt" || attachment.Size != int64(len(content)) || attachment.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("附件信息错误, got %+v", attachment)
+	}
+
+	if _, err := svc.Upload(ctx, "missing", "a.txt", "", strings.NewReader("x"), 1); err == nil {
+		t.Error("题目不存在时应上传失败")
+	}
+	if _, err := svc.Upload(ctx, "c1", "big.bin", "", strings.NewReader(""), 2<<20); err == nil {
+		t.Error("超过大小限制时应上传失败")
+	}
+
+	// 未发布题目对玩家不可见
+	if _, err := svc.ListForPlayer(ctx, "u1", "c1", ""); err == nil {
+		t.Error("未发布题目的附件不应返回给玩家")
+	}
+	db.Model(&model.Challenge{}).Where("id = ?", "c1").Update("status", "published")
+
+	views, err := svc.ListForPlayer(ctx, "u1", "c1", "")
+	if err != nil {
+		t.Fatalf("ListForPlayer() error = %v", err)
+	}
+	if len(views) != 1 || views[0].SHA256 != attachment.SHA256 {
+		t.Fatalf("附件列表错误, got %+v", views)
+	}
+
+	link, _ := url.Parse(views[0].URL)
+	expires, sig := link.Query().Get("expires"), link.Query().Get("sig")
+
+	a, body, err := svc.Open(ctx, attachment.ID, expires, sig)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != content || a.Filename != "task.txt" {
+		t.Errorf("下载内容错误, got %q", data)
+	}
+
+	// 篡改签名或有效期
+	if _, _, err := svc.Open(ctx, attachment.ID, expires, strings.Repeat("0", 64)); err == nil {
+		t.Error("错误的签名应被拒绝")
+	}
+	later := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	if _, _, err := svc.Open(ctx, attachment.ID, later, sig); err == nil {
+		t.Error("篡改有效期后签名应失效")
+	}
+	past := time.Now().Add(-time.Minute).Unix()
+	if _, _, err := svc.Open(ctx, attachment.ID, strconv.FormatInt(past, 10), svc.sign(attachment.ID, past)); err == nil {
+		t.Error("过期链接应被拒绝")
+	}
+
+	if err := svc.DeleteAttachment(ctx, attachment.ID); err != nil {
+		t.Fatalf("DeleteAttachment() error = %v", err)
+	}
+	if list, _ := svc.ListAttachments(ctx, "c1"); len(list) != 0 {
+		t.Errorf("删除后附件列表应为空, got %d", len(list))
+	}
+	if _, err := store.Open(ctx, attachment.StorageKey); err == nil {
+		t.Error("删除附件后存储对象应被删除")
+	}
+
+	// 删除题目时清理全部附件
+	var keys []string
+	for _, name := range []string{"a.txt", "b.txt"} {
+		a, err := svc.Upload(ctx, "c1", name, "", strings.NewReader(name), int64(len(name)))
+		if err != nil {
+			t.Fatalf("Upload() error = %v", err)
+		}
+		keys = append(keys, a.StorageKey)
+	}
+	if err := svc.DeleteChallengeAttachments(ctx, "c1"); err != nil {
+		t.Fatalf("DeleteChallengeAttachments() error = %v", err)
+	}
+	if list, _ := svc.ListAttachments(ctx, "c1"); len(list) != 0 {
+		t.Errorf("删除题目附件后列表应为空, got %d", len(list))
+	}
+	for _, key := range keys {
+		if _, err := store.Open(ctx, key); err == nil {
+			t.Errorf("删除题目附件后存储对象 %s 应被删除", key)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"cyber-range/internal/infra/docker"
+	redisRepo "cyber-range/internal/infra/redis"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/logger"
+	"errors"
+	"math"
+	"time"
+)
+
+// 主机容量已满时的准入策略
+const (
+	AdmissionReject = "reject" // 启动任务立即失败
+	AdmissionQueue  = "queue"  // 启动任务排队等待空闲容量
+)
+
+const (
+	defaultQueueTimeout = 5 * time.Minute // 默认排队等待的最长时间
+	queueRetryInterval  = 3 * time.Second // 排队期间重新尝试调度的间隔
+)
+
+// ErrRangeFull 可连接的候选主机容量均已用尽
+var ErrRangeFull = errors.New("靶场资源已满")
+
+// rangeFullReason 容量已满时启动任务的失败原因
+const rangeFullReason = "靶场资源已满，请等待其他实例释放后重试"
+
+// containerLimit 单个容器的资源限制（0 表示使用主机默认值）
+type containerLimit struct {
+	memory int64
+	cpu    float64
+}
+
+// containerLimits 题目实例各容器的资源限制，多容器题目为每个服务容器
+func (s *ChallengeService) containerLimits(ctx context.Context, challenge *model.Challenge) ([]containerLimit, error) {
+	if !challenge.IsCompose() {
+		return []containerLimit{{memory: challenge.MemoryLimit, cpu: challenge.CPULimit}}, nil
+	}
+	defs, err := s.GetServices(ctx, challenge.ID)
+	if err != nil {
+		return nil, err
+	}
+	limits := make([]containerLimit, 0, len(defs))
+	for _, def := range defs {
+		limits = append(limits, containerLimit{memory: def.MemoryLimit, cpu: def.CPULimit})
+	}
+	return limits, nil
+}
+
+// hostReservation 实例在主机上需预留的资源：各容器限制之和，未设置的按主机默认限制计算
+func hostReservation(limits []containerLimit, host *model.DockerHost) redisRepo.HostReservation {
+	var r redisRepo.HostReservation
+	for _, l := range limits {
+		memory, cpu := l.memory, l.cpu
+		if memory <= 0 {
+			memory = host.MemoryLimit
+		}
+		if cpu <= 0 {
+			cpu = host.CPULimit
+		}
+		r.Memory += memory
+		r.MilliCPU += int64(math.Round(cpu * 1000))
+	}
+	return r
+}
+
+// hostCapacity 主机的容量上限
+func hostCapacity(host *model.DockerHost) redisRepo.HostCapacity {
+	return redisRepo.HostCapacity{
+		MaxInstances: host.MaxInstances,
+		MaxMemory:    host.MaxMemory,
+		MaxMilliCPU:  int64(math.Round(host.MaxCPU * 1000)),
+	}
+}
+
+// placeInstance 调度实例主机并预留容量
+// 候选主机均已满时按准入策略处理：reject 直接返回 ErrRangeFull，queue 将任务置为 queued 并定期重试直到超时
+func (s *ChallengeService) placeInstance(ctx context.Context, job *InstanceJob, challenge *model.Challenge, images []string, instanceID string) (*Placement, error) {
+	limits, err := s.containerLimits(ctx, challenge)
+	if err != nil {
+		return nil, err
+	}
+	demand := func(host *model.DockerHost) redisRepo.HostReservation { return hostReservation(limits, host) }
+
+	var deadline time.Time
+	for {
+		placement, err := s.scheduler.Place(ctx, challenge, images, instanceID, demand)
+		if !errors.Is(err, ErrRangeFull) || s.cfg.Scheduler.Admission != AdmissionQueue {
+			return placement, err
+		}
+		if deadline.IsZero() {
+			deadline = time.Now().Add(s.queueTimeout())
+			s.updateJob(ctx, job, JobQueued, "")
+			logger.Info(ctx, "Instance job queued, all Docker hosts are full", "job_id", job.ID, "challenge_id", challenge.ID)
+		}
+		if time.Now().After(deadline) {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(queueRetryInterval):
+		}
+	}
+}
+
+func (s *ChallengeService) queueTimeout() time.Duration {
+	if s.cfg.Scheduler.QueueTimeout > 0 {
+		return time.Duration(s.cfg.Scheduler.QueueTimeout) * time.Second
+	}
+	return defaultQueueTimeout
+}
+
+// releaseReservation 实例停止或回收后释放其在主机上的预留
+func releaseReservation(ctx context.Context, instance *model.Instance) {
+	if err := redisRepo.ReleaseHostReservation(ctx, instance.DockerHostID, instance.ID); err != nil {
+		logger.Warn(ctx, "Failed to release host reservation", "instance_id", instance.ID, "docker_host_id", instance.DockerHostID, "error", err)
+	}
+}
+
+// HostUtilization 主机容量使用情况（管理端主机列表展示）
+type HostUtilization struct {
+	Instances      int     `json:"instances"`       // 已预留的实例数（含创建中）
+	MaxInstances   int     `json:"max_instances"`   // 0 表示不限制
+	MemoryReserved int64   `json:"memory_reserved"` // 已预留内存（字节）
+	MaxMemory      int64   `json:"max_memory"`
+	CPUReserved    float64 `json:"cpu_reserved"` // 已预留 CPU（核心数）
+	MaxCPU         float64 `json:"max_cpu"`
+	UsagePercent   float64 `json:"usage_percent"` // 已设置上限的各项中占用比例最高的一项，均未设置时为 0
+
+	Health *HostHealth `json:"health,omitempty"` // 调度器最近一次探测结果（尚未探测时为空）
+}
+
+// HostHealth 主机健康状态
+type HostHealth struct {
+	Healthy   bool                  `json:"healthy"`
+	Error     string                `json:"error,omitempty"`
+	Resources *docker.HostResources `json:"resources,omitempty"`
+	CheckedAt time.Time             `json:"checked_at"`
+}
+
+// Utilization 主机容量使用情况与健康状态
+func (s *HostScheduler) Utilization(ctx context.Context, host *model.DockerHost) (*HostUtilization, error) {
+	usage, err := redisRepo.GetHostUsage(ctx, host.ID)
+	if err != nil {
+		return nil, err
+	}
+	u := &HostUtilization{
+		Instances:      usage.Instances,
+		MaxInstances:   host.MaxInstances,
+		MemoryReserved: usage.Memory,
+		MaxMemory:      host.MaxMemory,
+		CPUReserved:    float64(usage.MilliCPU) / 1000,
+		MaxCPU:         host.MaxCPU,
+	}
+	if host.MaxInstances > 0 {
+		u.UsagePercent = max(u.UsagePercent, percent(float64(u.Instances), float64(host.MaxInstances)))
+	}
+	if host.MaxMemory > 0 {
+		u.UsagePercent = max(u.UsagePercent, percent(float64(u.MemoryReserved), float64(host.MaxMemory)))
+	}
+	if host.MaxCPU > 0 {
+		u.UsagePercent = max(u.UsagePercent, percent(u.CPUReserved, host.MaxCPU))
+	}
+
+	s.mu.Lock()
+	state, ok := s.states[host.ID]
+	s.mu.Unlock()
+	if ok {
+		u.Health = &HostHealth{Healthy: state.healthy, Error: state.err, Resources: state.resources, CheckedAt: state.checkedAt}
+	}
+	return u, nil
+}
+
+// SyncReservations 按数据库中运行中的实例重建各主机的容量预留（服务启动时调用）
+func (s *HostScheduler) SyncReservations(ctx context.Context) error {
+	hosts, err := s.repo.ListDockerHosts(ctx, false)
+	if err != nil {
+		return err
+	}
+	instances, err := s.repo.GetRunningInstances(ctx)
+	if err != nil {
+		return err
+	}
+	byHost := make(map[string]map[string]redisRepo.HostReservation, len(hosts))
+	for _, host := range hosts {
+		byHost[host.ID] = make(map[string]redisRepo.HostReservation)
+	}
+	for _, instance := range instances {
+		if reservations, ok := byHost[instance.DockerHostID]; ok {
+			reservations[instance.ID] = redisRepo.HostReservation{
+				Memory:   instance.ReservedMemory,
+				MilliCPU: int64(math.Round(instance.ReservedCPU * 1000)),
+			}
+		}
+	}
+	for _, host := range hosts {
+		if err := redisRepo.ResetHostReservations(ctx, host.ID, byHost[host.ID]); err != nil {
+			return err
+		}
+		logger.Info(ctx, "Host reservations synced", "docker_host", host.Name, "instances", len(byHost[host.ID]))
+	}
+	return nil
+}
+
+// percent 保留两位小数的百分比
+func percent(used, limit float64) float64 {
+	return math.Round(used/limit*10000) / 100
+}
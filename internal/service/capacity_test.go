@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	redisRepo "cyber-range/internal/infra/redis"
+	"cyber-range/internal/model"
+	"testing"
+	"time"
+)
+
+func hostUtilization(t *testing.T, svc *ChallengeService, hostID string) *HostUtilization {
+	t.Helper()
+	ctx := context.Background()
+	host, err := svc.repo.GetDockerHostByID(ctx, hostID)
+	if err != nil {
+		t.Fatalf("GetDockerHostByID() error = %v", err)
+	}
+	u, err := svc.scheduler.Utilization(ctx, host)
+	if err != nil {
+		t.Fatalf("Utilization() error = %v", err)
+	}
+	return u
+}
+
+func TestCapacity_SkipsFullHostAndReleases(t *testing.T) {
+	svc, _ := setupStartTest(t)
+	ctx := context.Background()
+	svc.gormDB.Model(&model.DockerHost{}).Where("id = ?", "test-docker-host").Update("max_instances", 2)
+	// host-b 没有运行中的实例（调度优先），但容量已被其他 API 实例正在创建的实例占满
+	svc.gormDB.Create(&model.DockerHost{ID: "host-b", Name: "主机B", PortRangeMin: 20000, PortRangeMax: 40000, MemoryLimit: 134217728, CPULimit: 0.5, MaxInstances: 1, Enabled: true})
+	if exceeded, err := redisRepo.ReserveHostCapacity(ctx, "host-b", "other-instance", redisRepo.HostReservation{Memory: 1}, redisRepo.HostCapacity{MaxInstances: 1}); err != nil || exceeded != "" {
+		t.Fatalf("ReserveHostCapacity() = %q, %v", exceeded, err)
+	}
+
+	instance := startAndWait(t, svc, "test-user-1", "test-challenge-2", "")
+	if instance.DockerHostID != "test-docker-host" {
+		t.Fatalf("容量已满的主机应被跳过, got %s", instance.DockerHostID)
+	}
+	if instance.ReservedMemory != 134217728 || instance.ReservedCPU != 0.5 {
+		t.Errorf("未设置资源限制的题目应按主机默认限制预留, got %d / %v", instance.ReservedMemory, instance.ReservedCPU)
+	}
+
+	u := hostUtilization(t, svc, "test-docker-host")
+	if u.Instances != 1 || u.MemoryReserved != 134217728 || u.CPUReserved != 0.5 || u.UsagePercent != 50 {
+		t.Errorf("主机容量使用情况 = %+v", u)
+	}
+
+	// 服务重启后按运行中的实例重建预留，未确认的预留在有效期内保留
+	redisRepo.ReleaseHostReservation(ctx, "test-docker-host", instance.ID)
+	if err := svc.scheduler.SyncReservations(ctx); err != nil {
+		t.Fatalf("SyncReservations() error = %v", err)
+	}
+	if u := hostUtilization(t, svc, "test-docker-host"); u.Instances != 1 {
+		t.Errorf("重建后的实例数 = %d, want 1", u.Instances)
+	}
+	if u := hostUtilization(t, svc, "host-b"); u.Instances != 1 {
+		t.Errorf("未确认的预留不应被重建清除, got %d", u.Instances)
+	}
+
+	if err := svc.StopInstance(ctx, "test-user-1", "test-challenge-2"); err != nil {
+		t.Fatalf("StopInstance() error = %v", err)
+	}
+	if u := hostUtilization(t, svc, "test-docker-host"); u.Instances != 0 || u.MemoryReserved != 0 || u.UsagePercent != 0 {
+		t.Errorf("停止实例后应释放预留, got %+v", u)
+	}
+}
+
+func TestCapacity_RejectWhenFull(t *testing.T) {
+	svc, _ := setupStartTest(t)
+	ctx := context.Background()
+	svc.gormDB.Model(&model.DockerHost{}).Where("id = ?", "test-docker-host").Update("max_cpu", 0.5)
+
+	startAndWait(t, svc, "test-user-1", "test-challenge-2", "")
+	job, err := svc.StartInstance(ctx, "test-user-2", "test-challenge-2", "", "")
+	if err != nil {
+		t.Fatalf("StartInstance() error = %v", err)
+	}
+	if job = waitJob(t, svc, "test-user-2", job.ID); job.Status != JobFailed || job.Reason != rangeFullReason {
+		t.Errorf("主机容量已满时任务应失败, got %s (%s)", job.Status, job.Reason)
+	}
+	if u := hostUtilization(t, svc, "test-docker-host"); u.Instances != 1 {
+		t.Errorf("失败的任务不应占用容量, got %d", u.Instances)
+	}
+}
+
+func TestCapacity_QueueUntilReleased(t *testing.T) {
+	svc, _ := setupStartTest(t)
+	ctx := context.Background()
+	svc.cfg.Scheduler.Admission = AdmissionQueue
+	svc.cfg.Scheduler.QueueTimeout = 600
+	svc.gormDB.Model(&model.DockerHost{}).Where("id = ?", "test-docker-host").Update("max_instances", 1)
+
+	startAndWait(t, svc, "test-user-1", "test-challenge-2", "")
+	job, err := svc.StartInstance(ctx, "test-user-2", "test-challenge-2", "", "")
+	if err != nil {
+		t.Fatalf("StartInstance() error = %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for job.Status != JobQueued {
+		if job.Done() || time.Now().After(deadline) {
+			t.Fatalf("主机容量已满时任务应排队, got %s", job.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if job, err = svc.GetJob(ctx, "test-user-2", job.ID); err != nil {
+			t.Fatalf("GetJob() error = %v", err)
+		}
+	}
+
+	// 排队期间启动名额的有效期覆盖创建与排队的最长耗时
+	score, err := redisRepo.Client.ZScore(ctx, redisRepo.KeyUserPendingPrefix+"test-user-2", "test-challenge-2").Result()
+	if err != nil {
+		t.Fatalf("排队中的任务应占用启动名额: %v", err)
+	}
+	if expires := time.UnixMilli(int64(score)); expires.Before(time.Now().Add(provisionTimeout + 600*time.Second)) {
+		t.Errorf("启动名额有效期应覆盖排队时间, expires in %v", time.Until(expires))
+	}
+
+	// 其他实例释放后，排队的任务在下一次重试时创建成功
+	if err := svc.StopInstance(ctx, "test-user-1", "test-challenge-2"); err != nil {
+		t.Fatalf("StopInstance() error = %v", err)
+	}
+	if job = waitJob(t, svc, "test-user-2", job.ID); job.Status != JobRunning {
+		t.Errorf("释放容量后排队的任务应成功, got %s (%s)", job.Status, job.Reason)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -19,30 +20,53 @@ import (
 
 type ChallengeService struct {
 	dockerManager *docker.DockerHostManager // Docker 主机管理器
+	engines       docker.EngineProvider     // 容器引擎（默认为 dockerManager，测试中可替换）
 	repo          *db.Repository            // 数据访问层
 	gormDB        *gorm.DB                  // 保留用于兼容现有代码
 	cfg           *config.Config
+	scheduler     *HostScheduler // 实例主机调度器
 }
 
 func NewChallengeService(dockerManager *docker.DockerHostManager, repo *db.Repository, gormDB *gorm.DB, cfg *config.Config) *ChallengeService {
 	return &ChallengeService{
 		dockerManager: dockerManager,
+		engines:       dockerManager,
 		repo:          repo,
 		gormDB:        gormDB,
 		cfg:           cfg,
+		scheduler:     NewHostScheduler(dockerManager, repo, cfg),
 	}
 }
 
-// ListChallenges returns all published challenges for users
-func (s *ChallengeService) ListChallenges(ctx context.Context) ([]model.Challenge, error) {
+// Scheduler 实例主机调度器（由调用方启动后台探测）
+func (s *ChallengeService) Scheduler() *HostScheduler {
+	return s.scheduler
+}
+
+// ListChallenges returns published challenges for users
+// eventID 非空时返回该赛事的题目集合（需已报名且赛事已开始）
+// 未解出前置题目的题目标记为锁定（设置了 hide_locked 的题目不返回）
+func (s *ChallengeService) ListChallenges(ctx context.Context, userID, eventID string) ([]ChallengeView, error) {
 	var challenges []model.Challenge
+	if eventID != "" {
+		if _, err := checkEventAccess(ctx, s.gormDB, userID, eventID, "", false); err != nil {
+			return nil, err
+		}
+		if err := s.gormDB.WithContext(ctx).
+			Where("id IN (?)", s.gormDB.Model(&model.EventChallenge{}).Select("challenge_id").Where("event_id = ?", eventID)).
+			Find(&challenges).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch challenges: %w", err)
+		}
+		return applyPrerequisites(ctx, s.gormDB, s.cfg, userID, challenges)
+	}
+
 	// 只返回已发布的题目给用户
 	if err := s.gormDB.WithContext(ctx).
 		Where("status = ?", "published").
 		Find(&challenges).Error; err != nil {
 		return nil, fmt.Errorf("failed to fetch challenges: %w", err)
 	}
-	return challenges, nil
+	return applyPrerequisites(ctx, s.gormDB, s.cfg, userID, challenges)
 }
 
 // GetChallenge returns a single challenge by ID
@@ -57,171 +81,324 @@ func (s *ChallengeService) GetChallenge(ctx context.Context, id string) (*model.
 	return &challenge, nil
 }
 
-// StartInstance 创建并启动带资源限制的容器
-// 返回：容器ID, 分配的端口, 错误
-func (s *ChallengeService) StartInstance(ctx context.Context, userID, challengeID string) (*model.Instance, error) {
-	// 1. 检查题目是否存在
+// StartInstance 校验并创建实例启动任务，容器在后台创建（拉取镜像可能耗时数分钟）
+// 返回 pending 状态的任务，通过 GetJob / WatchJob 获取进度，任务 running 后附带实例信息
+// eventID 非空时仅允许在赛事比赛窗口内启动该赛事的题目
+// idempotencyKey 非空时，使用相同 key 重试将返回同一个任务（任务未失败且实例仍在运行时）
+func (s *ChallengeService) StartInstance(ctx context.Context, userID, challengeID, eventID, idempotencyKey string) (*InstanceJob, error) {
+	// 1. 检查题目是否存在，以及赛事/发布状态
 	challenge, err := s.GetChallenge(ctx, challengeID)
 	if err != nil {
 		return nil, fmt.Errorf("challenge not found: %w", err)
 	}
-
-	// 2. 检查是否已经有该题目的运行实例（每个题目只能有1个实例）
-	existingInstance, err := redisRepo.GetInstanceByUserAndChallenge(ctx, userID, challengeID)
-	if err == nil && existingInstance != nil {
-		return nil, fmt.Errorf("你已经启动了该题目的实例，请先停止后再重新启动")
+	if eventID != "" {
+		if _, err := checkEventAccess(ctx, s.gormDB, userID, eventID, challengeID, true); err != nil {
+			return nil, err
+		}
+	} else if challenge.Status != "published" {
+		// 未发布的题目只能通过赛事访问
+		return nil, errors.New("题目未发布")
+	}
+	if !challenge.RequiresInstance() {
+		return nil, errors.New("该题目无需启动实例，请直接提交 Flag")
+	}
+	if err := checkPrerequisites(ctx, s.gormDB, s.cfg, userID, challengeID); err != nil {
+		return nil, err
 	}
 
-	// 注意：允许用户同时运行多个不同题目的实例，不限制总数
+	teamID, err := s.resolveTeamID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if idempotencyKey != "" {
+		if job, err := s.idempotentJob(ctx, userID, challengeID, idempotencyKey); err != nil || job != nil {
+			return job, err
+		}
+	}
 
-	// 3. 确定 Docker 主机
-	dockerHostID := challenge.DockerHostID
-	if dockerHostID == "" {
-		// 使用默认主机
-		defaultHost, err := s.repo.GetDefaultDockerHost(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("未找到默认 Docker 主机: %w", err)
+	// 2. 启动锁：同一用户（团队模式下为队伍）对同一题目同时只处理一个启动请求
+	owner := scoreOwner(userID, teamID)
+	lockToken := generateID()
+	if err := s.acquireStartLock(ctx, owner, challengeID, lockToken, idempotencyKey != ""); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := redisRepo.ReleaseStartLock(ctx, owner, challengeID, lockToken); err != nil {
+			logger.Warn(ctx, "Failed to release start lock", "owner", owner, "challenge_id", challengeID, "error", err)
+		}
+	}()
+	if idempotencyKey != "" {
+		// 等待期间持锁的请求可能已用同一 key 创建任务
+		if job, err := s.idempotentJob(ctx, userID, challengeID, idempotencyKey); err != nil || job != nil {
+			return job, err
 		}
-		dockerHostID = defaultHost.ID
 	}
 
-	// 4. 加载 Docker 主机配置
-	dockerHost, err := s.repo.GetDockerHostByID(ctx, dockerHostID)
+	// 3. 检查实例配额并预留启动名额（每个题目只能有1个实例，团队模式下按队伍计）
+	// 配额检查与预留在 Redis Lua 脚本中原子完成，名额在后台任务结束时释放
+	quota := redisRepo.InstanceQuota{
+		UserID:      userID,
+		TeamID:      teamID,
+		ChallengeID: challengeID,
+		UserLimit:   s.cfg.Instance.MaxPerUser,
+		TeamLimit:   s.cfg.Instance.MaxPerTeam,
+		DailyLimit:  s.cfg.Instance.DailyStarts,
+		Day:         time.Now().Format("20060102"),
+		SlotTTL:     s.jobTimeout() + slotTTLMargin,
+	}
+	violation, err := redisRepo.ReserveInstanceSlot(ctx, quota)
 	if err != nil {
-		return nil, fmt.Errorf("Docker 主机配置不存在: %w", err)
+		return nil, fmt.Errorf("检查实例配额失败: %w", err)
+	}
+	if violation != nil {
+		return nil, s.quotaError(ctx, violation, teamID)
 	}
 
-	// 5. 检查主机是否启用
-	if !dockerHost.Enabled {
-		return nil, fmt.Errorf("Docker 主机已禁用: %s", dockerHost.Name)
+	// 4. 创建启动任务并在后台创建容器
+	now := time.Now()
+	job := &InstanceJob{
+		ID:          generateID(),
+		UserID:      userID,
+		TeamID:      teamID,
+		EventID:     eventID,
+		ChallengeID: challengeID,
+		Status:      JobPending,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := saveJob(ctx, job); err != nil {
+		redisRepo.ReleaseInstanceSlot(ctx, quota, true)
+		return nil, fmt.Errorf("创建启动任务失败: %w", err)
+	}
+	if idempotencyKey != "" {
+		if err := redisRepo.SetIdempotentStart(ctx, userID, idempotencyKey, challengeID, job.ID, idempotencyKeyTTL); err != nil {
+			logger.Warn(ctx, "Failed to store idempotency key", "job_id", job.ID, "error", err)
+		}
 	}
 
-	// 6. 获取 Docker 客户端
-	dockerClient, err := s.dockerManager.GetOrCreateClient(ctx, dockerHost)
+	logger.Info(ctx, "Instance job created",
+		"job_id", job.ID,
+		"user_id", userID,
+		"team_id", teamID,
+		"challenge_id", challengeID,
+		"event_id", eventID)
+
+	go s.runInstanceJob(context.WithoutCancel(ctx), *job, challenge, quota)
+	return job, nil
+}
+
+// createInstance 在 Docker 主机上创建实例容器并写入 Redis 与数据库
+// 执行过程中更新任务状态（pulling/creating/starting），失败时任务停留在出错的阶段
+func (s *ChallengeService) createInstance(ctx context.Context, job *InstanceJob, challenge *model.Challenge) (*model.Instance, error) {
+	// 1. 由调度器选择 Docker 主机并预留容量（题目指定主机时固定使用该主机，连接失败或容量已满时切换到下一台候选主机）
+	images, err := s.challengeImages(ctx, challenge)
 	if err != nil {
-		return nil, fmt.Errorf("连接 Docker 主机失败: %w", err)
+		return nil, err
 	}
+	instanceID := generateID()
+	placement, err := s.placeInstance(ctx, job, challenge, images, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	dockerHost, dockerClient := placement.Host, placement.Engine
+	created := false
+	defer func() {
+		if !created {
+			redisRepo.ReleaseHostReservation(context.WithoutCancel(ctx), dockerHost.ID, instanceID)
+		}
+	}()
 
-	// 7. 生成唯一Flag
-	flag := s.generateFlag(userID)
-	logger.Debug(ctx, "Generated flag for user", "user_id", userID, "flag", flag)
-
-	// 8. 启动 Docker 容器
-	imageName := challenge.Image
-	if challenge.ImageID != "" {
-		var dockerImg model.DockerImage
-		if err := s.gormDB.WithContext(ctx).First(&dockerImg, "id = ?", challenge.ImageID).Error; err == nil {
-			imageName = dockerImg.GetFullName()
-		} else {
-			logger.Warn(ctx, "关联镜像不存在，降级使用 challenge.Image", "image_id", challenge.ImageID, "error", err)
+	// 2. 确保镜像存在（不存在时拉取，可能耗时较长；多容器题目拉取全部服务镜像）
+	s.updateJob(ctx, job, JobPulling, "")
+	for _, imageName := range images {
+		if err := dockerClient.EnsureImage(ctx, imageName); err != nil {
+			return nil, fmt.Errorf("镜像准备失败: %w", err)
 		}
 	}
 
-	envVars := []string{fmt.Sprintf("FLAG=%s", flag)}
-	containerID, port, err := dockerClient.StartContainer(ctx, imageName, envVars, challenge.Port, challenge.Privileged, challenge.MemoryLimit, challenge.CPULimit)
+	// 3. 生成实例Flag（动态Flag为每个实例唯一，静态Flag直接注入）
+	flag := s.instanceFlagFor(challenge, job.UserID)
+	logger.Debug(ctx, "Generated flag for user", "user_id", job.UserID, "flag_type", challenge.FlagType, "flag", flag)
+
+	// 4. 启动 Docker 容器（首个容器创建完成、开始启动时任务进入 starting）
+	s.updateJob(ctx, job, JobCreating, "")
+	stageCtx := docker.WithStageReporter(ctx, func(stage string) {
+		if stage == docker.StageStarting && job.Status != JobStarting {
+			s.updateJob(ctx, job, JobStarting, "")
+		}
+	})
+	containerID, ports, services, err := s.startInstanceContainers(stageCtx, dockerClient, challenge, flag)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start container: %w", err)
 	}
+	port := 0
+	if len(ports) > 0 {
+		port = ports[0].HostPort
+	}
 
-	// 9. 创建实例记录
+	// 5. 创建实例记录
 	instance := &model.Instance{
-		ID:           generateID(),
-		UserID:       userID,
-		ChallengeID:  challengeID,
-		ContainerID:  containerID,
-		DockerHostID: dockerHost.ID,
-		Flag:         flag,
-		Port:         port,
-		Status:       "running",
-		ExpiresAt:    time.Now().Add(time.Duration(s.cfg.Instance.TTLHours) * time.Hour),
-		CreatedAt:    time.Now(),
-	}
-
-	// 10. 存储到 Redis (with TTL) and DB (for history)
-	if err := redisRepo.StoreInstance(ctx, instance.ID, userID, challengeID, containerID, flag, port, instance.ExpiresAt); err != nil {
-		// Rollback: kill container if Redis fails
-		dockerClient.StopContainer(ctx, containerID)
+		ID:             instanceID,
+		UserID:         job.UserID,
+		TeamID:         job.TeamID,
+		EventID:        job.EventID,
+		ChallengeID:    job.ChallengeID,
+		ContainerID:    containerID,
+		DockerHostID:   dockerHost.ID,
+		Flag:           flag,
+		Port:           port,
+		Ports:          ports,
+		Status:         "running",
+		Services:       services,
+		GatewayToken:   instanceID + ":" + generateGatewaySecret(),
+		ReservedMemory: placement.Reservation.Memory,
+		ReservedCPU:    float64(placement.Reservation.MilliCPU) / 1000,
+		ExpiresAt:      time.Now().Add(time.Duration(s.cfg.Instance.TTLHours) * time.Hour),
+		CreatedAt:      time.Now(),
+	}
+
+	// 6. 存储到 Redis (with TTL) and DB (for history)
+	if err := redisRepo.StoreInstance(ctx, instance); err != nil {
+		// Rollback: kill containers if Redis fails
+		dockerClient.StopContainers(ctx, instance.ContainerIDs())
 		return nil, fmt.Errorf("failed to store instance in Redis: %w", err)
 	}
 
 	if err := s.gormDB.Create(instance).Error; err != nil {
 		logger.Warn(ctx, "Failed to save instance to DB (non-critical)", "error", err)
 	}
+	created = true
+	if err := redisRepo.ConfirmHostReservation(ctx, dockerHost.ID, instance.ID, placement.Reservation); err != nil {
+		logger.Warn(ctx, "Failed to confirm host reservation", "instance_id", instance.ID, "docker_host", dockerHost.Name, "error", err)
+	}
 
 	logger.Info(ctx, "Instance started successfully",
 		"instance_id", instance.ID,
-		"user_id", userID,
-		"challenge_id", challengeID,
+		"job_id", job.ID,
+		"user_id", job.UserID,
+		"team_id", job.TeamID,
+		"challenge_id", job.ChallengeID,
+		"event_id", job.EventID,
 		"docker_host", dockerHost.Name,
 		"port", port)
 
+	s.setConnectionInfo(instance, dockerHost)
 	return instance, nil
 }
 
+// resolveImageName 获取题目使用的镜像全名（优先使用关联镜像）
+func (s *ChallengeService) resolveImageName(ctx context.Context, challenge *model.Challenge) string {
+	if challenge.ImageID != "" {
+		var dockerImg model.DockerImage
+		if err := s.gormDB.WithContext(ctx).First(&dockerImg, "id = ?", challenge.ImageID).Error; err == nil {
+			return dockerImg.GetFullName()
+		} else {
+			logger.Warn(ctx, "关联镜像不存在，降级使用 challenge.Image", "image_id", challenge.ImageID, "error", err)
+		}
+	}
+	return challenge.Image
+}
+
+// setEndpoints 按实例所在 Docker 主机的地址填充各端口的连接地址
+func (s *ChallengeService) setEndpoints(ctx context.Context, instance *model.Instance) {
+	dockerHost, err := s.repo.GetDockerHostByID(ctx, instance.DockerHostID)
+	if err != nil {
+		logger.Warn(ctx, "Docker host not found for instance endpoints", "instance_id", instance.ID, "docker_host_id", instance.DockerHostID, "error", err)
+		return
+	}
+	s.setConnectionInfo(instance, dockerHost)
+}
+
+// setConnectionInfo 填充实例的连接地址、连接信息与 HTTP 网关访问地址
+func (s *ChallengeService) setConnectionInfo(instance *model.Instance, dockerHost *model.DockerHost) {
+	instance.SetEndpoints(dockerHost)
+	ports := instance.HostPorts()
+	if _, ok := model.WebPort(ports); ok {
+		instance.GatewayURL = s.cfg.Gateway.HTTP.InstanceURL(instance.ID)
+	}
+	if _, ok := model.StreamPort(ports); ok && s.cfg.Gateway.TCP.Enabled && instance.GatewayToken != "" {
+		instance.TCPGateway = tcpGatewayAccess(&s.cfg.Gateway.TCP, instance)
+	}
+}
+
+// startChallengeContainer 按题目配置启动容器并注入 Flag，返回容器ID与端口映射
+func (s *ChallengeService) startChallengeContainer(ctx context.Context, dockerClient docker.ContainerEngine, challenge *model.Challenge, flag string) (string, []model.InstancePort, error) {
+	spec := docker.ContainerSpec{
+		Image:           s.resolveImageName(ctx, challenge),
+		Ports:           challenge.ExposedPorts(),
+		Privileged:      challenge.Privileged,
+		MemoryLimit:     challenge.MemoryLimit,
+		CPULimit:        challenge.CPULimit,
+		Egress:          challenge.EgressPolicy(),
+		EgressAllowlist: challenge.EgressAllowlistEntries(),
+	}
+	if flag != "" {
+		spec.Env = append(spec.Env, fmt.Sprintf("FLAG=%s", flag))
+	}
+	return dockerClient.StartContainer(ctx, spec)
+}
+
 // StopInstance forcefully stops and cleans up an instance
 func (s *ChallengeService) StopInstance(ctx context.Context, userID, challengeID string) error {
-	// Get instance from Redis
-	activeInstances, err := redisRepo.GetUserActiveInstances(ctx, userID)
+	teamID, err := s.resolveTeamID(ctx, userID)
 	if err != nil {
-		return fmt.Errorf("failed to get user instances: %w", err)
+		return err
 	}
 
-	var targetInstanceID string
-	for _, instID := range activeInstances {
-		instData, _ := redisRepo.GetInstance(ctx, instID)
-		if instData["challenge_id"] == challengeID {
-			targetInstanceID = instID
-			break
-		}
+	// Get instance from Redis（团队模式下队伍成员均可停止队伍实例）
+	targetInstanceID, instData, err := s.findActiveInstance(ctx, userID, teamID, challengeID)
+	if err != nil {
+		return err
 	}
 
 	if targetInstanceID == "" {
 		return errors.New("no active instance found for this challenge")
 	}
 
-	instData, err := redisRepo.GetInstance(ctx, targetInstanceID)
-	if err != nil {
-		return fmt.Errorf("failed to get instance data: %w", err)
+	// 实例由启动者创建，Redis 集合以启动者为准
+	ownerID := instData["user_id"]
+	if ownerID == "" {
+		ownerID = userID
 	}
 
-	containerID := instData["container_id"]
-
 	// 从数据库读取完整的实例信息（包含 docker_host_id）
 	var instance model.Instance
 	if err := s.gormDB.WithContext(ctx).First(&instance, "id = ?", targetInstanceID).Error; err != nil {
 		logger.Warn(ctx, "Instance not found in DB", "instance_id", targetInstanceID, "error", err)
 		// 即使数据库查询失败，仍然清理 Redis
-		redisRepo.DeleteInstance(ctx, targetInstanceID, userID)
+		redisRepo.DeleteInstance(ctx, targetInstanceID, ownerID, instData["team_id"])
 		return fmt.Errorf("instance not found in database: %w", err)
 	}
+	applyInstanceContainers(&instance, instData)
+	defer releaseReservation(ctx, &instance)
 
 	// 获取 Docker 主机配置
 	dockerHost, err := s.repo.GetDockerHostByID(ctx, instance.DockerHostID)
 	if err != nil {
 		logger.Warn(ctx, "Docker host not found", "docker_host_id", instance.DockerHostID, "error", err)
 		// 清理 Redis
-		redisRepo.DeleteInstance(ctx, targetInstanceID, userID)
+		redisRepo.DeleteInstance(ctx, targetInstanceID, ownerID, instData["team_id"])
 		s.gormDB.Model(&model.Instance{}).Where("id = ?", targetInstanceID).Update("status", "stopped")
 		return fmt.Errorf("Docker 主机配置不存在: %w", err)
 	}
 
 	// 获取 Docker 客户端
-	dockerClient, err := s.dockerManager.GetOrCreateClient(ctx, dockerHost)
+	dockerClient, err := s.engines.GetEngine(ctx, dockerHost)
 	if err != nil {
 		logger.Warn(ctx, "Failed to get Docker client", "docker_host", dockerHost.Name, "error", err)
 		// 清理 Redis
-		redisRepo.DeleteInstance(ctx, targetInstanceID, userID)
+		redisRepo.DeleteInstance(ctx, targetInstanceID, ownerID, instData["team_id"])
 		s.gormDB.Model(&model.Instance{}).Where("id = ?", targetInstanceID).Update("status", "stopped")
 		return fmt.Errorf("连接 Docker 主机失败: %w", err)
 	}
 
-	// Force kill Docker container
-	if err := dockerClient.StopContainer(ctx, containerID); err != nil {
+	// Force kill Docker containers（多容器实例删除全部服务容器）
+	if err := dockerClient.StopContainers(ctx, instance.ContainerIDs()); err != nil {
 		logger.Warn(ctx, "Failed to stop container (may already be stopped)", "error", err)
 	}
 
 	// Clean up Redis
-	if err := redisRepo.DeleteInstance(ctx, targetInstanceID, userID); err != nil {
+	if err := redisRepo.DeleteInstance(ctx, targetInstanceID, ownerID, instData["team_id"]); err != nil {
 		return fmt.Errorf("failed to delete instance from Redis: %w", err)
 	}
 
@@ -234,53 +411,219 @@ func (s *ChallengeService) StopInstance(ctx context.Context, userID, challengeID
 	return nil
 }
 
-// VerifyFlag checks if submitted flag matches user's instance flag
-func (s *ChallengeService) VerifyFlag(ctx context.Context, userID, challengeID, submittedFlag string) (bool, string, error) {
-	// Get user's active instance
-	activeInstances, err := redisRepo.GetUserActiveInstances(ctx, userID)
+// VerifyResult Flag 提交结果
+type VerifyResult struct {
+	Correct       bool   `json:"correct"`
+	Message       string `json:"message"`
+	Points        int    `json:"points,omitempty"`         // 本次获得的题目分值
+	BonusPoints   int    `json:"bonus_points,omitempty"`   // 血奖励积分
+	BloodRank     int    `json:"blood_rank,omitempty"`     // 1/2/3 表示一/二/三血
+	AlreadySolved bool   `json:"already_solved,omitempty"` // 重复解出，本次不计分
+}
+
+// VerifyFlag checks the submitted flag with the challenge's flag checker
+// 动态 Flag 题目需要运行中的实例；静态/正则/多 Flag 题目无需启动实例即可提交
+// eventID 为空时沿用实例启动时所属的赛事；赛事提交仅在比赛窗口内有效
+// 同一用户（团队模式下为队伍）对同一题目仅首次正确提交计分
+func (s *ChallengeService) VerifyFlag(ctx context.Context, userID, challengeID, eventID, submittedFlag string) (*VerifyResult, error) {
+	teamID, err := s.resolveTeamID(ctx, userID)
 	if err != nil {
-		return false, "", fmt.Errorf("failed to get user instances: %w", err)
+		return &VerifyResult{Message: err.Error()}, nil
 	}
 
-	var correctFlag string
-	for _, instID := range activeInstances {
-		instData, _ := redisRepo.GetInstance(ctx, instID)
-		if instData["challenge_id"] == challengeID {
-			correctFlag = instData["flag"]
-			break
-		}
+	challenge, err := s.GetChallenge(ctx, challengeID)
+	if err != nil {
+		return nil, err
 	}
 
-	if correctFlag == "" {
-		return false, "No active instance found. Please start the challenge first.", nil
+	// Get user's (or team's) active instance
+	_, instData, err := s.findActiveInstance(ctx, userID, teamID, challengeID)
+	if err != nil {
+		return nil, err
+	}
+	if usesInstanceFlag(challenge) && instData["flag"] == "" {
+		return &VerifyResult{Message: "No active instance found. Please start the challenge first."}, nil
 	}
 
-	isCorrect := correctFlag == submittedFlag
+	if eventID == "" {
+		eventID = instData["event_id"]
+	}
+	if eventID != "" {
+		if _, err := checkEventAccess(ctx, s.gormDB, userID, eventID, challengeID, true); err != nil {
+			return &VerifyResult{Message: err.Error()}, nil
+		}
+	} else if challenge.Status != "published" {
+		// 未发布的题目只能通过赛事访问
+		return &VerifyResult{Message: "题目未发布"}, nil
+	}
+	if err := checkPrerequisites(ctx, s.gormDB, s.cfg, userID, challengeID); err != nil {
+		return &VerifyResult{Message: err.Error()}, nil
+	}
 
-	// Record submission in DB
-	challenge, _ := s.GetChallenge(ctx, challengeID)
-	points := 0
-	if isCorrect {
-		points = challenge.Points
-		// Award points to user
-		s.gormDB.Model(&model.User{}).Where("id = ?", userID).Update("total_points", gorm.Expr("total_points + ?", points))
+	checker, err := NewFlagChecker(challenge, instData["flag"])
+	if err != nil {
+		return nil, fmt.Errorf("题目 Flag 配置错误: %w", err)
 	}
+	submittedFlag = strings.TrimSpace(submittedFlag)
+	isCorrect := checker.Check(submittedFlag)
 
 	submission := &model.Submission{
 		ID:          generateID(),
 		UserID:      userID,
+		TeamID:      teamID,
+		EventID:     eventID,
 		ChallengeID: challengeID,
 		Flag:        submittedFlag,
 		IsCorrect:   isCorrect,
-		Points:      points,
 		SubmittedAt: time.Now(),
 	}
-	s.gormDB.Create(submission)
 
-	if isCorrect {
-		return true, "回答正确！你获得了积分。", nil
+	err = s.gormDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if isCorrect {
+			return s.scoreSolve(tx, challenge, submission)
+		}
+		return tx.Create(submission).Error
+	})
+	if isCorrect && isDuplicateKeyError(err) {
+		// 并发的重复正确提交：其他请求已先写入解题唯一键并计分，本次按重复解出记录
+		submission.Scored, submission.SolveKey = false, nil
+		submission.Points, submission.BonusPoints, submission.BloodRank = 0, 0, 0
+		err = s.gormDB.WithContext(ctx).Create(submission).Error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record submission: %w", err)
+	}
+
+	if !isCorrect {
+		return &VerifyResult{Message: "Flag 错误，请重试。"}, nil
+	}
+	if !submission.Scored {
+		return &VerifyResult{Correct: true, AlreadySolved: true, Message: "回答正确！你已解出过该题目，本次不重复计分。"}, nil
+	}
+	invalidateScoreboard(ctx)
+
+	result := &VerifyResult{
+		Correct:     true,
+		Points:      submission.Points,
+		BonusPoints: submission.BonusPoints,
+		BloodRank:   submission.BloodRank,
+		Message:     fmt.Sprintf("回答正确！你获得了 %d 积分。", submission.Points),
+	}
+	if submission.BloodRank > 0 {
+		result.Message = fmt.Sprintf("回答正确！恭喜拿下%s，获得 %d 积分（血奖励 %d）。",
+			bloodNames[submission.BloodRank-1], submission.Points+submission.BonusPoints, submission.BonusPoints)
+	}
+	return result, nil
+}
+
+var bloodNames = []string{"一血", "二血", "三血"}
+
+// SolveRecord 题目解出记录（玩家端展示）
+type SolveRecord struct {
+	UserID      string    `json:"user_id"`
+	Username    string    `json:"username"`
+	TeamID      string    `json:"team_id,omitempty"`
+	TeamName    string    `json:"team_name,omitempty"`
+	BloodRank   int       `json:"blood_rank,omitempty"`
+	BonusPoints int       `json:"bonus_points,omitempty"`
+	SolvedAt    time.Time `json:"solved_at"`
+}
+
+// ListSolves 获取题目的解出记录（按解出时间升序，前三名为一/二/三血）
+// 可见性与题目列表一致：eventID 非空时校验赛事访问权限，否则题目需已发布；锁定且隐藏的题目不可查看
+// 封榜后（全站或赛事）仅返回封榜时间之前的解出记录
+func (s *ChallengeService) ListSolves(ctx context.Context, userID, challengeID, eventID string) ([]SolveRecord, error) {
+	challenge, err := s.GetChallenge(ctx, challengeID)
+	if err != nil {
+		return nil, errors.New("题目不存在或未发布")
+	}
+	if eventID != "" {
+		if _, err := checkEventAccess(ctx, s.gormDB, userID, eventID, challengeID, false); err != nil {
+			return nil, err
+		}
+	} else if challenge.Status != "published" {
+		return nil, errors.New("题目不存在或未发布")
+	}
+	if challenge.HideLocked {
+		if err := checkPrerequisites(ctx, s.gormDB, s.cfg, userID, challengeID); err != nil {
+			return nil, err
+		}
+	}
+
+	// 封榜后不返回封榜时间之后的解出记录
+	cutoff, err := frozenCutoff(ctx, s.gormDB, s.cfg, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]SolveRecord, 0)
+	query := s.gormDB.WithContext(ctx).Table("submissions").
+		Select("submissions.user_id, users.username, submissions.team_id, teams.name AS team_name, submissions.blood_rank, submissions.bonus_points, submissions.submitted_at AS solved_at").
+		Joins("LEFT JOIN users ON users.id = submissions.user_id").
+		Joins("LEFT JOIN teams ON teams.id = submissions.team_id").
+		Where("submissions.challenge_id = ? AND submissions.event_id = ? AND submissions.scored = ?", challengeID, eventID, true)
+	if cutoff != nil {
+		query = query.Where("submissions.submitted_at <= ?", *cutoff)
+	}
+	if err := query.Order("submissions.submitted_at ASC").Scan(&records).Error; err != nil {
+		return nil, fmt.Errorf("获取解出记录失败: %w", err)
+	}
+	return records, nil
+}
+
+// resolveTeamID 团队模式下返回用户所属队伍ID，个人模式返回空字符串
+func (s *ChallengeService) resolveTeamID(ctx context.Context, userID string) (string, error) {
+	return lookupTeamID(ctx, s.gormDB, s.cfg, userID)
+}
+
+// lookupTeamID 团队模式下返回用户所属队伍ID（未加入队伍时报错），个人模式返回空字符串
+func lookupTeamID(ctx context.Context, db *gorm.DB, cfg *config.Config, userID string) (string, error) {
+	if !cfg.Team.Enabled {
+		return "", nil
+	}
+
+	var user model.User
+	if err := db.WithContext(ctx).Select("id", "team_id").First(&user, "id = ?", userID).Error; err != nil {
+		return "", fmt.Errorf("用户不存在: %w", err)
+	}
+	if user.TeamID == "" {
+		return "", errors.New("当前为团队模式，请先创建或加入队伍")
+	}
+	return user.TeamID, nil
+}
+
+// findActiveInstance 查找用户（团队模式下为队伍）在指定题目上的运行实例
+// 返回：实例ID, 实例数据, 错误（不存在时返回空ID）
+func (s *ChallengeService) findActiveInstance(ctx context.Context, userID, teamID, challengeID string) (string, map[string]string, error) {
+	instanceIDs, err := activeInstanceIDs(ctx, userID, teamID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, instID := range instanceIDs {
+		instData, _ := redisRepo.GetInstance(ctx, instID)
+		if instData["challenge_id"] == challengeID {
+			return instID, instData, nil
+		}
+	}
+	return "", map[string]string{}, nil
+}
+
+// activeInstanceIDs 用户（团队模式下为队伍）的运行实例ID
+func activeInstanceIDs(ctx context.Context, userID, teamID string) ([]string, error) {
+	var (
+		instanceIDs []string
+		err         error
+	)
+	if teamID != "" {
+		instanceIDs, err = redisRepo.GetTeamActiveInstances(ctx, teamID)
+	} else {
+		instanceIDs, err = redisRepo.GetUserActiveInstances(ctx, userID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user instances: %w", err)
 	}
-	return false, "Flag 错误，请重试。", nil
+	return instanceIDs, nil
 }
 
 // generateFlag creates a unique flag: flag{userID_timestamp_random}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"cyber-range/internal/infra/db"
 	"cyber-range/internal/infra/docker"
 	"cyber-range/internal/model"
@@ -27,6 +28,9 @@ func setupTestDB(t *testing.T) *gorm.DB {
 		&model.Instance{},
 		&model.User{},
 		&model.Submission{},
+		&model.HintUnlock{},
+		&model.ChallengePrerequisite{},
+		&model.ServiceDefinition{},
 	)
 
 	// 插入测试 Docker 主机
@@ -177,7 +181,7 @@ func TestListChallenges(t *testing.T) {
 	// 更新题目为已发布状态
 	testDB.Model(&model.Challenge{}).Where("id = ?", "test-challenge-1").Update("status", "published")
 
-	challenges, err := svc.ListChallenges(nil)
+	challenges, err := svc.ListChallenges(context.Background(), "test-user-1", "")
 	if err != nil {
 		t.Errorf("ListChallenges() error = %v", err)
 	}
@@ -187,6 +191,39 @@ func TestListChallenges(t *testing.T) {
 	}
 }
 
+// TestStaticChallenge 测试无需实例的题目
+func TestStaticChallenge(t *testing.T) {
+	setupTestRedis(t)
+	svc, testDB := setupTestService(t)
+	ctx := context.Background()
+
+	testDB.Create(&model.Challenge{
+		ID:     "static-challenge",
+		Title:  "附件题",
+		Kind:   "static",
+		Flag:   "flag{attachment}",
+		Points: 200,
+		Status: "published",
+	})
+
+	challenges, _ := svc.ListChallenges(ctx, "test-user-1", "")
+	if len(challenges) != 1 || challenges[0].Kind != "static" {
+		t.Fatalf("题目列表应返回题目类型, got %+v", challenges)
+	}
+
+	if _, err := svc.StartInstance(ctx, "test-user-1", "static-challenge", "", ""); err == nil {
+		t.Error("无需实例的题目不应允许启动实例")
+	}
+
+	result, err := svc.VerifyFlag(ctx, "test-user-1", "static-challenge", "", "flag{attachment}")
+	if err != nil {
+		t.Fatalf("VerifyFlag() error = %v", err)
+	}
+	if !result.Correct || result.Points != 200 {
+		t.Errorf("无需实例的题目应可直接提交, got %+v", result)
+	}
+}
+
 // TestStartInstance_MockSuccess 测试容器启动的核心逻辑
 // 注意：此测试需要Mock Redis，或者跳过Redis部分
 func TestStartInstance_MockSuccess(t *testing.T) {
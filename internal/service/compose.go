@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"cyber-range/internal/infra/docker"
+	"cyber-range/internal/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// maxChallengeServices 多容器题目最多包含的服务数
+const maxChallengeServices = 10
+
+// serviceNamePattern 服务名与网络别名（DNS 标签）
+var serviceNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,48}[a-z0-9])?$`)
+
+// ValidateServices 校验多容器题目的服务定义：至少一个服务暴露端口，服务名、别名与端口标签在组内唯一
+func ValidateServices(services []model.ServiceDefinition) error {
+	if len(services) == 0 {
+		return errors.New("多容器题目至少需要一个服务")
+	}
+	if len(services) > maxChallengeServices {
+		return fmt.Errorf("多容器题目最多 %d 个服务", maxChallengeServices)
+	}
+
+	names := make(map[string]bool)
+	labels := make(map[string]bool)
+	exposed := false
+	for _, svc := range services {
+		if !serviceNamePattern.MatchString(svc.Name) {
+			return fmt.Errorf("服务名 %q 不合法（小写字母、数字与连字符，最长 50 个字符）", svc.Name)
+		}
+		for _, name := range append([]string{svc.Name}, svc.AliasList()...) {
+			if !serviceNamePattern.MatchString(name) {
+				return fmt.Errorf("服务 %s 的网络别名 %q 不合法", svc.Name, name)
+			}
+			if names[name] {
+				return fmt.Errorf("服务名或网络别名 %s 重复", name)
+			}
+			names[name] = true
+		}
+		if strings.TrimSpace(svc.Image) == "" {
+			return fmt.Errorf("服务 %s 未指定镜像", svc.Name)
+		}
+		if svc.Port < 0 || svc.Port > 65535 {
+			return fmt.Errorf("服务 %s 的端口必须在 0-65535 之间", svc.Name)
+		}
+		ports := svc.ExposedPorts()
+		if err := ValidatePorts(ports, labels); err != nil {
+			return fmt.Errorf("服务 %s: %w", svc.Name, err)
+		}
+		if len(ports) > 0 {
+			exposed = true
+		}
+		if svc.MemoryLimit < 0 || svc.CPULimit < 0 {
+			return fmt.Errorf("服务 %s 的资源限制不能为负数", svc.Name)
+		}
+		for _, env := range svc.EnvList() {
+			if key, _, ok := strings.Cut(env, "="); !ok || key == "" {
+				return fmt.Errorf("服务 %s 的环境变量 %q 格式应为 KEY=VALUE", svc.Name, env)
+			}
+		}
+	}
+	if !exposed {
+		return errors.New("多容器题目至少需要一个服务暴露端口")
+	}
+	return nil
+}
+
+// GetServices 获取题目的服务定义（按启动顺序）
+func (s *ChallengeService) GetServices(ctx context.Context, challengeID string) ([]model.ServiceDefinition, error) {
+	services := make([]model.ServiceDefinition, 0)
+	if err := s.gormDB.WithContext(ctx).
+		Where("challenge_id = ?", challengeID).
+		Order("position ASC, created_at ASC").
+		Find(&services).Error; err != nil {
+		return nil, fmt.Errorf("获取题目服务失败: %w", err)
+	}
+	return services, nil
+}
+
+// SetServices 覆盖题目的服务定义（需在事务中调用，调用前应通过 ValidateServices 校验）
+// 未指定启动顺序时按传入顺序启动
+func (s *ChallengeService) SetServices(tx *gorm.DB, challengeID string, services []model.ServiceDefinition) error {
+	if err := tx.Where("challenge_id = ?", challengeID).Delete(&model.ServiceDefinition{}).Error; err != nil {
+		return fmt.Errorf("更新题目服务失败: %w", err)
+	}
+	for i, svc := range services {
+		svc.ID = generateID()
+		svc.ChallengeID = challengeID
+		if svc.Position == 0 {
+			svc.Position = i + 1
+		}
+		if err := tx.Create(&svc).Error; err != nil {
+			return fmt.Errorf("更新题目服务失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// challengeImages 实例需要的全部镜像（多容器题目为各服务的镜像）
+func (s *ChallengeService) challengeImages(ctx context.Context, challenge *model.Challenge) ([]string, error) {
+	if !challenge.IsCompose() {
+		return []string{s.resolveImageName(ctx, challenge)}, nil
+	}
+	services, err := s.GetServices(ctx, challenge.ID)
+	if err != nil {
+		return nil, err
+	}
+	images := make([]string, 0, len(services))
+	for _, svc := range services {
+		images = append(images, svc.Image)
+	}
+	return images, nil
+}
+
+// startInstanceContainers 启动实例容器，返回主容器ID与全部端口映射
+// 多容器题目在同一实例网络中成组启动全部服务，主容器为首个暴露端口的服务，同时返回全部服务容器
+func (s *ChallengeService) startInstanceContainers(ctx context.Context, dockerClient docker.ContainerEngine, challenge *model.Challenge, flag string) (string, []model.InstancePort, []model.InstanceService, error) {
+	if !challenge.IsCompose() {
+		containerID, ports, err := s.startChallengeContainer(ctx, dockerClient, challenge, flag)
+		return containerID, ports, nil, err
+	}
+
+	defs, err := s.GetServices(ctx, challenge.ID)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if len(defs) == 0 {
+		return "", nil, nil, errors.New("多容器题目未配置服务")
+	}
+
+	group := docker.ServiceGroupSpec{
+		Egress:          challenge.EgressPolicy(),
+		EgressAllowlist: challenge.EgressAllowlistEntries(),
+	}
+	flagged := model.FlagServices(defs)
+	for i, def := range defs {
+		spec := docker.ServiceSpec{
+			Name:        def.Name,
+			Aliases:     def.AliasList(),
+			Image:       def.Image,
+			Env:         def.EnvList(),
+			Ports:       def.ExposedPorts(),
+			Privileged:  def.Privileged,
+			MemoryLimit: def.MemoryLimit,
+			CPULimit:    def.CPULimit,
+		}
+		if flag != "" && flagged[i] {
+			spec.Env = append(spec.Env, fmt.Sprintf("FLAG=%s", flag))
+		}
+		group.Services = append(group.Services, spec)
+	}
+
+	started, err := dockerClient.StartServices(ctx, group)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	var (
+		ports    []model.InstancePort
+		primary  string
+		services = make([]model.InstanceService, len(started))
+	)
+	for i, svc := range started {
+		services[i] = model.InstanceService{Name: svc.Name, ContainerID: svc.ContainerID}
+		if len(svc.Ports) > 0 {
+			services[i].Port = svc.Ports[0].HostPort
+			if primary == "" {
+				primary = svc.ContainerID
+			}
+		}
+		ports = append(ports, svc.Ports...)
+	}
+	if primary == "" {
+		primary = services[0].ContainerID
+	}
+	return primary, ports, services, nil
+}
+
+// applyInstanceContainers 以 Redis 中的容器信息覆盖数据库记录（实例重置后容器与端口会变化）
+func applyInstanceContainers(instance *model.Instance, instData map[string]string) {
+	if instData["container_id"] == "" {
+		return
+	}
+	instance.ContainerID = instData["container_id"]
+	if port, err := strconv.Atoi(instData["port"]); err == nil {
+		instance.Port = port
+	}
+	instance.Ports = nil
+	if raw := instData["ports"]; raw != "" {
+		json.Unmarshal([]byte(raw), &instance.Ports)
+	}
+	instance.Services = nil
+	if raw := instData["services"]; raw != "" {
+		json.Unmarshal([]byte(raw), &instance.Services)
+	}
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	redisRepo "cyber-range/internal/infra/redis"
+	"cyber-range/internal/model"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateServices(t *testing.T) {
+	web := model.ServiceDefinition{Name: "web", Image: "app:latest", Port: 80}
+	db := model.ServiceDefinition{Name: "db", Image: "mysql:8", Env: "MYSQL_ROOT_PASSWORD=root\nMYSQL_DATABASE=app", Aliases: "mysql"}
+
+	tests := []struct {
+		name     string
+		services []model.ServiceDefinition
+		wantErr  bool
+	}{
+		{"Web+数据库", []model.ServiceDefinition{web, db}, false},
+		{"无服务", nil, true},
+		{"无暴露端口", []model.ServiceDefinition{db}, true},
+		{"服务名重复", []model.ServiceDefinition{web, {Name: "web", Image: "bot"}}, true},
+		{"别名与服务名冲突", []model.ServiceDefinition{web, {Name: "db", Image: "mysql:8", Aliases: "web"}}, true},
+		{"服务名不合法", []model.ServiceDefinition{{Name: "Web_1", Image: "app", Port: 80}}, true},
+		{"未指定镜像", []model.ServiceDefinition{web, {Name: "bot"}}, true},
+		{"环境变量格式错误", []model.ServiceDefinition{web, {Name: "bot", Image: "bot", Env: "TOKEN"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateServices(tt.services); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateServices() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFlagServices(t *testing.T) {
+	web := model.ServiceDefinition{Name: "web", Image: "app", Port: 80}
+	db := model.ServiceDefinition{Name: "db", Image: "mysql:8"}
+	bot := model.ServiceDefinition{Name: "bot", Image: "bot", Flag: true}
+	tests := []struct {
+		name     string
+		services []model.ServiceDefinition
+		want     map[int]bool
+	}{
+		{"默认注入首个暴露端口的服务", []model.ServiceDefinition{db, web}, map[int]bool{1: true}},
+		{"仅注入标记的服务", []model.ServiceDefinition{db, web, bot}, map[int]bool{2: true}},
+		{"无暴露端口且未标记", []model.ServiceDefinition{db}, map[int]bool{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := model.FlagServices(tt.services); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FlagServices() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComposeInstanceLifecycle(t *testing.T) {
+	svc, engine := setupStartTest(t)
+	ctx := context.Background()
+
+	svc.gormDB.Create(&model.Challenge{ID: "compose-1", Title: "多容器题目", Kind: "compose", Flag: "x", Status: "published"})
+	if err := svc.SetServices(svc.gormDB, "compose-1", []model.ServiceDefinition{
+		{Name: "db", Image: "mysql:8", Env: "MYSQL_ROOT_PASSWORD=root", Aliases: "mysql"},
+		{Name: "web", Image: "app:latest", Port: 80},
+		{Name: "bot", Image: "bot:latest"},
+	}); err != nil {
+		t.Fatalf("SetServices() error = %v", err)
+	}
+
+	// 全部服务在同一组内按顺序启动，主容器为暴露端口的服务
+	instance := startAndWait(t, svc, "test-user-1", "compose-1", "")
+	groups := engine.Groups()
+	if len(groups) != 1 || len(groups[0].Services) != 3 {
+		t.Fatalf("应成组启动 3 个服务, got %+v", groups)
+	}
+	if db := groups[0].Services[0]; db.Name != "db" || !reflect.DeepEqual(db.Aliases, []string{"mysql"}) ||
+		!reflect.DeepEqual(db.Env, []string{"MYSQL_ROOT_PASSWORD=root"}) {
+		t.Errorf("服务配置应包含别名与环境变量，且不注入 Flag, got %+v", db)
+	}
+	if web := groups[0].Services[1]; len(web.Env) != 1 || !strings.HasPrefix(web.Env[0], "FLAG=flag{") {
+		t.Errorf("未标记 flag 时应仅向首个暴露端口的服务注入 Flag, got %+v", web)
+	}
+	if bot := groups[0].Services[2]; len(bot.Env) != 0 {
+		t.Errorf("未标记 flag 的服务不应注入 Flag, got %+v", bot)
+	}
+	if len(instance.Services) != 3 || instance.ContainerID != instance.Services[1].ContainerID ||
+		instance.Port == 0 || instance.Port != instance.Services[1].Port || instance.Services[0].Port != 0 {
+		t.Errorf("实例应记录全部服务容器，主容器为 web, got %+v", instance)
+	}
+
+	// 重置与停止删除整组容器
+	reset, err := svc.ResetInstance(ctx, "test-user-1", "compose-1")
+	if err != nil {
+		t.Fatalf("ResetInstance() error = %v", err)
+	}
+	if !reflect.DeepEqual(engine.Stopped(), instance.ContainerIDs()) || len(reset.Services) != 3 {
+		t.Errorf("重置应删除旧的全部服务容器并重建, got stopped=%v reset=%+v", engine.Stopped(), reset)
+	}
+	data, _ := redisRepo.GetInstance(ctx, instance.ID)
+	if !strings.Contains(data["services"], reset.Services[2].ContainerID) {
+		t.Errorf("Redis 中应记录重置后的服务容器, got %v", data["services"])
+	}
+	var stored model.Instance
+	svc.gormDB.First(&stored, "id = ?", instance.ID)
+	if !reflect.DeepEqual(stored.Services, reset.Services) {
+		t.Errorf("数据库中应记录重置后的服务容器, got %+v", stored.Services)
+	}
+
+	if err := svc.StopInstance(ctx, "test-user-1", "compose-1"); err != nil {
+		t.Fatalf("StopInstance() error = %v", err)
+	}
+	if stopped := engine.Stopped(); !reflect.DeepEqual(stopped[3:], reset.ContainerIDs()) {
+		t.Errorf("停止实例应删除全部服务容器, got %v", stopped)
+	}
+}
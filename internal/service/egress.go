@@ -0,0 +1,54 @@
+package service
+
+import (
+	"cyber-range/internal/model"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+)
+
+// hostnamePattern 出站白名单中的域名（RFC 1123 主机名）
+var hostnamePattern = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)*[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// ValidateEgress 校验题目的出站网络策略与白名单（每个条目为 IP、CIDR 或域名）
+// 特权容器可修改宿主机网络规则，只能使用 internet 策略
+func ValidateEgress(policy, allowlist string, privileged bool) error {
+	if privileged && policy != model.EgressInternet {
+		return errors.New("特权容器不支持限制出站网络，出站策略必须是 internet")
+	}
+	switch policy {
+	case model.EgressNone, model.EgressInternet:
+		return nil
+	case model.EgressAllowlist:
+	default:
+		return errors.New("出站策略必须是 none、internet 或 allowlist")
+	}
+
+	entries := (&model.Challenge{EgressAllowlist: allowlist}).EgressAllowlistEntries()
+	if len(entries) == 0 {
+		return errors.New("allowlist 出站策略至少需要一个白名单地址")
+	}
+	for _, entry := range entries {
+		if _, _, err := net.ParseCIDR(entry); err == nil {
+			continue
+		}
+		if net.ParseIP(entry) != nil {
+			continue
+		}
+		if !hostnamePattern.MatchString(entry) {
+			return fmt.Errorf("无效的出站白名单地址: %s", entry)
+		}
+	}
+	return nil
+}
+
+// HasPrivilegedService 服务定义中是否有以特权模式运行的服务
+func HasPrivilegedService(services []model.ServiceDefinition) bool {
+	for _, svc := range services {
+		if svc.Privileged {
+			return true
+		}
+	}
+	return false
+}
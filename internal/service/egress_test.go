@@ -0,0 +1,51 @@
+package service
+
+import (
+	"cyber-range/internal/model"
+	"reflect"
+	"testing"
+)
+
+func TestValidateEgress(t *testing.T) {
+	tests := []struct {
+		policy     string
+		allowlist  string
+		privileged bool
+		wantErr    bool
+	}{
+		{model.EgressInternet, "", false, false},
+		{model.EgressNone, "", false, false},
+		{model.EgressAllowlist, "10.0.0.0/8\napi.example.com, 1.1.1.1", false, false},
+		{model.EgressAllowlist, "", false, true},
+		{model.EgressAllowlist, "1.1.1.1; rm -rf /", false, true},
+		{"proxy", "", false, true},
+		{model.EgressInternet, "", true, false},
+		{model.EgressNone, "", true, true},
+		{model.EgressAllowlist, "1.1.1.1", true, true},
+	}
+	for _, tt := range tests {
+		if err := ValidateEgress(tt.policy, tt.allowlist, tt.privileged); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateEgress(%q, %q, %v) error = %v, wantErr %v", tt.policy, tt.allowlist, tt.privileged, err, tt.wantErr)
+		}
+	}
+}
+
+func TestStartInstance_EgressSpec(t *testing.T) {
+	svc, engine := setupStartTest(t)
+	svc.gormDB.Model(&model.Challenge{}).Where("id = ?", "test-challenge-1").
+		Updates(map[string]interface{}{"egress": model.EgressAllowlist, "egress_allowlist": "10.0.0.0/8\napi.example.com"})
+
+	startAndWait(t, svc, "test-user-1", "test-challenge-1", "")
+	startAndWait(t, svc, "test-user-1", "test-challenge-2", "")
+
+	specs := engine.Specs()
+	if len(specs) != 2 {
+		t.Fatalf("应创建 2 个容器, got %d", len(specs))
+	}
+	if specs[0].Egress != model.EgressAllowlist || !reflect.DeepEqual(specs[0].EgressAllowlist, []string{"10.0.0.0/8", "api.example.com"}) {
+		t.Errorf("容器配置应包含题目的出站白名单, got %+v", specs[0])
+	}
+	if specs[1].Egress != model.EgressInternet {
+		t.Errorf("未设置出站策略的题目默认允许访问外网, got %q", specs[1].Egress)
+	}
+}
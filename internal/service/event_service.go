@@ -0,0 +1,366 @@
+package service
+
+import (
+	"context"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/config"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// EventService 赛事服务
+type EventService struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+// NewEventService 创建赛事服务
+func NewEventService(db *gorm.DB, cfg *config.Config) *EventService {
+	return &EventService{db: db, cfg: cfg}
+}
+
+// AdminEventView 管理端赛事视图（包含邀请码与统计信息）
+type AdminEventView struct {
+	model.Event
+	InviteCode       string   `json:"invite_code,omitempty"`
+	ChallengeIDs     []string `json:"challenge_ids"`
+	ParticipantCount int64    `json:"participant_count"`
+}
+
+// PlayerEventView 玩家端赛事视图
+type PlayerEventView struct {
+	model.Event
+	Status     string `json:"status"`     // upcoming / running / ended
+	Registered bool   `json:"registered"` // 当前玩家是否已报名
+}
+
+// CreateEvent 创建赛事并设置题目集合
+func (s *EventService) CreateEvent(ctx context.Context, event *model.Event, challengeIDs []string) (*AdminEventView, error) {
+	if err := validateEvent(event); err != nil {
+		return nil, err
+	}
+
+	event.ID = uuid.New().String()
+	if event.Visibility == "invite_only" {
+		event.InviteCode = generateInviteCode()
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(event).Error; err != nil {
+			return fmt.Errorf("创建赛事失败: %w", err)
+		}
+		return replaceEventChallenges(tx, event.ID, challengeIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetEvent(ctx, event.ID)
+}
+
+// UpdateEvent 更新赛事基本信息
+func (s *EventService) UpdateEvent(ctx context.Context, eventID string, updates *model.Event) (*AdminEventView, error) {
+	if err := validateEvent(updates); err != nil {
+		return nil, err
+	}
+
+	var event model.Event
+	if err := s.db.WithContext(ctx).First(&event, "id = ?", eventID).Error; err != nil {
+		return nil, errors.New("赛事不存在")
+	}
+
+	// 由公开改为邀请制时生成邀请码
+	inviteCode := event.InviteCode
+	if updates.Visibility == "invite_only" && inviteCode == "" {
+		inviteCode = generateInviteCode()
+	}
+
+	if err := s.db.WithContext(ctx).Model(&event).Select(
+		"name", "description", "visibility", "invite_code",
+		"registration_start_at", "registration_end_at", "start_at", "end_at", "freeze_at",
+	).Updates(&model.Event{
+		Name:                updates.Name,
+		Description:         updates.Description,
+		Visibility:          updates.Visibility,
+		InviteCode:          inviteCode,
+		RegistrationStartAt: updates.RegistrationStartAt,
+		RegistrationEndAt:   updates.RegistrationEndAt,
+		StartAt:             updates.StartAt,
+		EndAt:               updates.EndAt,
+		FreezeAt:            updates.FreezeAt,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("更新赛事失败: %w", err)
+	}
+
+	return s.GetEvent(ctx, eventID)
+}
+
+// DeleteEvent 删除赛事及其题目关联与报名记录（提交记录保留）
+func (s *EventService) DeleteEvent(ctx context.Context, eventID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&model.Event{}, "id = ?", eventID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("赛事不存在")
+		}
+		if err := tx.Delete(&model.EventChallenge{}, "event_id = ?", eventID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.EventParticipant{}, "event_id = ?", eventID).Error
+	})
+}
+
+// SetEventChallenges 覆盖设置赛事的题目集合
+func (s *EventService) SetEventChallenges(ctx context.Context, eventID string, challengeIDs []string) (*AdminEventView, error) {
+	var count int64
+	s.db.WithContext(ctx).Model(&model.Event{}).Where("id = ?", eventID).Count(&count)
+	if count == 0 {
+		return nil, errors.New("赛事不存在")
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return replaceEventChallenges(tx, eventID, challengeIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetEvent(ctx, eventID)
+}
+
+// GetEvent 获取赛事详情（管理端）
+func (s *EventService) GetEvent(ctx context.Context, eventID string) (*AdminEventView, error) {
+	var event model.Event
+	if err := s.db.WithContext(ctx).First(&event, "id = ?", eventID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("赛事不存在")
+		}
+		return nil, err
+	}
+	return s.buildAdminView(ctx, event), nil
+}
+
+// ListEvents 获取全部赛事（管理端）
+func (s *EventService) ListEvents(ctx context.Context) ([]AdminEventView, error) {
+	var events []model.Event
+	if err := s.db.WithContext(ctx).Order("start_at DESC").Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("获取赛事列表失败: %w", err)
+	}
+
+	views := make([]AdminEventView, 0, len(events))
+	for _, event := range events {
+		views = append(views, *s.buildAdminView(ctx, event))
+	}
+	return views, nil
+}
+
+// ListVisibleEvents 获取玩家可见的赛事：公开赛事 + 已报名的邀请制赛事
+func (s *EventService) ListVisibleEvents(ctx context.Context, userID string) ([]PlayerEventView, error) {
+	var joinedIDs []string
+	s.db.WithContext(ctx).Model(&model.EventParticipant{}).Where("user_id = ?", userID).Pluck("event_id", &joinedIDs)
+
+	query := s.db.WithContext(ctx).Where("visibility = ?", "public")
+	if len(joinedIDs) > 0 {
+		query = query.Or("id IN ?", joinedIDs)
+	}
+
+	var events []model.Event
+	if err := query.Order("start_at DESC").Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("获取赛事列表失败: %w", err)
+	}
+
+	joined := make(map[string]bool, len(joinedIDs))
+	for _, id := range joinedIDs {
+		joined[id] = true
+	}
+
+	now := time.Now()
+	views := make([]PlayerEventView, 0, len(events))
+	for _, event := range events {
+		views = append(views, PlayerEventView{
+			Event:      event,
+			Status:     eventStatus(&event, now),
+			Registered: joined[event.ID],
+		})
+	}
+	return views, nil
+}
+
+// RegisterEvent 玩家报名赛事（邀请制赛事需要提供邀请码）
+func (s *EventService) RegisterEvent(ctx context.Context, userID, eventID, inviteCode string) error {
+	var event model.Event
+	if err := s.db.WithContext(ctx).First(&event, "id = ?", eventID).Error; err != nil {
+		return errors.New("赛事不存在")
+	}
+
+	if event.Visibility == "invite_only" && inviteCode != event.InviteCode {
+		return errors.New("邀请码无效")
+	}
+	if !event.IsRegistrationOpen(time.Now()) {
+		return errors.New("当前不在报名时间内")
+	}
+
+	var count int64
+	s.db.WithContext(ctx).Model(&model.EventParticipant{}).
+		Where("event_id = ? AND user_id = ?", eventID, userID).Count(&count)
+	if count > 0 {
+		return errors.New("你已经报名了该赛事")
+	}
+
+	return s.db.WithContext(ctx).Create(&model.EventParticipant{
+		EventID:  eventID,
+		UserID:   userID,
+		JoinedAt: time.Now(),
+	}).Error
+}
+
+// buildAdminView 组装管理端赛事视图
+func (s *EventService) buildAdminView(ctx context.Context, event model.Event) *AdminEventView {
+	view := &AdminEventView{
+		Event:        event,
+		InviteCode:   event.InviteCode,
+		ChallengeIDs: make([]string, 0),
+	}
+	s.db.WithContext(ctx).Model(&model.EventChallenge{}).
+		Where("event_id = ?", event.ID).Order("created_at ASC").Pluck("challenge_id", &view.ChallengeIDs)
+	s.db.WithContext(ctx).Model(&model.EventParticipant{}).
+		Where("event_id = ?", event.ID).Count(&view.ParticipantCount)
+	return view
+}
+
+// replaceEventChallenges 覆盖写入赛事题目集合（需在事务中调用）
+func replaceEventChallenges(tx *gorm.DB, eventID string, challengeIDs []string) error {
+	if err := tx.Delete(&model.EventChallenge{}, "event_id = ?", eventID).Error; err != nil {
+		return err
+	}
+	if len(challengeIDs) == 0 {
+		return nil
+	}
+
+	// 去重并校验题目存在
+	unique := make([]string, 0, len(challengeIDs))
+	seen := make(map[string]bool, len(challengeIDs))
+	for _, id := range challengeIDs {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	var count int64
+	tx.Model(&model.Challenge{}).Where("id IN ?", unique).Count(&count)
+	if count != int64(len(unique)) {
+		return errors.New("题目列表中包含不存在的题目")
+	}
+
+	links := make([]model.EventChallenge, 0, len(unique))
+	now := time.Now()
+	for _, id := range unique {
+		links = append(links, model.EventChallenge{EventID: eventID, ChallengeID: id, CreatedAt: now})
+	}
+	return tx.Create(&links).Error
+}
+
+// validateEvent 校验赛事时间窗口与可见性
+func validateEvent(event *model.Event) error {
+	if event.Visibility == "" {
+		event.Visibility = "public"
+	}
+	if event.Visibility != "public" && event.Visibility != "invite_only" {
+		return errors.New("可见性必须是 public 或 invite_only")
+	}
+	if !event.EndAt.After(event.StartAt) {
+		return errors.New("结束时间必须晚于开始时间")
+	}
+	if event.RegistrationStartAt != nil && event.RegistrationEndAt != nil &&
+		!event.RegistrationEndAt.After(*event.RegistrationStartAt) {
+		return errors.New("报名截止时间必须晚于报名开始时间")
+	}
+	if event.RegistrationEndAt != nil && event.RegistrationEndAt.After(event.EndAt) {
+		return errors.New("报名截止时间不能晚于比赛结束时间")
+	}
+	if event.FreezeAt != nil && (event.FreezeAt.Before(event.StartAt) || event.FreezeAt.After(event.EndAt)) {
+		return errors.New("封榜时间必须在比赛时间窗口内")
+	}
+	return nil
+}
+
+// eventStatus 计算赛事当前状态
+func eventStatus(event *model.Event, now time.Time) string {
+	switch {
+	case now.Before(event.StartAt):
+		return "upcoming"
+	case event.IsRunning(now):
+		return "running"
+	default:
+		return "ended"
+	}
+}
+
+// checkEventAccess 校验玩家在赛事中的访问权限
+// requireRunning 为 true 时要求当前处于比赛窗口内（启动实例、提交Flag），否则只要求比赛已开始（查看题目）
+// challengeID 非空时同时校验题目属于该赛事
+func checkEventAccess(ctx context.Context, db *gorm.DB, userID, eventID, challengeID string, requireRunning bool) (*model.Event, error) {
+	var event model.Event
+	if err := db.WithContext(ctx).First(&event, "id = ?", eventID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("赛事不存在")
+		}
+		return nil, err
+	}
+
+	var count int64
+	db.WithContext(ctx).Model(&model.EventParticipant{}).
+		Where("event_id = ? AND user_id = ?", eventID, userID).Count(&count)
+	if count == 0 {
+		return nil, errors.New("你尚未报名该赛事")
+	}
+
+	now := time.Now()
+	if now.Before(event.StartAt) {
+		return nil, errors.New("赛事尚未开始")
+	}
+	if requireRunning && !event.IsRunning(now) {
+		return nil, errors.New("赛事已结束")
+	}
+
+	if challengeID != "" {
+		db.WithContext(ctx).Model(&model.EventChallenge{}).
+			Where("event_id = ? AND challenge_id = ?", eventID, challengeID).Count(&count)
+		if count == 0 {
+			return nil, errors.New("该题目不属于此赛事")
+		}
+	}
+
+	return &event, nil
+}
+
+// checkEventScoreboardAccess 校验玩家查看赛事积分榜的权限：公开赛事所有玩家可见，邀请制赛事仅报名玩家可见
+func checkEventScoreboardAccess(ctx context.Context, db *gorm.DB, userID, eventID string) error {
+	var event model.Event
+	if err := db.WithContext(ctx).Select("id", "visibility").First(&event, "id = ?", eventID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("赛事不存在")
+		}
+		return err
+	}
+	if event.Visibility != "invite_only" {
+		return nil
+	}
+
+	var count int64
+	if err := db.WithContext(ctx).Model(&model.EventParticipant{}).
+		Where("event_id = ? AND user_id = ?", eventID, userID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("你尚未报名该赛事")
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/config"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupEventTest(t *testing.T) (*EventService, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("创建测试数据库失败: %v", err)
+	}
+	db.AutoMigrate(
+		&model.User{},
+		&model.Team{},
+		&model.Challenge{},
+		&model.Submission{},
+		&model.Event{},
+		&model.EventChallenge{},
+		&model.EventParticipant{},
+		&model.ChallengePrerequisite{},
+	)
+
+	for _, id := range []string{"u1", "u2"} {
+		db.Create(&model.User{ID: id, Username: id, Email: id + "@test.com", PasswordHash: "hash"})
+	}
+	for _, id := range []string{"c1", "c2"} {
+		db.Create(&model.Challenge{ID: id, Title: id, Image: "nginx:alpine", Flag: "flag{x}", Points: 100})
+	}
+
+	return NewEventService(db, &config.Config{}), db
+}
+
+func TestEventService_CreateAndRegister(t *testing.T) {
+	svc, _ := setupEventTest(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	// 非法时间窗口
+	_, err := svc.CreateEvent(ctx, &model.Event{Name: "bad", StartAt: now, EndAt: now.Add(-time.Hour)}, nil)
+	if err == nil {
+		t.Error("结束时间早于开始时间时应创建失败")
+	}
+
+	// 不存在的题目
+	_, err = svc.CreateEvent(ctx, &model.Event{Name: "bad", StartAt: now, EndAt: now.Add(time.Hour)}, []string{"missing"})
+	if err == nil {
+		t.Error("包含不存在的题目时应创建失败")
+	}
+
+	event, err := svc.CreateEvent(ctx, &model.Event{
+		Name:       "邀请赛",
+		Visibility: "invite_only",
+		StartAt:    now.Add(-time.Hour),
+		EndAt:      now.Add(time.Hour),
+	}, []string{"c1", "c1"})
+	if err != nil {
+		t.Fatalf("CreateEvent() error = %v", err)
+	}
+	if event.InviteCode == "" {
+		t.Error("邀请制赛事应生成邀请码")
+	}
+	if len(event.ChallengeIDs) != 1 {
+		t.Errorf("题目集合应去重, got %v", event.ChallengeIDs)
+	}
+
+	// 邀请制赛事对未报名玩家不可见
+	visible, _ := svc.ListVisibleEvents(ctx, "u1")
+	if len(visible) != 0 {
+		t.Errorf("未报名玩家不应看到邀请制赛事, got %d", len(visible))
+	}
+
+	if err := svc.RegisterEvent(ctx, "u1", event.ID, "wrong"); err == nil {
+		t.Error("错误的邀请码应报名失败")
+	}
+	if err := svc.RegisterEvent(ctx, "u1", event.ID, event.InviteCode); err != nil {
+		t.Fatalf("RegisterEvent() error = %v", err)
+	}
+	if err := svc.RegisterEvent(ctx, "u1", event.ID, event.InviteCode); err == nil {
+		t.Error("重复报名应失败")
+	}
+
+	visible, _ = svc.ListVisibleEvents(ctx, "u1")
+	if len(visible) != 1 || !visible[0].Registered || visible[0].Status != "running" {
+		t.Errorf("已报名玩家应看到进行中的赛事, got %+v", visible)
+	}
+
+	// 报名截止后不可报名
+	closed := now.Add(-time.Minute)
+	ended, err := svc.CreateEvent(ctx, &model.Event{
+		Name:              "已截止",
+		StartAt:           now.Add(-time.Hour),
+		EndAt:             now.Add(time.Hour),
+		RegistrationEndAt: &closed,
+	}, nil)
+	if err != nil {
+		t.Fatalf("CreateEvent() error = %v", err)
+	}
+	if err := svc.RegisterEvent(ctx, "u2", ended.ID, ""); err == nil {
+		t.Error("报名截止后应报名失败")
+	}
+}
+
+func TestCheckEventAccess(t *testing.T) {
+	svc, db := setupEventTest(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	running, _ := svc.CreateEvent(ctx, &model.Event{Name: "running", StartAt: now.Add(-time.Hour), EndAt: now.Add(time.Hour)}, []string{"c1"})
+	upcoming, _ := svc.CreateEvent(ctx, &model.Event{Name: "upcoming", StartAt: now.Add(time.Hour), EndAt: now.Add(2 * time.Hour)}, []string{"c1"})
+	past, _ := svc.CreateEvent(ctx, &model.Event{Name: "past", StartAt: now.Add(-2 * time.Hour), EndAt: now.Add(-time.Hour)}, []string{"c1"})
+	for _, e := range []*AdminEventView{running, upcoming, past} {
+		db.Create(&model.EventParticipant{EventID: e.ID, UserID: "u1"})
+	}
+
+	tests := []struct {
+		name           string
+		userID         string
+		eventID        string
+		challengeID    string
+		requireRunning bool
+		wantErr        bool
+	}{
+		{"进行中", "u1", running.ID, "c1", true, false},
+		{"未报名", "u2", running.ID, "c1", true, true},
+		{"题目不属于赛事", "u1", running.ID, "c2", true, true},
+		{"尚未开始", "u1", upcoming.ID, "", false, true},
+		{"已结束时提交", "u1", past.ID, "c1", true, true},
+		{"已结束时查看题目", "u1", past.ID, "", false, false},
+		{"赛事不存在", "u1", "missing", "", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := checkEventAccess(ctx, db, tt.userID, tt.eventID, tt.challengeID, tt.requireRunning)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkEventAccess() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckEventScoreboardAccess(t *testing.T) {
+	svc, db := setupEventTest(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	public, _ := svc.CreateEvent(ctx, &model.Event{Name: "public", StartAt: now.Add(-time.Hour), EndAt: now.Add(time.Hour)}, nil)
+	private, _ := svc.CreateEvent(ctx, &model.Event{Name: "private", Visibility: "invite_only", StartAt: now.Add(-time.Hour), EndAt: now.Add(time.Hour)}, nil)
+	db.Create(&model.EventParticipant{EventID: private.ID, UserID: "u1"})
+
+	tests := []struct {
+		name    string
+		userID  string
+		eventID string
+		wantErr bool
+	}{
+		{"公开赛事未报名", "u2", public.ID, false},
+		{"邀请制赛事已报名", "u1", private.ID, false},
+		{"邀请制赛事未报名", "u2", private.ID, true},
+		{"赛事不存在", "u1", "missing", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkEventScoreboardAccess(ctx, db, tt.userID, tt.eventID); (err != nil) != tt.wantErr {
+				t.Errorf("checkEventScoreboardAccess() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"cyber-range/internal/model"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	maxExposedPorts        = 10  // 每个容器最多暴露的端口数
+	maxConnectTemplateSize = 255 // 连接信息模板最大长度
+)
+
+// ValidatePorts 校验容器暴露的端口列表：标签与端口/协议不重复，协议为 tcp 或 udp（为空时默认 tcp）
+// labels 记录已使用的标签，多容器题目中标签在全部服务间唯一
+func ValidatePorts(ports []model.ChallengePort, labels map[string]bool) error {
+	if len(ports) > maxExposedPorts {
+		return fmt.Errorf("每个容器最多暴露 %d 个端口", maxExposedPorts)
+	}
+	bound := make(map[string]bool, len(ports))
+	for _, p := range ports {
+		if !serviceNamePattern.MatchString(p.Label) {
+			return fmt.Errorf("端口标签 %q 不合法（小写字母、数字与连字符）", p.Label)
+		}
+		if labels[p.Label] {
+			return fmt.Errorf("端口标签 %s 重复", p.Label)
+		}
+		labels[p.Label] = true
+
+		if p.Port < 1 || p.Port > 65535 {
+			return fmt.Errorf("端口 %s 必须在 1-65535 之间", p.Label)
+		}
+		protocol := p.Protocol
+		if protocol == "" {
+			protocol = model.ProtocolTCP
+		}
+		if protocol != model.ProtocolTCP && protocol != model.ProtocolUDP {
+			return errors.New("端口协议必须是 tcp 或 udp")
+		}
+		key := fmt.Sprintf("%d/%s", p.Port, protocol)
+		if bound[key] {
+			return fmt.Errorf("端口 %s 重复暴露", key)
+		}
+		bound[key] = true
+
+		if err := ValidateConnectTemplate(p.Template); err != nil {
+			return fmt.Errorf("端口 %s: %w", p.Label, err)
+		}
+	}
+	return nil
+}
+
+// ValidateConnectTemplate 校验连接信息模板（如 http://{host}:{port}、nc {host} {port}），为空表示使用默认格式
+func ValidateConnectTemplate(template string) error {
+	if template == "" {
+		return nil
+	}
+	if len(template) > maxConnectTemplateSize {
+		return fmt.Errorf("连接信息模板最长 %d 个字符", maxConnectTemplateSize)
+	}
+	if !strings.Contains(template, model.TemplatePort) {
+		return fmt.Errorf("连接信息模板必须包含 %s 占位符", model.TemplatePort)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"cyber-range/internal/model"
+	"reflect"
+	"testing"
+)
+
+func TestValidatePorts(t *testing.T) {
+	tests := []struct {
+		name    string
+		ports   []model.ChallengePort
+		wantErr bool
+	}{
+		{"HTTP+SSH+UDP", []model.ChallengePort{{Label: "http", Port: 80}, {Label: "ssh", Port: 22, Protocol: "tcp"}, {Label: "dns", Port: 53, Protocol: "udp"}}, false},
+		{"同端口不同协议", []model.ChallengePort{{Label: "dns-tcp", Port: 53}, {Label: "dns-udp", Port: 53, Protocol: "udp"}}, false},
+		{"标签重复", []model.ChallengePort{{Label: "http", Port: 80}, {Label: "http", Port: 8080}}, true},
+		{"端口重复", []model.ChallengePort{{Label: "a", Port: 80}, {Label: "b", Port: 80, Protocol: "tcp"}}, true},
+		{"协议不支持", []model.ChallengePort{{Label: "sctp", Port: 80, Protocol: "sctp"}}, true},
+		{"端口越界", []model.ChallengePort{{Label: "http", Port: 70000}}, true},
+		{"标签为空", []model.ChallengePort{{Port: 80}}, true},
+		{"连接模板", []model.ChallengePort{{Label: "http", Port: 80, Template: "http://{host}:{port}/"}}, false},
+		{"连接模板缺少端口", []model.ChallengePort{{Label: "http", Port: 80, Template: "http://{host}/"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidatePorts(tt.ports, map[string]bool{}); (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePorts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// 多容器题目的端口标签在服务间唯一（未声明端口列表的服务以服务名为标签）
+	err := ValidateServices([]model.ServiceDefinition{
+		{Name: "web", Image: "app", Port: 80},
+		{Name: "ssh", Image: "sshd", Ports: []model.ChallengePort{{Label: "web", Port: 22}}},
+	})
+	if err == nil {
+		t.Error("服务间端口标签重复时应返回错误")
+	}
+}
+
+func TestStartInstance_MultiplePorts(t *testing.T) {
+	svc, engine := setupStartTest(t)
+	ctx := context.Background()
+	ports := []model.ChallengePort{{Label: "http", Port: 80}, {Label: "ssh", Port: 22}, {Label: "dns", Port: 53, Protocol: model.ProtocolUDP}}
+	svc.gormDB.Model(&model.Challenge{ID: "test-challenge-1"}).Select("ports").Updates(&model.Challenge{Ports: ports})
+
+	instance := startAndWait(t, svc, "test-user-1", "test-challenge-1", "")
+	if spec := engine.Specs()[0]; !reflect.DeepEqual(spec.Ports, []model.ChallengePort{
+		{Label: "http", Port: 80, Protocol: "tcp"}, {Label: "ssh", Port: 22, Protocol: "tcp"}, {Label: "dns", Port: 53, Protocol: "udp"},
+	}) {
+		t.Errorf("容器应暴露全部端口, got %+v", spec.Ports)
+	}
+	wantEndpoints := map[string]string{"http": "localhost:23456", "ssh": "localhost:23457", "dns": "localhost:23458"}
+	if len(instance.Ports) != 3 || instance.Port != 23456 || !reflect.DeepEqual(instance.Endpoints, wantEndpoints) {
+		t.Errorf("实例应返回每个端口的连接地址, got ports=%+v endpoints=%v", instance.Ports, instance.Endpoints)
+	}
+
+	// 重置后端口映射更新，连接地址随之变化
+	reset, err := svc.ResetInstance(ctx, "test-user-1", "test-challenge-1")
+	if err != nil {
+		t.Fatalf("ResetInstance() error = %v", err)
+	}
+	if reset.Endpoints["dns"] != "localhost:23461" || reset.Ports[2].Protocol != model.ProtocolUDP {
+		t.Errorf("重置后应返回新的连接地址, got %v", reset.Endpoints)
+	}
+
+	// 未声明端口列表的题目使用 port 字段
+	legacy := startAndWait(t, svc, "test-user-1", "test-challenge-2", "")
+	if !reflect.DeepEqual(legacy.Endpoints, map[string]string{model.DefaultPortLabel: "localhost:23462"}) {
+		t.Errorf("未声明端口列表时应暴露 port 字段对应的端口, got %v", legacy.Endpoints)
+	}
+}
+
+func TestListInstances_ConnectionInfo(t *testing.T) {
+	svc, _ := setupStartTest(t)
+	ctx := context.Background()
+	svc.gormDB.Model(&model.DockerHost{ID: "test-docker-host"}).
+		Updates(map[string]interface{}{"public_host": "ctf.example.com", "connect_template": "nc {host} {port}"})
+	ports := []model.ChallengePort{{Label: "http", Port: 80, Template: "http://{host}:{port}"}, {Label: "shell", Port: 9999}}
+	svc.gormDB.Model(&model.Challenge{ID: "test-challenge-1"}).Select("ports").Updates(&model.Challenge{Ports: ports})
+
+	// 端口模板优先，其次为主机模板；连接地址使用主机的公网地址
+	instance := startAndWait(t, svc, "test-user-1", "test-challenge-1", "")
+	wantConnections := map[string]string{"http": "http://ctf.example.com:23456", "shell": "nc ctf.example.com 23457"}
+	if !reflect.DeepEqual(instance.Connections, wantConnections) || instance.Endpoints["http"] != "ctf.example.com:23456" {
+		t.Errorf("启动结果应返回连接信息, got endpoints=%v connections=%v", instance.Endpoints, instance.Connections)
+	}
+	startAndWait(t, svc, "test-user-1", "test-challenge-2", "")
+
+	instances, err := svc.ListInstances(ctx, "test-user-1")
+	if err != nil {
+		t.Fatalf("ListInstances() error = %v", err)
+	}
+	if len(instances) != 2 {
+		t.Fatalf("应返回 2 个运行实例, got %d", len(instances))
+	}
+	byChallenge := make(map[string]model.Instance)
+	for _, inst := range instances {
+		byChallenge[inst.ChallengeID] = inst
+	}
+	if got := byChallenge["test-challenge-1"].Connections; !reflect.DeepEqual(got, wantConnections) {
+		t.Errorf("实例列表应返回连接信息, got %v", got)
+	}
+	if got := byChallenge["test-challenge-2"].Connections; !reflect.DeepEqual(got, map[string]string{model.DefaultPortLabel: "nc ctf.example.com 23458"}) {
+		t.Errorf("未声明端口模板时应使用主机模板, got %v", got)
+	}
+
+	if err := svc.StopInstance(ctx, "test-user-1", "test-challenge-1"); err != nil {
+		t.Fatalf("StopInstance() error = %v", err)
+	}
+	if instances, _ = svc.ListInstances(ctx, "test-user-1"); len(instances) != 1 || instances[0].ChallengeID != "test-challenge-2" {
+		t.Errorf("停止后实例列表应只剩 1 个实例, got %+v", instances)
+	}
+	if instances, _ = svc.ListInstances(ctx, "test-user-2"); len(instances) != 0 {
+		t.Errorf("其他用户不应看到该用户的实例, got %d", len(instances))
+	}
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"crypto/subtle"
+	"cyber-range/internal/model"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Flag 校验方式
+const (
+	FlagTypeDynamic  = "dynamic"   // 每个实例动态生成，通过 FLAG 环境变量注入容器
+	FlagTypeStatic   = "static"    // 静态 Flag，精确匹配
+	FlagTypeStaticCI = "static_ci" // 静态 Flag，忽略大小写
+	FlagTypeRegex    = "regex"     // 正则表达式，需完整匹配
+	FlagTypeMultiple = "multiple"  // 多个可接受的 Flag，每行一个
+)
+
+// FlagChecker Flag 校验器
+type FlagChecker interface {
+	Check(submitted string) bool
+}
+
+// flagCheckerFactory 根据题目配置（及实例动态 Flag）构造校验器
+type flagCheckerFactory func(challenge *model.Challenge, instanceFlag string) (FlagChecker, error)
+
+var flagCheckers = map[string]flagCheckerFactory{
+	FlagTypeDynamic: func(_ *model.Challenge, instanceFlag string) (FlagChecker, error) {
+		if instanceFlag == "" {
+			return nil, errors.New("实例 Flag 为空")
+		}
+		return exactFlagChecker{flag: instanceFlag}, nil
+	},
+	FlagTypeStatic: func(c *model.Challenge, _ string) (FlagChecker, error) {
+		if c.Flag == "" {
+			return nil, errors.New("Flag 不能为空")
+		}
+		return exactFlagChecker{flag: c.Flag}, nil
+	},
+	FlagTypeStaticCI: func(c *model.Challenge, _ string) (FlagChecker, error) {
+		if c.Flag == "" {
+			return nil, errors.New("Flag 不能为空")
+		}
+		return caseInsensitiveFlagChecker{flag: c.Flag}, nil
+	},
+	FlagTypeRegex: func(c *model.Challenge, _ string) (FlagChecker, error) {
+		if c.Flag == "" {
+			return nil, errors.New("正则表达式不能为空")
+		}
+		re, err := regexp.Compile(`^(?:` + c.Flag + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("正则表达式无效: %w", err)
+		}
+		return regexFlagChecker{re: re}, nil
+	},
+	FlagTypeMultiple: func(c *model.Challenge, _ string) (FlagChecker, error) {
+		var flags []string
+		for _, line := range strings.Split(c.Flag, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				flags = append(flags, line)
+			}
+		}
+		if len(flags) == 0 {
+			return nil, errors.New("至少需要一个可接受的 Flag")
+		}
+		return multipleFlagChecker{flags: flags}, nil
+	},
+}
+
+// NewFlagChecker 根据题目的 Flag 校验方式创建校验器
+// instanceFlag 为实例的动态 Flag，仅 dynamic 方式使用
+func NewFlagChecker(challenge *model.Challenge, instanceFlag string) (FlagChecker, error) {
+	factory, ok := flagCheckers[flagTypeOf(challenge)]
+	if !ok {
+		return nil, fmt.Errorf("不支持的 Flag 校验方式: %s", challenge.FlagType)
+	}
+	return factory(challenge, instanceFlag)
+}
+
+// ValidateFlagConfig 校验题目的 Flag 配置（管理端创建/更新题目时调用）
+func ValidateFlagConfig(flagType, flag string) error {
+	challenge := &model.Challenge{FlagType: flagType, Flag: flag}
+	if usesInstanceFlag(challenge) {
+		// 动态 Flag 在启动实例时生成，题目 Flag 仅作为模板保存
+		return nil
+	}
+	_, err := NewFlagChecker(challenge, "")
+	return err
+}
+
+// usesInstanceFlag 是否需要通过运行中的实例校验 Flag
+func usesInstanceFlag(challenge *model.Challenge) bool {
+	return flagTypeOf(challenge) == FlagTypeDynamic
+}
+
+// instanceFlagFor 返回启动实例时注入容器的 Flag
+// dynamic 为每个实例生成的唯一 Flag；静态 Flag 直接注入；regex/multiple 无单一 Flag，不注入
+func (s *ChallengeService) instanceFlagFor(challenge *model.Challenge, userID string) string {
+	switch flagTypeOf(challenge) {
+	case FlagTypeDynamic:
+		return s.generateFlag(userID)
+	case FlagTypeStatic, FlagTypeStaticCI:
+		return challenge.Flag
+	default:
+		return ""
+	}
+}
+
+// flagTypeOf 返回题目的 Flag 校验方式，未设置时默认动态 Flag
+// 无需实例的题目没有动态 Flag，按静态 Flag 校验
+func flagTypeOf(challenge *model.Challenge) string {
+	flagType := challenge.FlagType
+	if flagType == "" {
+		flagType = FlagTypeDynamic
+	}
+	if flagType == FlagTypeDynamic && !challenge.RequiresInstance() {
+		return FlagTypeStatic
+	}
+	return flagType
+}
+
+type exactFlagChecker struct{ flag string }
+
+func (c exactFlagChecker) Check(submitted string) bool {
+	return subtle.ConstantTimeCompare([]byte(c.flag), []byte(submitted)) == 1
+}
+
+type caseInsensitiveFlagChecker struct{ flag string }
+
+func (c caseInsensitiveFlagChecker) Check(submitted string) bool {
+	return strings.EqualFold(c.flag, submitted)
+}
+
+type regexFlagChecker struct{ re *regexp.Regexp }
+
+func (c regexFlagChecker) Check(submitted string) bool {
+	return c.re.MatchString(submitted)
+}
+
+type multipleFlagChecker struct{ flags []string }
+
+func (c multipleFlagChecker) Check(submitted string) bool {
+	for _, flag := range c.flags {
+		if subtle.ConstantTimeCompare([]byte(flag), []byte(submitted)) == 1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"cyber-range/internal/model"
+	"testing"
+)
+
+func TestFlagChecker(t *testing.T) {
+	tests := []struct {
+		name      string
+		flagType  string
+		flag      string
+		instance  string
+		submitted string
+		want      bool
+	}{
+		{"动态-正确", FlagTypeDynamic, "flag{template}", "flag{u1_123_abcd}", "flag{u1_123_abcd}", true},
+		{"动态-模板不可用", FlagTypeDynamic, "flag{template}", "flag{u1_123_abcd}", "flag{template}", false},
+		{"未设置时默认动态", "", "flag{template}", "flag{u1_123_abcd}", "flag{u1_123_abcd}", true},
+		{"静态-正确", FlagTypeStatic, "flag{Static}", "", "flag{Static}", true},
+		{"静态-大小写不同", FlagTypeStatic, "flag{Static}", "", "flag{static}", false},
+		{"忽略大小写", FlagTypeStaticCI, "flag{Static}", "", "FLAG{STATIC}", true},
+		{"正则-匹配", FlagTypeRegex, `flag\{[0-9a-f]{8}\}`, "", "flag{deadbeef}", true},
+		{"正则-需完整匹配", FlagTypeRegex, `flag\{[0-9a-f]{8}\}`, "", "xxflag{deadbeef}xx", false},
+		{"正则-分支需完整匹配", FlagTypeRegex, `flag\{a\}|flag\{b\}`, "", "flag{a}x", false},
+		{"多个-第二个", FlagTypeMultiple, "flag{one}\n flag{two} \n\n", "", "flag{two}", true},
+		{"多个-不匹配", FlagTypeMultiple, "flag{one}\nflag{two}", "", "flag{three}", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker, err := NewFlagChecker(&model.Challenge{FlagType: tt.flagType, Flag: tt.flag}, tt.instance)
+			if err != nil {
+				t.Fatalf("NewFlagChecker() error = %v", err)
+			}
+			if got := checker.Check(tt.submitted); got != tt.want {
+				t.Errorf("Check(%q) = %v, want %v", tt.submitted, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFlagConfig(t *testing.T) {
+	tests := []struct {
+		flagType string
+		flag     string
+		wantErr  bool
+	}{
+		{FlagTypeDynamic, "flag{template}", false},
+		{"", "", false},
+		{FlagTypeStatic, "", true},
+		{FlagTypeRegex, `flag\{(`, true},
+		{FlagTypeMultiple, "\n \n", true},
+		{"unknown", "flag{x}", true},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateFlagConfig(tt.flagType, tt.flag); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateFlagConfig(%q, %q) error = %v, wantErr %v", tt.flagType, tt.flag, err, tt.wantErr)
+		}
+	}
+}
+
+func TestVerifyFlag_StaticWithoutInstance(t *testing.T) {
+	setupTestRedis(t)
+	svc, testDB := setupTestService(t)
+	ctx := context.Background()
+
+	testDB.Model(&model.Challenge{}).Where("id = ?", "test-challenge-1").Updates(map[string]interface{}{
+		"flag_type": FlagTypeStaticCI,
+		"status":    "published",
+	})
+
+	result, err := svc.VerifyFlag(ctx, "test-user-1", "test-challenge-1", "", "flag{wrong}")
+	if err != nil {
+		t.Fatalf("VerifyFlag() error = %v", err)
+	}
+	if result.Correct {
+		t.Error("错误的 Flag 不应通过")
+	}
+
+	result, err = svc.VerifyFlag(ctx, "test-user-1", "test-challenge-1", "", " FLAG{STATIC} ")
+	if err != nil {
+		t.Fatalf("VerifyFlag() error = %v", err)
+	}
+	if !result.Correct || result.Points != 100 {
+		t.Errorf("静态 Flag 无需实例即可提交, got %+v", result)
+	}
+
+	// 动态 Flag 题目仍需运行中的实例
+	testDB.Model(&model.Challenge{}).Where("id = ?", "test-challenge-1").Update("flag_type", FlagTypeDynamic)
+	result, _ = svc.VerifyFlag(ctx, "test-user-1", "test-challenge-1", "", "flag{static}")
+	if result.Correct {
+		t.Error("动态 Flag 题目没有实例时不应通过")
+	}
+}
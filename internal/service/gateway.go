@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"cyber-range/internal/infra/db"
+	redisRepo "cyber-range/internal/infra/redis"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/config"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	gatewayHostCacheTTL = 30 * time.Second // Docker 主机配置缓存时间
+	gatewayDialTimeout  = 5 * time.Second  // 连接实例端口的超时时间
+)
+
+// gatewayResolver 网关共用的实例路由解析：实例数据取自 Redis，Docker 主机配置短时缓存，避免每个连接查询数据库
+type gatewayResolver struct {
+	repo *db.Repository
+
+	mu    sync.Mutex
+	hosts map[string]cachedDockerHost
+}
+
+type cachedDockerHost struct {
+	host     *model.DockerHost
+	loadedAt time.Time
+}
+
+func newGatewayResolver(repo *db.Repository) *gatewayResolver {
+	return &gatewayResolver{repo: repo, hosts: make(map[string]cachedDockerHost)}
+}
+
+// instance 获取实例在 Redis 中的数据，实例不存在（已停止或过期）时返回空
+func (r *gatewayResolver) instance(ctx context.Context, instanceID string) (map[string]string, error) {
+	if instanceID == "" {
+		return nil, nil
+	}
+	return redisRepo.GetInstance(ctx, instanceID)
+}
+
+// target 按 pick 选择实例端口，返回其在 Docker 主机上的转发地址（host:port）
+func (r *gatewayResolver) target(ctx context.Context, instData map[string]string, pick func([]model.InstancePort) (model.InstancePort, bool)) (string, error) {
+	var instance model.Instance
+	applyInstanceContainers(&instance, instData)
+	port, ok := pick(instance.HostPorts())
+	if !ok {
+		return "", errors.New("实例没有 TCP 端口")
+	}
+	host, err := r.dockerHost(ctx, instData["docker_host_id"])
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host.GatewayAddress(), strconv.Itoa(port.HostPort)), nil
+}
+
+// dockerHost 获取 Docker 主机配置（短时缓存）
+func (r *gatewayResolver) dockerHost(ctx context.Context, hostID string) (*model.DockerHost, error) {
+	if hostID == "" {
+		return nil, errors.New("实例数据缺少 Docker 主机")
+	}
+	r.mu.Lock()
+	cached, ok := r.hosts[hostID]
+	r.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) < gatewayHostCacheTTL {
+		return cached.host, nil
+	}
+
+	host, err := r.repo.GetDockerHostByID(ctx, hostID)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.hosts[hostID] = cachedDockerHost{host: host, loadedAt: time.Now()}
+	r.mu.Unlock()
+	return host, nil
+}
+
+// generateGatewaySecret 生成 TCP 网关令牌的随机部分
+func generateGatewaySecret() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// tcpGatewayAccess 实例经 TCP 网关访问的连接信息
+func tcpGatewayAccess(cfg *config.TCPGatewayConfig, instance *model.Instance) *model.TCPGatewayAccess {
+	access := &model.TCPGatewayAccess{Address: cfg.PublicAddress, Token: instance.GatewayToken}
+	if access.Address == "" {
+		access.Address = cfg.Listen
+	}
+	if cfg.TLSEnabled() {
+		access.ServerName = instance.ID + "." + cfg.Domain
+	}
+	return access
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/config"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// HintService 题目提示服务
+// 付费提示解锁后从用户（团队模式下同时从队伍）积分中扣除，并计入积分榜
+type HintService struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+// NewHintService 创建提示服务
+func NewHintService(db *gorm.DB, cfg *config.Config) *HintService {
+	return &HintService{db: db, cfg: cfg}
+}
+
+// HintView 玩家端提示信息（未解锁的付费提示不返回内容）
+type HintView struct {
+	ID       string `json:"id"`
+	Position int    `json:"position"`
+	Cost     int    `json:"cost"`
+	Unlocked bool   `json:"unlocked"`
+	Content  string `json:"content,omitempty"`
+}
+
+// HintUnlockRecord 管理端解锁记录
+type HintUnlockRecord struct {
+	model.HintUnlock
+	Username       string `json:"username"`
+	TeamName       string `json:"team_name,omitempty"`
+	ChallengeTitle string `json:"challenge_title"`
+	HintPosition   int    `json:"hint_position"`
+}
+
+// ListHints 获取题目的提示列表（管理端，按顺序）
+func (s *HintService) ListHints(ctx context.Context, challengeID string) ([]model.Hint, error) {
+	hints := make([]model.Hint, 0)
+	if err := s.db.WithContext(ctx).Where("challenge_id = ?", challengeID).
+		Order("position ASC, created_at ASC").Find(&hints).Error; err != nil {
+		return nil, fmt.Errorf("获取提示列表失败: %w", err)
+	}
+	return hints, nil
+}
+
+// CreateHint 创建提示
+func (s *HintService) CreateHint(ctx context.Context, hint *model.Hint) (*model.Hint, error) {
+	if err := validateHint(hint); err != nil {
+		return nil, err
+	}
+	var count int64
+	s.db.WithContext(ctx).Model(&model.Challenge{}).Where("id = ?", hint.ChallengeID).Count(&count)
+	if count == 0 {
+		return nil, errors.New("题目不存在")
+	}
+
+	hint.ID = generateID()
+	if err := s.db.WithContext(ctx).Create(hint).Error; err != nil {
+		return nil, fmt.Errorf("创建提示失败: %w", err)
+	}
+	return hint, nil
+}
+
+// UpdateHint 更新提示内容、扣分与顺序（已解锁记录保留解锁时的扣分）
+func (s *HintService) UpdateHint(ctx context.Context, id string, input *model.Hint) (*model.Hint, error) {
+	if err := validateHint(input); err != nil {
+		return nil, err
+	}
+
+	var hint model.Hint
+	if err := s.db.WithContext(ctx).First(&hint, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("提示不存在")
+		}
+		return nil, err
+	}
+
+	if err := s.db.WithContext(ctx).Model(&hint).
+		Select("content", "cost", "position").
+		Updates(&model.Hint{Content: input.Content, Cost: input.Cost, Position: input.Position}).Error; err != nil {
+		return nil, fmt.Errorf("更新提示失败: %w", err)
+	}
+	return &hint, nil
+}
+
+// DeleteHint 删除提示，并退还已解锁者被扣除的积分
+func (s *HintService) DeleteHint(ctx context.Context, id string) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&model.Hint{}, "id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("提示不存在")
+		}
+
+		var userIDs, teamIDs []string
+		tx.Model(&model.HintUnlock{}).Where("hint_id = ?", id).Distinct().Pluck("user_id", &userIDs)
+		tx.Model(&model.HintUnlock{}).Where("hint_id = ? AND team_id <> ''", id).Distinct().Pluck("team_id", &teamIDs)
+		if err := tx.Delete(&model.HintUnlock{}, "hint_id = ?", id).Error; err != nil {
+			return err
+		}
+		return recalculateTotals(tx, userIDs, teamIDs)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateScoreboard(ctx)
+	return nil
+}
+
+// ListUnlocks 查看提示解锁记录（管理端），challengeID / hintID 为空表示不过滤
+func (s *HintService) ListUnlocks(ctx context.Context, challengeID, hintID string) ([]HintUnlockRecord, error) {
+	query := s.db.WithContext(ctx).Table("hint_unlocks").
+		Select("hint_unlocks.*, users.username, teams.name AS team_name, challenges.title AS challenge_title, hints.position AS hint_position").
+		Joins("LEFT JOIN users ON users.id = hint_unlocks.user_id").
+		Joins("LEFT JOIN teams ON teams.id = hint_unlocks.team_id").
+		Joins("LEFT JOIN challenges ON challenges.id = hint_unlocks.challenge_id").
+		Joins("LEFT JOIN hints ON hints.id = hint_unlocks.hint_id")
+	if challengeID != "" {
+		query = query.Where("hint_unlocks.challenge_id = ?", challengeID)
+	}
+	if hintID != "" {
+		query = query.Where("hint_unlocks.hint_id = ?", hintID)
+	}
+
+	records := make([]HintUnlockRecord, 0)
+	if err := query.Order("hint_unlocks.unlocked_at DESC").Scan(&records).Error; err != nil {
+		return nil, fmt.Errorf("获取解锁记录失败: %w", err)
+	}
+	return records, nil
+}
+
+// ListForPlayer 获取玩家可见的提示列表
+// 免费提示直接返回内容，付费提示解锁后返回内容
+func (s *HintService) ListForPlayer(ctx context.Context, userID, challengeID, eventID string) ([]HintView, error) {
+	if err := s.checkChallengeAccess(ctx, userID, challengeID, eventID, false); err != nil {
+		return nil, err
+	}
+	teamID, err := lookupTeamID(ctx, s.db, s.cfg, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	hints, err := s.ListHints(ctx, challengeID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := s.db.WithContext(ctx).Model(&model.HintUnlock{}).Where("challenge_id = ? AND event_id = ?", challengeID, eventID)
+	if teamID != "" {
+		query = query.Where("team_id = ?", teamID)
+	} else {
+		query = query.Where("user_id = ?", userID)
+	}
+	var unlockedIDs []string
+	if err := query.Pluck("hint_id", &unlockedIDs).Error; err != nil {
+		return nil, fmt.Errorf("获取解锁记录失败: %w", err)
+	}
+	unlocked := make(map[string]bool, len(unlockedIDs))
+	for _, id := range unlockedIDs {
+		unlocked[id] = true
+	}
+
+	views := make([]HintView, 0, len(hints))
+	for _, h := range hints {
+		view := HintView{ID: h.ID, Position: h.Position, Cost: h.Cost, Unlocked: h.Cost == 0 || unlocked[h.ID]}
+		if view.Unlocked {
+			view.Content = h.Content
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// UnlockHint 解锁提示并扣除积分；已解锁的提示不重复扣分
+// 积分不足时允许解锁，总分可为负
+func (s *HintService) UnlockHint(ctx context.Context, userID, hintID, eventID string) (*HintView, error) {
+	var hint model.Hint
+	if err := s.db.WithContext(ctx).First(&hint, "id = ?", hintID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("提示不存在")
+		}
+		return nil, err
+	}
+	if err := s.checkChallengeAccess(ctx, userID, hint.ChallengeID, eventID, true); err != nil {
+		return nil, err
+	}
+	teamID, err := lookupTeamID(ctx, s.db, s.cfg, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &HintView{ID: hint.ID, Position: hint.Position, Cost: hint.Cost, Unlocked: true, Content: hint.Content}
+	if hint.Cost == 0 {
+		return view, nil
+	}
+
+	charged := false
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		key := fmt.Sprintf("%s:%s:%s", eventID, hint.ID, scoreOwner(userID, teamID))
+		var existing int64
+		if err := tx.Model(&model.HintUnlock{}).Where("unlock_key = ?", key).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return nil
+		}
+
+		if err := tx.Create(&model.HintUnlock{
+			ID:          generateID(),
+			HintID:      hint.ID,
+			ChallengeID: hint.ChallengeID,
+			UserID:      userID,
+			TeamID:      teamID,
+			EventID:     eventID,
+			UnlockKey:   key,
+			Cost:        hint.Cost,
+			UnlockedAt:  time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		charged = true
+
+		if err := tx.Model(&model.User{}).Where("id = ?", userID).
+			Update("total_points", gorm.Expr("total_points - ?", hint.Cost)).Error; err != nil {
+			return err
+		}
+		if teamID != "" {
+			return tx.Model(&model.Team{}).Where("id = ?", teamID).
+				Update("total_points", gorm.Expr("total_points - ?", hint.Cost)).Error
+		}
+		return nil
+	})
+	if isDuplicateKeyError(err) {
+		// 并发解锁同一提示：其他请求已先写入解锁记录并扣分，本次视为已解锁
+		charged, err = false, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解锁提示失败: %w", err)
+	}
+
+	if charged {
+		invalidateScoreboard(ctx)
+	}
+	return view, nil
+}
+
+// checkChallengeAccess 校验玩家能否访问题目：赛事题目校验赛事权限，否则题目需已发布
+func (s *HintService) checkChallengeAccess(ctx context.Context, userID, challengeID, eventID string, requireRunning bool) error {
+	if eventID != "" {
+		_, err := checkEventAccess(ctx, s.db, userID, eventID, challengeID, requireRunning)
+		return err
+	}
+	var challenge model.Challenge
+	if err := s.db.WithContext(ctx).Select("id", "status").First(&challenge, "id = ?", challengeID).Error; err != nil || challenge.Status != "published" {
+		return errors.New("题目不存在或未发布")
+	}
+	return checkPrerequisites(ctx, s.db, s.cfg, userID, challengeID)
+}
+
+// scoreOwner 返回计分主体：团队模式下为队伍，否则为用户
+func scoreOwner(userID, teamID string) string {
+	if teamID != "" {
+		return teamID
+	}
+	return userID
+}
+
+func validateHint(hint *model.Hint) error {
+	if hint.Content == "" {
+		return errors.New("提示内容不能为空")
+	}
+	if hint.Cost < 0 || hint.Cost > 10000 {
+		return errors.New("扣分必须在 0-10000 之间")
+	}
+	return nil
+}
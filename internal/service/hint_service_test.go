@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/config"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupHintTest(t *testing.T, teamMode bool) (*HintService, *gorm.DB) {
+	setupTestRedis(t)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("创建测试数据库失败: %v", err)
+	}
+	db.AutoMigrate(&model.User{}, &model.Team{}, &model.Challenge{}, &model.Submission{}, &model.Hint{}, &model.HintUnlock{}, &model.ChallengePrerequisite{})
+
+	db.Create(&model.Team{ID: "t1", Name: "team1", InviteCode: "code", CaptainID: "u1", TotalPoints: 300})
+	db.Create(&model.User{ID: "u1", Username: "alice", Email: "u1@test.com", PasswordHash: "hash", TeamID: "t1", TotalPoints: 300})
+	db.Create(&model.User{ID: "u2", Username: "bob", Email: "u2@test.com", PasswordHash: "hash", TeamID: "t1"})
+	db.Create(&model.Challenge{ID: "c1", Title: "web1", Category: "Web", Image: "x", Flag: "x", Points: 300, Status: "published"})
+	db.Create(&model.Submission{ID: "s1", UserID: "u1", TeamID: "t1", ChallengeID: "c1", IsCorrect: true, Scored: true, Points: 300, SubmittedAt: time.Now().Add(-time.Hour)})
+
+	return NewHintService(db, &config.Config{Team: config.TeamConfig{Enabled: teamMode}}), db
+}
+
+func TestHintService_Unlock(t *testing.T) {
+	svc, db := setupHintTest(t, false)
+	ctx := context.Background()
+
+	if _, err := svc.CreateHint(ctx, &model.Hint{ChallengeID: "c1", Content: "", Cost: 10}); err == nil {
+		t.Error("提示内容为空时应创建失败")
+	}
+	free, _ := svc.CreateHint(ctx, &model.Hint{ChallengeID: "c1", Content: "看看源码", Position: 1})
+	paid, err := svc.CreateHint(ctx, &model.Hint{ChallengeID: "c1", Content: "注入点在 id 参数", Cost: 50, Position: 2})
+	if err != nil {
+		t.Fatalf("CreateHint() error = %v", err)
+	}
+
+	hints, err := svc.ListForPlayer(ctx, "u1", "c1", "")
+	if err != nil {
+		t.Fatalf("ListForPlayer() error = %v", err)
+	}
+	if len(hints) != 2 || hints[0].ID != free.ID || hints[0].Content == "" || hints[1].Unlocked || hints[1].Content != "" {
+		t.Fatalf("免费提示应直接可见，付费提示未解锁时不返回内容, got %+v", hints)
+	}
+
+	for i := 0; i < 2; i++ {
+		view, err := svc.UnlockHint(ctx, "u1", paid.ID, "")
+		if err != nil {
+			t.Fatalf("UnlockHint() error = %v", err)
+		}
+		if view.Content != paid.Content {
+			t.Errorf("解锁后应返回提示内容, got %+v", view)
+		}
+	}
+
+	var user model.User
+	db.First(&user, "id = ?", "u1")
+	if user.TotalPoints != 250 {
+		t.Errorf("重复解锁不应重复扣分, got total_points=%d", user.TotalPoints)
+	}
+
+	hints, _ = svc.ListForPlayer(ctx, "u1", "c1", "")
+	if !hints[1].Unlocked || hints[1].Content == "" {
+		t.Errorf("已解锁的提示应返回内容, got %+v", hints[1])
+	}
+	// 个人模式下其他用户不共享解锁
+	hints, _ = svc.ListForPlayer(ctx, "u2", "c1", "")
+	if hints[1].Unlocked {
+		t.Error("其他用户不应看到已解锁状态")
+	}
+
+	records, _ := svc.ListUnlocks(ctx, "c1", "")
+	if len(records) != 1 || records[0].Username != "alice" || records[0].ChallengeTitle != "web1" || records[0].HintPosition != 2 {
+		t.Errorf("解锁记录错误, got %+v", records)
+	}
+
+	// 积分榜扣除提示分
+	board, err := NewScoreboardService(db, svc.cfg).GetScoreboard(ctx, "", false)
+	if err != nil {
+		t.Fatalf("GetScoreboard() error = %v", err)
+	}
+	if len(board.Entries) != 1 || board.Entries[0].Points != 250 || board.Entries[0].Penalty != 50 || board.Entries[0].Solves != 1 {
+		t.Errorf("积分榜应扣除提示分, got %+v", board.Entries)
+	}
+
+	// 删除提示后退还扣分
+	if err := svc.DeleteHint(ctx, paid.ID); err != nil {
+		t.Fatalf("DeleteHint() error = %v", err)
+	}
+	db.First(&user, "id = ?", "u1")
+	if user.TotalPoints != 300 {
+		t.Errorf("删除提示后应退还扣分, got total_points=%d", user.TotalPoints)
+	}
+}
+
+func TestHintService_TeamUnlock(t *testing.T) {
+	svc, db := setupHintTest(t, true)
+	ctx := context.Background()
+
+	paid, _ := svc.CreateHint(ctx, &model.Hint{ChallengeID: "c1", Content: "hint", Cost: 30})
+	if _, err := svc.UnlockHint(ctx, "u2", paid.ID, ""); err != nil {
+		t.Fatalf("UnlockHint() error = %v", err)
+	}
+	// 队友再次解锁不重复扣分
+	if _, err := svc.UnlockHint(ctx, "u1", paid.ID, ""); err != nil {
+		t.Fatalf("UnlockHint() error = %v", err)
+	}
+
+	var team model.Team
+	db.First(&team, "id = ?", "t1")
+	if team.TotalPoints != 270 {
+		t.Errorf("队伍积分应扣除一次, got %d", team.TotalPoints)
+	}
+
+	hints, _ := svc.ListForPlayer(ctx, "u1", "c1", "")
+	if !hints[0].Unlocked {
+		t.Error("队伍成员应共享解锁状态")
+	}
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"cyber-range/internal/infra/db"
+	redisRepo "cyber-range/internal/infra/redis"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/config"
+	"cyber-range/pkg/logger"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	gatewayAuthPath      = ".cyber-range/auth"  // 相对实例根路径的票据兑换地址
+	gatewaySessionCookie = "cr_gateway_session" // 网关访问会话 Cookie
+	gatewayTicketTTL     = 1 * time.Minute      // 一次性访问票据有效期
+	gatewaySessionTTL    = 12 * time.Hour       // 访问会话有效期（实例停止后会话随之失效）
+	gatewayShutdownWait  = 10 * time.Second     // 停止网关时等待请求结束的最长时间
+)
+
+// HTTPGateway Web 题目的 HTTP 反向代理网关
+// 按 <instance-id>.<domain> 子域名或 <path_prefix><instance-id>/ 路径将请求转发到实例所在 Docker 主机的映射端口，
+// 支持 WebSocket；路由信息取自 Redis 中的实例数据，实例停止或过期后立即不可访问
+type HTTPGateway struct {
+	cfg       config.HTTPGatewayConfig
+	resolver  *gatewayResolver
+	transport *http.Transport
+	server    *http.Server
+}
+
+// gatewayRoute 请求对应的实例与转发路径
+type gatewayRoute struct {
+	instanceID string
+	root       string // 实例根路径（子域名路由为 /，路径路由为 <prefix><instance-id>/）
+	path       string // 转发到实例的路径
+}
+
+func NewHTTPGateway(cfg config.HTTPGatewayConfig, repo *db.Repository) *HTTPGateway {
+	return &HTTPGateway{
+		cfg:      cfg,
+		resolver: newGatewayResolver(repo),
+		transport: &http.Transport{
+			Proxy:               nil, // 直连实例端口，不走环境变量代理
+			DialContext:         (&net.Dialer{Timeout: gatewayDialTimeout}).DialContext,
+			MaxIdleConnsPerHost: 16,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
+
+// Start 在后台启动网关监听
+func (g *HTTPGateway) Start(ctx context.Context) {
+	g.server = &http.Server{
+		Addr:              g.cfg.Listen,
+		Handler:           g,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	logger.Info(ctx, "HTTP gateway started", "listen", g.cfg.Listen, "domain", g.cfg.Domain,
+		"path_prefix", g.cfg.NormalizedPathPrefix(), "require_owner", g.cfg.RequireOwner)
+
+	go func() {
+		var err error
+		if g.cfg.TLSCert != "" {
+			err = g.server.ListenAndServeTLS(g.cfg.TLSCert, g.cfg.TLSKey)
+		} else {
+			err = g.server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error(ctx, "HTTP gateway failed to run", "error", err)
+		}
+	}()
+}
+
+// Stop 停止网关，等待进行中的请求结束（WebSocket 等长连接会被关闭）
+func (g *HTTPGateway) Stop() {
+	if g.server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), gatewayShutdownWait)
+	defer cancel()
+	if err := g.server.Shutdown(ctx); err != nil {
+		g.server.Close()
+	}
+	g.transport.CloseIdleConnections()
+}
+
+// ServeHTTP 解析实例路由，校验访问会话后转发请求
+func (g *HTTPGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	route, ok := g.route(r)
+	if !ok {
+		http.Error(w, "实例不存在", http.StatusNotFound)
+		return
+	}
+	if r.URL.Path == strings.TrimSuffix(route.root, "/") {
+		// 路径路由访问 /<prefix>/<instance-id> 时补全结尾的 /，保证实例页面的相对链接正确
+		http.Redirect(w, r, route.root, http.StatusFound)
+		return
+	}
+
+	instData, err := g.resolver.instance(ctx, route.instanceID)
+	if err != nil {
+		logger.Error(ctx, "HTTP gateway failed to load instance", "instance_id", route.instanceID, "error", err)
+		http.Error(w, "网关暂时不可用", http.StatusBadGateway)
+		return
+	}
+	if len(instData) == 0 {
+		http.Error(w, "实例不存在或已过期", http.StatusNotFound)
+		return
+	}
+
+	if route.path == "/"+gatewayAuthPath {
+		g.handleAuth(w, r, route)
+		return
+	}
+	if g.cfg.RequireOwner && !g.authorized(r, route.instanceID) {
+		http.Error(w, "请从平台打开实例访问地址", http.StatusForbidden)
+		return
+	}
+
+	addr, err := g.resolver.target(ctx, instData, model.WebPort)
+	if err != nil {
+		logger.Warn(ctx, "HTTP gateway has no route to instance", "instance_id", route.instanceID, "error", err)
+		http.Error(w, "实例不可访问", http.StatusBadGateway)
+		return
+	}
+
+	target := &url.URL{Scheme: "http", Host: addr}
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.Out.URL.Path = route.path
+			pr.Out.URL.RawPath = ""
+			pr.Out.Host = pr.In.Host // 保留原始 Host，便于题目生成正确的绝对链接
+			pr.SetXForwarded()
+			removeCookie(pr.Out, gatewaySessionCookie)
+		},
+		Transport: g.transport,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			logger.Warn(r.Context(), "HTTP gateway upstream error", "instance_id", route.instanceID, "target", target.Host, "error", err)
+			http.Error(w, "实例服务未响应", http.StatusBadGateway)
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// route 按子域名或路径前缀解析请求对应的实例
+func (g *HTTPGateway) route(r *http.Request) (gatewayRoute, bool) {
+	if g.cfg.Domain != "" {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(host)
+		if id, ok := strings.CutSuffix(host, "."+strings.ToLower(g.cfg.Domain)); ok && id != "" && !strings.Contains(id, ".") {
+			return gatewayRoute{instanceID: id, root: "/", path: r.URL.Path}, true
+		}
+	}
+	if prefix := g.cfg.NormalizedPathPrefix(); prefix != "" {
+		if rest, ok := strings.CutPrefix(r.URL.Path, prefix); ok {
+			id, path, _ := strings.Cut(rest, "/")
+			if id != "" {
+				return gatewayRoute{instanceID: id, root: prefix + id + "/", path: "/" + path}, true
+			}
+		}
+	}
+	return gatewayRoute{}, false
+}
+
+// handleAuth 兑换一次性票据，写入访问会话 Cookie 后跳转到实例根路径
+func (g *HTTPGateway) handleAuth(w http.ResponseWriter, r *http.Request, route gatewayRoute) {
+	ctx := r.Context()
+	instanceID, err := redisRepo.ConsumeGatewayTicket(ctx, r.URL.Query().Get("ticket"))
+	if err != nil || instanceID != route.instanceID {
+		http.Error(w, "访问票据无效或已过期，请从平台重新打开", http.StatusForbidden)
+		return
+	}
+
+	b := make([]byte, 32)
+	rand.Read(b)
+	sessionID := hex.EncodeToString(b)
+	if err := redisRepo.StoreGatewaySession(ctx, sessionID, instanceID, gatewaySessionTTL); err != nil {
+		logger.Error(ctx, "HTTP gateway failed to store session", "instance_id", instanceID, "error", err)
+		http.Error(w, "网关暂时不可用", http.StatusBadGateway)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     gatewaySessionCookie,
+		Value:    sessionID,
+		Path:     route.root,
+		MaxAge:   int(gatewaySessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil || strings.HasPrefix(g.cfg.PublicURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, route.root, http.StatusFound)
+}
+
+// authorized 请求是否携带该实例的访问会话
+func (g *HTTPGateway) authorized(r *http.Request, instanceID string) bool {
+	cookie, err := r.Cookie(gatewaySessionCookie)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	sessionInstance, err := redisRepo.GetGatewaySession(r.Context(), cookie.Value)
+	return err == nil && sessionInstance == instanceID
+}
+
+// removeCookie 从转发的请求中移除指定 Cookie，其余 Cookie 原样保留
+func removeCookie(r *http.Request, name string) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, c := range cookies {
+		if c.Name != name {
+			r.AddCookie(c)
+		}
+	}
+}
+
+// GatewayAccess 生成实例的 HTTP 网关访问地址
+// 网关要求访问会话时附带一次性票据，玩家打开该地址后获得仅对该实例有效的会话 Cookie
+func (s *ChallengeService) GatewayAccess(ctx context.Context, userID, instanceID string) (string, error) {
+	gw := s.cfg.Gateway.HTTP
+	if !gw.Enabled {
+		return "", errors.New("HTTP 网关未启用")
+	}
+	teamID, err := s.resolveTeamID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	instanceIDs, err := activeInstanceIDs(ctx, userID, teamID)
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(instanceIDs, instanceID) {
+		return "", errors.New("实例不存在或已停止")
+	}
+
+	instData, err := redisRepo.GetInstance(ctx, instanceID)
+	if err != nil || len(instData) == 0 {
+		return "", errors.New("实例不存在或已停止")
+	}
+	var instance model.Instance
+	applyInstanceContainers(&instance, instData)
+	if _, ok := model.WebPort(instance.HostPorts()); !ok {
+		return "", errors.New("该实例没有可通过网关访问的端口")
+	}
+
+	accessURL := gw.InstanceURL(instanceID)
+	if accessURL == "" {
+		return "", errors.New("HTTP 网关未配置根域名或路径前缀")
+	}
+	if !gw.RequireOwner {
+		return accessURL, nil
+	}
+
+	b := make([]byte, 24)
+	rand.Read(b)
+	ticket := hex.EncodeToString(b)
+	if err := redisRepo.CreateGatewayTicket(ctx, ticket, instanceID, gatewayTicketTTL); err != nil {
+		return "", fmt.Errorf("生成访问票据失败: %w", err)
+	}
+	return accessURL + gatewayAuthPath + "?ticket=" + ticket, nil
+}
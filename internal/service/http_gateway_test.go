@@ -0,0 +1,175 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"cyber-range/pkg/config"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHTTPGatewayConfig_InstanceURL(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.HTTPGatewayConfig
+		want string
+	}{
+		{"未启用", config.HTTPGatewayConfig{Domain: "inst.example.com"}, ""},
+		{"子域名", config.HTTPGatewayConfig{Enabled: true, Domain: "inst.example.com", TLSCert: "cert.pem"}, "https://abc.inst.example.com/"},
+		{"子域名+公网端口", config.HTTPGatewayConfig{Enabled: true, Domain: "inst.example.com", PublicURL: "http://inst.example.com:8081"}, "http://abc.inst.example.com:8081/"},
+		{"路径", config.HTTPGatewayConfig{Enabled: true, PathPrefix: "i", PublicURL: "https://ctf.example.com"}, "https://ctf.example.com/i/abc/"},
+		{"路径缺少公网地址", config.HTTPGatewayConfig{Enabled: true, PathPrefix: "/i/"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.InstanceURL("abc"); got != tt.want {
+				t.Errorf("InstanceURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// newGatewayUpstream 模拟题目 Web 服务：普通请求回显路径、Host 与 Cookie，/ws 升级为回显连接
+func newGatewayUpstream(t *testing.T) int {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ws" {
+			conn, rw, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+			rw.Flush()
+			line, _ := rw.ReadString('\n')
+			rw.WriteString("echo:" + line)
+			rw.Flush()
+			return
+		}
+		fmt.Fprintf(w, "path=%s host=%s cookie=%s", r.URL.RequestURI(), r.Host, r.Header.Get("Cookie"))
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream.Listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestHTTPGateway_ProxyWithOwnerSession(t *testing.T) {
+	svc, engine := setupStartTest(t)
+	ctx := context.Background()
+	upstreamPort := newGatewayUpstream(t)
+	engine.NextPort = upstreamPort // 实例端口映射到模拟的 Web 服务（本地 Docker 主机地址为 127.0.0.1）
+
+	svc.cfg.Gateway.HTTP = config.HTTPGatewayConfig{
+		Enabled:      true,
+		Domain:       "inst.test",
+		PathPrefix:   "/i/",
+		PublicURL:    "http://inst.test:8081",
+		RequireOwner: true,
+	}
+	instance := startAndWait(t, svc, "test-user-1", "test-challenge-1", "")
+	if want := "http://" + instance.ID + ".inst.test:8081/"; instance.GatewayURL != want {
+		t.Fatalf("GatewayURL = %q, want %q", instance.GatewayURL, want)
+	}
+
+	gateway := httptest.NewServer(NewHTTPGateway(svc.cfg.Gateway.HTTP, svc.repo))
+	defer gateway.Close()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	instanceHost := instance.ID + ".inst.test"
+	get := func(host, path, cookie string) (*http.Response, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, gateway.URL+path, nil)
+		req.Host = host
+		if cookie != "" {
+			req.Header.Set("Cookie", cookie)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("gateway request error = %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	// 未携带会话时拒绝访问
+	if resp, _ := get(instanceHost, "/", ""); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("未携带会话应返回 403, got %d", resp.StatusCode)
+	}
+
+	// 其他用户无法获取访问地址
+	if _, err := svc.GatewayAccess(ctx, "test-user-2", instance.ID); err == nil {
+		t.Error("非实例所属用户不应获得访问地址")
+	}
+
+	// 兑换一次性票据获得会话 Cookie
+	accessURL, err := svc.GatewayAccess(ctx, "test-user-1", instance.ID)
+	if err != nil {
+		t.Fatalf("GatewayAccess() error = %v", err)
+	}
+	u, _ := url.Parse(accessURL)
+	if u.Host != instanceHost+":8081" {
+		t.Fatalf("访问地址应为实例子域名, got %s", accessURL)
+	}
+	resp, _ := get(instanceHost, u.RequestURI(), "")
+	if resp.StatusCode != http.StatusFound || len(resp.Cookies()) != 1 {
+		t.Fatalf("兑换票据应跳转并写入会话 Cookie, got %d %v", resp.StatusCode, resp.Cookies())
+	}
+	session := resp.Cookies()[0]
+	if resp, _ := get(instanceHost, u.RequestURI(), ""); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("票据只能使用一次, got %d", resp.StatusCode)
+	}
+
+	// 子域名路由：保留原始 Host，网关会话 Cookie 不转发给题目
+	cookie := session.Name + "=" + session.Value + "; theme=dark"
+	resp, body := get(instanceHost, "/index.php?id=1", cookie)
+	if resp.StatusCode != http.StatusOK || body != "path=/index.php?id=1 host="+instanceHost+" cookie=theme=dark" {
+		t.Errorf("子域名路由转发结果不符, got %d %q", resp.StatusCode, body)
+	}
+
+	// 路径路由：去掉实例前缀后转发，缺少结尾 / 时跳转
+	if resp, body := get("inst.test", "/i/"+instance.ID+"/static/app.js", cookie); resp.StatusCode != http.StatusOK || !strings.HasPrefix(body, "path=/static/app.js ") {
+		t.Errorf("路径路由转发结果不符, got %d %q", resp.StatusCode, body)
+	}
+	if resp, _ := get("inst.test", "/i/"+instance.ID, cookie); resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/i/"+instance.ID+"/" {
+		t.Errorf("缺少结尾 / 时应跳转, got %d %s", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	// 会话只对所属实例有效
+	other := startAndWait(t, svc, "test-user-1", "test-challenge-2", "")
+	if resp, _ := get(other.ID+".inst.test", "/", cookie); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("会话不应对其他实例有效, got %d", resp.StatusCode)
+	}
+
+	// WebSocket 升级请求透传
+	conn, err := net.Dial("tcp", gateway.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial gateway error = %v", err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\nHost: %s\r\nConnection: Upgrade\r\nUpgrade: websocket\r\nCookie: %s\r\n\r\n", instanceHost, cookie)
+	reader := bufio.NewReader(conn)
+	wsResp, err := http.ReadResponse(reader, nil)
+	if err != nil || wsResp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("WebSocket 升级应成功, got %v %v", wsResp, err)
+	}
+	fmt.Fprint(conn, "hello\n")
+	if line, _ := reader.ReadString('\n'); line != "echo:hello\n" {
+		t.Errorf("升级后的连接应双向透传, got %q", line)
+	}
+
+	// 实例停止后不可访问
+	if err := svc.StopInstance(ctx, "test-user-1", "test-challenge-1"); err != nil {
+		t.Fatalf("StopInstance() error = %v", err)
+	}
+	if resp, _ := get(instanceHost, "/", cookie); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("实例停止后应返回 404, got %d", resp.StatusCode)
+	}
+	if resp, _ := get("unknown.example.com", "/", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("未匹配路由应返回 404, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"cyber-range/internal/infra/docker"
+	redisRepo "cyber-range/internal/infra/redis"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/logger"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// 实例启动任务状态
+const (
+	JobPending  = "pending"  // 已创建，等待分配 Docker 主机
+	JobQueued   = "queued"   // Docker 主机容量已满，排队等待空闲容量
+	JobPulling  = "pulling"  // 正在拉取题目镜像
+	JobCreating = "creating" // 正在创建容器
+	JobStarting = "starting" // 容器已创建，正在启动
+	JobRunning  = "running"  // 实例已就绪（终态）
+	JobFailed   = "failed"   // 启动失败（终态）
+)
+
+const (
+	instanceJobTTL   = time.Hour        // 启动任务状态保留时间（每次状态变更刷新）
+	provisionTimeout = 10 * time.Minute // 后台创建实例的最长耗时（含拉取镜像）
+	slotTTLMargin    = time.Minute      // 启动名额有效期超出任务超时的余量，保证任务结束前名额不会过期
+)
+
+// InstanceJob 实例启动任务（状态保存在 Redis，容器在后台创建）
+type InstanceJob struct {
+	ID          string          `json:"id"`
+	UserID      string          `json:"user_id"`
+	TeamID      string          `json:"team_id,omitempty"`
+	EventID     string          `json:"event_id,omitempty"`
+	ChallengeID string          `json:"challenge_id"`
+	Status      string          `json:"status"`
+	Reason      string          `json:"reason,omitempty"` // 失败原因（面向玩家，完整错误仅记录在日志中）
+	InstanceID  string          `json:"instance_id,omitempty"`
+	Instance    *model.Instance `json:"instance,omitempty"` // 任务 running 时附带实例信息
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// jobStageOrder 任务状态的先后顺序，用于丢弃订阅建立前已发布的旧状态
+var jobStageOrder = map[string]int{
+	JobPending:  0,
+	JobQueued:   1,
+	JobPulling:  2,
+	JobCreating: 3,
+	JobStarting: 4,
+	JobRunning:  5,
+	JobFailed:   5,
+}
+
+// Done 任务是否已结束（running 或 failed）
+func (j *InstanceJob) Done() bool {
+	return j.Status == JobRunning || j.Status == JobFailed
+}
+
+// saveJob 写入任务状态并发布状态变更通知
+func saveJob(ctx context.Context, job *InstanceJob) error {
+	event, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	fields := map[string]interface{}{
+		"id":           job.ID,
+		"user_id":      job.UserID,
+		"team_id":      job.TeamID,
+		"event_id":     job.EventID,
+		"challenge_id": job.ChallengeID,
+		"status":       job.Status,
+		"reason":       job.Reason,
+		"instance_id":  job.InstanceID,
+		"created_at":   job.CreatedAt.Unix(),
+		"updated_at":   job.UpdatedAt.Unix(),
+	}
+	return redisRepo.SaveInstanceJob(ctx, job.ID, fields, instanceJobTTL, event)
+}
+
+// loadJob 从 Redis 读取任务，不存在或已过期时返回 nil
+func loadJob(ctx context.Context, jobID string) (*InstanceJob, error) {
+	data, err := redisRepo.GetInstanceJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	createdAt, _ := strconv.ParseInt(data["created_at"], 10, 64)
+	updatedAt, _ := strconv.ParseInt(data["updated_at"], 10, 64)
+	return &InstanceJob{
+		ID:          data["id"],
+		UserID:      data["user_id"],
+		TeamID:      data["team_id"],
+		EventID:     data["event_id"],
+		ChallengeID: data["challenge_id"],
+		Status:      data["status"],
+		Reason:      data["reason"],
+		InstanceID:  data["instance_id"],
+		CreatedAt:   time.Unix(createdAt, 0),
+		UpdatedAt:   time.Unix(updatedAt, 0),
+	}, nil
+}
+
+// updateJob 更新任务状态，写入失败仅记录日志（玩家可通过轮询重新获取）
+func (s *ChallengeService) updateJob(ctx context.Context, job *InstanceJob, status, reason string) {
+	job.Status = status
+	job.Reason = reason
+	job.UpdatedAt = time.Now()
+	if err := saveJob(ctx, job); err != nil {
+		logger.Warn(ctx, "Failed to update instance job", "job_id", job.ID, "status", status, "error", err)
+	}
+}
+
+// attachInstance 为已就绪的任务附带实例信息
+func (s *ChallengeService) attachInstance(ctx context.Context, job *InstanceJob) {
+	if job.Status != JobRunning || job.InstanceID == "" {
+		return
+	}
+	var instance model.Instance
+	if err := s.gormDB.WithContext(ctx).First(&instance, "id = ?", job.InstanceID).Error; err == nil {
+		if instData, err := redisRepo.GetInstance(ctx, instance.ID); err == nil && len(instData) > 0 {
+			applyInstanceState(&instance, instData)
+		}
+		s.setEndpoints(ctx, &instance)
+		job.Instance = &instance
+	}
+}
+
+// jobTimeout 后台启动任务的最长耗时，排队模式下加上排队等待容量的时间
+func (s *ChallengeService) jobTimeout() time.Duration {
+	if s.cfg.Scheduler.Admission == AdmissionQueue {
+		return provisionTimeout + s.queueTimeout()
+	}
+	return provisionTimeout
+}
+
+// runInstanceJob 后台创建实例并更新任务状态，结束后释放启动名额（失败时退还每日启动次数）
+// job 为副本，避免与返回给调用方的任务并发读写
+func (s *ChallengeService) runInstanceJob(ctx context.Context, job InstanceJob, challenge *model.Challenge, quota redisRepo.InstanceQuota) {
+	provisionCtx, cancel := context.WithTimeout(ctx, s.jobTimeout())
+	defer cancel()
+
+	instance, err := s.createInstance(provisionCtx, &job, challenge)
+
+	// 先释放名额再发布终态，玩家收到 failed 后可立即重试（成功时实例已写入 Redis，继续占用配额）
+	if releaseErr := redisRepo.ReleaseInstanceSlot(ctx, quota, err != nil); releaseErr != nil {
+		logger.Warn(ctx, "Failed to release instance slot", "user_id", job.UserID, "challenge_id", job.ChallengeID, "error", releaseErr)
+	}
+
+	if err != nil {
+		reason := jobFailureReason(job.Status)
+		if errors.Is(err, docker.ErrPortsExhausted) {
+			reason = portsExhaustedReason
+		} else if errors.Is(err, ErrRangeFull) {
+			reason = rangeFullReason
+		} else if errors.Is(provisionCtx.Err(), context.DeadlineExceeded) {
+			reason = "实例启动超时，请稍后重试"
+		}
+		logger.Error(ctx, "Instance job failed",
+			"job_id", job.ID,
+			"user_id", job.UserID,
+			"challenge_id", job.ChallengeID,
+			"stage", job.Status,
+			"error", err)
+		s.updateJob(ctx, &job, JobFailed, reason)
+	} else {
+		job.InstanceID = instance.ID
+		job.Instance = instance
+		s.updateJob(ctx, &job, JobRunning, "")
+	}
+
+}
+
+// portsExhaustedReason Docker 主机端口耗尽时的失败原因
+const portsExhaustedReason = "平台端口资源不足，请稍后重试或联系管理员"
+
+// jobFailureReason 按失败阶段返回面向玩家的失败原因
+func jobFailureReason(stage string) string {
+	switch stage {
+	case JobPending:
+		return "暂无可用的 Docker 主机，请稍后重试"
+	case JobQueued:
+		return rangeFullReason
+	case JobPulling:
+		return "题目镜像拉取失败，请稍后重试或联系管理员"
+	case JobCreating:
+		return "容器创建失败，请稍后重试"
+	case JobStarting:
+		return "容器启动失败，请稍后重试"
+	default:
+		return "实例启动失败，请稍后重试"
+	}
+}
+
+// GetJob 查询实例启动任务（仅任务所属用户或同队成员可查询）
+func (s *ChallengeService) GetJob(ctx context.Context, userID, jobID string) (*InstanceJob, error) {
+	job, err := loadJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, errors.New("启动任务不存在或已过期")
+	}
+	if job.UserID != userID {
+		teamID, err := s.resolveTeamID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if job.TeamID == "" || job.TeamID != teamID {
+			return nil, errors.New("启动任务不存在或已过期")
+		}
+	}
+	s.attachInstance(ctx, job)
+	return job, nil
+}
+
+// WatchJob 订阅实例启动任务的状态变更
+// 先发送当前状态，之后每次状态变更发送一次，任务结束或 ctx 取消后关闭通道
+func (s *ChallengeService) WatchJob(ctx context.Context, userID, jobID string) (<-chan *InstanceJob, error) {
+	if _, err := s.GetJob(ctx, userID, jobID); err != nil {
+		return nil, err
+	}
+
+	// 先订阅再读取当前状态，避免错过两者之间的状态变更
+	pubsub := redisRepo.SubscribeInstanceJob(ctx, jobID)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+	current, err := s.GetJob(ctx, userID, jobID)
+	if err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	ch := make(chan *InstanceJob)
+	go func() {
+		defer close(ch)
+		defer pubsub.Close()
+
+		send := func(job *InstanceJob) bool {
+			select {
+			case ch <- job:
+				return !job.Done()
+			case <-ctx.Done():
+				return false
+			}
+		}
+		if !send(current) {
+			return
+		}
+		messages := pubsub.Channel()
+		for {
+			select {
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				var job InstanceJob
+				if err := json.Unmarshal([]byte(msg.Payload), &job); err != nil {
+					logger.Warn(ctx, "Invalid instance job event", "job_id", jobID, "error", err)
+					continue
+				}
+				if jobStageOrder[job.Status] < jobStageOrder[current.Status] {
+					continue
+				}
+				current = &job
+				if !send(current) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
@@ -0,0 +1,307 @@
+package service
+
+import (
+	"context"
+	redisRepo "cyber-range/internal/infra/redis"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/logger"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	instanceResetLockTTL = 2 * time.Minute  // 实例重置锁的最长持有时间（覆盖停止与重新创建容器的耗时）
+	startLockTTL         = 1 * time.Minute  // 启动锁的最长持有时间（仅覆盖校验与创建任务，容器在后台创建）
+	startLockWait        = 30 * time.Second // 携带幂等键的重试请求等待启动锁的最长时间
+	startLockPoll        = 100 * time.Millisecond
+	idempotencyKeyTTL    = 24 * time.Hour // 幂等键保留时间
+)
+
+// acquireStartLock 获取启动锁；wait 为 true 时（携带幂等键的重试）等待持有者完成，否则立即返回错误
+func (s *ChallengeService) acquireStartLock(ctx context.Context, owner, challengeID, token string, wait bool) error {
+	deadline := time.Now().Add(startLockWait)
+	for {
+		ok, err := redisRepo.AcquireStartLock(ctx, owner, challengeID, token, startLockTTL)
+		if err != nil {
+			return fmt.Errorf("获取启动锁失败: %w", err)
+		}
+		if ok {
+			return nil
+		}
+		if !wait || time.Now().After(deadline) {
+			return errors.New("该题目的实例正在启动中，请勿重复提交")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(startLockPoll):
+		}
+	}
+}
+
+// idempotentJob 查找幂等键对应的启动任务；任务已失败、已过期或实例已停止时返回 nil，按新请求处理
+func (s *ChallengeService) idempotentJob(ctx context.Context, userID, challengeID, key string) (*InstanceJob, error) {
+	storedChallengeID, jobID, err := redisRepo.GetIdempotentStart(ctx, userID, key)
+	if err != nil {
+		return nil, fmt.Errorf("读取幂等键失败: %w", err)
+	}
+	if jobID == "" {
+		return nil, nil
+	}
+	if storedChallengeID != challengeID {
+		return nil, errors.New("Idempotency-Key 已用于其他题目的启动请求")
+	}
+	job, err := loadJob(ctx, jobID)
+	if err != nil || job == nil || job.Status == JobFailed {
+		return nil, nil
+	}
+	if job.Status == JobRunning {
+		if data, err := redisRepo.GetInstance(ctx, job.InstanceID); err != nil || len(data) == 0 {
+			return nil, nil
+		}
+		s.attachInstance(ctx, job)
+	}
+	logger.Info(ctx, "Returning job for repeated idempotency key",
+		"job_id", jobID, "user_id", userID, "challenge_id", challengeID)
+	return job, nil
+}
+
+// instanceLifetimePolicy 实例延长策略（题目配置优先，未配置时使用全局配置）
+type instanceLifetimePolicy struct {
+	ExtendBy      time.Duration // 每次延长的时间
+	MaxExtensions int           // 最多延长次数，<=0 表示不允许延长
+	MaxLifetime   time.Duration // 最长存活时间（自创建起，含延长），0 表示不限制
+}
+
+func (s *ChallengeService) lifetimePolicy(challenge *model.Challenge) instanceLifetimePolicy {
+	ic := s.cfg.Instance
+	policy := instanceLifetimePolicy{
+		ExtendBy:      time.Duration(ic.ExtendMinutes) * time.Minute,
+		MaxExtensions: ic.MaxExtensions,
+		MaxLifetime:   time.Duration(ic.MaxLifetimeHours) * time.Hour,
+	}
+	if policy.ExtendBy <= 0 {
+		policy.ExtendBy = time.Duration(ic.TTLHours) * time.Hour
+	}
+	if challenge.MaxExtensions != 0 {
+		policy.MaxExtensions = challenge.MaxExtensions
+	}
+	if challenge.MaxLifetime > 0 {
+		policy.MaxLifetime = time.Duration(challenge.MaxLifetime) * time.Minute
+	}
+	return policy
+}
+
+// ExtendInstance 延长用户（团队模式下为队伍）在题目上的运行实例
+// 每次延长 extend_minutes，受题目/全局的最多延长次数与最长存活时间限制
+func (s *ChallengeService) ExtendInstance(ctx context.Context, userID, challengeID string) (*model.Instance, error) {
+	instance, _, err := s.loadActiveInstance(ctx, userID, challengeID)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := s.GetChallenge(ctx, challengeID)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := s.lifetimePolicy(challenge)
+	if policy.MaxExtensions <= 0 || policy.ExtendBy <= 0 {
+		return nil, errors.New("该题目的实例不允许延长")
+	}
+	if instance.Extensions >= policy.MaxExtensions {
+		return nil, fmt.Errorf("实例最多只能延长 %d 次", policy.MaxExtensions)
+	}
+
+	expiresAt := instance.ExpiresAt.Add(policy.ExtendBy)
+	if policy.MaxLifetime > 0 {
+		if limit := instance.CreatedAt.Add(policy.MaxLifetime); expiresAt.After(limit) {
+			expiresAt = limit
+		}
+	}
+	if !expiresAt.After(instance.ExpiresAt) {
+		return nil, errors.New("实例已达到最长存活时间，无法继续延长")
+	}
+
+	ok, err := redisRepo.ExtendInstance(ctx, instance.ID, instance.Extensions, expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("延长实例失败: %w", err)
+	}
+	if !ok {
+		return nil, errors.New("实例正在被延长，请刷新后重试")
+	}
+
+	instance.Extensions++
+	instance.ExpiresAt = expiresAt
+	if err := s.gormDB.WithContext(ctx).Model(&model.Instance{}).Where("id = ?", instance.ID).
+		Updates(map[string]interface{}{"expires_at": expiresAt, "extensions": instance.Extensions}).Error; err != nil {
+		logger.Warn(ctx, "Failed to save instance extension to DB (non-critical)", "instance_id", instance.ID, "error", err)
+	}
+
+	logger.Info(ctx, "Instance extended",
+		"instance_id", instance.ID,
+		"user_id", userID,
+		"challenge_id", challengeID,
+		"extensions", instance.Extensions,
+		"expires_at", expiresAt)
+	s.setEndpoints(ctx, instance)
+	return instance, nil
+}
+
+// ResetInstance 在原 Docker 主机上重建实例容器，保留 Flag 与过期时间
+// 用于玩家把环境打坏后恢复初始状态，容器ID与映射端口会变化
+func (s *ChallengeService) ResetInstance(ctx context.Context, userID, challengeID string) (*model.Instance, error) {
+	instance, instData, err := s.loadActiveInstance(ctx, userID, challengeID)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := s.GetChallenge(ctx, challengeID)
+	if err != nil {
+		return nil, err
+	}
+
+	locked, err := redisRepo.AcquireInstanceLock(ctx, instance.ID, instanceResetLockTTL)
+	if err != nil {
+		return nil, fmt.Errorf("重置实例失败: %w", err)
+	}
+	if !locked {
+		return nil, errors.New("实例正在重置中，请稍后再试")
+	}
+	defer redisRepo.ReleaseInstanceLock(ctx, instance.ID)
+
+	dockerHost, err := s.repo.GetDockerHostByID(ctx, instance.DockerHostID)
+	if err != nil {
+		return nil, fmt.Errorf("Docker 主机配置不存在: %w", err)
+	}
+	dockerClient, err := s.engines.GetEngine(ctx, dockerHost)
+	if err != nil {
+		return nil, fmt.Errorf("连接 Docker 主机失败: %w", err)
+	}
+
+	if err := dockerClient.StopContainers(ctx, instance.ContainerIDs()); err != nil {
+		logger.Warn(ctx, "Failed to stop container before reset (may already be stopped)",
+			"instance_id", instance.ID, "container_ids", instance.ContainerIDs(), "error", err)
+	}
+
+	// 旧容器已删除，此后任一步骤失败时实例不可用，从 Redis 与数据库中一并回收，避免仍指向已删除的容器
+	discard := func() {
+		redisRepo.DeleteInstance(ctx, instance.ID, instance.UserID, instance.TeamID)
+		s.gormDB.Model(&model.Instance{}).Where("id = ?", instance.ID).Update("status", "stopped")
+		releaseReservation(ctx, instance)
+	}
+
+	containerID, ports, services, err := s.startInstanceContainers(ctx, dockerClient, challenge, instData["flag"])
+	if err != nil {
+		discard()
+		return nil, fmt.Errorf("重置实例失败，实例已停止，请重新启动: %w", err)
+	}
+
+	instance.ContainerID = containerID
+	instance.Port = 0
+	if len(ports) > 0 {
+		instance.Port = ports[0].HostPort
+	}
+	instance.Ports = ports
+	instance.Services = services
+	if err := s.gormDB.WithContext(ctx).Model(instance).
+		Select("container_id", "port", "ports", "services").Updates(instance).Error; err != nil {
+		dockerClient.StopContainers(ctx, instance.ContainerIDs())
+		discard()
+		return nil, fmt.Errorf("重置实例失败，实例已停止，请重新启动: %w", err)
+	}
+	if err := redisRepo.UpdateInstanceContainer(ctx, instance); err != nil {
+		dockerClient.StopContainers(ctx, instance.ContainerIDs())
+		discard()
+		return nil, fmt.Errorf("重置实例失败，实例已停止，请重新启动: %w", err)
+	}
+
+	logger.Info(ctx, "Instance reset successfully",
+		"instance_id", instance.ID,
+		"user_id", userID,
+		"challenge_id", challengeID,
+		"docker_host", dockerHost.Name,
+		"port", instance.Port)
+	s.setConnectionInfo(instance, dockerHost)
+	return instance, nil
+}
+
+// loadActiveInstance 获取用户（团队模式下为队伍）在题目上的运行实例
+// 过期时间、延长次数、容器与端口以 Redis 为准
+func (s *ChallengeService) loadActiveInstance(ctx context.Context, userID, challengeID string) (*model.Instance, map[string]string, error) {
+	teamID, err := s.resolveTeamID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	instanceID, instData, err := s.findActiveInstance(ctx, userID, teamID, challengeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if instanceID == "" {
+		return nil, nil, errors.New("no active instance found for this challenge")
+	}
+
+	var instance model.Instance
+	if err := s.gormDB.WithContext(ctx).First(&instance, "id = ?", instanceID).Error; err != nil {
+		return nil, nil, fmt.Errorf("instance not found in database: %w", err)
+	}
+	applyInstanceState(&instance, instData)
+	return &instance, instData, nil
+}
+
+// ListInstances 获取用户（团队模式下为队伍）的全部运行实例，附带各端口的连接信息
+func (s *ChallengeService) ListInstances(ctx context.Context, userID string) ([]model.Instance, error) {
+	teamID, err := s.resolveTeamID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	instanceIDs, err := activeInstanceIDs(ctx, userID, teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := make([]model.Instance, 0, len(instanceIDs))
+	if len(instanceIDs) == 0 {
+		return instances, nil
+	}
+	var rows []model.Instance
+	if err := s.gormDB.WithContext(ctx).Where("id IN ?", instanceIDs).
+		Order("created_at ASC").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("获取实例列表失败: %w", err)
+	}
+
+	hosts := make(map[string]*model.DockerHost)
+	for _, instance := range rows {
+		instData, err := redisRepo.GetInstance(ctx, instance.ID)
+		if err != nil || len(instData) == 0 {
+			// 实例已过期或正在回收
+			continue
+		}
+		applyInstanceState(&instance, instData)
+
+		host, ok := hosts[instance.DockerHostID]
+		if !ok {
+			if host, err = s.repo.GetDockerHostByID(ctx, instance.DockerHostID); err != nil {
+				logger.Warn(ctx, "Docker host not found for instance endpoints", "instance_id", instance.ID, "docker_host_id", instance.DockerHostID, "error", err)
+			}
+			hosts[instance.DockerHostID] = host
+		}
+		if host != nil {
+			s.setConnectionInfo(&instance, host)
+		}
+		instances = append(instances, instance)
+	}
+	return instances, nil
+}
+
+// applyInstanceState 以 Redis 中的过期时间、延长次数、网关令牌与容器信息覆盖数据库记录
+func applyInstanceState(instance *model.Instance, instData map[string]string) {
+	if sec, err := strconv.ParseInt(instData["expires_at"], 10, 64); err == nil {
+		instance.ExpiresAt = time.Unix(sec, 0)
+	}
+	if n, err := strconv.Atoi(instData["extensions"]); err == nil {
+		instance.Extensions = n
+	}
+	instance.GatewayToken = instData["gateway_token"]
+	applyInstanceContainers(instance, instData)
+}
@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	redisRepo "cyber-range/internal/infra/redis"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/logger"
+	"cyber-range/tests/mock"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestExtendInstance(t *testing.T) {
+	logger.InitLogger("dev")
+	mr := setupTestRedis(t)
+	svc, testDB := setupTestService(t)
+	ctx := context.Background()
+	svc.cfg.Instance.ExtendMinutes = 30
+	svc.cfg.Instance.MaxExtensions = 3
+	svc.cfg.Instance.MaxLifetimeHours = 2
+
+	createdAt := time.Now().Add(-10 * time.Minute).Truncate(time.Second)
+	instance := &model.Instance{
+		ID:           "inst-1",
+		UserID:       "test-user-1",
+		ChallengeID:  "test-challenge-1",
+		ContainerID:  "container-1",
+		DockerHostID: "test-docker-host",
+		Flag:         "flag{dynamic}",
+		Port:         23456,
+		Status:       "running",
+		ExpiresAt:    createdAt.Add(time.Hour),
+		CreatedAt:    createdAt,
+	}
+	testDB.Create(instance)
+	if err := redisRepo.StoreInstance(ctx, instance); err != nil {
+		t.Fatalf("StoreInstance() error = %v", err)
+	}
+
+	extended, err := svc.ExtendInstance(ctx, "test-user-1", "test-challenge-1")
+	if err != nil {
+		t.Fatalf("ExtendInstance() error = %v", err)
+	}
+	want := createdAt.Add(90 * time.Minute)
+	if !extended.ExpiresAt.Equal(want) || extended.Extensions != 1 {
+		t.Errorf("应延长 30 分钟, got expires_at=%v extensions=%d", extended.ExpiresAt, extended.Extensions)
+	}
+	if score, _ := mr.ZScore(redisRepo.KeyExpiredInstancesSet, "inst-1"); int64(score) != want.Unix() {
+		t.Errorf("过期 ZSET 分数应同步更新, got %v", score)
+	}
+	var saved model.Instance
+	testDB.First(&saved, "id = ?", "inst-1")
+	if saved.Extensions != 1 || !saved.ExpiresAt.Equal(want) {
+		t.Errorf("数据库记录应同步更新, got %+v", saved)
+	}
+
+	// 第二次延长受最长存活时间（2小时）限制
+	extended, err = svc.ExtendInstance(ctx, "test-user-1", "test-challenge-1")
+	if err != nil {
+		t.Fatalf("ExtendInstance() error = %v", err)
+	}
+	if want = createdAt.Add(2 * time.Hour); !extended.ExpiresAt.Equal(want) {
+		t.Errorf("延长后不应超过最长存活时间, got %v", extended.ExpiresAt)
+	}
+	if _, err := svc.ExtendInstance(ctx, "test-user-1", "test-challenge-1"); err == nil {
+		t.Error("达到最长存活时间后不应允许继续延长")
+	}
+
+	// 题目单独设置的延长次数优先于全局配置
+	testDB.Model(&model.Challenge{}).Where("id = ?", "test-challenge-1").
+		Updates(map[string]interface{}{"max_extensions": 2, "max_lifetime": 600})
+	if _, err := svc.ExtendInstance(ctx, "test-user-1", "test-challenge-1"); err == nil {
+		t.Error("超过题目最多延长次数后不应允许延长")
+	}
+	testDB.Model(&model.Challenge{}).Where("id = ?", "test-challenge-1").Update("max_extensions", -1)
+	if _, err := svc.ExtendInstance(ctx, "test-user-1", "test-challenge-1"); err == nil {
+		t.Error("题目设置为不允许延长时应返回错误")
+	}
+
+	if _, err := svc.ExtendInstance(ctx, "test-user-1", "missing-challenge"); err == nil {
+		t.Error("没有运行实例时应返回错误")
+	}
+}
+
+// setupStartTest 使用 Mock 容器引擎，题目 test-challenge-1 已发布
+func setupStartTest(t *testing.T) (*ChallengeService, *mock.MockDockerClient) {
+	t.Helper()
+	logger.InitLogger("dev")
+	setupTestRedis(t)
+	svc, testDB := setupTestService(t)
+	// 内存数据库每个连接相互独立，并发测试需共用同一个连接
+	sqlDB, _ := testDB.DB()
+	sqlDB.SetMaxOpenConns(1)
+	testDB.Model(&model.Challenge{}).Where("id = ?", "test-challenge-1").Update("status", "published")
+	testDB.Create(&model.Challenge{ID: "test-challenge-2", Title: "测试题目2", Image: "nginx:alpine", Port: 80, Flag: "x", Status: "published"})
+	svc.cfg.Instance.MaxPerUser = 5
+
+	engine := mock.NewMockDockerClient()
+	engine.StartDelay = 50 * time.Millisecond
+	svc.engines = engine
+	svc.scheduler.engines = engine
+	return svc, engine
+}
+
+// waitJob 轮询启动任务直到结束（running 或 failed）
+func waitJob(t *testing.T, svc *ChallengeService, userID, jobID string) *InstanceJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := svc.GetJob(context.Background(), userID, jobID)
+		if err != nil {
+			t.Fatalf("GetJob() error = %v", err)
+		}
+		if job.Done() {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("启动任务未在规定时间内结束, status = %s", job.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// startAndWait 启动实例并等待任务就绪
+func startAndWait(t *testing.T, svc *ChallengeService, userID, challengeID, key string) *model.Instance {
+	t.Helper()
+	job, err := svc.StartInstance(context.Background(), userID, challengeID, "", key)
+	if err != nil {
+		t.Fatalf("StartInstance() error = %v", err)
+	}
+	if job = waitJob(t, svc, userID, job.ID); job.Status != JobRunning || job.Instance == nil {
+		t.Fatalf("启动任务应成功, got %+v", job)
+	}
+	return job.Instance
+}
+
+func TestStartInstance_ConcurrentCreatesOneContainer(t *testing.T) {
+	svc, engine := setupStartTest(t)
+	ctx := context.Background()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		jobs []*InstanceJob
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if job, err := svc.StartInstance(ctx, "test-user-1", "test-challenge-1", "", ""); err == nil {
+				mu.Lock()
+				jobs = append(jobs, job)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(jobs) != 1 {
+		t.Fatalf("并发启动只应有 1 个请求成功, got %d", len(jobs))
+	}
+	waitJob(t, svc, "test-user-1", jobs[0].ID)
+	if started := engine.Started(); len(started) != 1 {
+		t.Errorf("并发启动只应创建 1 个容器, got %v", started)
+	}
+}
+
+func TestStartInstance_AsyncJob(t *testing.T) {
+	svc, engine := setupStartTest(t)
+	ctx := context.Background()
+
+	job, err := svc.StartInstance(ctx, "test-user-1", "test-challenge-1", "", "")
+	if err != nil {
+		t.Fatalf("StartInstance() error = %v", err)
+	}
+	if job.Status != JobPending || job.Instance != nil {
+		t.Errorf("启动接口应立即返回 pending 任务, got %+v", job)
+	}
+
+	events, err := svc.WatchJob(ctx, "test-user-1", job.ID)
+	if err != nil {
+		t.Fatalf("WatchJob() error = %v", err)
+	}
+	var statuses []string
+	for e := range events {
+		statuses = append(statuses, e.Status)
+	}
+	if len(statuses) == 0 || statuses[len(statuses)-1] != JobRunning {
+		t.Fatalf("事件流应以 running 结束, got %v", statuses)
+	}
+
+	done, err := svc.GetJob(ctx, "test-user-1", job.ID)
+	if err != nil {
+		t.Fatalf("GetJob() error = %v", err)
+	}
+	if done.Instance == nil || done.Instance.ContainerID != engine.Started()[0] {
+		t.Errorf("就绪的任务应附带实例信息, got %+v", done)
+	}
+	if _, err := svc.GetJob(ctx, "other-user", job.ID); err == nil {
+		t.Error("其他用户不应查询到该任务")
+	}
+
+	// 镜像拉取失败：任务失败并返回面向玩家的原因，启动名额被释放
+	engine.ShouldFailPull = true
+	job, err = svc.StartInstance(ctx, "test-user-1", "test-challenge-2", "", "")
+	if err != nil {
+		t.Fatalf("StartInstance() error = %v", err)
+	}
+	failed := waitJob(t, svc, "test-user-1", job.ID)
+	if failed.Status != JobFailed || failed.Reason != jobFailureReason(JobPulling) {
+		t.Errorf("镜像拉取失败应返回安全的失败原因, got %+v", failed)
+	}
+	if strings.Contains(failed.Reason, "nginx") {
+		t.Errorf("失败原因不应包含内部错误, got %q", failed.Reason)
+	}
+	engine.ShouldFailPull = false
+	startAndWait(t, svc, "test-user-1", "test-challenge-2", "")
+}
+
+func TestStartInstance_IdempotencyKey(t *testing.T) {
+	svc, engine := setupStartTest(t)
+	ctx := context.Background()
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		ids = make(map[string]int)
+	)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			job, err := svc.StartInstance(ctx, "test-user-1", "test-challenge-1", "", "retry-1")
+			if err != nil {
+				t.Errorf("携带相同幂等键的重试应返回同一任务, got error %v", err)
+				return
+			}
+			mu.Lock()
+			ids[job.ID]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if len(ids) != 1 {
+		t.Fatalf("相同幂等键应返回同一个任务, got %v", ids)
+	}
+	for id := range ids {
+		waitJob(t, svc, "test-user-1", id)
+	}
+	if started := engine.Started(); len(started) != 1 {
+		t.Errorf("相同幂等键只应创建 1 个容器, got %v", started)
+	}
+	if job, err := svc.StartInstance(ctx, "test-user-1", "test-challenge-1", "", "retry-1"); err != nil || ids[job.ID] == 0 || job.Instance == nil {
+		t.Errorf("任务完成后重试应返回同一个任务及实例, got %+v %v", job, err)
+	}
+
+	if _, err := svc.StartInstance(ctx, "test-user-1", "test-challenge-2", "", "retry-1"); err == nil {
+		t.Error("幂等键不应复用于其他题目")
+	}
+
+	// 实例停止后，相同幂等键按新请求处理
+	if err := svc.StopInstance(ctx, "test-user-1", "test-challenge-1"); err != nil {
+		t.Fatalf("StopInstance() error = %v", err)
+	}
+	startAndWait(t, svc, "test-user-1", "test-challenge-1", "retry-1")
+	if len(engine.Started()) != 2 {
+		t.Errorf("实例停止后应重新创建实例, got %v", engine.Started())
+	}
+}
+
+func TestResetInstance(t *testing.T) {
+	svc, engine := setupStartTest(t)
+	ctx := context.Background()
+
+	instance := startAndWait(t, svc, "test-user-1", "test-challenge-1", "")
+	reset, err := svc.ResetInstance(ctx, "test-user-1", "test-challenge-1")
+	if err != nil {
+		t.Fatalf("ResetInstance() error = %v", err)
+	}
+	if reset.ID != instance.ID || reset.ContainerID == instance.ContainerID || reset.Flag != instance.Flag {
+		t.Errorf("重置应保留实例与 Flag 并更换容器, got %+v", reset)
+	}
+	if !reset.ExpiresAt.Equal(instance.ExpiresAt.Truncate(time.Second)) {
+		t.Errorf("重置不应改变过期时间, got %v", reset.ExpiresAt)
+	}
+	if stopped := engine.Stopped(); len(stopped) != 1 || stopped[0] != instance.ContainerID {
+		t.Errorf("应删除旧容器, got %v", stopped)
+	}
+	data, _ := redisRepo.GetInstance(ctx, instance.ID)
+	if data["container_id"] != reset.ContainerID || data["flag"] != instance.Flag {
+		t.Errorf("Redis 中的实例应指向新容器, got %v", data)
+	}
+}
+
+func TestResetInstance_SaveFailureDiscardsInstance(t *testing.T) {
+	svc, engine := setupStartTest(t)
+	ctx := context.Background()
+
+	instance := startAndWait(t, svc, "test-user-1", "test-challenge-1", "")
+	svc.gormDB.Callback().Update().Before("gorm:update").Register("test:fail_container_update", func(tx *gorm.DB) {
+		if _, ok := tx.Statement.Dest.(*model.Instance); ok {
+			tx.AddError(errors.New("模拟的数据库写入失败"))
+		}
+	})
+
+	if _, err := svc.ResetInstance(ctx, "test-user-1", "test-challenge-1"); err == nil {
+		t.Fatal("保存新容器失败时重置应返回错误")
+	}
+	if stopped := engine.Stopped(); len(stopped) != 2 {
+		t.Errorf("应删除旧容器与新创建的容器, got %v", stopped)
+	}
+	if data, _ := redisRepo.GetInstance(ctx, instance.ID); len(data) != 0 {
+		t.Errorf("实例不可用时应从 Redis 中删除, got %v", data)
+	}
+	var saved model.Instance
+	svc.gormDB.First(&saved, "id = ?", instance.ID)
+	if saved.Status != "stopped" {
+		t.Errorf("实例状态应为 stopped, got %s", saved.Status)
+	}
+}
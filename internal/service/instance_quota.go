@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	redisRepo "cyber-range/internal/infra/redis"
+	"cyber-range/internal/model"
+	"fmt"
+	"strings"
+)
+
+// QuotaError 实例配额不足，Instances 为需要先停止的运行中实例
+type QuotaError struct {
+	Message   string
+	Instances []QuotaInstance
+}
+
+func (e *QuotaError) Error() string {
+	return e.Message
+}
+
+// QuotaInstance 占用配额的运行中实例
+type QuotaInstance struct {
+	InstanceID     string `json:"instance_id"`
+	ChallengeID    string `json:"challenge_id"`
+	ChallengeTitle string `json:"challenge_title"`
+}
+
+// quotaError 将配额检查结果转换为面向玩家的错误，列出需要停止的实例
+func (s *ChallengeService) quotaError(ctx context.Context, v *redisRepo.QuotaViolation, teamID string) *QuotaError {
+	instances := make([]QuotaInstance, 0, len(v.InstanceIDs))
+	for _, id := range v.InstanceIDs {
+		data, err := redisRepo.GetInstance(ctx, id)
+		if err != nil || data["challenge_id"] == "" {
+			continue
+		}
+		item := QuotaInstance{InstanceID: id, ChallengeID: data["challenge_id"], ChallengeTitle: data["challenge_id"]}
+		var challenge model.Challenge
+		if s.gormDB.WithContext(ctx).Select("id", "title").First(&challenge, "id = ?", item.ChallengeID).Error == nil {
+			item.ChallengeTitle = challenge.Title
+		}
+		instances = append(instances, item)
+	}
+
+	titles := make([]string, 0, len(instances))
+	for _, inst := range instances {
+		titles = append(titles, inst.ChallengeTitle)
+	}
+	stopHint := "请先停止以下实例：" + strings.Join(titles, "、")
+	if len(titles) == 0 {
+		stopHint = "其余实例正在启动中，请稍后再试"
+	}
+
+	var msg string
+	switch v.Kind {
+	case redisRepo.QuotaDuplicate:
+		msg = "你已经启动了该题目的实例，请先停止后再重新启动"
+		if teamID != "" {
+			msg = "你的队伍已经启动了该题目的实例，请先停止后再重新启动"
+		}
+		if len(instances) == 0 {
+			msg = "该题目的实例正在启动中，请稍后再试"
+		}
+	case redisRepo.QuotaUser:
+		msg = fmt.Sprintf("同时运行的实例已达上限（%d 个），%s", s.cfg.Instance.MaxPerUser, stopHint)
+	case redisRepo.QuotaTeam:
+		msg = fmt.Sprintf("队伍同时运行的实例已达上限（%d 个），%s", s.cfg.Instance.MaxPerTeam, stopHint)
+	case redisRepo.QuotaDaily:
+		msg = fmt.Sprintf("今日启动实例次数已达上限（%d 次），请明天再试", s.cfg.Instance.DailyStarts)
+	default:
+		msg = "实例配额不足"
+	}
+	return &QuotaError{Message: msg, Instances: instances}
+}
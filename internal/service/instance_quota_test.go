@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	redisRepo "cyber-range/internal/infra/redis"
+	"cyber-range/internal/model"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func storeTestInstance(t *testing.T, id, userID, teamID, challengeID string) {
+	t.Helper()
+	if err := redisRepo.StoreInstance(context.Background(), &model.Instance{
+		ID: id, UserID: userID, TeamID: teamID, ChallengeID: challengeID,
+		ContainerID: "c-" + id, Port: 20000, ExpiresAt: time.Now().Add(time.Hour),
+	}); err != nil {
+		t.Fatalf("StoreInstance() error = %v", err)
+	}
+}
+
+func TestInstanceQuota_Concurrent(t *testing.T) {
+	setupTestRedis(t)
+	ctx := context.Background()
+	quota := redisRepo.InstanceQuota{UserID: "u1", ChallengeID: "c1", UserLimit: 3, Day: "20260101"}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		passed int
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := redisRepo.ReserveInstanceSlot(ctx, quota)
+			if err != nil {
+				t.Errorf("ReserveInstanceSlot() error = %v", err)
+				return
+			}
+			if v == nil {
+				mu.Lock()
+				passed++
+				mu.Unlock()
+			} else if v.Kind != redisRepo.QuotaDuplicate {
+				t.Errorf("并发启动同一题目应返回 duplicate, got %s", v.Kind)
+			}
+		}()
+	}
+	wg.Wait()
+	if passed != 1 {
+		t.Fatalf("并发启动同一题目只应有 1 个请求通过, got %d", passed)
+	}
+
+	// 释放后可再次预留
+	redisRepo.ReleaseInstanceSlot(ctx, quota, true)
+	if v, _ := redisRepo.ReserveInstanceSlot(ctx, quota); v != nil {
+		t.Errorf("释放后应可再次预留, got %+v", v)
+	}
+}
+
+func TestInstanceQuota_Limits(t *testing.T) {
+	setupTestRedis(t)
+	svc, testDB := setupTestService(t)
+	ctx := context.Background()
+	svc.cfg.Instance.MaxPerUser = 2
+	svc.cfg.Instance.MaxPerTeam = 3
+	svc.cfg.Instance.DailyStarts = 4
+
+	testDB.Create(&model.Challenge{ID: "web1", Title: "Web 1", Image: "x", Flag: "x"})
+	testDB.Create(&model.Challenge{ID: "web2", Title: "Web 2", Image: "x", Flag: "x"})
+	storeTestInstance(t, "i1", "u1", "", "web1")
+	storeTestInstance(t, "i2", "u1", "", "web2")
+
+	quota := func(userID, teamID, challengeID string) redisRepo.InstanceQuota {
+		return redisRepo.InstanceQuota{
+			UserID: userID, TeamID: teamID, ChallengeID: challengeID,
+			UserLimit: svc.cfg.Instance.MaxPerUser, TeamLimit: svc.cfg.Instance.MaxPerTeam,
+			DailyLimit: svc.cfg.Instance.DailyStarts, Day: "20260101",
+		}
+	}
+
+	v, _ := redisRepo.ReserveInstanceSlot(ctx, quota("u1", "", "web1"))
+	if v == nil || v.Kind != redisRepo.QuotaDuplicate || len(v.InstanceIDs) != 1 || v.InstanceIDs[0] != "i1" {
+		t.Fatalf("已运行的题目应返回 duplicate, got %+v", v)
+	}
+
+	v, _ = redisRepo.ReserveInstanceSlot(ctx, quota("u1", "", "pwn1"))
+	if v == nil || v.Kind != redisRepo.QuotaUser {
+		t.Fatalf("超过用户上限应返回 user, got %+v", v)
+	}
+	err := svc.quotaError(ctx, v, "")
+	if len(err.Instances) != 2 || !strings.Contains(err.Error(), "Web 1") || !strings.Contains(err.Error(), "Web 2") {
+		t.Errorf("错误信息应列出需要停止的实例, got %q %+v", err.Error(), err.Instances)
+	}
+
+	// 过期被清理的实例不占用配额
+	redisRepo.DeleteInstance(ctx, "i2", "u1", "")
+	if v, _ := redisRepo.ReserveInstanceSlot(ctx, quota("u1", "", "pwn1")); v != nil {
+		t.Fatalf("停止实例后应可启动, got %+v", v)
+	}
+	// 启动中的名额同样占用配额
+	if v, _ := redisRepo.ReserveInstanceSlot(ctx, quota("u1", "", "pwn2")); v == nil || v.Kind != redisRepo.QuotaUser {
+		t.Errorf("启动中的实例应计入配额, got %+v", v)
+	}
+
+	// 队伍上限按队伍实例集合计数
+	storeTestInstance(t, "t1", "u2", "team1", "web1")
+	storeTestInstance(t, "t2", "u3", "team1", "web2")
+	storeTestInstance(t, "t3", "u4", "team1", "pwn1")
+	v, _ = redisRepo.ReserveInstanceSlot(ctx, quota("u2", "team1", "pwn2"))
+	if v == nil || v.Kind != redisRepo.QuotaTeam || len(v.InstanceIDs) != 3 {
+		t.Errorf("超过队伍上限应返回 team, got %+v", v)
+	}
+
+	// 每日启动次数（u1 已成功启动 1 次，启动失败的预留会退还次数）
+	redisRepo.ReleaseInstanceSlot(ctx, quota("u1", "", "pwn1"), false)
+	redisRepo.ReserveInstanceSlot(ctx, quota("u1", "", "failed"))
+	redisRepo.ReleaseInstanceSlot(ctx, quota("u1", "", "failed"), true)
+	svc.cfg.Instance.MaxPerUser = 0
+	for _, cid := range []string{"a", "b", "c"} {
+		if v, _ := redisRepo.ReserveInstanceSlot(ctx, quota("u1", "", cid)); v != nil {
+			t.Fatalf("未超过每日次数时应通过, got %+v", v)
+		}
+	}
+	v, _ = redisRepo.ReserveInstanceSlot(ctx, quota("u1", "", "d"))
+	if v == nil || v.Kind != redisRepo.QuotaDaily {
+		t.Errorf("超过每日启动次数应返回 daily, got %+v", v)
+	}
+}
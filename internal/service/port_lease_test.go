@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"cyber-range/internal/infra/docker"
+	redisRepo "cyber-range/internal/infra/redis"
+	"cyber-range/internal/model"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestPortLeases(t *testing.T) {
+	setupTestRedis(t)
+	ctx := context.Background()
+	const host = "host-1"
+
+	// 并发分配不会得到重复端口，端口耗尽时返回 0
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		ports = make(map[int]int)
+	)
+	for i := 0; i < 12; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			port, err := redisRepo.LeasePort(ctx, host, 20000, 20009)
+			if err != nil {
+				t.Errorf("LeasePort() error = %v", err)
+				return
+			}
+			mu.Lock()
+			ports[port]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if ports[0] != 2 || len(ports) != 11 {
+		t.Fatalf("10 个端口应分配给 10 个请求且不重复，其余返回 0, got %v", ports)
+	}
+
+	// 绑定、冲突与释放
+	redisRepo.BindPort(ctx, host, 20000, "container-a")
+	redisRepo.BindPort(ctx, host, 20001, "container-a")
+	redisRepo.MarkPortConflict(ctx, host, 20002)
+	leases, _ := redisRepo.GetPortLeases(ctx, host)
+	if leases[20000] != "container-a" || leases[20002] != redisRepo.PortConflict || leases[20003] != redisRepo.PortPending {
+		t.Errorf("租约状态不正确, got %v", leases)
+	}
+	redisRepo.ReleaseContainerPorts(ctx, host, "container-a")
+	redisRepo.ReleasePort(ctx, host, 20003)
+	if port, _ := redisRepo.LeasePort(ctx, host, 20000, 20009); port != 20000 && port != 20001 && port != 20003 {
+		t.Errorf("释放后的端口应可再次分配, got %d", port)
+	}
+
+	// 重建：以运行中容器为准，保留有效期内的 pending/conflict 租约
+	if err := redisRepo.ResetPortLeases(ctx, host, map[int]string{20005: "container-b", 20100: "container-c"}); err != nil {
+		t.Fatalf("ResetPortLeases() error = %v", err)
+	}
+	leases, _ = redisRepo.GetPortLeases(ctx, host)
+	if leases[20005] != "container-b" || leases[20002] != redisRepo.PortConflict || leases[20004] != redisRepo.PortPending {
+		t.Errorf("重建后的租约不正确, got %v", leases)
+	}
+
+	manager := docker.NewDockerHostManager()
+	manager.SetPortLeaser(redisRepo.PortLeaser{})
+	usage, err := manager.PortUsage(ctx, &model.DockerHost{ID: host, PortRangeMin: 20000, PortRangeMax: 20009})
+	if err != nil {
+		t.Fatalf("PortUsage() error = %v", err)
+	}
+	// 20100 在端口范围外不计入
+	if usage.Total != 10 || usage.Leased != 1 || usage.Conflict != 1 || usage.Available+usage.Pending != 8 {
+		t.Errorf("端口使用统计不正确, got %+v", usage)
+	}
+}
+
+func TestStartInstance_PortsExhausted(t *testing.T) {
+	svc, engine := setupStartTest(t)
+	engine.StartError = fmt.Errorf("%w（20000-20009）", docker.ErrPortsExhausted)
+
+	job, err := svc.StartInstance(context.Background(), "test-user-1", "test-challenge-1", "", "")
+	if err != nil {
+		t.Fatalf("StartInstance() error = %v", err)
+	}
+	if job = waitJob(t, svc, "test-user-1", job.ID); job.Status != JobFailed || job.Reason != portsExhaustedReason {
+		t.Errorf("端口耗尽时任务应失败并提示端口不足, got %+v", job)
+	}
+}
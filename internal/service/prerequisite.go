@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/config"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ChallengeView 玩家端题目信息（附带前置条件与锁定状态）
+type ChallengeView struct {
+	model.Challenge
+	Prerequisites []string `json:"prerequisites,omitempty"`
+	Locked        bool     `json:"locked"`
+	LockedReason  string   `json:"locked_reason,omitempty"`
+}
+
+// GetPrerequisites 获取题目的前置题目ID列表
+func (s *ChallengeService) GetPrerequisites(ctx context.Context, challengeID string) ([]string, error) {
+	prereqs, err := loadPrerequisites(ctx, s.gormDB, []string{challengeID})
+	if err != nil {
+		return nil, err
+	}
+	ids := prereqs[challengeID]
+	if ids == nil {
+		ids = []string{}
+	}
+	return ids, nil
+}
+
+// SetPrerequisites 覆盖题目的前置题目（需在事务中调用），存在循环依赖时返回错误
+func (s *ChallengeService) SetPrerequisites(tx *gorm.DB, challengeID string, prerequisiteIDs []string) error {
+	ids := make([]string, 0, len(prerequisiteIDs))
+	seen := make(map[string]bool, len(prerequisiteIDs))
+	for _, id := range prerequisiteIDs {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		if id == challengeID {
+			return errors.New("题目不能将自身设为前置题目")
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+
+	if len(ids) > 0 {
+		var count int64
+		if err := tx.Model(&model.Challenge{}).Where("id IN ?", ids).Count(&count).Error; err != nil {
+			return fmt.Errorf("查询前置题目失败: %w", err)
+		}
+		if int(count) != len(ids) {
+			return errors.New("前置题目不存在")
+		}
+
+		// 以新的前置关系替换原有关系后检测是否成环
+		var edges []model.ChallengePrerequisite
+		if err := tx.Where("challenge_id <> ?", challengeID).Find(&edges).Error; err != nil {
+			return fmt.Errorf("查询前置关系失败: %w", err)
+		}
+		graph := make(map[string][]string, len(edges)+1)
+		for _, e := range edges {
+			graph[e.ChallengeID] = append(graph[e.ChallengeID], e.PrerequisiteID)
+		}
+		graph[challengeID] = ids
+		if cycle := findCycle(graph, challengeID); cycle != nil {
+			return fmt.Errorf("前置题目存在循环依赖: %s", strings.Join(challengeTitles(tx, cycle), " → "))
+		}
+	}
+
+	if err := tx.Where("challenge_id = ?", challengeID).Delete(&model.ChallengePrerequisite{}).Error; err != nil {
+		return fmt.Errorf("更新前置题目失败: %w", err)
+	}
+	for _, id := range ids {
+		if err := tx.Create(&model.ChallengePrerequisite{ChallengeID: challengeID, PrerequisiteID: id}).Error; err != nil {
+			return fmt.Errorf("更新前置题目失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// findCycle 从 start 出发做深度优先搜索，找到回到 start 的路径时返回该路径（首尾均为 start）
+func findCycle(graph map[string][]string, start string) []string {
+	visited := make(map[string]bool)
+	var path []string
+	var dfs func(node string) bool
+	dfs = func(node string) bool {
+		path = append(path, node)
+		for _, next := range graph[node] {
+			if next == start {
+				path = append(path, next)
+				return true
+			}
+			if !visited[next] {
+				visited[next] = true
+				if dfs(next) {
+					return true
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+	if dfs(start) {
+		return path
+	}
+	return nil
+}
+
+// checkPrerequisites 检查用户（团队模式下为队伍）是否已解出题目的全部前置题目
+func checkPrerequisites(ctx context.Context, db *gorm.DB, cfg *config.Config, userID, challengeID string) error {
+	prereqs, err := loadPrerequisites(ctx, db, []string{challengeID})
+	if err != nil {
+		return err
+	}
+	if len(prereqs[challengeID]) == 0 {
+		return nil
+	}
+
+	teamID, _ := lookupTeamID(ctx, db, cfg, userID)
+	solved, err := solvedChallengeIDs(ctx, db, userID, teamID)
+	if err != nil {
+		return err
+	}
+	if missing := missingPrerequisites(prereqs[challengeID], solved); len(missing) > 0 {
+		return errors.New("题目未解锁，" + lockedReason(db.WithContext(ctx), missing))
+	}
+	return nil
+}
+
+// applyPrerequisites 计算题目列表的锁定状态；锁定且设置为隐藏的题目不返回，锁定题目不返回描述与提示
+func applyPrerequisites(ctx context.Context, db *gorm.DB, cfg *config.Config, userID string, challenges []model.Challenge) ([]ChallengeView, error) {
+	ids := make([]string, 0, len(challenges))
+	for _, c := range challenges {
+		ids = append(ids, c.ID)
+	}
+	prereqs, err := loadPrerequisites(ctx, db, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var solved map[string]bool
+	if len(prereqs) > 0 {
+		teamID, _ := lookupTeamID(ctx, db, cfg, userID)
+		if solved, err = solvedChallengeIDs(ctx, db, userID, teamID); err != nil {
+			return nil, err
+		}
+	}
+
+	views := make([]ChallengeView, 0, len(challenges))
+	for _, c := range challenges {
+		view := ChallengeView{Challenge: c, Prerequisites: prereqs[c.ID]}
+		if missing := missingPrerequisites(prereqs[c.ID], solved); len(missing) > 0 {
+			if c.HideLocked {
+				continue
+			}
+			view.Locked = true
+			view.LockedReason = lockedReason(db.WithContext(ctx), missing)
+			view.Description = ""
+			view.Hint = ""
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// loadPrerequisites 批量加载题目的前置题目ID
+func loadPrerequisites(ctx context.Context, db *gorm.DB, challengeIDs []string) (map[string][]string, error) {
+	result := make(map[string][]string)
+	if len(challengeIDs) == 0 {
+		return result, nil
+	}
+	var rows []model.ChallengePrerequisite
+	if err := db.WithContext(ctx).Where("challenge_id IN ?", challengeIDs).
+		Order("created_at ASC").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询前置题目失败: %w", err)
+	}
+	for _, r := range rows {
+		result[r.ChallengeID] = append(result[r.ChallengeID], r.PrerequisiteID)
+	}
+	return result, nil
+}
+
+// solvedChallengeIDs 获取用户（团队模式下为队伍）已正确提交的题目，不区分赛事
+func solvedChallengeIDs(ctx context.Context, db *gorm.DB, userID, teamID string) (map[string]bool, error) {
+	query := db.WithContext(ctx).Model(&model.Submission{}).Where("is_correct = ?", true)
+	if teamID != "" {
+		query = query.Where("team_id = ?", teamID)
+	} else {
+		query = query.Where("user_id = ?", userID)
+	}
+	var ids []string
+	if err := query.Distinct().Pluck("challenge_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("查询解题记录失败: %w", err)
+	}
+	solved := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		solved[id] = true
+	}
+	return solved, nil
+}
+
+func missingPrerequisites(prereqs []string, solved map[string]bool) []string {
+	var missing []string
+	for _, id := range prereqs {
+		if !solved[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+func lockedReason(db *gorm.DB, missing []string) string {
+	return "需先解出：" + strings.Join(challengeTitles(db, missing), "、")
+}
+
+// challengeTitles 按顺序将题目ID转换为标题，找不到时保留ID
+func challengeTitles(db *gorm.DB, ids []string) []string {
+	var challenges []model.Challenge
+	db.Select("id", "title").Where("id IN ?", ids).Find(&challenges)
+	titles := make(map[string]string, len(challenges))
+	for _, c := range challenges {
+		titles[c.ID] = c.Title
+	}
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if title, ok := titles[id]; ok {
+			names = append(names, title)
+		} else {
+			names = append(names, id)
+		}
+	}
+	return names
+}
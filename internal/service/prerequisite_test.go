@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"cyber-range/internal/model"
+	"strings"
+	"testing"
+)
+
+func TestChallengePrerequisites(t *testing.T) {
+	setupTestRedis(t)
+	svc, testDB := setupTestService(t)
+	ctx := context.Background()
+
+	testDB.Create(&model.Challenge{ID: "web1", Title: "Web 1", Kind: "static", Flag: "flag{web1}", Points: 100, Status: "published"})
+	testDB.Create(&model.Challenge{ID: "web2", Title: "Web 2", Kind: "static", Flag: "flag{web2}", Points: 100, Status: "published", Description: "secret"})
+	testDB.Create(&model.Challenge{ID: "web3", Title: "Web 3", Kind: "static", Flag: "flag{web3}", Points: 100, Status: "published", HideLocked: true})
+	testDB.Create(&model.Challenge{ID: "pwn1", Title: "Pwn 1", Image: "pwn", Port: 9999, Flag: "flag{pwn}", Points: 100, Status: "published"})
+
+	for id, prereqs := range map[string][]string{"web2": {"web1"}, "web3": {"web2"}, "pwn1": {"web1", "web2"}} {
+		if err := svc.SetPrerequisites(testDB, id, prereqs); err != nil {
+			t.Fatalf("SetPrerequisites(%s) error = %v", id, err)
+		}
+	}
+
+	views, err := svc.ListChallenges(ctx, "test-user-1", "")
+	if err != nil {
+		t.Fatalf("ListChallenges() error = %v", err)
+	}
+	byID := make(map[string]ChallengeView)
+	for _, v := range views {
+		byID[v.ID] = v
+	}
+	if _, ok := byID["web3"]; ok {
+		t.Error("设置了 hide_locked 的锁定题目不应返回")
+	}
+	if byID["web1"].Locked {
+		t.Error("无前置题目的题目不应锁定")
+	}
+	web2 := byID["web2"]
+	if !web2.Locked || !strings.Contains(web2.LockedReason, "Web 1") || web2.Description != "" {
+		t.Errorf("web2 应锁定并给出原因、隐藏描述, got %+v", web2)
+	}
+
+	if _, err := svc.StartInstance(ctx, "test-user-1", "pwn1", "", ""); err == nil || !strings.Contains(err.Error(), "未解锁") {
+		t.Errorf("锁定题目不应允许启动实例, got %v", err)
+	}
+	result, err := svc.VerifyFlag(ctx, "test-user-1", "web2", "", "flag{web2}")
+	if err != nil {
+		t.Fatalf("VerifyFlag() error = %v", err)
+	}
+	if result.Correct {
+		t.Error("锁定题目不应允许提交")
+	}
+
+	// 解出 web1 后 web2 解锁，web3 仍隐藏
+	if result, _ := svc.VerifyFlag(ctx, "test-user-1", "web1", "", "flag{web1}"); !result.Correct {
+		t.Fatalf("web1 应提交成功, got %+v", result)
+	}
+	if result, _ := svc.VerifyFlag(ctx, "test-user-1", "web2", "", "flag{web2}"); !result.Correct {
+		t.Errorf("解出前置题目后应允许提交, got %+v", result)
+	}
+	views, _ = svc.ListChallenges(ctx, "test-user-1", "")
+	for _, v := range views {
+		if v.Locked {
+			t.Errorf("全部前置题目解出后不应锁定, got %+v", v)
+		}
+	}
+	if len(views) != 4 {
+		t.Errorf("解锁后应返回全部题目, got %d", len(views))
+	}
+}
+
+func TestSetPrerequisites_Validation(t *testing.T) {
+	svc, testDB := setupTestService(t)
+
+	for _, id := range []string{"a", "b", "c"} {
+		testDB.Create(&model.Challenge{ID: id, Title: "题目" + id, Kind: "static", Flag: "x", Points: 100})
+	}
+	if err := svc.SetPrerequisites(testDB, "b", []string{"a"}); err != nil {
+		t.Fatalf("SetPrerequisites() error = %v", err)
+	}
+	if err := svc.SetPrerequisites(testDB, "c", []string{"b"}); err != nil {
+		t.Fatalf("SetPrerequisites() error = %v", err)
+	}
+
+	err := svc.SetPrerequisites(testDB, "a", []string{"c"})
+	if err == nil || !strings.Contains(err.Error(), "循环依赖") {
+		t.Fatalf("应检测到循环依赖, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "题目a → 题目c → 题目b → 题目a") {
+		t.Errorf("循环依赖错误应包含依赖路径, got %v", err)
+	}
+	if err := svc.SetPrerequisites(testDB, "a", []string{"a"}); err == nil {
+		t.Error("不应允许将自身设为前置题目")
+	}
+	if err := svc.SetPrerequisites(testDB, "a", []string{"missing"}); err == nil {
+		t.Error("不存在的前置题目应返回错误")
+	}
+
+	// 覆盖与清空
+	if err := svc.SetPrerequisites(testDB, "c", []string{"a", "a", "b"}); err != nil {
+		t.Fatalf("SetPrerequisites() error = %v", err)
+	}
+	if ids, _ := svc.GetPrerequisites(context.Background(), "c"); len(ids) != 2 {
+		t.Errorf("重复的前置题目应去重, got %v", ids)
+	}
+	if err := svc.SetPrerequisites(testDB, "b", []string{}); err != nil {
+		t.Fatalf("SetPrerequisites() error = %v", err)
+	}
+	if ids, _ := svc.GetPrerequisites(context.Background(), "b"); len(ids) != 0 {
+		t.Errorf("传空列表应清空前置题目, got %v", ids)
+	}
+}
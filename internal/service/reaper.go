@@ -40,6 +40,7 @@ func (r *Reaper) Start(ctx context.Context) {
 			select {
 			case <-r.ticker.C:
 				r.reapExpiredInstances(ctx)
+				r.pruneInstanceNetworks(ctx)
 			case <-r.stopChan:
 				logger.Info(ctx, "The Reaper stopped")
 				return
@@ -93,12 +94,11 @@ func (r *Reaper) killInstance(ctx context.Context, instanceID string) {
 		return
 	}
 
-	// 优先使用数据库中的数据，Redis 作为备用校验
-	containerID := instance.ContainerID
+	// 数据库字段为权威数据，Redis 仅覆盖容器与端口信息（实例重置后会变化）
 	userID := instance.UserID
-	if len(instData) > 0 && instData["container_id"] != "" {
-		containerID = instData["container_id"]
-	}
+	applyInstanceContainers(&instance, instData)
+	containerIDs := instance.ContainerIDs()
+	defer releaseReservation(ctx, &instance)
 
 	// 获取 Docker 主机配置
 	dockerHost, err := r.repo.GetDockerHostByID(ctx, instance.DockerHostID)
@@ -108,7 +108,7 @@ func (r *Reaper) killInstance(ctx context.Context, instanceID string) {
 			"docker_host_id", instance.DockerHostID,
 			"error", err)
 		// 清理 Redis，即使无法停止容器
-		redisRepo.DeleteInstance(ctx, instanceID, userID)
+		redisRepo.DeleteInstance(ctx, instanceID, userID, instance.TeamID)
 		r.updateInstanceStatus(ctx, instanceID, "expired")
 		return
 	}
@@ -120,21 +120,21 @@ func (r *Reaper) killInstance(ctx context.Context, instanceID string) {
 			"docker_host", dockerHost.Name,
 			"error", err)
 		// 清理 Redis，即使无法停止容器
-		redisRepo.DeleteInstance(ctx, instanceID, userID)
+		redisRepo.DeleteInstance(ctx, instanceID, userID, instance.TeamID)
 		r.updateInstanceStatus(ctx, instanceID, "expired")
 		return
 	}
 
-	// Force kill container
-	if err := dockerClient.StopContainer(ctx, containerID); err != nil {
+	// Force kill containers（多容器实例删除全部服务容器及实例网络）
+	if err := dockerClient.StopContainers(ctx, containerIDs); err != nil {
 		logger.Warn(ctx, "Reaper: failed to stop container",
-			"container_id", containerID,
+			"container_ids", containerIDs,
 			"docker_host", dockerHost.Name,
 			"error", err)
 	}
 
 	// Clean up Redis
-	if err := redisRepo.DeleteInstance(ctx, instanceID, userID); err != nil {
+	if err := redisRepo.DeleteInstance(ctx, instanceID, userID, instance.TeamID); err != nil {
 		logger.Error(ctx, "Reaper: failed to delete from Redis", "instance_id", instanceID, "error", err)
 	}
 
@@ -143,10 +143,30 @@ func (r *Reaper) killInstance(ctx context.Context, instanceID string) {
 
 	logger.Info(ctx, "Reaper: successfully killed expired instance",
 		"instance_id", instanceID,
-		"container_id", containerID,
+		"container_ids", containerIDs,
 		"docker_host", dockerHost.Name)
 }
 
+// pruneInstanceNetworks 清理各主机上未挂载容器的实例网络（容器删除失败或进程异常退出时残留）
+func (r *Reaper) pruneInstanceNetworks(ctx context.Context) {
+	hosts, err := r.repo.ListDockerHosts(ctx, true)
+	if err != nil {
+		logger.Error(ctx, "Reaper failed to list Docker hosts", "error", err)
+		return
+	}
+
+	for _, host := range hosts {
+		pruned, err := r.dockerManager.PruneInstanceNetworks(ctx, host)
+		if err != nil {
+			logger.Warn(ctx, "Reaper: failed to prune instance networks", "docker_host", host.Name, "error", err)
+			continue
+		}
+		if pruned > 0 {
+			logger.Info(ctx, "Reaper: pruned orphaned instance networks", "docker_host", host.Name, "count", pruned)
+		}
+	}
+}
+
 // updateInstanceStatus 更新实例状态
 func (r *Reaper) updateInstanceStatus(ctx context.Context, instanceID, status string) {
 	r.gormDB.WithContext(ctx).Model(&model.Instance{}).
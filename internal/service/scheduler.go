@@ -0,0 +1,387 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"cyber-range/internal/infra/db"
+	"cyber-range/internal/infra/docker"
+	redisRepo "cyber-range/internal/infra/redis"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/config"
+	"cyber-range/pkg/logger"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 主机调度策略
+const (
+	SchedulerLeastInstances = "least_instances"  // 运行中实例最少的主机
+	SchedulerMostFreeMemory = "most_free_memory" // 可用内存最多的主机
+	SchedulerRoundRobin     = "round_robin"      // 轮询
+	SchedulerImageLocality  = "image_locality"   // 优先已缓存题目镜像的主机，其次实例最少
+)
+
+const (
+	defaultProbeInterval = 30 * time.Second // 默认主机探测间隔
+	hostProbeTimeout     = 10 * time.Second // 单台主机探测的超时时间
+	hostPingTimeout      = 3 * time.Second  // 选定主机后连接检查的超时时间
+)
+
+// HostScheduler 实例主机调度器
+// 题目指定了 Docker 主机时固定使用该主机；否则在已启用且具备题目所需标签的主机中按策略排序。
+// 后台定期探测主机健康状态与资源，探测失败的主机排在最后；创建实例时按顺序连接并预留主机容量，
+// 连接失败的主机被标记为不健康、容量已满的主机被跳过，自动切换到下一台
+type HostScheduler struct {
+	engines docker.EngineProvider
+	repo    *db.Repository
+	cfg     *config.Config
+
+	mu     sync.Mutex
+	states map[string]hostState // hostID -> 最近一次探测结果
+	next   atomic.Uint64        // 轮询计数
+
+	ticker   *time.Ticker
+	stopChan chan struct{}
+}
+
+// hostState 主机探测结果
+type hostState struct {
+	healthy   bool
+	err       string
+	resources *docker.HostResources // 资源概况（引擎不支持或获取失败时为空）
+	checkedAt time.Time
+}
+
+func NewHostScheduler(engines docker.EngineProvider, repo *db.Repository, cfg *config.Config) *HostScheduler {
+	return &HostScheduler{
+		engines:  engines,
+		repo:     repo,
+		cfg:      cfg,
+		states:   make(map[string]hostState),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start 立即探测一次全部已启用主机，之后按 probe_interval 定期探测
+func (s *HostScheduler) Start(ctx context.Context) {
+	interval := s.probeInterval()
+	s.ticker = time.NewTicker(interval)
+	logger.Info(ctx, "Host scheduler started", "strategy", s.strategy(), "probe_interval", interval)
+
+	go func() {
+		s.probeAll(ctx)
+		for {
+			select {
+			case <-s.ticker.C:
+				s.probeAll(ctx)
+			case <-s.stopChan:
+				logger.Info(ctx, "Host scheduler stopped")
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台探测
+func (s *HostScheduler) Stop() {
+	if s.ticker != nil {
+		s.ticker.Stop()
+	}
+	close(s.stopChan)
+}
+
+// Placement 调度结果
+type Placement struct {
+	Host        *model.DockerHost
+	Engine      docker.ContainerEngine
+	Reservation redisRepo.HostReservation // 已在主机上预留的资源（实例创建失败时须释放）
+}
+
+// Place 为题目实例选择 Docker 主机、获取其容器引擎并以 instanceID 预留主机容量
+// 按候选顺序依次尝试：连接或健康检查失败的主机标记为不健康，容量已满的主机跳过；
+// 可连接的候选主机均已满时返回 ErrRangeFull
+func (s *HostScheduler) Place(ctx context.Context, challenge *model.Challenge, images []string, instanceID string, demand func(*model.DockerHost) redisRepo.HostReservation) (*Placement, error) {
+	hosts, err := s.Candidates(ctx, challenge, images)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, host := range hosts {
+		engine, err := s.engines.GetEngine(ctx, host)
+		if err == nil {
+			if prober, ok := engine.(docker.HostProber); ok {
+				pingCtx, cancel := context.WithTimeout(ctx, hostPingTimeout)
+				_, err = prober.Ping(pingCtx)
+				cancel()
+			}
+		}
+		if err != nil {
+			logger.Warn(ctx, "Docker host unavailable, trying next host", "docker_host", host.Name, "error", err)
+			s.MarkUnhealthy(host.ID, err)
+			if !errors.Is(lastErr, ErrRangeFull) {
+				lastErr = fmt.Errorf("连接 Docker 主机 %s 失败: %w", host.Name, err)
+			}
+			continue
+		}
+
+		reservation := demand(host)
+		exceeded, err := redisRepo.ReserveHostCapacity(ctx, host.ID, instanceID, reservation, hostCapacity(host))
+		if err != nil {
+			return nil, fmt.Errorf("预留主机资源失败: %w", err)
+		}
+		if exceeded != "" {
+			logger.Info(ctx, "Docker host is full, trying next host", "docker_host", host.Name, "exceeded", exceeded)
+			lastErr = ErrRangeFull
+			continue
+		}
+		logger.Debug(ctx, "Docker host selected", "docker_host", host.Name, "challenge_id", challenge.ID, "strategy", s.strategy())
+		return &Placement{Host: host, Engine: engine, Reservation: reservation}, nil
+	}
+	return nil, lastErr
+}
+
+// Candidates 返回题目实例的候选主机，按调度优先级排序（健康主机在前）
+func (s *HostScheduler) Candidates(ctx context.Context, challenge *model.Challenge, images []string) ([]*model.DockerHost, error) {
+	if challenge.DockerHostID != "" {
+		host, err := s.repo.GetDockerHostByID(ctx, challenge.DockerHostID)
+		if err != nil {
+			return nil, fmt.Errorf("Docker 主机配置不存在: %w", err)
+		}
+		if !host.Enabled {
+			return nil, fmt.Errorf("Docker 主机已禁用: %s", host.Name)
+		}
+		return []*model.DockerHost{host}, nil
+	}
+
+	hosts, err := s.repo.GetEnabledDockerHosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	required := model.ParseTags(challenge.HostTags)
+	hosts = slices.DeleteFunc(hosts, func(h *model.DockerHost) bool { return !h.HasTags(required) })
+	if len(hosts) == 0 {
+		if len(required) > 0 {
+			return nil, fmt.Errorf("没有具备标签 %s 的可用 Docker 主机", strings.Join(required, ","))
+		}
+		return nil, errors.New("没有可用的 Docker 主机")
+	}
+
+	// 基准顺序：默认主机在前，其余按 ID，保证相同条件下的选择稳定
+	slices.SortFunc(hosts, func(a, b *model.DockerHost) int {
+		if a.IsDefault != b.IsDefault {
+			if a.IsDefault {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	states := s.ensureProbed(ctx, hosts)
+	if err := s.rank(ctx, hosts, states, images); err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(hosts, func(a, b *model.DockerHost) int {
+		return compareBool(states[b.ID].healthy, states[a.ID].healthy)
+	})
+	return hosts, nil
+}
+
+// rank 按调度策略对主机排序（稳定排序，相同条件保持基准顺序）
+func (s *HostScheduler) rank(ctx context.Context, hosts []*model.DockerHost, states map[string]hostState, images []string) error {
+	switch s.strategy() {
+	case SchedulerRoundRobin:
+		offset := int((s.next.Add(1) - 1) % uint64(len(hosts)))
+		rotated := append(slices.Clone(hosts[offset:]), hosts[:offset]...)
+		copy(hosts, rotated)
+
+	case SchedulerMostFreeMemory:
+		// 资源未知的主机排在最后
+		slices.SortStableFunc(hosts, func(a, b *model.DockerHost) int {
+			return cmp.Compare(freeMemory(states[b.ID]), freeMemory(states[a.ID]))
+		})
+
+	case SchedulerImageLocality:
+		counts, err := s.repo.CountRunningInstancesByHost(ctx)
+		if err != nil {
+			return err
+		}
+		cached := make(map[string]int, len(hosts))
+		for _, host := range hosts {
+			if states[host.ID].healthy {
+				cached[host.ID] = s.cachedImages(ctx, host, images)
+			}
+		}
+		slices.SortStableFunc(hosts, func(a, b *model.DockerHost) int {
+			return cmp.Or(cmp.Compare(cached[b.ID], cached[a.ID]), cmp.Compare(counts[a.ID], counts[b.ID]))
+		})
+
+	default:
+		counts, err := s.repo.CountRunningInstancesByHost(ctx)
+		if err != nil {
+			return err
+		}
+		slices.SortStableFunc(hosts, func(a, b *model.DockerHost) int {
+			return cmp.Compare(counts[a.ID], counts[b.ID])
+		})
+	}
+	return nil
+}
+
+// cachedImages 主机上已缓存的题目镜像数
+func (s *HostScheduler) cachedImages(ctx context.Context, host *model.DockerHost, images []string) int {
+	engine, err := s.engines.GetEngine(ctx, host)
+	if err != nil {
+		return 0
+	}
+	prober, ok := engine.(docker.HostProber)
+	if !ok {
+		return 0
+	}
+	cached := 0
+	for _, image := range images {
+		if prober.HasLocalImage(ctx, image) {
+			cached++
+		}
+	}
+	return cached
+}
+
+// MarkUnhealthy 将主机标记为不健康，下次探测成功前排在候选主机的最后
+func (s *HostScheduler) MarkUnhealthy(hostID string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.states[hostID]
+	state.healthy = false
+	state.err = err.Error()
+	state.checkedAt = time.Now()
+	s.states[hostID] = state
+}
+
+// ensureProbed 返回主机的探测结果，尚未探测或结果已过期（超过两个探测间隔）的主机立即探测
+func (s *HostScheduler) ensureProbed(ctx context.Context, hosts []*model.DockerHost) map[string]hostState {
+	stale := time.Now().Add(-2 * s.probeInterval())
+	var missing []*model.DockerHost
+	s.mu.Lock()
+	for _, host := range hosts {
+		if state, ok := s.states[host.ID]; !ok || state.checkedAt.Before(stale) {
+			missing = append(missing, host)
+		}
+	}
+	s.mu.Unlock()
+	if len(missing) > 0 {
+		s.probeHosts(ctx, missing)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	states := make(map[string]hostState, len(hosts))
+	for _, host := range hosts {
+		states[host.ID] = s.states[host.ID]
+	}
+	return states
+}
+
+// probeAll 探测全部已启用主机
+func (s *HostScheduler) probeAll(ctx context.Context) {
+	hosts, err := s.repo.GetEnabledDockerHosts(ctx)
+	if err != nil {
+		logger.Error(ctx, "Host scheduler failed to list Docker hosts", "error", err)
+		return
+	}
+	s.probeHosts(ctx, hosts)
+}
+
+// probeHosts 并发探测主机的连通性与资源概况
+func (s *HostScheduler) probeHosts(ctx context.Context, hosts []*model.DockerHost) {
+	var wg sync.WaitGroup
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(host *model.DockerHost) {
+			defer wg.Done()
+			probeCtx, cancel := context.WithTimeout(ctx, hostProbeTimeout)
+			defer cancel()
+			state := s.probe(probeCtx, host)
+
+			s.mu.Lock()
+			previous, known := s.states[host.ID]
+			s.states[host.ID] = state
+			s.mu.Unlock()
+
+			if !state.healthy && (!known || previous.healthy) {
+				logger.Warn(ctx, "Docker host is unhealthy", "docker_host", host.Name, "error", state.err)
+			} else if state.healthy && known && !previous.healthy {
+				logger.Info(ctx, "Docker host recovered", "docker_host", host.Name)
+			}
+		}(host)
+	}
+	wg.Wait()
+}
+
+// probe 探测单台主机；引擎不支持探测时视为健康且资源未知
+func (s *HostScheduler) probe(ctx context.Context, host *model.DockerHost) hostState {
+	state := hostState{checkedAt: time.Now()}
+	engine, err := s.engines.GetEngine(ctx, host)
+	if err != nil {
+		state.err = err.Error()
+		return state
+	}
+	prober, ok := engine.(docker.HostProber)
+	if !ok {
+		state.healthy = true
+		return state
+	}
+	if _, err := prober.Ping(ctx); err != nil {
+		state.err = err.Error()
+		return state
+	}
+	state.healthy = true
+	if resources, err := prober.HostResources(ctx); err != nil {
+		logger.Warn(ctx, "Failed to get Docker host resources", "docker_host", host.Name, "error", err)
+	} else {
+		state.resources = resources
+	}
+	return state
+}
+
+func (s *HostScheduler) strategy() string {
+	switch strategy := s.cfg.Scheduler.Strategy; strategy {
+	case SchedulerMostFreeMemory, SchedulerRoundRobin, SchedulerImageLocality:
+		return strategy
+	default:
+		return SchedulerLeastInstances
+	}
+}
+
+func (s *HostScheduler) probeInterval() time.Duration {
+	if s.cfg.Scheduler.ProbeInterval > 0 {
+		return time.Duration(s.cfg.Scheduler.ProbeInterval) * time.Second
+	}
+	return defaultProbeInterval
+}
+
+// freeMemory 主机可用内存，资源未知时返回 -1
+func freeMemory(state hostState) int64 {
+	if state.resources == nil {
+		return -1
+	}
+	return state.resources.MemoryFree()
+}
+
+// compareBool false < true
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"cyber-range/internal/infra/docker"
+	"cyber-range/internal/model"
+	"cyber-range/tests/mock"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupSchedulerTest 三台已启用主机：默认主机 test-docker-host（2 个运行中实例）、host-b（标签 pwn，1 个实例，已缓存镜像）、
+// host-c（标签 pwn,gpu，无实例，资源未知）
+func setupSchedulerTest(t *testing.T) (*ChallengeService, *mock.MockDockerClient) {
+	t.Helper()
+	svc, engine := setupStartTest(t)
+	svc.gormDB.Create(&model.DockerHost{ID: "host-b", Name: "主机B", Tags: "pwn", PortRangeMin: 20000, PortRangeMax: 40000, Enabled: true})
+	svc.gormDB.Create(&model.DockerHost{ID: "host-c", Name: "主机C", Tags: "pwn,gpu", PortRangeMin: 20000, PortRangeMax: 40000, Enabled: true})
+	for i, hostID := range []string{"test-docker-host", "test-docker-host", "host-b"} {
+		svc.gormDB.Create(&model.Instance{
+			ID: "running-" + string(rune('a'+i)), UserID: "u", ChallengeID: "c", ContainerID: "x",
+			DockerHostID: hostID, Flag: "f", Status: "running", ExpiresAt: time.Now().Add(time.Hour),
+		})
+	}
+	svc.gormDB.Create(&model.Instance{
+		ID: "stopped-a", UserID: "u", ChallengeID: "c", ContainerID: "x",
+		DockerHostID: "host-c", Flag: "f", Status: "stopped", ExpiresAt: time.Now(),
+	})
+
+	const gb = int64(1) << 30
+	engine.Resources = map[string]*docker.HostResources{
+		"test-docker-host": {MemoryTotal: 8 * gb, MemoryUsage: 1 * gb},
+		"host-b":           {MemoryTotal: 16 * gb, MemoryUsage: 2 * gb},
+	}
+	engine.LocalImages = map[string][]string{"host-b": {"nginx:alpine"}}
+	return svc, engine
+}
+
+func candidateIDs(t *testing.T, svc *ChallengeService, challenge *model.Challenge) []string {
+	t.Helper()
+	hosts, err := svc.scheduler.Candidates(context.Background(), challenge, []string{"nginx:alpine"})
+	if err != nil {
+		t.Fatalf("Candidates() error = %v", err)
+	}
+	var ids []string
+	for _, h := range hosts {
+		ids = append(ids, h.ID)
+	}
+	return ids
+}
+
+func TestHostScheduler_Strategies(t *testing.T) {
+	tests := []struct {
+		strategy string
+		want     string
+	}{
+		{"", "host-c,host-b,test-docker-host"},
+		{SchedulerLeastInstances, "host-c,host-b,test-docker-host"},
+		{SchedulerMostFreeMemory, "host-b,test-docker-host,host-c"},
+		{SchedulerImageLocality, "host-b,host-c,test-docker-host"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			svc, _ := setupSchedulerTest(t)
+			svc.cfg.Scheduler.Strategy = tt.strategy
+			if got := strings.Join(candidateIDs(t, svc, &model.Challenge{ID: "c"}), ","); got != tt.want {
+				t.Errorf("候选主机顺序 = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run(SchedulerRoundRobin, func(t *testing.T) {
+		svc, _ := setupSchedulerTest(t)
+		svc.cfg.Scheduler.Strategy = SchedulerRoundRobin
+		var firsts []string
+		for range 4 {
+			firsts = append(firsts, candidateIDs(t, svc, &model.Challenge{ID: "c"})[0])
+		}
+		if got := strings.Join(firsts, ","); got != "test-docker-host,host-b,host-c,test-docker-host" {
+			t.Errorf("轮询顺序 = %s", got)
+		}
+	})
+}
+
+func TestHostScheduler_AffinityAndTags(t *testing.T) {
+	svc, _ := setupSchedulerTest(t)
+	ctx := context.Background()
+
+	if got := strings.Join(candidateIDs(t, svc, &model.Challenge{HostTags: "gpu"}), ","); got != "host-c" {
+		t.Errorf("标签约束 gpu 的候选主机 = %s", got)
+	}
+	if got := strings.Join(candidateIDs(t, svc, &model.Challenge{HostTags: " PWN "}), ","); got != "host-c,host-b" {
+		t.Errorf("标签约束 pwn 的候选主机 = %s", got)
+	}
+	if _, err := svc.scheduler.Candidates(ctx, &model.Challenge{HostTags: "arm"}, nil); err == nil || !strings.Contains(err.Error(), "arm") {
+		t.Errorf("没有满足标签的主机时应返回错误, got %v", err)
+	}
+
+	// 题目指定主机时固定使用该主机，不参与调度
+	if got := strings.Join(candidateIDs(t, svc, &model.Challenge{DockerHostID: "host-b"}), ","); got != "host-b" {
+		t.Errorf("指定主机的候选主机 = %s", got)
+	}
+	svc.gormDB.Model(&model.DockerHost{}).Where("id = ?", "host-b").Update("enabled", false)
+	if _, err := svc.scheduler.Candidates(ctx, &model.Challenge{DockerHostID: "host-b"}, nil); err == nil {
+		t.Error("指定的主机已禁用时应返回错误")
+	}
+	if got := strings.Join(candidateIDs(t, svc, &model.Challenge{HostTags: "pwn"}), ","); got != "host-c" {
+		t.Errorf("已禁用的主机不应参与调度, got %s", got)
+	}
+}
+
+func TestHostScheduler_Failover(t *testing.T) {
+	svc, engine := setupSchedulerTest(t)
+	ctx := context.Background()
+
+	// 首选主机不可连接时切换到下一台，并在恢复前排在最后（之后 host-b 与默认主机均有 2 个实例）
+	engine.DownHosts = map[string]bool{"host-c": true}
+	instance := startAndWait(t, svc, "test-user-1", "test-challenge-2", "")
+	if instance.DockerHostID != "host-b" {
+		t.Fatalf("首选主机不可用时应切换到 host-b, got %s", instance.DockerHostID)
+	}
+	engine.DownHosts = nil
+	if got := strings.Join(candidateIDs(t, svc, &model.Challenge{}), ","); got != "test-docker-host,host-b,host-c" {
+		t.Errorf("不健康的主机应排在最后, got %s", got)
+	}
+
+	// 重新探测成功后恢复调度
+	svc.scheduler.probeAll(ctx)
+	if got := candidateIDs(t, svc, &model.Challenge{})[0]; got != "host-c" {
+		t.Errorf("主机恢复后应重新参与调度, got %s", got)
+	}
+
+	// 全部主机不可连接时启动失败
+	engine.DownHosts = map[string]bool{"test-docker-host": true, "host-b": true, "host-c": true}
+	job, err := svc.StartInstance(ctx, "test-user-2", "test-challenge-2", "", "")
+	if err != nil {
+		t.Fatalf("StartInstance() error = %v", err)
+	}
+	if job = waitJob(t, svc, "test-user-2", job.ID); job.Status != JobFailed {
+		t.Errorf("全部主机不可用时任务应失败, got %s", job.Status)
+	}
+}
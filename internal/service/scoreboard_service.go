@@ -0,0 +1,439 @@
+package service
+
+import (
+	"context"
+	redisRepo "cyber-range/internal/infra/redis"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/config"
+	"cyber-range/pkg/logger"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const defaultScoreboardCacheTTL = 10 * time.Second
+
+// ScoreboardService 积分榜服务
+// 数据来自计分提交记录（分值 + 血奖励），团队模式下以队伍为单位排名
+type ScoreboardService struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+// NewScoreboardService 创建积分榜服务
+func NewScoreboardService(db *gorm.DB, cfg *config.Config) *ScoreboardService {
+	return &ScoreboardService{db: db, cfg: cfg}
+}
+
+// ScoreboardEntry 积分榜条目
+type ScoreboardEntry struct {
+	Rank        int            `json:"rank"`
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Points      int            `json:"points"`
+	Solves      int            `json:"solves"`
+	Penalty     int            `json:"penalty,omitempty"` // 提示解锁扣分
+	LastSolveAt time.Time      `json:"last_solve_at"`
+	Categories  map[string]int `json:"categories"` // 分类 -> 得分
+}
+
+// Scoreboard 积分榜
+type Scoreboard struct {
+	Entries    []ScoreboardEntry `json:"entries"`
+	Categories []string          `json:"categories"`
+	Frozen     bool              `json:"frozen"` // 当前视图是否处于封榜状态
+	FreezeAt   *time.Time        `json:"freeze_at,omitempty"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+// ScorePoint 得分曲线上的一个点
+type ScorePoint struct {
+	Time  time.Time `json:"time"`
+	Score int       `json:"score"`
+}
+
+// ScoreSeries 单个玩家（或队伍）的累计得分曲线
+type ScoreSeries struct {
+	ID     string       `json:"id"`
+	Name   string       `json:"name"`
+	Points []ScorePoint `json:"points"`
+}
+
+// solveRow 积分榜统计用的解题记录（Penalty 为 true 时表示提示解锁扣分，Points 为负数）
+type solveRow struct {
+	UserID      string
+	TeamID      string
+	ChallengeID string
+	Category    string
+	Points      int
+	BonusPoints int
+	SubmittedAt time.Time
+	Penalty     bool
+}
+
+// GetScoreboard 获取积分榜（eventID 为空表示全站积分榜）
+// unfrozen 为 true 时忽略封榜时间（管理员视图）
+func (s *ScoreboardService) GetScoreboard(ctx context.Context, eventID string, unfrozen bool) (*Scoreboard, error) {
+	var board Scoreboard
+	err := s.cached(ctx, fmt.Sprintf("board:%s:%t", eventID, unfrozen), &board, func() (interface{}, error) {
+		return s.buildScoreboard(ctx, eventID, unfrozen)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &board, nil
+}
+
+// CheckEventAccess 校验玩家查看赛事积分榜的权限（邀请制赛事仅报名玩家可见）
+func (s *ScoreboardService) CheckEventAccess(ctx context.Context, userID, eventID string) error {
+	return checkEventScoreboardAccess(ctx, s.db, userID, eventID)
+}
+
+// ListTeamScoreboard 队伍积分榜（按积分降序）
+// 全站封榜后队伍积分按封榜时间之前的解题与提示扣分统计
+func (s *ScoreboardService) ListTeamScoreboard(ctx context.Context) ([]model.Team, error) {
+	teams := make([]model.Team, 0)
+	if err := s.db.WithContext(ctx).
+		Order("total_points DESC, created_at ASC").
+		Find(&teams).Error; err != nil {
+		return nil, fmt.Errorf("获取队伍积分榜失败: %w", err)
+	}
+
+	totals, frozen, err := s.frozenTotals(ctx, true)
+	if err != nil || !frozen {
+		return teams, err
+	}
+	for i := range teams {
+		teams[i].TotalPoints = totals[teams[i].ID]
+	}
+	sort.SliceStable(teams, func(i, j int) bool { return teams[i].TotalPoints > teams[j].TotalPoints })
+	return teams, nil
+}
+
+// ApplyFreeze 全站封榜后将玩家总分替换为封榜时间之前的得分
+func (s *ScoreboardService) ApplyFreeze(ctx context.Context, user *model.User) error {
+	totals, frozen, err := s.frozenTotals(ctx, false)
+	if err != nil || !frozen {
+		return err
+	}
+	user.TotalPoints = totals[user.ID]
+	return nil
+}
+
+// frozenTotals 全站封榜后汇总每个玩家（byTeam 为 true 时为队伍）封榜时间之前的得分（含血奖励与提示扣分），
+// 未封榜时 frozen 为 false；结果与积分榜共用缓存，封榜期间的轮询不会每次读取全部提交记录
+func (s *ScoreboardService) frozenTotals(ctx context.Context, byTeam bool) (map[string]int, bool, error) {
+	cutoff, err := frozenCutoff(ctx, s.db, s.cfg, "")
+	if err != nil || cutoff == nil {
+		return nil, false, err
+	}
+
+	var totals map[string]int
+	err = s.cached(ctx, fmt.Sprintf("frozen-totals:%t", byTeam), &totals, func() (interface{}, error) {
+		rows, _, _, err := s.loadSolves(ctx, "", false)
+		if err != nil {
+			return nil, err
+		}
+		totals := make(map[string]int)
+		for _, row := range rows {
+			id := row.UserID
+			if byTeam {
+				id = row.TeamID
+			}
+			if id != "" {
+				totals[id] += row.Points + row.BonusPoints
+			}
+		}
+		return totals, nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return totals, true, nil
+}
+
+// GetProgression 获取前 topN 名的累计得分曲线
+func (s *ScoreboardService) GetProgression(ctx context.Context, eventID string, topN int, unfrozen bool) ([]ScoreSeries, error) {
+	if topN <= 0 {
+		topN = 10
+	}
+
+	var series []ScoreSeries
+	err := s.cached(ctx, fmt.Sprintf("progression:%s:%t:%d", eventID, unfrozen, topN), &series, func() (interface{}, error) {
+		return s.buildProgression(ctx, eventID, topN, unfrozen)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// buildScoreboard 从提交记录统计积分榜
+func (s *ScoreboardService) buildScoreboard(ctx context.Context, eventID string, unfrozen bool) (*Scoreboard, error) {
+	rows, freezeAt, frozen, err := s.loadSolves(ctx, eventID, unfrozen)
+	if err != nil {
+		return nil, err
+	}
+
+	ranked, categories := s.rank(ctx, rows)
+	return &Scoreboard{
+		Entries:    ranked,
+		Categories: categories,
+		Frozen:     frozen,
+		FreezeAt:   freezeAt,
+		UpdatedAt:  time.Now(),
+	}, nil
+}
+
+// buildProgression 按解题时间累计前 topN 名的得分
+func (s *ScoreboardService) buildProgression(ctx context.Context, eventID string, topN int, unfrozen bool) ([]ScoreSeries, error) {
+	rows, _, _, err := s.loadSolves(ctx, eventID, unfrozen)
+	if err != nil {
+		return nil, err
+	}
+
+	ranked, _ := s.rank(ctx, rows)
+	if len(ranked) < topN {
+		topN = len(ranked)
+	}
+	index := make(map[string]int, topN)
+	series := make([]ScoreSeries, topN)
+	for i := 0; i < topN; i++ {
+		index[ranked[i].ID] = i
+		series[i] = ScoreSeries{ID: ranked[i].ID, Name: ranked[i].Name, Points: make([]ScorePoint, 0)}
+	}
+
+	totals := make(map[string]int, topN)
+	for _, row := range rows {
+		ownerID := s.ownerOf(row)
+		i, ok := index[ownerID]
+		if !ok {
+			continue
+		}
+		totals[ownerID] += row.Points + row.BonusPoints
+		series[i].Points = append(series[i].Points, ScorePoint{Time: row.SubmittedAt, Score: totals[ownerID]})
+	}
+
+	return series, nil
+}
+
+// rank 汇总解题记录并排名，返回排名结果与出现过的题目分类
+func (s *ScoreboardService) rank(ctx context.Context, rows []solveRow) ([]ScoreboardEntry, []string) {
+	entries := make(map[string]*ScoreboardEntry)
+	categorySet := make(map[string]bool)
+	for _, row := range rows {
+		ownerID := s.ownerOf(row)
+		if ownerID == "" {
+			continue
+		}
+
+		entry, ok := entries[ownerID]
+		if !ok {
+			entry = &ScoreboardEntry{ID: ownerID, Categories: make(map[string]int)}
+			entries[ownerID] = entry
+		}
+		points := row.Points + row.BonusPoints
+		entry.Points += points
+		entry.Categories[row.Category] += points
+		if row.Penalty {
+			entry.Penalty -= points
+		} else {
+			entry.Solves++
+			entry.LastSolveAt = row.SubmittedAt
+		}
+		categorySet[row.Category] = true
+	}
+
+	ranked := rankEntries(entries)
+	names := lookupOwnerNames(ctx, s.db, entryIDs(ranked), s.cfg.Team.Enabled)
+	for i := range ranked {
+		ranked[i].Name = names[ranked[i].ID]
+	}
+
+	categories := make([]string, 0, len(categorySet))
+	for c := range categorySet {
+		categories = append(categories, c)
+	}
+	sort.Strings(categories)
+
+	return ranked, categories
+}
+
+// loadSolves 加载计分提交记录与提示解锁扣分（按时间升序），并根据封榜时间截断
+// eventID 为空时为全站积分榜，累计全部赛事与非赛事的得分（与玩家/队伍的 total_points 一致）
+// 返回：解题记录, 封榜时间, 当前视图是否封榜, 错误
+func (s *ScoreboardService) loadSolves(ctx context.Context, eventID string, unfrozen bool) ([]solveRow, *time.Time, bool, error) {
+	freezeAt, err := freezeTime(ctx, s.db, s.cfg, eventID)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	frozen := !unfrozen && freezeAt != nil && time.Now().After(*freezeAt)
+
+	query := s.db.WithContext(ctx).Table("submissions").
+		Select("submissions.user_id, submissions.team_id, submissions.challenge_id, challenges.category, submissions.points, submissions.bonus_points, submissions.submitted_at").
+		Joins("LEFT JOIN challenges ON challenges.id = submissions.challenge_id").
+		Where("submissions.scored = ?", true)
+	if eventID != "" {
+		query = query.Where("submissions.event_id = ?", eventID)
+	}
+	if frozen {
+		query = query.Where("submissions.submitted_at <= ?", *freezeAt)
+	}
+
+	var rows []solveRow
+	if err := query.Order("submissions.submitted_at ASC").Scan(&rows).Error; err != nil {
+		return nil, nil, false, fmt.Errorf("获取解题记录失败: %w", err)
+	}
+
+	penaltyQuery := s.db.WithContext(ctx).Table("hint_unlocks").
+		Select("hint_unlocks.user_id, hint_unlocks.team_id, hint_unlocks.challenge_id, challenges.category, -hint_unlocks.cost AS points, hint_unlocks.unlocked_at AS submitted_at, ? AS penalty", true).
+		Joins("LEFT JOIN challenges ON challenges.id = hint_unlocks.challenge_id").
+		Where("hint_unlocks.cost > 0")
+	if eventID != "" {
+		penaltyQuery = penaltyQuery.Where("hint_unlocks.event_id = ?", eventID)
+	}
+	if frozen {
+		penaltyQuery = penaltyQuery.Where("hint_unlocks.unlocked_at <= ?", *freezeAt)
+	}
+
+	var penalties []solveRow
+	if err := penaltyQuery.Scan(&penalties).Error; err != nil {
+		return nil, nil, false, fmt.Errorf("获取提示扣分记录失败: %w", err)
+	}
+	if len(penalties) > 0 {
+		rows = append(rows, penalties...)
+		sort.SliceStable(rows, func(i, j int) bool { return rows[i].SubmittedAt.Before(rows[j].SubmittedAt) })
+	}
+	return rows, freezeAt, frozen, nil
+}
+
+// freezeTime 获取封榜时间：赛事积分榜使用赛事配置，全站积分榜使用全局配置
+func freezeTime(ctx context.Context, db *gorm.DB, cfg *config.Config, eventID string) (*time.Time, error) {
+	if eventID == "" {
+		return cfg.Scoreboard.FreezeTime(), nil
+	}
+
+	var event model.Event
+	if err := db.WithContext(ctx).Select("id", "freeze_at").First(&event, "id = ?", eventID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("赛事不存在")
+		}
+		return nil, err
+	}
+	return event.FreezeAt, nil
+}
+
+// frozenCutoff 玩家视图的封榜截止时间：已过封榜时间时返回封榜时间，否则为 nil
+// 题目解出记录、队伍积分榜、玩家总分等公开数据均按此截断，避免泄露封榜后的解题
+func frozenCutoff(ctx context.Context, db *gorm.DB, cfg *config.Config, eventID string) (*time.Time, error) {
+	freezeAt, err := freezeTime(ctx, db, cfg, eventID)
+	if err != nil || freezeAt == nil || time.Now().Before(*freezeAt) {
+		return nil, err
+	}
+	return freezeAt, nil
+}
+
+// ownerOf 返回解题记录的排名主体（团队模式下为队伍）
+func (s *ScoreboardService) ownerOf(row solveRow) string {
+	if s.cfg.Team.Enabled {
+		return row.TeamID
+	}
+	return row.UserID
+}
+
+// cached 优先读取 Redis 缓存，未命中时计算并写入；Redis 不可用时直接计算
+func (s *ScoreboardService) cached(ctx context.Context, name string, out interface{}, build func() (interface{}, error)) error {
+	key, err := redisRepo.ScoreboardCacheKey(ctx, name)
+	if err == nil {
+		if data, ok, err := redisRepo.GetScoreboardCache(ctx, key); err == nil && ok {
+			if json.Unmarshal(data, out) == nil {
+				return nil
+			}
+		}
+	} else {
+		logger.Warn(ctx, "Scoreboard cache unavailable", "error", err)
+	}
+
+	value, err := build()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	if key != "" {
+		ttl := time.Duration(s.cfg.Scoreboard.CacheTTL) * time.Second
+		if ttl <= 0 {
+			ttl = defaultScoreboardCacheTTL
+		}
+		if err := redisRepo.SetScoreboardCache(ctx, key, data, ttl); err != nil {
+			logger.Warn(ctx, "Failed to cache scoreboard", "error", err)
+		}
+	}
+	return json.Unmarshal(data, out)
+}
+
+// rankEntries 按积分降序排名，同分时最后解题时间早者在前
+func rankEntries(entries map[string]*ScoreboardEntry) []ScoreboardEntry {
+	ranked := make([]ScoreboardEntry, 0, len(entries))
+	for _, entry := range entries {
+		ranked = append(ranked, *entry)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Points != ranked[j].Points {
+			return ranked[i].Points > ranked[j].Points
+		}
+		if !ranked[i].LastSolveAt.Equal(ranked[j].LastSolveAt) {
+			return ranked[i].LastSolveAt.Before(ranked[j].LastSolveAt)
+		}
+		return ranked[i].ID < ranked[j].ID
+	})
+	for i := range ranked {
+		ranked[i].Rank = i + 1
+	}
+	return ranked
+}
+
+func entryIDs(entries []ScoreboardEntry) []string {
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		ids[i] = e.ID
+	}
+	return ids
+}
+
+// lookupOwnerNames 批量查询用户名或队伍名
+func lookupOwnerNames(ctx context.Context, db *gorm.DB, ids []string, byTeam bool) map[string]string {
+	names := make(map[string]string, len(ids))
+	if len(ids) == 0 {
+		return names
+	}
+
+	if byTeam {
+		var teams []model.Team
+		db.WithContext(ctx).Select("id", "name").Where("id IN ?", ids).Find(&teams)
+		for _, t := range teams {
+			names[t.ID] = t.Name
+		}
+	} else {
+		var users []model.User
+		db.WithContext(ctx).Select("id", "username").Where("id IN ?", ids).Find(&users)
+		for _, u := range users {
+			names[u.ID] = u.Username
+		}
+	}
+	return names
+}
+
+// invalidateScoreboard 解题或分值变化后使积分榜缓存失效
+func invalidateScoreboard(ctx context.Context) {
+	if err := redisRepo.InvalidateScoreboardCache(ctx); err != nil {
+		logger.Warn(ctx, "Failed to invalidate scoreboard cache", "error", err)
+	}
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	redisRepo "cyber-range/internal/infra/redis"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/config"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupTestRedis 使用 miniredis 替换全局 Redis 客户端
+func setupTestRedis(t *testing.T) *miniredis.Miniredis {
+	mr := miniredis.RunT(t)
+	prev := redisRepo.Client
+	redisRepo.Client = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		redisRepo.Client.Close()
+		redisRepo.Client = prev
+	})
+	return mr
+}
+
+func setupScoreboardTest(t *testing.T) (*ScoreboardService, *gorm.DB) {
+	setupTestRedis(t)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("创建测试数据库失败: %v", err)
+	}
+	db.AutoMigrate(&model.User{}, &model.Team{}, &model.Challenge{}, &model.Submission{}, &model.Event{}, &model.HintUnlock{})
+
+	for _, id := range []string{"u1", "u2", "u3"} {
+		db.Create(&model.User{ID: id, Username: "name-" + id, Email: id + "@test.com", PasswordHash: "hash"})
+	}
+	db.Create(&model.Challenge{ID: "web1", Title: "web1", Category: "Web", Image: "x", Flag: "x", Points: 100})
+	db.Create(&model.Challenge{ID: "pwn1", Title: "pwn1", Category: "Pwn", Image: "x", Flag: "x", Points: 200})
+
+	return NewScoreboardService(db, &config.Config{}), db
+}
+
+func createSolve(db *gorm.DB, id, userID, challengeID string, points, bonus int, at time.Time) {
+	db.Create(&model.Submission{
+		ID: id, UserID: userID, ChallengeID: challengeID,
+		IsCorrect: true, Scored: true, Points: points, BonusPoints: bonus, SubmittedAt: at,
+	})
+}
+
+func TestScoreboardService_Ranking(t *testing.T) {
+	svc, db := setupScoreboardTest(t)
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour)
+
+	createSolve(db, "s1", "u1", "web1", 100, 50, base)
+	createSolve(db, "s2", "u2", "pwn1", 200, 0, base.Add(time.Minute))
+	createSolve(db, "s3", "u3", "web1", 100, 0, base.Add(2*time.Minute))
+	createSolve(db, "s4", "u1", "pwn1", 200, 0, base.Add(3*time.Minute))
+	// 未计分的重复提交不计入
+	db.Create(&model.Submission{ID: "s5", UserID: "u3", ChallengeID: "web1", IsCorrect: true, SubmittedAt: base.Add(4 * time.Minute)})
+
+	board, err := svc.GetScoreboard(ctx, "", false)
+	if err != nil {
+		t.Fatalf("GetScoreboard() error = %v", err)
+	}
+	if len(board.Entries) != 3 {
+		t.Fatalf("积分榜应有 3 名玩家, got %d", len(board.Entries))
+	}
+
+	first := board.Entries[0]
+	if first.ID != "u1" || first.Points != 350 || first.Solves != 2 || first.Name != "name-u1" {
+		t.Errorf("第一名应为 u1 (350分), got %+v", first)
+	}
+	if first.Categories["Web"] != 150 || first.Categories["Pwn"] != 200 {
+		t.Errorf("分类得分错误, got %v", first.Categories)
+	}
+	if board.Entries[1].ID != "u2" || board.Entries[2].ID != "u3" {
+		t.Errorf("排名错误, got %s, %s", board.Entries[1].ID, board.Entries[2].ID)
+	}
+	if len(board.Categories) != 2 || board.Categories[0] != "Pwn" {
+		t.Errorf("分类列表错误, got %v", board.Categories)
+	}
+
+	// 同分时先达到该分数者在前
+	createSolve(db, "s6", "u3", "pwn1", 100, 0, base.Add(5*time.Minute))
+	invalidateScoreboard(ctx)
+	board, _ = svc.GetScoreboard(ctx, "", false)
+	if board.Entries[1].ID != "u2" || board.Entries[2].ID != "u3" || board.Entries[2].Points != 200 {
+		t.Errorf("同分应按最后解题时间排序, got %+v", board.Entries)
+	}
+}
+
+func TestScoreboardService_Freeze(t *testing.T) {
+	svc, db := setupScoreboardTest(t)
+	ctx := context.Background()
+	freeze := time.Now().Add(-30 * time.Minute)
+	svc.cfg.Scoreboard.FreezeAt = freeze.Format(time.RFC3339)
+
+	createSolve(db, "s1", "u1", "web1", 100, 0, freeze.Add(-time.Minute))
+	createSolve(db, "s2", "u2", "pwn1", 200, 0, freeze.Add(time.Minute))
+
+	public, err := svc.GetScoreboard(ctx, "", false)
+	if err != nil {
+		t.Fatalf("GetScoreboard() error = %v", err)
+	}
+	if !public.Frozen || len(public.Entries) != 1 || public.Entries[0].ID != "u1" {
+		t.Errorf("封榜后玩家视图应隐藏封榜后的解题, got %+v", public)
+	}
+
+	admin, _ := svc.GetScoreboard(ctx, "", true)
+	if admin.Frozen || len(admin.Entries) != 2 || admin.Entries[0].ID != "u2" {
+		t.Errorf("管理员视图应显示全部解题, got %+v", admin)
+	}
+}
+
+func TestScoreboardService_CacheAndProgression(t *testing.T) {
+	svc, db := setupScoreboardTest(t)
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour)
+
+	createSolve(db, "s1", "u1", "web1", 100, 0, base)
+	board, _ := svc.GetScoreboard(ctx, "", false)
+	if len(board.Entries) != 1 {
+		t.Fatalf("积分榜应有 1 名玩家, got %d", len(board.Entries))
+	}
+
+	// 缓存未失效前读取的是旧数据
+	createSolve(db, "s2", "u1", "pwn1", 200, 0, base.Add(time.Minute))
+	createSolve(db, "s3", "u2", "web1", 100, 0, base.Add(2*time.Minute))
+	board, _ = svc.GetScoreboard(ctx, "", false)
+	if len(board.Entries) != 1 {
+		t.Errorf("应命中缓存, got %d 名玩家", len(board.Entries))
+	}
+
+	invalidateScoreboard(ctx)
+	board, _ = svc.GetScoreboard(ctx, "", false)
+	if len(board.Entries) != 2 {
+		t.Errorf("缓存失效后应重新统计, got %d 名玩家", len(board.Entries))
+	}
+
+	series, err := svc.GetProgression(ctx, "", 1, false)
+	if err != nil {
+		t.Fatalf("GetProgression() error = %v", err)
+	}
+	if len(series) != 1 || series[0].ID != "u1" {
+		t.Fatalf("应只返回第一名的曲线, got %+v", series)
+	}
+	points := series[0].Points
+	if len(points) != 2 || points[0].Score != 100 || points[1].Score != 300 {
+		t.Errorf("累计得分曲线错误, got %+v", points)
+	}
+}
+
+func TestScoreboardService_EventScoreboard(t *testing.T) {
+	svc, db := setupScoreboardTest(t)
+	ctx := context.Background()
+	now := time.Now()
+	db.Create(&model.Event{ID: "e1", Name: "e1", StartAt: now.Add(-time.Hour), EndAt: now.Add(time.Hour)})
+
+	subs := []model.Submission{
+		{ID: "s1", UserID: "u1", EventID: "e1", ChallengeID: "web1", IsCorrect: true, Scored: true, Points: 100, SubmittedAt: now.Add(-30 * time.Minute)},
+		{ID: "s2", UserID: "u2", EventID: "e1", ChallengeID: "web1", IsCorrect: true, Scored: true, Points: 100, SubmittedAt: now.Add(-40 * time.Minute)},
+		{ID: "s3", UserID: "u1", EventID: "e1", ChallengeID: "web1", IsCorrect: true, SubmittedAt: now.Add(-20 * time.Minute)}, // 重复解题不计分
+		{ID: "s4", UserID: "u1", EventID: "e1", ChallengeID: "pwn1", IsCorrect: false, SubmittedAt: now.Add(-10 * time.Minute)},
+		{ID: "s5", UserID: "u1", ChallengeID: "pwn1", IsCorrect: true, Scored: true, Points: 200, SubmittedAt: now}, // 非赛事提交不计入
+	}
+	for i := range subs {
+		db.Create(&subs[i])
+	}
+
+	board, err := svc.GetScoreboard(ctx, "e1", false)
+	if err != nil {
+		t.Fatalf("GetScoreboard() error = %v", err)
+	}
+	if len(board.Entries) != 2 {
+		t.Fatalf("积分榜应有 2 名玩家, got %d", len(board.Entries))
+	}
+	// 同分时先达到该分数者排名靠前
+	if board.Entries[0].ID != "u2" || board.Entries[0].Rank != 1 || board.Entries[0].Name != "name-u2" {
+		t.Errorf("第一名应为 u2, got %+v", board.Entries[0])
+	}
+	if board.Entries[1].Points != 100 || board.Entries[1].Solves != 1 {
+		t.Errorf("u1 应为 100 分 1 题, got %+v", board.Entries[1])
+	}
+
+	if _, err := svc.GetScoreboard(ctx, "missing", false); err == nil {
+		t.Error("赛事不存在时应返回错误")
+	}
+}
+
+func TestScoreboardService_FreezeTotals(t *testing.T) {
+	svc, db := setupScoreboardTest(t)
+	ctx := context.Background()
+	freeze := time.Now().Add(-30 * time.Minute)
+
+	db.Create(&model.Team{ID: "t1", Name: "t1", InviteCode: "c1", CaptainID: "u1", TotalPoints: 100, CreatedAt: freeze.Add(-time.Hour)})
+	db.Create(&model.Team{ID: "t2", Name: "t2", InviteCode: "c2", CaptainID: "u2", TotalPoints: 250, CreatedAt: freeze.Add(-time.Hour).Add(time.Second)})
+	db.Create(&model.Submission{ID: "s1", UserID: "u1", TeamID: "t1", ChallengeID: "web1", IsCorrect: true, Scored: true, Points: 100, SubmittedAt: freeze.Add(-time.Minute)})
+	db.Create(&model.Submission{ID: "s2", UserID: "u2", TeamID: "t2", ChallengeID: "web1", IsCorrect: true, Scored: true, Points: 50, SubmittedAt: freeze.Add(-time.Minute)})
+	db.Create(&model.Submission{ID: "s3", UserID: "u2", TeamID: "t2", ChallengeID: "pwn1", IsCorrect: true, Scored: true, Points: 200, SubmittedAt: freeze.Add(time.Minute)})
+
+	// 未封榜时返回实时积分
+	teams, err := svc.ListTeamScoreboard(ctx)
+	if err != nil {
+		t.Fatalf("ListTeamScoreboard() error = %v", err)
+	}
+	if teams[0].ID != "t2" || teams[0].TotalPoints != 250 {
+		t.Errorf("未封榜时应按实时积分排名, got %+v", teams)
+	}
+
+	svc.cfg.Scoreboard.FreezeAt = freeze.Format(time.RFC3339)
+	teams, _ = svc.ListTeamScoreboard(ctx)
+	if teams[0].ID != "t1" || teams[0].TotalPoints != 100 || teams[1].TotalPoints != 50 {
+		t.Errorf("封榜后应隐藏封榜后的解题, got %+v", teams)
+	}
+
+	user := &model.User{ID: "u2", TotalPoints: 250}
+	if err := svc.ApplyFreeze(ctx, user); err != nil {
+		t.Fatalf("ApplyFreeze() error = %v", err)
+	}
+	if user.TotalPoints != 50 {
+		t.Errorf("封榜后玩家总分应为 50, got %d", user.TotalPoints)
+	}
+
+	// 封榜总分走积分榜缓存，计分变化使缓存失效后重新统计
+	db.Create(&model.Submission{ID: "s4", UserID: "u2", TeamID: "t2", ChallengeID: "misc1", IsCorrect: true, Scored: true, Points: 30, SubmittedAt: freeze.Add(-2 * time.Minute)})
+	if user.TotalPoints = 0; svc.ApplyFreeze(ctx, user) != nil || user.TotalPoints != 50 {
+		t.Errorf("缓存有效期内应返回缓存的封榜总分, got %d", user.TotalPoints)
+	}
+	invalidateScoreboard(ctx)
+	if svc.ApplyFreeze(ctx, user); user.TotalPoints != 80 {
+		t.Errorf("缓存失效后应重新统计封榜总分, got %d", user.TotalPoints)
+	}
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"cyber-range/internal/model"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// dynamicPoints 计算动态计分题目在 solveCount 人解出后的当前分值（CTFd 算法）
+// 第一位解出者获得初始分值，之后随解出人数衰减，不低于最低分值
+//   - linear:      initial - decay * (solveCount - 1)
+//   - logarithmic: initial + (minimum - initial) / decay² * (solveCount - 1)²，decay 为降到最低分所需的解出次数
+func dynamicPoints(c *model.Challenge, solveCount int) int {
+	initial := c.InitialPoints
+	if initial <= 0 {
+		initial = c.Points
+	}
+	minimum := c.MinimumPoints
+
+	n := solveCount - 1
+	if n < 0 {
+		n = 0
+	}
+
+	var value float64
+	switch c.DecayFunction {
+	case "logarithmic":
+		decay := c.Decay
+		if decay <= 0 {
+			decay = 1
+		}
+		value = float64(minimum-initial)/float64(decay*decay)*float64(n*n) + float64(initial)
+		value = math.Ceil(value)
+	default: // linear
+		value = float64(initial - c.Decay*n)
+	}
+
+	if int(value) < minimum {
+		return minimum
+	}
+	return int(value)
+}
+
+// countSolves 统计题目的解出数（计分提交数，每个用户/队伍在每个赛事中最多计一次）
+func (s *ChallengeService) countSolves(tx *gorm.DB, challengeID string) (int64, error) {
+	var count int64
+	err := tx.Model(&model.Submission{}).
+		Where("challenge_id = ? AND scored = ?", challengeID, true).
+		Count(&count).Error
+	return count, err
+}
+
+// solveKey 生成解题唯一键：同一赛事内同一用户（团队模式下为队伍）对同一题目只计分一次
+func solveKey(eventID, challengeID, ownerID string) string {
+	return fmt.Sprintf("%s:%s:%s", eventID, challengeID, ownerID)
+}
+
+// scoreSolve 记录一次正确提交（需在事务中调用）
+// 首次解出计分并记录一/二/三血；重复解出仅记录，不计分
+func (s *ChallengeService) scoreSolve(tx *gorm.DB, challenge *model.Challenge, submission *model.Submission) error {
+	// 锁定题目行，串行化同一题目的计分（保证去重与血次序正确）
+	if err := tx.Model(&model.Challenge{}).Where("id = ?", challenge.ID).
+		Update("solve_count", gorm.Expr("solve_count")).Error; err != nil {
+		return err
+	}
+
+	ownerID := submission.UserID
+	if submission.TeamID != "" {
+		ownerID = submission.TeamID
+	}
+	key := solveKey(submission.EventID, challenge.ID, ownerID)
+
+	var existing int64
+	if err := tx.Model(&model.Submission{}).Where("solve_key = ?", key).Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		submission.Points = 0
+		return tx.Create(submission).Error
+	}
+
+	// 血次序按赛事范围计算
+	var solvedBefore int64
+	if err := tx.Model(&model.Submission{}).
+		Where("challenge_id = ? AND event_id = ? AND scored = ?", challenge.ID, submission.EventID, true).
+		Count(&solvedBefore).Error; err != nil {
+		return err
+	}
+	if solvedBefore < 3 {
+		submission.BloodRank = int(solvedBefore) + 1
+		if bonus := s.cfg.Scoring.BloodBonus; len(bonus) >= submission.BloodRank {
+			submission.BonusPoints = bonus[submission.BloodRank-1]
+		}
+	}
+
+	submission.Scored = true
+	submission.SolveKey = &key
+	submission.Points = challenge.Points
+	if err := tx.Create(submission).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&model.Challenge{}).Where("id = ?", challenge.ID).
+		Update("solve_count", gorm.Expr("solve_count + 1")).Error; err != nil {
+		return err
+	}
+
+	// 动态计分：按新的解出数重算分值，并追溯更新所有解题者积分
+	if challenge.IsDynamic() {
+		points, err := s.recalculateDynamicScore(tx, challenge)
+		submission.Points = points
+		return err
+	}
+
+	awarded := submission.Points + submission.BonusPoints
+	if err := tx.Model(&model.User{}).Where("id = ?", submission.UserID).
+		Update("total_points", gorm.Expr("total_points + ?", awarded)).Error; err != nil {
+		return err
+	}
+	// 团队模式下积分同时计入队伍
+	if submission.TeamID != "" {
+		return tx.Model(&model.Team{}).Where("id = ?", submission.TeamID).
+			Update("total_points", gorm.Expr("total_points + ?", awarded)).Error
+	}
+	return nil
+}
+
+// recalculateDynamicScore 按当前解出数重算动态题目分值，并追溯更新所有解题记录与相关用户/队伍总分
+// 需在事务中调用，返回重算后的分值
+func (s *ChallengeService) recalculateDynamicScore(tx *gorm.DB, challenge *model.Challenge) (int, error) {
+	solves, err := s.countSolves(tx, challenge.ID)
+	if err != nil {
+		return 0, fmt.Errorf("统计解出数失败: %w", err)
+	}
+	value := dynamicPoints(challenge, int(solves))
+
+	if err := tx.Model(&model.Challenge{}).Where("id = ?", challenge.ID).Update("points", value).Error; err != nil {
+		return 0, err
+	}
+	challenge.Points = value
+
+	if err := tx.Model(&model.Submission{}).
+		Where("challenge_id = ? AND scored = ?", challenge.ID, true).
+		Update("points", value).Error; err != nil {
+		return 0, err
+	}
+
+	var userIDs, teamIDs []string
+	tx.Model(&model.Submission{}).Where("challenge_id = ? AND scored = ?", challenge.ID, true).
+		Distinct().Pluck("user_id", &userIDs)
+	tx.Model(&model.Submission{}).Where("challenge_id = ? AND scored = ? AND team_id <> ''", challenge.ID, true).
+		Distinct().Pluck("team_id", &teamIDs)
+
+	if err := recalculateTotals(tx, userIDs, teamIDs); err != nil {
+		return 0, err
+	}
+	return value, nil
+}
+
+// recalculateTotals 以计分提交记录（分值 + 血奖励）减去提示解锁扣分为准重算用户与队伍总分
+func recalculateTotals(tx *gorm.DB, userIDs, teamIDs []string) error {
+	if len(userIDs) > 0 {
+		if err := tx.Model(&model.User{}).Where("id IN ?", userIDs).
+			Update("total_points", gorm.Expr(
+				"(SELECT COALESCE(SUM(points + bonus_points), 0) FROM submissions WHERE submissions.user_id = users.id AND submissions.scored = ?)"+
+					" - (SELECT COALESCE(SUM(cost), 0) FROM hint_unlocks WHERE hint_unlocks.user_id = users.id)", true,
+			)).Error; err != nil {
+			return fmt.Errorf("重算用户积分失败: %w", err)
+		}
+	}
+	if len(teamIDs) > 0 {
+		if err := tx.Model(&model.Team{}).Where("id IN ?", teamIDs).
+			Update("total_points", gorm.Expr(
+				"(SELECT COALESCE(SUM(points + bonus_points), 0) FROM submissions WHERE submissions.team_id = teams.id AND submissions.scored = ?)"+
+					" - (SELECT COALESCE(SUM(cost), 0) FROM hint_unlocks WHERE hint_unlocks.team_id = teams.id)", true,
+			)).Error; err != nil {
+			return fmt.Errorf("重算队伍积分失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// RecalculateChallengeScore 重算题目分值（管理员修改计分参数后调用）
+// 静态题目不做处理
+func (s *ChallengeService) RecalculateChallengeScore(ctx context.Context, challengeID string) error {
+	challenge, err := s.GetChallenge(ctx, challengeID)
+	if err != nil {
+		return err
+	}
+	if !challenge.IsDynamic() {
+		return nil
+	}
+
+	err = s.gormDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		_, err := s.recalculateDynamicScore(tx, challenge)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateScoreboard(ctx)
+	return nil
+}
+
+// isDuplicateKeyError 是否为唯一索引冲突（并发请求先写入了相同的唯一键）
+func isDuplicateKeyError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "Duplicate entry") || strings.Contains(msg, "UNIQUE constraint failed")
+}
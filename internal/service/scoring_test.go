@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"cyber-range/internal/model"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TestDynamicPoints 测试动态计分衰减公式
+func TestDynamicPoints(t *testing.T) {
+	linear := &model.Challenge{ScoringMode: "dynamic", InitialPoints: 500, MinimumPoints: 100, Decay: 50, DecayFunction: "linear"}
+	logarithmic := &model.Challenge{ScoringMode: "dynamic", InitialPoints: 500, MinimumPoints: 100, Decay: 10, DecayFunction: "logarithmic"}
+
+	tests := []struct {
+		name       string
+		challenge  *model.Challenge
+		solveCount int
+		want       int
+	}{
+		{"linear 无人解出", linear, 0, 500},
+		{"linear 首位解出", linear, 1, 500},
+		{"linear 第二位解出", linear, 2, 450},
+		{"linear 不低于最低分", linear, 100, 100},
+		{"logarithmic 首位解出", logarithmic, 1, 500},
+		{"logarithmic 第六位解出", logarithmic, 6, 400},
+		{"logarithmic 达到衰减次数", logarithmic, 11, 100},
+		{"logarithmic 超过衰减次数", logarithmic, 50, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dynamicPoints(tt.challenge, tt.solveCount); got != tt.want {
+				t.Errorf("dynamicPoints() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRecalculateDynamicScore 测试解出人数增加后追溯重算所有解题者积分
+func TestRecalculateDynamicScore(t *testing.T) {
+	svc, testDB := setupTestService(t)
+
+	testDB.Model(&model.Challenge{}).Where("id = ?", "test-challenge-1").Updates(map[string]interface{}{
+		"scoring_mode":   "dynamic",
+		"initial_points": 500,
+		"minimum_points": 100,
+		"decay":          100,
+		"decay_function": "linear",
+	})
+	testDB.Create(&model.User{ID: "test-user-2", Username: "testuser2", Email: "test2@test.com", PasswordHash: "hash"})
+
+	now := time.Now()
+	testDB.Create(&model.Submission{ID: "s1", UserID: "test-user-1", ChallengeID: "test-challenge-1", IsCorrect: true, Scored: true, Points: 500, SubmittedAt: now})
+	testDB.Create(&model.Submission{ID: "s2", UserID: "test-user-1", ChallengeID: "test-challenge-1", IsCorrect: false, SubmittedAt: now})
+	testDB.Create(&model.Submission{ID: "s3", UserID: "test-user-2", ChallengeID: "test-challenge-1", IsCorrect: true, Scored: true, Points: 500, SubmittedAt: now})
+
+	challenge, _ := svc.GetChallenge(context.Background(), "test-challenge-1")
+	value, err := svc.recalculateDynamicScore(testDB, challenge)
+	if err != nil {
+		t.Fatalf("recalculateDynamicScore() error = %v", err)
+	}
+	if value != 400 {
+		t.Errorf("两人解出后分值应为 400, got %d", value)
+	}
+
+	var updated model.Challenge
+	testDB.First(&updated, "id = ?", "test-challenge-1")
+	if updated.Points != 400 {
+		t.Errorf("题目当前分值应为 400, got %d", updated.Points)
+	}
+
+	for _, id := range []string{"test-user-1", "test-user-2"} {
+		var user model.User
+		testDB.First(&user, "id = ?", id)
+		if user.TotalPoints != 400 {
+			t.Errorf("用户 %s 总分应追溯为 400, got %d", id, user.TotalPoints)
+		}
+	}
+
+	var wrong model.Submission
+	testDB.First(&wrong, "id = ?", "s2")
+	if wrong.Points != 0 {
+		t.Errorf("错误提交不应获得积分, got %d", wrong.Points)
+	}
+}
+
+// TestScoreSolve 测试重复解题不计分以及一/二/三血奖励
+func TestScoreSolve(t *testing.T) {
+	svc, testDB := setupTestService(t)
+	svc.cfg.Scoring.BloodBonus = []int{50, 30, 10}
+
+	for _, id := range []string{"test-user-2", "test-user-3", "test-user-4"} {
+		testDB.Create(&model.User{ID: id, Username: id, Email: id + "@test.com", PasswordHash: "hash"})
+	}
+
+	challenge, _ := svc.GetChallenge(context.Background(), "test-challenge-1")
+	solve := func(id, userID string) *model.Submission {
+		sub := &model.Submission{ID: id, UserID: userID, ChallengeID: challenge.ID, IsCorrect: true, SubmittedAt: time.Now()}
+		if err := svc.scoreSolve(testDB, challenge, sub); err != nil {
+			t.Fatalf("scoreSolve() error = %v", err)
+		}
+		return sub
+	}
+
+	first := solve("s1", "test-user-1")
+	if !first.Scored || first.BloodRank != 1 || first.BonusPoints != 50 || first.Points != 100 {
+		t.Errorf("首次解出应为一血并获得奖励, got %+v", first)
+	}
+
+	// 重启实例后再次提交正确 Flag，不重复计分
+	again := solve("s2", "test-user-1")
+	if again.Scored || again.Points != 0 || again.BloodRank != 0 {
+		t.Errorf("重复解出不应计分, got %+v", again)
+	}
+
+	second := solve("s3", "test-user-2")
+	third := solve("s4", "test-user-3")
+	fourth := solve("s5", "test-user-4")
+	if second.BloodRank != 2 || second.BonusPoints != 30 {
+		t.Errorf("应为二血, got %+v", second)
+	}
+	if third.BloodRank != 3 || third.BonusPoints != 10 {
+		t.Errorf("应为三血, got %+v", third)
+	}
+	if fourth.BloodRank != 0 || fourth.BonusPoints != 0 || !fourth.Scored {
+		t.Errorf("第四位解出应计分但无血奖励, got %+v", fourth)
+	}
+
+	var user model.User
+	testDB.First(&user, "id = ?", "test-user-1")
+	if user.TotalPoints != 150 {
+		t.Errorf("一血用户总分应为 150, got %d", user.TotalPoints)
+	}
+
+	var updated model.Challenge
+	testDB.First(&updated, "id = ?", challenge.ID)
+	if updated.SolveCount != 4 {
+		t.Errorf("解出次数应为 4, got %d", updated.SolveCount)
+	}
+}
+
+// TestScoreSolve_CountFailure 测试统计已有解题失败时返回错误，不按首次解出计分
+func TestScoreSolve_CountFailure(t *testing.T) {
+	svc, testDB := setupTestService(t)
+	svc.cfg.Scoring.BloodBonus = []int{50, 30, 10}
+	challenge, _ := svc.GetChallenge(context.Background(), "test-challenge-1")
+
+	testDB.Callback().Query().Before("gorm:query").Register("test:fail_solve_count", func(tx *gorm.DB) {
+		if _, ok := tx.Statement.Dest.(*int64); ok {
+			tx.AddError(errors.New("模拟的数据库查询失败"))
+		}
+	})
+	defer testDB.Callback().Query().Remove("test:fail_solve_count")
+
+	sub := &model.Submission{ID: "s1", UserID: "test-user-1", ChallengeID: challenge.ID, IsCorrect: true, SubmittedAt: time.Now()}
+	if err := svc.scoreSolve(testDB, challenge, sub); err == nil {
+		t.Fatal("统计解题记录失败时应返回错误")
+	}
+	if sub.Scored || sub.BloodRank != 0 {
+		t.Errorf("统计失败时不应计分或授予血奖励, got %+v", sub)
+	}
+}
+
+// TestScoreSolve_AcrossEvents 测试赛事内单独计分：同一题目在不同赛事中各计一次，
+// 全站积分榜与玩家总分累计全部赛事（及非赛事）的得分
+func TestScoreSolve_AcrossEvents(t *testing.T) {
+	setupTestRedis(t)
+	svc, testDB := setupTestService(t)
+	testDB.AutoMigrate(&model.Event{})
+	now := time.Now()
+	for _, id := range []string{"training", "ctf"} {
+		testDB.Create(&model.Event{ID: id, Name: id, StartAt: now.Add(-time.Hour), EndAt: now.Add(time.Hour)})
+	}
+
+	challenge, _ := svc.GetChallenge(context.Background(), "test-challenge-1")
+	for i, eventID := range []string{"training", "ctf", "ctf"} {
+		sub := &model.Submission{ID: fmt.Sprintf("s%d", i), UserID: "test-user-1", EventID: eventID, ChallengeID: challenge.ID, IsCorrect: true, SubmittedAt: now}
+		if err := svc.scoreSolve(testDB, challenge, sub); err != nil {
+			t.Fatalf("scoreSolve() error = %v", err)
+		}
+		if wantScored := i < 2; sub.Scored != wantScored {
+			t.Errorf("赛事 %s 第 %d 次解出 scored = %v, want %v", eventID, i+1, sub.Scored, wantScored)
+		}
+	}
+
+	var user model.User
+	testDB.First(&user, "id = ?", "test-user-1")
+	if user.TotalPoints != 200 {
+		t.Errorf("玩家总分应累计两个赛事的得分, got %d", user.TotalPoints)
+	}
+
+	scoreboard := NewScoreboardService(testDB, svc.cfg)
+	for eventID, want := range map[string]int{"": 200, "training": 100, "ctf": 100} {
+		board, err := scoreboard.GetScoreboard(context.Background(), eventID, false)
+		if err != nil {
+			t.Fatalf("GetScoreboard(%q) error = %v", eventID, err)
+		}
+		if len(board.Entries) != 1 || board.Entries[0].Points != want {
+			t.Errorf("积分榜 %q 应为 %d 分, got %+v", eventID, want, board.Entries)
+		}
+	}
+}
+
+// TestListSolves_Visibility 测试解出记录与题目列表使用相同的可见性规则
+func TestListSolves_Visibility(t *testing.T) {
+	svc, testDB := setupTestService(t)
+	testDB.AutoMigrate(&model.Team{}, &model.Event{}, &model.EventChallenge{}, &model.EventParticipant{})
+	ctx := context.Background()
+
+	challenge, _ := svc.GetChallenge(ctx, "test-challenge-1")
+	if err := svc.scoreSolve(testDB, challenge, &model.Submission{ID: "s1", UserID: "test-user-1", ChallengeID: challenge.ID, IsCorrect: true, SubmittedAt: time.Now()}); err != nil {
+		t.Fatalf("scoreSolve() error = %v", err)
+	}
+
+	if _, err := svc.ListSolves(ctx, "test-user-1", challenge.ID, ""); err == nil {
+		t.Error("未发布的题目不应返回解出记录")
+	}
+	testDB.Create(&model.Event{ID: "e1", Name: "e1", Visibility: "invite_only", StartAt: time.Now().Add(-time.Hour), EndAt: time.Now().Add(time.Hour)})
+	testDB.Create(&model.EventChallenge{EventID: "e1", ChallengeID: challenge.ID})
+	if _, err := svc.ListSolves(ctx, "test-user-1", challenge.ID, "e1"); err == nil {
+		t.Error("未报名赛事时不应返回赛事内的解出记录")
+	}
+
+	testDB.Model(&model.Challenge{}).Where("id = ?", challenge.ID).Update("status", "published")
+	solves, err := svc.ListSolves(ctx, "test-user-1", challenge.ID, "")
+	if err != nil {
+		t.Fatalf("ListSolves() error = %v", err)
+	}
+	if len(solves) != 1 || solves[0].UserID != "test-user-1" || solves[0].BloodRank != 1 {
+		t.Errorf("解出记录 = %+v", solves)
+	}
+	// 封榜后不返回封榜时间之后的解出记录
+	svc.cfg.Scoreboard.FreezeAt = time.Now().Add(-time.Hour).Format(time.RFC3339)
+	if solves, _ := svc.ListSolves(ctx, "test-user-1", challenge.ID, ""); len(solves) != 0 {
+		t.Errorf("封榜后的解出记录应隐藏, got %+v", solves)
+	}
+}
+
+// TestIsDuplicateKeyError 测试并发写入相同解题唯一键时识别为重复解出
+func TestIsDuplicateKeyError(t *testing.T) {
+	_, testDB := setupTestService(t)
+
+	key := solveKey("", "test-challenge-1", "test-user-1")
+	if err := testDB.Create(&model.Submission{ID: "s1", UserID: "test-user-1", ChallengeID: "test-challenge-1", Scored: true, SolveKey: &key}).Error; err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	err := testDB.Create(&model.Submission{ID: "s2", UserID: "test-user-1", ChallengeID: "test-challenge-1", Scored: true, SolveKey: &key}).Error
+	if !isDuplicateKeyError(err) {
+		t.Errorf("相同解题唯一键应识别为唯一索引冲突, got %v", err)
+	}
+	if isDuplicateKeyError(errors.New("connection refused")) {
+		t.Error("其他错误不应识别为唯一索引冲突")
+	}
+}
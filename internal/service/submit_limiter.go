@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	redisRepo "cyber-range/internal/infra/redis"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/config"
+	"cyber-range/pkg/logger"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RateLimitError 提交过于频繁
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("提交过于频繁，请 %d 秒后再试", e.RetryAfterSeconds())
+}
+
+// RetryAfterSeconds 需等待的秒数（向上取整）
+func (e *RateLimitError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// SubmitLimiter Flag 提交限流器（Redis 滑动窗口 + 违规升级冷却）
+type SubmitLimiter struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+// NewSubmitLimiter 创建提交限流器
+func NewSubmitLimiter(db *gorm.DB, cfg *config.Config) *SubmitLimiter {
+	return &SubmitLimiter{db: db, cfg: cfg}
+}
+
+// ThrottledView 管理端限流名单条目
+type ThrottledView struct {
+	Kind           string    `json:"kind"` // user / ip
+	ID             string    `json:"id"`
+	Username       string    `json:"username,omitempty"`
+	ChallengeID    string    `json:"challenge_id"`
+	ChallengeTitle string    `json:"challenge_title,omitempty"`
+	Offenses       int       `json:"offenses"`
+	Until          time.Time `json:"until"`
+}
+
+// Allow 检查用户对题目的提交是否被限流，被限流时返回 *RateLimitError
+// Redis 不可用时放行，避免影响正常答题
+func (l *SubmitLimiter) Allow(ctx context.Context, userID, ip, challengeID string) error {
+	rl := l.cfg.RateLimit
+	if !rl.Enabled || rl.Window <= 0 {
+		return nil
+	}
+
+	subjects := []redisRepo.RateLimitSubject{
+		{Kind: "user", ID: userID, ChallengeID: challengeID, Limit: rl.UserLimit},
+	}
+	if ip != "" {
+		subjects = append(subjects, redisRepo.RateLimitSubject{Kind: "ip", ID: ip, ChallengeID: challengeID, Limit: rl.IPLimit})
+	}
+
+	cooldowns := make([]time.Duration, 0, len(rl.Cooldowns))
+	for _, sec := range rl.Cooldowns {
+		cooldowns = append(cooldowns, time.Duration(sec)*time.Second)
+	}
+	offenseTTL := time.Duration(rl.OffenseTTL) * time.Second
+	if offenseTTL <= 0 {
+		offenseTTL = time.Hour
+	}
+
+	blocked, retryAfter, err := redisRepo.CheckSubmitRateLimit(ctx, subjects, time.Duration(rl.Window)*time.Second, cooldowns, offenseTTL)
+	if err != nil {
+		logger.Warn(ctx, "Submit rate limit check failed", "error", err)
+		return nil
+	}
+	if blocked != nil {
+		logger.Warn(ctx, "Flag submission throttled",
+			"kind", blocked.Kind, "id", blocked.ID, "challenge_id", challengeID, "retry_after", retryAfter.String())
+		return &RateLimitError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// ListThrottled 获取当前被限流的用户与IP
+func (l *SubmitLimiter) ListThrottled(ctx context.Context) ([]ThrottledView, error) {
+	subjects, err := redisRepo.ListThrottled(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取限流名单失败: %w", err)
+	}
+
+	views := make([]ThrottledView, 0, len(subjects))
+	for _, s := range subjects {
+		view := ThrottledView{
+			Kind:        s.Kind,
+			ID:          s.ID,
+			ChallengeID: s.ChallengeID,
+			Offenses:    s.Offenses,
+			Until:       s.Until,
+		}
+		if s.Kind == "user" {
+			var user model.User
+			if l.db.WithContext(ctx).Select("id", "username").First(&user, "id = ?", s.ID).Error == nil {
+				view.Username = user.Username
+			}
+		}
+		var challenge model.Challenge
+		if l.db.WithContext(ctx).Select("id", "title").First(&challenge, "id = ?", s.ChallengeID).Error == nil {
+			view.ChallengeTitle = challenge.Title
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// ClearThrottle 管理员解除限流
+func (l *SubmitLimiter) ClearThrottle(ctx context.Context, kind, id, challengeID string) error {
+	if kind != "user" && kind != "ip" {
+		return fmt.Errorf("kind 必须是 user 或 ip")
+	}
+	return redisRepo.ClearThrottle(ctx, kind, id, challengeID)
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/config"
+	"cyber-range/pkg/logger"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestSubmitLimiter(t *testing.T) {
+	logger.InitLogger("dev")
+	mr := setupTestRedis(t)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("创建测试数据库失败: %v", err)
+	}
+	db.AutoMigrate(&model.User{}, &model.Challenge{})
+	db.Create(&model.User{ID: "u1", Username: "alice", Email: "u1@test.com", PasswordHash: "hash"})
+	db.Create(&model.Challenge{ID: "c1", Title: "web1", Image: "x", Flag: "x", Points: 100})
+
+	limiter := NewSubmitLimiter(db, &config.Config{RateLimit: config.RateLimitConfig{
+		Enabled:    true,
+		UserLimit:  2,
+		IPLimit:    10,
+		Window:     60,
+		Cooldowns:  []int{60, 300},
+		OffenseTTL: 3600,
+	}})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := limiter.Allow(ctx, "u1", "10.0.0.1", "c1"); err != nil {
+			t.Fatalf("第 %d 次提交不应被限流: %v", i+1, err)
+		}
+	}
+
+	// 超出窗口限制，进入第一级冷却
+	err = limiter.Allow(ctx, "u1", "10.0.0.1", "c1")
+	rlErr, ok := err.(*RateLimitError)
+	if !ok || rlErr.RetryAfterSeconds() != 60 {
+		t.Fatalf("第 3 次提交应被限流 60 秒, got %v", err)
+	}
+
+	// 其他题目不受影响
+	if err := limiter.Allow(ctx, "u1", "10.0.0.1", "c2"); err != nil {
+		t.Errorf("限流应按题目分别计数, got %v", err)
+	}
+
+	// 冷却结束后恢复，再次触发时冷却升级
+	mr.FastForward(61 * time.Second)
+	for i := 0; i < 2; i++ {
+		if err := limiter.Allow(ctx, "u1", "10.0.0.1", "c1"); err != nil {
+			t.Fatalf("冷却结束后提交不应被限流: %v", err)
+		}
+	}
+	err = limiter.Allow(ctx, "u1", "10.0.0.1", "c1")
+	if rlErr, ok := err.(*RateLimitError); !ok || rlErr.RetryAfterSeconds() != 300 {
+		t.Fatalf("再次违规应升级为 300 秒冷却, got %v", err)
+	}
+
+	list, err := limiter.ListThrottled(ctx)
+	if err != nil {
+		t.Fatalf("ListThrottled() error = %v", err)
+	}
+	if len(list) != 1 || list[0].Kind != "user" || list[0].Username != "alice" ||
+		list[0].ChallengeTitle != "web1" || list[0].Offenses != 2 {
+		t.Errorf("限流名单错误, got %+v", list)
+	}
+
+	if err := limiter.ClearThrottle(ctx, "team", "u1", "c1"); err == nil {
+		t.Error("非法 kind 应返回错误")
+	}
+	if err := limiter.ClearThrottle(ctx, "user", "u1", "c1"); err != nil {
+		t.Fatalf("ClearThrottle() error = %v", err)
+	}
+	if err := limiter.Allow(ctx, "u1", "10.0.0.1", "c1"); err != nil {
+		t.Errorf("解除限流后应可提交, got %v", err)
+	}
+	if list, _ := limiter.ListThrottled(ctx); len(list) != 0 {
+		t.Errorf("解除限流后名单应为空, got %+v", list)
+	}
+}
@@ -0,0 +1,297 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"cyber-range/internal/infra/db"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/config"
+	"cyber-range/pkg/logger"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultTCPIdleTimeout = 5 * time.Minute  // 默认连接空闲超时
+	tcpHandshakeTimeout   = 10 * time.Second // 等待首行令牌或 TLS 握手的最长时间
+	tcpTokenLineSize      = 1024             // 首行令牌的最大长度
+	tlsRecordHandshake    = 0x16             // TLS 握手记录类型（ClientHello 的首字节）
+)
+
+// TCPGateway nc 类题目的 TCP/TLS 网关，所有实例共用一个公网端口
+// 连接按首行发送的实例令牌路由；TLS 连接在网关终止 TLS，SNI（<instance-id>.<domain>）须与令牌指向的实例一致，
+// 之后双向透传到实例所在 Docker 主机的映射端口。路由信息取自 Redis 中的实例数据
+type TCPGateway struct {
+	cfg       config.TCPGatewayConfig
+	resolver  *gatewayResolver
+	tlsConfig *tls.Config
+	listener  net.Listener
+	wg        sync.WaitGroup
+
+	mu     sync.Mutex
+	conns  map[string]int        // 每个实例的活动连接数
+	active map[net.Conn]struct{} // 活动的客户端连接（停止时关闭）
+	closed bool
+}
+
+// NewTCPGateway 创建 TCP 网关，配置了证书与根域名时启用 TLS SNI 路由
+func NewTCPGateway(cfg config.TCPGatewayConfig, repo *db.Repository) (*TCPGateway, error) {
+	g := &TCPGateway{
+		cfg:      cfg,
+		resolver: newGatewayResolver(repo),
+		conns:    make(map[string]int),
+		active:   make(map[net.Conn]struct{}),
+	}
+	if cfg.TLSEnabled() {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("加载 TCP 网关证书失败: %w", err)
+		}
+		g.tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+	return g, nil
+}
+
+// Start 监听网关端口并在后台接受连接
+func (g *TCPGateway) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", g.cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("TCP 网关监听失败: %w", err)
+	}
+	g.listener = listener
+	logger.Info(ctx, "TCP gateway started", "listen", listener.Addr().String(), "tls", g.tlsConfig != nil,
+		"max_conns_per_instance", g.cfg.MaxConnsPerInstance, "idle_timeout", g.idleTimeout())
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					logger.Error(ctx, "TCP gateway accept failed", "error", err)
+				}
+				return
+			}
+			if !g.track(conn) {
+				conn.Close()
+				return
+			}
+			g.wg.Add(1)
+			go func() {
+				defer g.wg.Done()
+				defer g.untrack(conn)
+				g.handle(ctx, conn)
+			}()
+		}
+	}()
+	return nil
+}
+
+// Addr 网关实际监听的地址
+func (g *TCPGateway) Addr() net.Addr {
+	return g.listener.Addr()
+}
+
+// Stop 停止接受新连接并关闭全部活动连接
+func (g *TCPGateway) Stop() {
+	if g.listener == nil {
+		return
+	}
+	g.mu.Lock()
+	g.closed = true
+	for conn := range g.active {
+		conn.Close()
+	}
+	g.mu.Unlock()
+	g.listener.Close()
+	g.wg.Wait()
+}
+
+// handle 解析连接对应的实例，校验后透传到实例端口
+func (g *TCPGateway) handle(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(tcpHandshakeTimeout))
+
+	reader := bufio.NewReaderSize(conn, tcpTokenLineSize)
+	first, err := reader.Peek(1)
+	if err != nil {
+		return
+	}
+
+	var (
+		client     net.Conn = conn
+		src                 = reader
+		sniID      string
+		instanceID string
+	)
+	if first[0] == tlsRecordHandshake && g.tlsConfig != nil {
+		tlsConn := tls.Server(&bufferedConn{Conn: conn, r: reader}, g.tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			logger.Debug(ctx, "TCP gateway TLS handshake failed", "remote", conn.RemoteAddr().String(), "error", err)
+			return
+		}
+		serverName := strings.ToLower(tlsConn.ConnectionState().ServerName)
+		id, ok := strings.CutSuffix(serverName, "."+strings.ToLower(g.cfg.Domain))
+		if !ok || id == "" || strings.Contains(id, ".") {
+			fmt.Fprintln(tlsConn, "cyber-range: 实例不存在、已过期或令牌无效")
+			return
+		}
+		client, src, sniID = tlsConn, bufio.NewReaderSize(tlsConn, tcpTokenLineSize), id
+	}
+
+	// 明文与 TLS 连接都需在首行发送实例令牌，TLS 连接的令牌还需与 SNI 指向的实例一致
+	line, err := src.ReadSlice('\n')
+	if err != nil {
+		fmt.Fprintln(client, "cyber-range: 请在首行发送实例令牌")
+		return
+	}
+	token := strings.TrimSpace(string(line))
+	instanceID, _, _ = strings.Cut(token, ":")
+	if sniID != "" && !strings.EqualFold(instanceID, sniID) {
+		fmt.Fprintln(client, "cyber-range: 实例不存在、已过期或令牌无效")
+		return
+	}
+
+	instData, err := g.resolver.instance(ctx, instanceID)
+	if err != nil {
+		logger.Error(ctx, "TCP gateway failed to load instance", "instance_id", instanceID, "error", err)
+		fmt.Fprintln(client, "cyber-range: 网关暂时不可用")
+		return
+	}
+	if len(instData) == 0 || subtle.ConstantTimeCompare([]byte(token), []byte(instData["gateway_token"])) != 1 {
+		fmt.Fprintln(client, "cyber-range: 实例不存在、已过期或令牌无效")
+		return
+	}
+
+	addr, err := g.resolver.target(ctx, instData, model.StreamPort)
+	if err != nil {
+		logger.Warn(ctx, "TCP gateway has no route to instance", "instance_id", instanceID, "error", err)
+		fmt.Fprintln(client, "cyber-range: 实例不可访问")
+		return
+	}
+	if !g.acquire(instanceID) {
+		fmt.Fprintf(client, "cyber-range: 该实例的连接数已达上限（%d）\n", g.cfg.MaxConnsPerInstance)
+		return
+	}
+	defer g.release(instanceID)
+
+	upstream, err := net.DialTimeout("tcp", addr, gatewayDialTimeout)
+	if err != nil {
+		logger.Warn(ctx, "TCP gateway upstream dial failed", "instance_id", instanceID, "target", addr, "error", err)
+		fmt.Fprintln(client, "cyber-range: 实例服务未响应")
+		return
+	}
+	defer upstream.Close()
+	conn.SetDeadline(time.Time{})
+
+	logger.Debug(ctx, "TCP gateway connection established", "instance_id", instanceID, "remote", conn.RemoteAddr().String(), "target", addr)
+	g.pipe(client, src, upstream)
+}
+
+// pipe 双向透传，任一方向在空闲超时内都没有数据时断开
+// 客户端关闭写方向（EOF）后半关闭到实例的连接，继续转发实例的剩余输出
+func (g *TCPGateway) pipe(client net.Conn, clientReader io.Reader, upstream net.Conn) {
+	idle := g.idleTimeout()
+	var lastActive atomic.Int64
+	lastActive.Store(time.Now().UnixNano())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := copyWithIdle(upstream, clientReader, client, idle, &lastActive)
+		if cw, ok := upstream.(interface{ CloseWrite() error }); ok && errors.Is(err, io.EOF) {
+			cw.CloseWrite()
+			return
+		}
+		upstream.Close()
+	}()
+	copyWithIdle(client, upstream, upstream, idle, &lastActive)
+	client.Close()
+	upstream.Close()
+	<-done
+}
+
+// copyWithIdle 从 src 复制到 dst，直到出错或两个方向均空闲超过 idle；srcConn 用于设置读超时
+func copyWithIdle(dst io.Writer, src io.Reader, srcConn net.Conn, idle time.Duration, lastActive *atomic.Int64) error {
+	buf := make([]byte, 32*1024)
+	for {
+		srcConn.SetReadDeadline(time.Now().Add(idle))
+		n, err := src.Read(buf)
+		if n > 0 {
+			lastActive.Store(time.Now().UnixNano())
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return werr
+			}
+		}
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() && time.Since(time.Unix(0, lastActive.Load())) < idle {
+				// 另一方向仍有数据，继续等待
+				continue
+			}
+			return err
+		}
+	}
+}
+
+func (g *TCPGateway) idleTimeout() time.Duration {
+	if g.cfg.IdleTimeout > 0 {
+		return time.Duration(g.cfg.IdleTimeout) * time.Second
+	}
+	return defaultTCPIdleTimeout
+}
+
+// acquire 占用实例的一个连接名额，超过上限时返回 false
+func (g *TCPGateway) acquire(instanceID string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.cfg.MaxConnsPerInstance > 0 && g.conns[instanceID] >= g.cfg.MaxConnsPerInstance {
+		return false
+	}
+	g.conns[instanceID]++
+	return true
+}
+
+// release 归还实例的连接名额
+func (g *TCPGateway) release(instanceID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.conns[instanceID]--; g.conns[instanceID] <= 0 {
+		delete(g.conns, instanceID)
+	}
+}
+
+func (g *TCPGateway) track(conn net.Conn) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return false
+	}
+	g.active[conn] = struct{}{}
+	return true
+}
+
+func (g *TCPGateway) untrack(conn net.Conn) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.active, conn)
+}
+
+// bufferedConn 先读取已缓冲（探测协议时预读）的数据，再读取底层连接
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"cyber-range/pkg/config"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestCert 生成 *.<domain> 的自签名证书，返回证书与私钥文件路径
+func writeTestCert(t *testing.T, domain string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "*." + domain},
+		DNSNames:     []string{"*." + domain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certFile, keyFile
+}
+
+// newEchoUpstream 模拟 nc 题目：连接后发送欢迎语，之后逐行回显
+func newEchoUpstream(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				fmt.Fprint(conn, "welcome\n")
+				reader := bufio.NewReader(conn)
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						fmt.Fprint(conn, "bye\n")
+						return
+					}
+					fmt.Fprint(conn, "echo:"+line)
+				}
+			}()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestTCPGateway_TokenAndSNIRouting(t *testing.T) {
+	svc, engine := setupStartTest(t)
+	engine.NextPort = newEchoUpstream(t)
+
+	certFile, keyFile := writeTestCert(t, "nc.test")
+	svc.cfg.Gateway.TCP = config.TCPGatewayConfig{
+		Enabled:             true,
+		Listen:              "127.0.0.1:0",
+		PublicAddress:       "nc.test:31337",
+		Domain:              "nc.test",
+		TLSCert:             certFile,
+		TLSKey:              keyFile,
+		MaxConnsPerInstance: 1,
+		IdleTimeout:         1,
+	}
+	instance := startAndWait(t, svc, "test-user-1", "test-challenge-1", "")
+	access := instance.TCPGateway
+	if access == nil || access.Address != "nc.test:31337" || !strings.HasPrefix(access.Token, instance.ID+":") || access.ServerName != instance.ID+".nc.test" {
+		t.Fatalf("实例应返回 TCP 网关连接信息, got %+v", access)
+	}
+
+	gateway, err := NewTCPGateway(svc.cfg.Gateway.TCP, svc.repo)
+	if err != nil {
+		t.Fatalf("NewTCPGateway() error = %v", err)
+	}
+	if err := gateway.Start(t.Context()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer gateway.Stop()
+	addr := gateway.Addr().String()
+
+	dialToken := func(token string) (net.Conn, *bufio.Reader) {
+		t.Helper()
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("dial gateway error = %v", err)
+		}
+		fmt.Fprint(conn, token+"\n")
+		return conn, bufio.NewReader(conn)
+	}
+	readLine := func(r *bufio.Reader) string {
+		t.Helper()
+		line, _ := r.ReadString('\n')
+		return line
+	}
+
+	// 令牌错误或实例不存在时拒绝
+	for _, token := range []string{instance.ID + ":wrong", "missing:token", ""} {
+		conn, r := dialToken(token)
+		if line := readLine(r); !strings.Contains(line, "令牌无效") {
+			t.Errorf("令牌 %q 应被拒绝, got %q", token, line)
+		}
+		conn.Close()
+	}
+
+	// 明文连接：首行令牌之后的数据原样透传
+	conn, r := dialToken(access.Token)
+	if line := readLine(r); line != "welcome\n" {
+		t.Fatalf("应连接到实例, got %q", line)
+	}
+	fmt.Fprint(conn, "ping\n")
+	if line := readLine(r); line != "echo:ping\n" {
+		t.Errorf("应双向透传, got %q", line)
+	}
+
+	// 超过每实例连接数上限
+	extra, extraReader := dialToken(access.Token)
+	if line := readLine(extraReader); !strings.Contains(line, "上限") {
+		t.Errorf("超过连接数上限应拒绝, got %q", line)
+	}
+	extra.Close()
+
+	// 两个方向均无数据超过空闲超时后断开
+	start := time.Now()
+	if _, err := io.ReadAll(r); err != nil || time.Since(start) < 900*time.Millisecond || time.Since(start) > 3*time.Second {
+		t.Errorf("空闲连接应在超时后断开, err=%v elapsed=%v", err, time.Since(start))
+	}
+	conn.Close()
+
+	dialTLS := func(serverName, token string) (*tls.Conn, *bufio.Reader) {
+		t.Helper()
+		conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatalf("TLS dial error = %v", err)
+		}
+		fmt.Fprint(conn, token+"\n")
+		return conn, bufio.NewReader(conn)
+	}
+
+	// TLS 连接同样需要首行令牌，令牌须与 SNI 指向的实例一致
+	for _, token := range []string{"", instance.ID + ":wrong", "other:" + strings.SplitN(access.Token, ":", 2)[1]} {
+		conn, r := dialTLS(access.ServerName, token)
+		if line := readLine(r); !strings.Contains(line, "令牌无效") {
+			t.Errorf("TLS 令牌 %q 应被拒绝, got %q", token, line)
+		}
+		conn.Close()
+	}
+
+	// TLS 连接按 SNI 与令牌路由（等待上一个连接释放名额）
+	var (
+		tlsConn   *tls.Conn
+		tlsReader *bufio.Reader
+	)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		tlsConn, tlsReader = dialTLS(access.ServerName, access.Token)
+		line := readLine(tlsReader)
+		if line == "welcome\n" {
+			break
+		}
+		tlsConn.Close()
+		if time.Now().After(deadline) {
+			t.Fatalf("TLS 连接应路由到实例, got %q", line)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	fmt.Fprint(tlsConn, "over tls\n")
+	if line := readLine(tlsReader); line != "echo:over tls\n" {
+		t.Errorf("TLS 连接应双向透传, got %q", line)
+	}
+
+	// 客户端关闭写方向后仍能收到实例的剩余输出
+	tlsConn.CloseWrite()
+	if line := readLine(tlsReader); line != "bye\n" {
+		t.Errorf("半关闭后应继续转发实例输出, got %q", line)
+	}
+	tlsConn.Close()
+
+	// 未知 SNI 被拒绝
+	unknown, unknownReader := dialTLS("missing.nc.test", access.Token)
+	defer unknown.Close()
+	if line := readLine(unknownReader); !strings.Contains(line, "实例不存在") {
+		t.Errorf("未知 SNI 应被拒绝, got %q", line)
+	}
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/config"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errTeamModeDisabled = errors.New("当前未开启团队模式")
+
+// TeamService 队伍服务（团队模式）
+type TeamService struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+// NewTeamService 创建队伍服务
+func NewTeamService(db *gorm.DB, cfg *config.Config) *TeamService {
+	return &TeamService{db: db, cfg: cfg}
+}
+
+// TeamDetail 队伍详情（仅队伍成员可见，包含邀请码）
+type TeamDetail struct {
+	model.Team
+	InviteCode string       `json:"invite_code"`
+	Members    []model.User `json:"members"`
+}
+
+// CreateTeam 创建队伍，创建者自动成为队长
+func (s *TeamService) CreateTeam(ctx context.Context, userID, name string) (*model.Team, error) {
+	if !s.cfg.Team.Enabled {
+		return nil, errTeamModeDisabled
+	}
+
+	team := &model.Team{
+		ID:         uuid.New().String(),
+		Name:       name,
+		InviteCode: generateInviteCode(),
+		CaptainID:  userID,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		user, err := s.getUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if user.TeamID != "" {
+			return errors.New("你已经加入了队伍，请先退出当前队伍")
+		}
+
+		// 检查队伍名称是否已存在
+		var count int64
+		tx.Model(&model.Team{}).Where("name = ?", name).Count(&count)
+		if count > 0 {
+			return errors.New("队伍名称已存在")
+		}
+
+		if err := tx.Create(team).Error; err != nil {
+			return fmt.Errorf("创建队伍失败: %w", err)
+		}
+		return tx.Model(&model.User{}).Where("id = ?", userID).Update("team_id", team.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return team, nil
+}
+
+// JoinTeam 通过邀请码加入队伍
+func (s *TeamService) JoinTeam(ctx context.Context, userID, inviteCode string) (*model.Team, error) {
+	if !s.cfg.Team.Enabled {
+		return nil, errTeamModeDisabled
+	}
+
+	var team model.Team
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		user, err := s.getUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if user.TeamID != "" {
+			return errors.New("你已经加入了队伍，请先退出当前队伍")
+		}
+
+		// 锁定队伍行，同一队伍的并发加入串行执行，成员数检查与加入之间不会被其他请求插入
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("invite_code = ?", inviteCode).First(&team).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("邀请码无效")
+			}
+			return err
+		}
+
+		// 检查成员数量限制
+		if s.cfg.Team.MaxMembers > 0 {
+			var memberCount int64
+			if err := tx.Model(&model.User{}).Where("team_id = ?", team.ID).Count(&memberCount).Error; err != nil {
+				return err
+			}
+			if memberCount >= int64(s.cfg.Team.MaxMembers) {
+				return fmt.Errorf("队伍人数已满（最多 %d 人）", s.cfg.Team.MaxMembers)
+			}
+		}
+
+		// 仅在用户仍未加入队伍时更新，避免同一用户并发加入多个队伍
+		result := tx.Model(&model.User{}).Where("id = ? AND (team_id = ? OR team_id IS NULL)", userID, "").Update("team_id", team.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("你已经加入了队伍，请先退出当前队伍")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &team, nil
+}
+
+// LeaveTeam 退出队伍
+// 队长需先转让队长身份才能退出；最后一名成员退出时队伍解散
+func (s *TeamService) LeaveTeam(ctx context.Context, userID string) error {
+	if !s.cfg.Team.Enabled {
+		return errTeamModeDisabled
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		user, err := s.getUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if user.TeamID == "" {
+			return errors.New("你还没有加入队伍")
+		}
+
+		// 锁定队伍行，与并发的加入请求串行执行，避免最后一名成员退出解散队伍时有新成员加入
+		var team model.Team
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&team, "id = ?", user.TeamID).Error; err != nil {
+			return fmt.Errorf("队伍不存在: %w", err)
+		}
+
+		var memberCount int64
+		if err := tx.Model(&model.User{}).Where("team_id = ?", team.ID).Count(&memberCount).Error; err != nil {
+			return err
+		}
+
+		if team.CaptainID == userID && memberCount > 1 {
+			return errors.New("队长请先转让队长身份后再退出队伍")
+		}
+
+		if err := tx.Model(&model.User{}).Where("id = ?", userID).Update("team_id", "").Error; err != nil {
+			return err
+		}
+
+		// 最后一名成员退出，解散队伍
+		if memberCount <= 1 {
+			return tx.Delete(&model.Team{}, "id = ?", team.ID).Error
+		}
+		return nil
+	})
+}
+
+// TransferCaptain 队长将队长身份转让给队内其他成员
+func (s *TeamService) TransferCaptain(ctx context.Context, captainID, newCaptainID string) error {
+	if !s.cfg.Team.Enabled {
+		return errTeamModeDisabled
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		captain, err := s.getUser(tx, captainID)
+		if err != nil {
+			return err
+		}
+		if captain.TeamID == "" {
+			return errors.New("你还没有加入队伍")
+		}
+
+		var team model.Team
+		if err := tx.First(&team, "id = ?", captain.TeamID).Error; err != nil {
+			return fmt.Errorf("队伍不存在: %w", err)
+		}
+		if team.CaptainID != captainID {
+			return errors.New("只有队长可以转让队长身份")
+		}
+
+		newCaptain, err := s.getUser(tx, newCaptainID)
+		if err != nil {
+			return err
+		}
+		if newCaptain.TeamID != team.ID {
+			return errors.New("新队长必须是本队成员")
+		}
+
+		return tx.Model(&team).Update("captain_id", newCaptainID).Error
+	})
+}
+
+// GetMyTeam 获取用户所在队伍详情
+func (s *TeamService) GetMyTeam(ctx context.Context, userID string) (*TeamDetail, error) {
+	if !s.cfg.Team.Enabled {
+		return nil, errTeamModeDisabled
+	}
+
+	user, err := s.getUser(s.db.WithContext(ctx), userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TeamID == "" {
+		return nil, errors.New("你还没有加入队伍")
+	}
+
+	var team model.Team
+	if err := s.db.WithContext(ctx).First(&team, "id = ?", user.TeamID).Error; err != nil {
+		return nil, fmt.Errorf("队伍不存在: %w", err)
+	}
+
+	var members []model.User
+	if err := s.db.WithContext(ctx).Where("team_id = ?", team.ID).Order("created_at ASC").Find(&members).Error; err != nil {
+		return nil, fmt.Errorf("获取队伍成员失败: %w", err)
+	}
+
+	return &TeamDetail{
+		Team:       team,
+		InviteCode: team.InviteCode,
+		Members:    members,
+	}, nil
+}
+
+// getUser 查询用户（支持在事务中调用）
+func (s *TeamService) getUser(tx *gorm.DB, userID string) (*model.User, error) {
+	var user model.User
+	if err := tx.First(&user, "id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// generateInviteCode 生成 16 位十六进制邀请码
+func generateInviteCode() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
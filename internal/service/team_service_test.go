@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/config"
+	"errors"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTeamTest(t *testing.T, maxMembers int) (*TeamService, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("创建测试数据库失败: %v", err)
+	}
+	db.AutoMigrate(&model.User{}, &model.Team{})
+
+	for _, id := range []string{"u1", "u2", "u3"} {
+		db.Create(&model.User{ID: id, Username: id, Email: id + "@test.com", PasswordHash: "hash"})
+	}
+
+	cfg := &config.Config{Team: config.TeamConfig{Enabled: true, MaxMembers: maxMembers}}
+	return NewTeamService(db, cfg), db
+}
+
+func TestTeamService_CreateAndJoin(t *testing.T) {
+	svc, db := setupTeamTest(t, 2)
+	ctx := context.Background()
+
+	team, err := svc.CreateTeam(ctx, "u1", "team-a")
+	if err != nil {
+		t.Fatalf("创建队伍失败: %v", err)
+	}
+	if team.CaptainID != "u1" {
+		t.Errorf("CaptainID = %v, want u1", team.CaptainID)
+	}
+
+	// 已在队伍中不能再创建
+	if _, err := svc.CreateTeam(ctx, "u1", "team-b"); err == nil {
+		t.Error("已加入队伍的用户不应能再创建队伍")
+	}
+
+	// 错误邀请码
+	if _, err := svc.JoinTeam(ctx, "u2", "wrong-code"); err == nil {
+		t.Error("错误邀请码应该加入失败")
+	}
+
+	if _, err := svc.JoinTeam(ctx, "u2", team.InviteCode); err != nil {
+		t.Fatalf("加入队伍失败: %v", err)
+	}
+
+	var u2 model.User
+	db.First(&u2, "id = ?", "u2")
+	if u2.TeamID != team.ID {
+		t.Errorf("TeamID = %v, want %v", u2.TeamID, team.ID)
+	}
+
+	// 超过人数上限
+	if _, err := svc.JoinTeam(ctx, "u3", team.InviteCode); err == nil {
+		t.Error("队伍人数已满时应该加入失败")
+	}
+}
+
+func TestTeamService_TransferAndLeave(t *testing.T) {
+	svc, db := setupTeamTest(t, 0)
+	ctx := context.Background()
+
+	team, _ := svc.CreateTeam(ctx, "u1", "team-a")
+	svc.JoinTeam(ctx, "u2", team.InviteCode)
+
+	// 队长不能直接退出
+	if err := svc.LeaveTeam(ctx, "u1"); err == nil {
+		t.Error("队长未转让前不应能退出队伍")
+	}
+
+	// 非队长不能转让
+	if err := svc.TransferCaptain(ctx, "u2", "u1"); err == nil {
+		t.Error("非队长不应能转让队长")
+	}
+
+	// 不能转让给队外成员
+	if err := svc.TransferCaptain(ctx, "u1", "u3"); err == nil {
+		t.Error("不应能转让给队外成员")
+	}
+
+	if err := svc.TransferCaptain(ctx, "u1", "u2"); err != nil {
+		t.Fatalf("转让队长失败: %v", err)
+	}
+
+	if err := svc.LeaveTeam(ctx, "u1"); err != nil {
+		t.Fatalf("退出队伍失败: %v", err)
+	}
+
+	// 最后一名成员退出，队伍解散
+	if err := svc.LeaveTeam(ctx, "u2"); err != nil {
+		t.Fatalf("退出队伍失败: %v", err)
+	}
+
+	var count int64
+	db.Model(&model.Team{}).Where("id = ?", team.ID).Count(&count)
+	if count != 0 {
+		t.Error("最后一名成员退出后队伍应被解散")
+	}
+}
+
+func TestTeamService_LeaveCountFailure(t *testing.T) {
+	svc, db := setupTeamTest(t, 0)
+	ctx := context.Background()
+
+	team, _ := svc.CreateTeam(ctx, "u1", "team-a")
+	svc.JoinTeam(ctx, "u2", team.InviteCode)
+
+	// 统计成员数失败时不能按 0 名成员处理（否则队长可退出并解散队伍）
+	db.Callback().Query().Before("gorm:query").Register("test:fail_member_count", func(tx *gorm.DB) {
+		if _, ok := tx.Statement.Dest.(*int64); ok {
+			tx.AddError(errors.New("模拟的数据库查询失败"))
+		}
+	})
+	if err := svc.LeaveTeam(ctx, "u1"); err == nil {
+		t.Fatal("统计成员数失败时退出队伍应返回错误")
+	}
+	db.Callback().Query().Remove("test:fail_member_count")
+
+	var count int64
+	db.Model(&model.Team{}).Where("id = ?", team.ID).Count(&count)
+	var u1 model.User
+	db.First(&u1, "id = ?", "u1")
+	if count != 1 || u1.TeamID != team.ID {
+		t.Errorf("退出失败时队伍与成员应保持不变, teams=%d team_id=%q", count, u1.TeamID)
+	}
+}
+
+func TestTeamService_Disabled(t *testing.T) {
+	svc, _ := setupTeamTest(t, 0)
+	svc.cfg.Team.Enabled = false
+
+	if _, err := svc.CreateTeam(context.Background(), "u1", "team-a"); err == nil {
+		t.Error("未开启团队模式时不应能创建队伍")
+	}
+}
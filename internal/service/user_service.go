@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/jwt"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// UserService 玩家账号服务
+type UserService struct {
+	db *gorm.DB
+}
+
+// NewUserService 创建玩家账号服务
+func NewUserService(db *gorm.DB) *UserService {
+	return &UserService{db: db}
+}
+
+// Register 玩家注册
+func (s *UserService) Register(ctx context.Context, username, email, password string) (*model.User, error) {
+	// 检查用户名是否已存在
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&model.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errors.New("用户名已存在")
+	}
+
+	// 检查邮箱是否已被使用
+	if err := s.db.WithContext(ctx).Model(&model.User{}).Where("email = ?", email).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errors.New("邮箱已被注册")
+	}
+
+	// 密码加密
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &model.User{
+		ID:           uuid.New().String(),
+		Username:     username,
+		Email:        email,
+		PasswordHash: string(hashedPassword),
+		Role:         "user",
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+
+	if err := s.db.WithContext(ctx).Create(user).Error; err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// Login 玩家登录
+func (s *UserService) Login(ctx context.Context, username, password string) (token string, user *model.User, err error) {
+	var dbUser model.User
+	if err := s.db.WithContext(ctx).Where("username = ?", username).First(&dbUser).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil, errors.New("用户名或密码错误")
+		}
+		return "", nil, err
+	}
+
+	// 验证密码
+	if err := bcrypt.CompareHashAndPassword([]byte(dbUser.PasswordHash), []byte(password)); err != nil {
+		return "", nil, errors.New("用户名或密码错误")
+	}
+
+	// 生成 JWT Token
+	token, err = jwt.GenerateUserToken(dbUser.ID, dbUser.Username)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, &dbUser, nil
+}
+
+// GetUserByID 根据ID获取玩家信息
+func (s *UserService) GetUserByID(ctx context.Context, userID string) (*model.User, error) {
+	var user model.User
+	if err := s.db.WithContext(ctx).Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
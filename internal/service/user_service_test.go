@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"cyber-range/internal/model"
+	"cyber-range/pkg/jwt"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupUserTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("创建测试数据库失败: %v", err)
+	}
+	db.AutoMigrate(&model.User{})
+	return db
+}
+
+func TestUserService_Register(t *testing.T) {
+	db := setupUserTestDB(t)
+	svc := NewUserService(db)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		username string
+		email    string
+		password string
+		wantErr  bool
+	}{
+		{
+			name:     "成功注册",
+			username: "alice",
+			email:    "alice@test.com",
+			password: "Test@1234",
+			wantErr:  false,
+		},
+		{
+			name:     "重复用户名",
+			username: "alice",
+			email:    "alice2@test.com",
+			password: "Test@1234",
+			wantErr:  true,
+		},
+		{
+			name:     "重复邮箱",
+			username: "alice2",
+			email:    "alice@test.com",
+			password: "Test@1234",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := svc.Register(ctx, tt.username, tt.email, tt.password)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Register() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr {
+				if user.Username != tt.username {
+					t.Errorf("Username = %v, want %v", user.Username, tt.username)
+				}
+				if user.Role != "user" {
+					t.Errorf("Role = %v, want user", user.Role)
+				}
+				if user.PasswordHash == tt.password {
+					t.Error("密码不应以明文存储")
+				}
+			}
+		})
+	}
+}
+
+func TestUserService_Login(t *testing.T) {
+	db := setupUserTestDB(t)
+	svc := NewUserService(db)
+	ctx := context.Background()
+
+	registered, _ := svc.Register(ctx, "alice", "alice@test.com", "Test@1234")
+
+	token, user, err := svc.Login(ctx, "alice", "Test@1234")
+	if err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+
+	if user.ID != registered.ID {
+		t.Errorf("ID = %v, want %v", user.ID, registered.ID)
+	}
+
+	// Token 中应携带玩家 ID
+	claims, err := jwt.ParseUserToken(token)
+	if err != nil {
+		t.Fatalf("解析 Token 失败: %v", err)
+	}
+	if claims.UserID != registered.ID {
+		t.Errorf("UserID = %v, want %v", claims.UserID, registered.ID)
+	}
+
+	if _, _, err := svc.Login(ctx, "alice", "WrongPassword"); err == nil {
+		t.Error("错误密码应该登录失败")
+	}
+
+	if _, _, err := svc.Login(ctx, "nonexistent", "Test@1234"); err == nil {
+		t.Error("不存在的用户应该登录失败")
+	}
+}
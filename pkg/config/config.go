@@ -2,16 +2,27 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	MySQL    MySQLConfig    `mapstructure:"mysql"`
-	Redis    RedisConfig    `mapstructure:"redis"`
-	Docker   DockerConfig   `mapstructure:"docker"`
-	Instance InstanceConfig `mapstructure:"instance"`
+	Server     ServerConfig     `mapstructure:"server"`
+	MySQL      MySQLConfig      `mapstructure:"mysql"`
+	Redis      RedisConfig      `mapstructure:"redis"`
+	Docker     DockerConfig     `mapstructure:"docker"`
+	Instance   InstanceConfig   `mapstructure:"instance"`
+	Team       TeamConfig       `mapstructure:"team"`
+	Scoring    ScoringConfig    `mapstructure:"scoring"`
+	Scoreboard ScoreboardConfig `mapstructure:"scoreboard"`
+	RateLimit  RateLimitConfig  `mapstructure:"rate_limit"`
+	Storage    StorageConfig    `mapstructure:"storage"`
+	Gateway    GatewayConfig    `mapstructure:"gateway"`
+	Scheduler  SchedulerConfig  `mapstructure:"scheduler"`
 }
 
 type ServerConfig struct {
@@ -53,6 +64,8 @@ type DockerConfig struct {
 	PortRangeMax int              `mapstructure:"port_range_max"` // 端口范围最大值
 	MemoryLimit  int64            `mapstructure:"memory_limit"`   // 内存限制（字节）
 	CPULimit     float64          `mapstructure:"cpu_limit"`      // CPU限制（核心数）
+
+	EgressHelperImage string `mapstructure:"egress_helper_image"` // 出站规则辅助镜像（需包含 sh 与 iptables），为空使用 nicolaka/netshoot:v0.13
 }
 
 // GetActiveHost 根据当前模式获取激活的主机配置
@@ -64,8 +77,152 @@ func (d *DockerConfig) GetActiveHost() DockerHostConfig {
 }
 
 type InstanceConfig struct {
-	MaxPerUser int `mapstructure:"max_per_user"`
-	TTLHours   int `mapstructure:"ttl_hours"`
+	MaxPerUser       int `mapstructure:"max_per_user"`       // 每个用户同时运行的实例数，0 表示不限制
+	MaxPerTeam       int `mapstructure:"max_per_team"`       // 每支队伍同时运行的实例数（团队模式），0 表示不限制
+	DailyStarts      int `mapstructure:"daily_starts"`       // 每个用户每日启动实例次数，0 表示不限制
+	TTLHours         int `mapstructure:"ttl_hours"`          // 实例存活时间（小时）
+	ExtendMinutes    int `mapstructure:"extend_minutes"`     // 每次延长的时间（分钟），0 表示延长 ttl_hours
+	MaxExtensions    int `mapstructure:"max_extensions"`     // 默认最多延长次数，0 表示不允许延长（可按题目覆盖）
+	MaxLifetimeHours int `mapstructure:"max_lifetime_hours"` // 默认最长存活时间（小时，含延长），0 表示仅受延长次数限制
+}
+
+// TeamConfig 团队模式配置
+type TeamConfig struct {
+	Enabled    bool `mapstructure:"enabled"`     // 是否开启团队模式（实例、解题、积分按队伍共享）
+	MaxMembers int  `mapstructure:"max_members"` // 每支队伍最多成员数，0 表示不限制
+}
+
+// ScoringConfig 计分配置
+type ScoringConfig struct {
+	BloodBonus []int `mapstructure:"blood_bonus"` // 一/二/三血奖励分，如 [50, 30, 10]，留空表示不奖励
+}
+
+// ScoreboardConfig 积分榜配置
+type ScoreboardConfig struct {
+	FreezeAt string `mapstructure:"freeze_at"` // 封榜时间（RFC3339），之后的解题对非管理员隐藏，留空表示不封榜
+	CacheTTL int    `mapstructure:"cache_ttl"` // 积分榜缓存时间（秒），默认 10 秒
+}
+
+// FreezeTime 解析封榜时间，未配置或格式错误时返回 nil
+func (s *ScoreboardConfig) FreezeTime() *time.Time {
+	if s.FreezeAt == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, s.FreezeAt)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// RateLimitConfig Flag 提交限流配置（按题目分别计数）
+type RateLimitConfig struct {
+	Enabled    bool  `mapstructure:"enabled"`     // 是否开启提交限流
+	UserLimit  int   `mapstructure:"user_limit"`  // 每个用户每题在窗口内的最大提交次数，0 表示不限制
+	IPLimit    int   `mapstructure:"ip_limit"`    // 每个IP每题在窗口内的最大提交次数，0 表示不限制
+	Window     int   `mapstructure:"window"`      // 滑动窗口长度（秒）
+	Cooldowns  []int `mapstructure:"cooldowns"`   // 连续触发限流后的冷却时间（秒），逐级递增，如 [60, 300, 900]
+	OffenseTTL int   `mapstructure:"offense_ttl"` // 违规次数的保留时间（秒），超过后重新计数
+}
+
+// StorageConfig 文件存储配置（题目附件）
+type StorageConfig struct {
+	Driver     string   `mapstructure:"driver"`      // "local" 或 "s3"
+	LocalDir   string   `mapstructure:"local_dir"`   // 本地存储目录（driver=local）
+	S3         S3Config `mapstructure:"s3"`          // S3 兼容存储配置（driver=s3）
+	MaxSizeMB  int      `mapstructure:"max_size_mb"` // 单个附件大小上限（MB）
+	URLTTL     int      `mapstructure:"url_ttl"`     // 下载链接有效期（秒）
+	SignSecret string   `mapstructure:"sign_secret"` // 下载链接签名密钥，多实例部署时必须一致
+}
+
+// S3Config S3 兼容对象存储配置（AWS S3 / MinIO）
+type S3Config struct {
+	Endpoint  string `mapstructure:"endpoint"` // 如 http://127.0.0.1:9000
+	Region    string `mapstructure:"region"`   // 默认 us-east-1
+	Bucket    string `mapstructure:"bucket"`   // 需预先创建
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+}
+
+// SchedulerConfig 实例主机调度配置（题目未指定 Docker 主机时在已启用的主机中选择）
+type SchedulerConfig struct {
+	Strategy      string `mapstructure:"strategy"`       // least_instances（默认）/ most_free_memory / round_robin / image_locality
+	ProbeInterval int    `mapstructure:"probe_interval"` // 主机健康与资源探测间隔（秒），默认 30
+	Admission     string `mapstructure:"admission"`      // 全部候选主机容量已满时：reject（默认，启动失败）/ queue（排队等待空闲容量）
+	QueueTimeout  int    `mapstructure:"queue_timeout"`  // 排队等待的最长时间（秒），默认 300
+}
+
+// GatewayConfig 实例访问网关配置（玩家经网关访问实例，宿主机端口无需对外开放）
+type GatewayConfig struct {
+	HTTP HTTPGatewayConfig `mapstructure:"http"`
+	TCP  TCPGatewayConfig  `mapstructure:"tcp"`
+}
+
+// HTTPGatewayConfig HTTP 反向代理网关配置（Web 题目按子域名或路径转发到实例容器）
+type HTTPGatewayConfig struct {
+	Enabled      bool   `mapstructure:"enabled"`
+	Listen       string `mapstructure:"listen"`        // 监听地址，如 :8081
+	Domain       string `mapstructure:"domain"`        // 子域名路由的根域名（<instance-id>.<domain>），留空时仅使用路径路由
+	PathPrefix   string `mapstructure:"path_prefix"`   // 路径路由前缀（<prefix><instance-id>/...），如 /i/，留空不启用
+	PublicURL    string `mapstructure:"public_url"`    // 玩家访问网关的地址（如 https://inst.ctf.example.com），用于生成实例访问地址
+	TLSCert      string `mapstructure:"tls_cert"`      // TLS 证书（子域名路由需通配符证书），留空时监听 HTTP（由前置负载均衡终止 TLS）
+	TLSKey       string `mapstructure:"tls_key"`       // TLS 私钥
+	RequireOwner bool   `mapstructure:"require_owner"` // 是否仅允许实例所属用户（团队模式下为队伍成员）访问
+}
+
+// InstanceURL 实例的网关访问地址（以 / 结尾），配置了根域名时使用子域名路由，否则使用路径路由
+// 协议与端口取自 public_url；网关未启用时返回空字符串
+func (g *HTTPGatewayConfig) InstanceURL(instanceID string) string {
+	if !g.Enabled {
+		return ""
+	}
+	scheme := "http"
+	if g.TLSCert != "" {
+		scheme = "https"
+	}
+	var public *url.URL
+	if u, err := url.Parse(g.PublicURL); err == nil && u.Host != "" {
+		public = u
+		scheme = u.Scheme
+	}
+
+	if g.Domain != "" {
+		host := instanceID + "." + g.Domain
+		if public != nil && public.Port() != "" {
+			host = net.JoinHostPort(host, public.Port())
+		}
+		return scheme + "://" + host + "/"
+	}
+	if prefix := g.NormalizedPathPrefix(); prefix != "" && public != nil {
+		return scheme + "://" + public.Host + prefix + instanceID + "/"
+	}
+	return ""
+}
+
+// NormalizedPathPrefix 以 / 开头和结尾的路径路由前缀，未配置时返回空字符串
+func (g *HTTPGatewayConfig) NormalizedPathPrefix() string {
+	prefix := strings.Trim(g.PathPrefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return "/" + prefix + "/"
+}
+
+// TCPGatewayConfig TCP/TLS 网关配置（nc 类题目共用一个公网端口，按 TLS SNI 或首行令牌转发到实例容器）
+type TCPGatewayConfig struct {
+	Enabled             bool   `mapstructure:"enabled"`
+	Listen              string `mapstructure:"listen"`                 // 监听地址，如 :31337
+	PublicAddress       string `mapstructure:"public_address"`         // 玩家连接网关的地址（host:port），如 nc.ctf.example.com:31337
+	Domain              string `mapstructure:"domain"`                 // TLS SNI 路由的根域名（<instance-id>.<domain>），需配置证书
+	TLSCert             string `mapstructure:"tls_cert"`               // TLS 证书（通配符证书），留空时仅支持明文令牌路由
+	TLSKey              string `mapstructure:"tls_key"`                // TLS 私钥
+	MaxConnsPerInstance int    `mapstructure:"max_conns_per_instance"` // 每个实例的最大并发连接数（单个网关进程内），0 表示不限制
+	IdleTimeout         int    `mapstructure:"idle_timeout"`           // 连接空闲超时（秒），0 表示使用默认值 300 秒
+}
+
+// TLSEnabled 是否启用 TLS SNI 路由
+func (g *TCPGatewayConfig) TLSEnabled() bool {
+	return g.TLSCert != "" && g.Domain != ""
 }
 
 var AppConfig *Config
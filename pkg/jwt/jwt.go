@@ -66,7 +66,52 @@ func ParseAdminToken(tokenString string) (*AdminClaims, error) {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*AdminClaims); ok && token.Valid {
+	// AdminID 为空说明不是管理员 Token（例如玩家 Token），拒绝
+	if claims, ok := token.Claims.(*AdminClaims); ok && token.Valid && claims.AdminID != "" {
+		return claims, nil
+	}
+
+	return nil, errors.New("invalid token")
+}
+
+// UserClaims 玩家 JWT 自定义声明
+type UserClaims struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	jwt.RegisteredClaims
+}
+
+// GenerateUserToken 生成玩家 JWT Token
+func GenerateUserToken(userID, username string) (string, error) {
+	claims := UserClaims{
+		UserID:   userID,
+		Username: username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtSecret)
+}
+
+// ParseUserToken 解析并验证玩家 JWT Token
+func ParseUserToken(tokenString string) (*UserClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &UserClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return jwtSecret, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// UserID 为空说明不是玩家 Token（例如管理员 Token），拒绝
+	if claims, ok := token.Claims.(*UserClaims); ok && token.Valid && claims.UserID != "" {
 		return claims, nil
 	}
 
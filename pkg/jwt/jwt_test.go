@@ -104,3 +104,37 @@ func BenchmarkParseToken(b *testing.B) {
 		_, _ = ParseAdminToken(token)
 	}
 }
+
+func TestGenerateAndParseUserToken(t *testing.T) {
+	token, err := GenerateUserToken("user-123", "alice")
+	if err != nil {
+		t.Fatalf("生成 Token 失败: %v", err)
+	}
+
+	claims, err := ParseUserToken(token)
+	if err != nil {
+		t.Fatalf("解析 Token 失败: %v", err)
+	}
+
+	if claims.UserID != "user-123" {
+		t.Errorf("UserID = %v, want %v", claims.UserID, "user-123")
+	}
+	if claims.Username != "alice" {
+		t.Errorf("Username = %v, want %v", claims.Username, "alice")
+	}
+}
+
+func TestTokenTypeSeparation(t *testing.T) {
+	userToken, _ := GenerateUserToken("user-123", "alice")
+	adminToken, _ := GenerateAdminToken("admin-123", "admin")
+
+	// 玩家 Token 不能通过管理员认证
+	if _, err := ParseAdminToken(userToken); err == nil {
+		t.Error("玩家 Token 不应被解析为管理员 Token")
+	}
+
+	// 管理员 Token 不能通过玩家认证
+	if _, err := ParseUserToken(adminToken); err == nil {
+		t.Error("管理员 Token 不应被解析为玩家 Token")
+	}
+}
@@ -2,18 +2,44 @@ package mock
 
 import (
 	"context"
+	"cyber-range/internal/infra/docker"
+	"cyber-range/internal/model"
 	"fmt"
+	"slices"
+	"sync"
+	"time"
 )
 
-// MockDockerClient 用于单元测试的Mock Docker客户端
+// MockDockerClient 用于单元测试的Mock Docker客户端（实现 docker.ContainerEngine）
 type MockDockerClient struct {
 	// 可配置的返回值
+	ShouldFailPull  bool
 	ShouldFailStart bool
 	ShouldFailStop  bool
+	StartError      error // 非空时 StartContainer 返回该错误
 	NextPort        int
 	NextContainerID string
+	StartDelay      time.Duration // 模拟容器创建耗时，用于并发测试
+
+	// 多主机调度
+	DownHosts   map[string]bool                  // 不可连接的 Docker 主机ID（GetEngine 返回错误）
+	Resources   map[string]*docker.HostResources // 各主机的资源概况
+	LocalImages map[string][]string              // 各主机已缓存的镜像
+
+	mu      sync.Mutex
+	specs   []docker.ContainerSpec    // 已创建容器的配置
+	groups  []docker.ServiceGroupSpec // 已成组启动的服务配置
+	leased  int                       // 已分配的宿主机端口数
+	started []string                  // 已创建的容器ID
+	stopped []string                  // 已删除的容器ID
 }
 
+var (
+	_ docker.ContainerEngine = (*MockDockerClient)(nil)
+	_ docker.EngineProvider  = (*MockDockerClient)(nil)
+	_ docker.HostProber      = (*mockHostEngine)(nil)
+)
+
 func NewMockDockerClient() *MockDockerClient {
 	return &MockDockerClient{
 		NextPort:        23456,
@@ -21,24 +47,158 @@ func NewMockDockerClient() *MockDockerClient {
 	}
 }
 
+// GetEngine 所有 Docker 主机共用同一个 Mock 客户端，主机资源与镜像缓存按主机区分
+func (m *MockDockerClient) GetEngine(ctx context.Context, host *model.DockerHost) (docker.ContainerEngine, error) {
+	if m.DownHosts[host.ID] {
+		return nil, fmt.Errorf("模拟的 Docker 主机不可连接: %s", host.Name)
+	}
+	return &mockHostEngine{MockDockerClient: m, hostID: host.ID}, nil
+}
+
+// mockHostEngine 绑定到某台 Docker 主机的 Mock 客户端
+type mockHostEngine struct {
+	*MockDockerClient
+	hostID string
+}
+
+func (e *mockHostEngine) HostResources(ctx context.Context) (*docker.HostResources, error) {
+	if resources, ok := e.Resources[e.hostID]; ok {
+		return resources, nil
+	}
+	return nil, fmt.Errorf("模拟的主机资源未知")
+}
+
+func (e *mockHostEngine) HasLocalImage(ctx context.Context, imageName string) bool {
+	return slices.Contains(e.LocalImages[e.hostID], imageName)
+}
+
 func (m *MockDockerClient) Ping(ctx context.Context) (interface{}, error) {
 	return "pong", nil
 }
 
-func (m *MockDockerClient) StartContainer(ctx context.Context, imageName string, envVars []string, memoryLimit int64, cpuLimit float64) (string, int, error) {
+func (m *MockDockerClient) EnsureImage(ctx context.Context, imageName string) error {
+	if m.ShouldFailPull {
+		return fmt.Errorf("模拟的镜像拉取失败: %s", imageName)
+	}
+	return nil
+}
+
+func (m *MockDockerClient) StartContainer(ctx context.Context, spec docker.ContainerSpec) (string, []model.InstancePort, error) {
+	if m.StartDelay > 0 {
+		time.Sleep(m.StartDelay)
+	}
+	if m.ShouldFailStart {
+		return "", nil, fmt.Errorf("模拟的Docker启动失败")
+	}
+	if m.StartError != nil {
+		return "", nil, m.StartError
+	}
+
+	docker.ReportStage(ctx, docker.StageStarting)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	containerID := m.createContainer()
+	m.specs = append(m.specs, spec)
+	return containerID, m.mapPorts("", spec.Ports), nil
+}
+
+func (m *MockDockerClient) StartServices(ctx context.Context, spec docker.ServiceGroupSpec) ([]docker.StartedService, error) {
+	if m.StartDelay > 0 {
+		time.Sleep(m.StartDelay)
+	}
 	if m.ShouldFailStart {
-		return "", 0, fmt.Errorf("模拟的Docker启动失败")
+		return nil, fmt.Errorf("模拟的Docker启动失败")
+	}
+	if m.StartError != nil {
+		return nil, m.StartError
+	}
+
+	docker.ReportStage(ctx, docker.StageStarting)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	started := make([]docker.StartedService, 0, len(spec.Services))
+	for _, svc := range spec.Services {
+		started = append(started, docker.StartedService{
+			Name:        svc.Name,
+			ContainerID: m.createContainer(),
+			Ports:       m.mapPorts(svc.Name, svc.Ports),
+		})
 	}
-	return m.NextContainerID, m.NextPort, nil
+	m.groups = append(m.groups, spec)
+	return started, nil
+}
+
+// createContainer 生成容器ID（需持有锁）
+func (m *MockDockerClient) createContainer() string {
+	containerID := fmt.Sprintf("%s-%d", m.NextContainerID, len(m.started)+1)
+	m.started = append(m.started, containerID)
+	return containerID
+}
+
+// mapPorts 从 NextPort 开始依次分配宿主机端口（需持有锁）
+func (m *MockDockerClient) mapPorts(service string, ports []model.ChallengePort) []model.InstancePort {
+	var mapped []model.InstancePort
+	for _, p := range ports {
+		mapped = append(mapped, model.InstancePort{
+			Label:    p.Label,
+			Service:  service,
+			Protocol: p.Protocol,
+			Port:     p.Port,
+			HostPort: m.NextPort + m.leased,
+			Template: p.Template,
+		})
+		m.leased++
+	}
+	return mapped
 }
 
 func (m *MockDockerClient) StopContainer(ctx context.Context, containerID string) error {
 	if m.ShouldFailStop {
 		return fmt.Errorf("模拟的Docker停止失败")
 	}
+	m.mu.Lock()
+	m.stopped = append(m.stopped, containerID)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MockDockerClient) StopContainers(ctx context.Context, containerIDs []string) error {
+	for _, containerID := range containerIDs {
+		if err := m.StopContainer(ctx, containerID); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func (m *MockDockerClient) AllocatePort() int {
 	return m.NextPort
 }
+
+// Started 返回已创建的容器ID
+func (m *MockDockerClient) Started() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.started...)
+}
+
+// Specs 返回已创建容器的配置
+func (m *MockDockerClient) Specs() []docker.ContainerSpec {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]docker.ContainerSpec(nil), m.specs...)
+}
+
+// Groups 返回已成组启动的服务配置
+func (m *MockDockerClient) Groups() []docker.ServiceGroupSpec {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]docker.ServiceGroupSpec(nil), m.groups...)
+}
+
+// Stopped 返回已删除的容器ID
+func (m *MockDockerClient) Stopped() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.stopped...)
+}